@@ -6,122 +6,421 @@ package main
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// SetupHealthRoutes defines unauthenticated probe and API description endpoints on the root router.
+func SetupHealthRoutes(router *mux.Router, cfg *Config, releaseService *ReleaseService, readiness *ReadinessState, shutdownState *ShutdownState, logger *slog.Logger) {
+	router.HandleFunc("/healthz", handleLiveness()).Methods("GET")
+	router.HandleFunc("/readyz", handleReadiness(cfg, releaseService, readiness, shutdownState, logger)).Methods("GET")
+	router.HandleFunc("/openapi.json", handleGetOpenAPI()).Methods("GET")
+}
+
 // SetupPublicRoutes defines public API endpoints that do not require authentication.
-func SetupPublicRoutes(router *mux.Router, releaseService *ReleaseService, userService *UserService, logger *log.Logger) {
-	router.HandleFunc("/status", handleGetStatus(releaseService, logger)).Methods("GET")
-	router.HandleFunc("/packages", handleListPackages(releaseService, logger)).Methods("GET")
-	router.HandleFunc("/packages/{software_name}/releases", handleListReleasesForSoftware(releaseService, logger)).Methods("GET")
+func SetupPublicRoutes(router *mux.Router, cfg *Config, releaseService *ReleaseService, userService *UserService, notice *ServerNotice, logger *slog.Logger) {
+	router.HandleFunc("/status", handleGetStatus(releaseService, notice, logger)).Methods("GET")
+	router.HandleFunc("/search", handleSearchReleases(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/releases", handleListAllReleases(cfg, releaseService, logger)).Methods("GET") // Registered before the token-authenticated /releases routes
+	router.HandleFunc("/packages", handleListPackages(cfg, releaseService, false, logger)).Methods("GET")
+	router.HandleFunc("/packages/featured", handleListFeaturedPackages(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/packages/{software_name}/releases", handleListReleasesForSoftware(cfg, releaseService, false, logger)).Methods("GET")
 	router.HandleFunc("/packages/{software_name}/latest", handleGetLatestReleaseForSoftware(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/packages/{software_name}/tags", handleListReleaseTags(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/packages/{software_name}/releases/{version}", handleGetRelease(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/packages/{software_name}/{version}/sbom", handleGetReleaseSBOM(releaseService, logger)).Methods("GET")
+	router.HandleFunc("/packages/{software_name}/{version}/changelog", handleGetReleaseChangelog(releaseService, logger)).Methods("GET")
 }
 
 // SetupAdminRoutes defines admin API endpoints requiring basic authentication and admin role.
-func SetupAdminRoutes(router *mux.Router, releaseService *ReleaseService, userService *UserService, authService *AuthService, logger *log.Logger) {
+func SetupAdminRoutes(router *mux.Router, cfg *Config, releaseService *ReleaseService, userService *UserService, authService *AuthService, idempotencyCache *IdempotencyCache, auditLog *AuditLog, notice *ServerNotice, logger *slog.Logger) {
 	adminRouter := router.PathPrefix("/admin").Subrouter()
 	adminRouter.Use(authService.BasicAuthMiddleware)
-	adminRouter.Use(AdminRoleMiddleware) // Ensure only admins can access
+	adminRouter.Use(authService.AdminRoleMiddleware) // Ensure only admins can access
+	adminRouter.Use(idempotencyCache.Middleware)     // Replay retried POSTs carrying an Idempotency-Key
+	adminRouter.Use(auditLog.Middleware)             // Record admin actions; inside the replay cache so replays are not recorded twice
 
 	adminRouter.HandleFunc("/users", handleListUsers(userService, logger)).Methods("GET")
 	adminRouter.HandleFunc("/users", handleCreateUser(userService, logger)).Methods("POST")
+	adminRouter.HandleFunc("/users/bulk", handleBulkCreateUsers(userService, logger)).Methods("POST")
 	adminRouter.HandleFunc("/users/{username}", handleUpdateUser(userService, logger)).Methods("PUT")
 	adminRouter.HandleFunc("/users/{username}", handleDeleteUser(userService, logger)).Methods("DELETE")
 	adminRouter.HandleFunc("/users/{username}/status", handleEnableDisableUser(userService, logger)).Methods("PATCH")
+	adminRouter.HandleFunc("/users/{username}/access", handleGetUserAccess(userService, authService, releaseService, logger)).Methods("GET")
+	adminRouter.HandleFunc("/users/{username}/security", handleGetUserSecurity(authService, logger)).Methods("GET")
+	adminRouter.HandleFunc("/users/{username}/security", handleResetUserSecurity(authService, logger)).Methods("DELETE")
 
+	adminRouter.HandleFunc("/packages", handleListPackages(cfg, releaseService, true, logger)).Methods("GET")
 	adminRouter.HandleFunc("/packages", handleCreateSoftwarePackage(releaseService, logger)).Methods("POST")
 	adminRouter.HandleFunc("/packages/{software_name}", handleUpdateSoftwarePackage(releaseService, logger)).Methods("PUT")
 	adminRouter.HandleFunc("/packages/{software_name}", handleDeleteSoftwarePackage(releaseService, logger)).Methods("DELETE")
 	adminRouter.HandleFunc("/packages/{software_name}/status", handleEnableDisableSoftwarePackage(releaseService, logger)).Methods("PATCH")
+	adminRouter.HandleFunc("/packages/{software_name}/gaps", handleGetVersionGaps(releaseService, logger)).Methods("GET")
+	adminRouter.HandleFunc("/packages/{software_name}/transfer", handleTransferSoftwarePackage(releaseService, userService, logger)).Methods("POST")
+	adminRouter.HandleFunc("/packages/{software_name}/featured", handleSetSoftwarePackageFeatured(releaseService, logger)).Methods("PATCH")
+	adminRouter.HandleFunc("/packages/{software_name}/quota", handleSetSoftwarePackageStorageQuota(releaseService, logger)).Methods("PATCH")
+	adminRouter.HandleFunc("/packages/{software_name}/tags/{tag}", handleSetReleaseTag(releaseService, logger)).Methods("PUT")
+	adminRouter.HandleFunc("/packages/{software_name}/tags/{tag}", handleDeleteReleaseTag(releaseService, logger)).Methods("DELETE")
+	adminRouter.HandleFunc("/packages/{software_name}/releases", handleListReleasesForSoftware(cfg, releaseService, true, logger)).Methods("GET")
+	adminRouter.HandleFunc("/packages/{software_name}/releases/{version}", handleDeleteRelease(releaseService, logger)).Methods("DELETE")
+	adminRouter.HandleFunc("/packages/{software_name}/releases/{version}/state", handleSetReleaseState(releaseService, logger)).Methods("PATCH")
+
+	adminRouter.HandleFunc("/tokens", handleListAllAPITokens(authService, logger)).Methods("GET")
+	adminRouter.HandleFunc("/tokens/{token_id}", handleAdminRevokeAPIToken(authService, logger)).Methods("DELETE")
+
+	adminRouter.HandleFunc("/notice", handleSetServerNotice(notice, logger)).Methods("PUT")
+	adminRouter.HandleFunc("/notice", handleClearServerNotice(notice, logger)).Methods("DELETE")
+
+	adminRouter.HandleFunc("/reconcile", handleReconcileReleases(releaseService, logger)).Methods("POST")
+	adminRouter.HandleFunc("/catalog", handleExportCatalog(releaseService, logger)).Methods("GET")
+	adminRouter.HandleFunc("/catalog/diff", handleDiffCatalog(releaseService, logger)).Methods("POST")
 }
 
 // SetupUserRoutes defines user API endpoints requiring basic authentication for all users.
-func SetupUserRoutes(router *mux.Router, userService *UserService, authService *AuthService, logger *log.Logger) {
+func SetupUserRoutes(router *mux.Router, userService *UserService, authService *AuthService, logger *slog.Logger) {
 	userRouter := router.PathPrefix("/auth").Subrouter()
 	userRouter.Use(authService.BasicAuthMiddleware) // All authenticated users
 
+	userRouter.HandleFunc("/password", handleChangeOwnPassword(userService, logger)).Methods("PUT").Name(passwordChangeRouteName)
 	userRouter.HandleFunc("/token", handleCreateAPIToken(userService, authService, logger)).Methods("POST")
+	userRouter.HandleFunc("/tokens", handleListOwnAPITokens(authService, logger)).Methods("GET")
+	userRouter.HandleFunc("/token/by-name/{name}", handleRevokeAPITokenByName(authService, logger)).Methods("DELETE")
+	userRouter.HandleFunc("/token/{token_id}", handleRevokeAPIToken(authService, logger)).Methods("DELETE")
 }
 
 // SetupTokenRoutes defines API endpoints requiring API key authentication in header.
-func SetupTokenRoutes(router *mux.Router, releaseService *ReleaseService, authService *AuthService, logger *log.Logger) {
+func SetupTokenRoutes(router *mux.Router, cfg *Config, releaseService *ReleaseService, authService *AuthService, shutdownState *ShutdownState, logger *slog.Logger) {
 	tokenRouter := router.PathPrefix("/releases").Subrouter()
 	tokenRouter.Use(authService.APIKeyAuthMiddleware) // API Key required in header
 
-	tokenRouter.HandleFunc("", handleUploadRelease(releaseService, logger)).Methods("POST")
-	tokenRouter.HandleFunc("/{software_name}/{version}", handleRetrieveRelease(releaseService, logger)).Methods("GET")
+	var uploadHandler http.Handler = authService.PublisherRoleMiddleware(handleUploadRelease(cfg, releaseService, logger))
+	if cfg.RejectUploadsOnShutdown {
+		uploadHandler = shutdownState.RejectDuringShutdownMiddleware(uploadHandler) // Avoid partial files during shutdown
+	}
+	tokenRouter.Handle("", uploadHandler).Methods("POST")
+	tokenRouter.HandleFunc("/by-id/{id}", handleRetrieveReleaseByID(cfg, releaseService, logger)).Methods("GET", "HEAD") // Registered first so "by-id" is not taken as a software name
+	tokenRouter.HandleFunc("/{software_name}/{version}", handleRetrieveRelease(cfg, releaseService, logger)).Methods("GET", "HEAD")
+
+	// Lives under /packages next to the latest metadata route, but authenticates like the download routes above.
+	router.Handle("/packages/{software_name}/latest/download", authService.APIKeyAuthMiddleware(handleDownloadLatestRelease(cfg, releaseService, logger))).Methods("GET", "HEAD")
+}
+
+// --- Health Endpoints Handlers ---
+
+// handleLiveness reports that the HTTP server is up, regardless of startup progress.
+func handleLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// handleReadiness reports 200 once startup, including reconciliation, has completed and
+// 503 before that and once graceful shutdown has begun.
+func handleReadiness(cfg *Config, releaseService *ReleaseService, readiness *ReadinessState, shutdownState *ShutdownState, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !readiness.IsReady() {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+			return
+		}
+		if shutdownState.IsShuttingDown() {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+			return
+		}
+		if cfg.DeepHealthCheck {
+			if err := releaseService.CheckReleaseRetrieval(); err != nil {
+				logger.Warn("Readiness deep health check failed", "error", err)
+				respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "error": err.Error()})
+				return
+			}
+		}
+		respondJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
 }
 
 // --- Public Endpoints Handlers ---
 
-func handleGetStatus(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleGetStatus(releaseService *ReleaseService, notice *ServerNotice, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
+		totalPackages, err := releaseService.GetTotalSoftwarePackages()
+		if err != nil {
+			logger.Error("Failed to count software packages", "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get status")
+			return
+		}
+		totalReleases, err := releaseService.GetTotalReleases()
+		if err != nil {
+			logger.Error("Failed to count releases", "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get status")
+			return
+		}
 		status := map[string]interface{}{
-			"uptime":         time.Since(startTime).String(),            // Placeholder - needs actual uptime tracking
-			"total_packages": releaseService.GetTotalSoftwarePackages(), // Placeholder - needs implementation
-			"total_releases": releaseService.GetTotalReleases(),         // Placeholder - needs implementation
+			"uptime":         time.Since(startTime).String(), // Placeholder - needs actual uptime tracking
+			"total_packages": totalPackages,
+			"total_releases": totalReleases,
+		}
+		if message := notice.Get(); message != "" {
+			status["notice"] = message
 		}
 		respondJSON(w, http.StatusOK, status)
 	}
 }
 
-func handleListPackages(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+// handleListPackages lists packages; with adminView, include_disabled=true also lists disabled packages.
+func handleListPackages(cfg *Config, releaseService *ReleaseService, adminView bool, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		packages, err := releaseService.ListSoftwarePackages()
+		limit, offset, err := parsePagination(r, cfg.MaxPageLimit)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		includeDisabled := adminView && r.URL.Query().Get("include_disabled") == "true"
+
+		packages, err := releaseService.ListSoftwarePackages(includeDisabled)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "Failed to list software packages")
 			return
 		}
+		respondJSON(w, http.StatusOK, newPaginatedResponse(packages, limit, offset))
+	}
+}
+
+func handleSearchReleases(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			respondError(w, http.StatusBadRequest, "Query parameter q is required")
+			return
+		}
+
+		results, err := releaseService.SearchReleases(query, r.URL.Query().Get("field"))
+		if err != nil {
+			if errors.Is(err, ErrInvalidSearchField) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to search releases")
+			return
+		}
+		respondJSON(w, http.StatusOK, results)
+	}
+}
+
+func handleListFeaturedPackages(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packages, err := releaseService.ListFeaturedSoftwarePackages()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to list featured software packages")
+			return
+		}
 		respondJSON(w, http.StatusOK, packages)
 	}
 }
 
-func handleListReleasesForSoftware(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+// handleListReleasesForSoftware lists releases; with adminView, include_disabled=true also lists releases of a disabled package.
+func handleListReleasesForSoftware(cfg *Config, releaseService *ReleaseService, adminView bool, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
 		sort := r.URL.Query().Get("sort")
 		order := r.URL.Query().Get("order")
+		versionPrefix := r.URL.Query().Get("version_prefix")
+		limit, offset, err := parsePagination(r, cfg.MaxPageLimit)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		filter, err := parseReleaseFilter(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		includeYanked := r.URL.Query().Get("include_yanked") == "true"
+		includeDisabled := adminView && r.URL.Query().Get("include_disabled") == "true"
 
-		releases, err := releaseService.ListReleasesForSoftware(softwareName, sort, order)
+		releases, err := releaseService.ListReleasesForSoftware(softwareName, sort, order, versionPrefix, filter, includeYanked, includeDisabled) // Sorted before paging
 		if err != nil {
+			if errors.Is(err, ErrInvalidVersionPrefix) || errors.Is(err, ErrInvalidReleaseFilter) || errors.Is(err, ErrInvalidSort) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, ErrNotFound) {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("Software package not found: %s", softwareName))
+				return
+			}
+			logger.Error("Failed to list releases for software", "software_name", softwareName, "error", err)
 			respondError(w, http.StatusInternalServerError, "Failed to list releases for software")
 			return
 		}
-		respondJSON(w, http.StatusOK, releases)
+		respondJSON(w, http.StatusOK, newPaginatedResponse(releases, limit, offset))
+	}
+}
+
+func handleListAllReleases(cfg *Config, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sort := r.URL.Query().Get("sort")
+		order := r.URL.Query().Get("order")
+		limit, offset, err := parsePagination(r, cfg.MaxPageLimit)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		includeYanked := r.URL.Query().Get("include_yanked") == "true"
+
+		releases, err := releaseService.ListAllReleases(sort, order, includeYanked) // Sorted before paging
+		if err != nil {
+			if errors.Is(err, ErrInvalidSort) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.Error("Failed to list all releases", "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list releases")
+			return
+		}
+		respondJSON(w, http.StatusOK, newPaginatedResponse(releases, limit, offset))
 	}
 }
 
-func handleGetLatestReleaseForSoftware(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleGetLatestReleaseForSoftware(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
 
 		release, err := releaseService.GetLatestReleaseForSoftware(softwareName)
 		if err != nil {
-			respondError(w, http.StatusNotFound, fmt.Sprintf("No releases found for software: %s", softwareName))
+			if errors.Is(err, ErrNotFound) {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("No releases found for software: %s", softwareName))
+				return
+			}
+			logger.Error("Failed to get latest release", "software_name", softwareName, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get latest release")
 			return
 		}
-		respondJSON(w, http.StatusOK, release)
+		versionInfo, err := releaseService.DescribeReleaseVersion(release)
+		if err != nil {
+			logger.Error("Failed to compute version info", "software_name", release.SoftwareName, "version", release.Version, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to compute release version info")
+			return
+		}
+		respondJSON(w, http.StatusOK, versionInfo)
+	}
+}
+
+func handleGetRelease(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		version := vars["version"]
+
+		release, err := releaseService.GetRelease(softwareName, version)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %s %s", softwareName, version))
+				return
+			}
+			logger.Error("Failed to get release", "software_name", softwareName, "version", version, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get release")
+			return
+		}
+		versionInfo, err := releaseService.DescribeReleaseVersion(release)
+		if err != nil {
+			logger.Error("Failed to compute version info", "software_name", release.SoftwareName, "version", release.Version, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to compute release version info")
+			return
+		}
+		respondJSON(w, http.StatusOK, versionInfo)
+	}
+}
+
+func handleListReleaseTags(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		softwareName := mux.Vars(r)["software_name"]
+
+		tags, err := releaseService.ListReleaseTags(softwareName)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("Software package not found: %s", softwareName))
+				return
+			}
+			logger.Error("Failed to list release tags", "software_name", softwareName, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list release tags")
+			return
+		}
+		respondJSON(w, http.StatusOK, tags)
+	}
+}
+
+func handleGetReleaseSBOM(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		version := vars["version"]
+
+		sbomFilePath, err := releaseService.GetReleaseSBOMFilePath(softwareName, version)
+		if err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("SBOM not found: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, sbomFilePath)
+	}
+}
+
+func handleGetReleaseChangelog(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	contentTypes := map[string]string{
+		ChangelogFormatText:     "text/plain; charset=utf-8",
+		ChangelogFormatMarkdown: "text/markdown; charset=utf-8",
+		ChangelogFormatHTML:     "text/html; charset=utf-8",
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		version := vars["version"]
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = ChangelogFormatText
+		}
+
+		changelog, err := releaseService.RenderReleaseChangelog(softwareName, version, format)
+		if err != nil {
+			if errors.Is(err, ErrUnsupportedChangelogFormat) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypes[format])
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, changelog)
 	}
 }
 
 // --- Admin Endpoints Handlers ---
 
-func handleListUsers(userService *UserService, logger *log.Logger) http.HandlerFunc {
+func handleListUsers(userService *UserService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		users, err := userService.ListUsers()
 		if err != nil {
@@ -132,20 +431,20 @@ func handleListUsers(userService *UserService, logger *log.Logger) http.HandlerF
 	}
 }
 
-func handleCreateUser(userService *UserService, logger *log.Logger) http.HandlerFunc {
+func handleCreateUser(userService *UserService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var newUserRequest CreateUserRequest
 		if err := decodeJSONBody(w, r, &newUserRequest); err != nil {
 			return // decodeJSONBody already handles error response
 		}
 
-		u := &User{
-			Username:     newUserRequest.Username,
-			PasswordHash: HashPassword(newUserRequest.Password),
-			Roles:        newUserRequest.Roles,
-			Enabled:      true, // Default to enabled on creation
-		}
-		if err := userService.CreateUser(u); err != nil {
+		actor, _ := GetUsernameFromContext(r.Context())
+		u := newUserFromRequest(newUserRequest, actor)
+		if err := userService.CreateUser(u, newUserRequest.Password); err != nil {
+			if errors.Is(err, ErrInvalidUsername) || errors.Is(err, ErrInvalidRole) || errors.Is(err, ErrWeakPassword) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to create user: %v", err))
 			return
 		}
@@ -153,7 +452,61 @@ func handleCreateUser(userService *UserService, logger *log.Logger) http.Handler
 	}
 }
 
-func handleUpdateUser(userService *UserService, logger *log.Logger) http.HandlerFunc {
+// maxBulkUsers caps the entries of a bulk user import, since every password is hashed within the request.
+const maxBulkUsers = 100
+
+// handleBulkCreateUsers creates the users of a JSON array of CreateUserRequest objects. Entries are
+// validated before any user is created, and those that fail do not stop the others; the response
+// reports the outcome of every entry.
+func handleBulkCreateUsers(userService *UserService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requests []CreateUserRequest
+		if err := decodeJSONBody(w, r, &requests); err != nil {
+			return
+		}
+		if len(requests) == 0 || len(requests) > maxBulkUsers {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Expected 1 to %d users", maxBulkUsers))
+			return
+		}
+
+		actor, _ := GetUsernameFromContext(r.Context())
+		users := make([]*User, len(requests))
+		passwords := make([]string, len(requests))
+		for i, request := range requests {
+			users[i] = newUserFromRequest(request, actor)
+			passwords[i] = request.Password
+		}
+
+		response := BulkCreateUsersResponse{Results: make([]BulkCreateUserResult, len(users))}
+		for i, err := range userService.CreateUsers(users, passwords) {
+			response.Results[i] = BulkCreateUserResult{Username: users[i].Username, Created: err == nil}
+			if err != nil {
+				response.Results[i].Error = err.Error()
+				response.Failed++
+				continue
+			}
+			response.Created++
+		}
+		logger.Info("AUDIT: users imported", "actor", actor, "created", response.Created, "failed", response.Failed)
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// newUserFromRequest builds the enabled user described by a create request.
+func newUserFromRequest(request CreateUserRequest, actor string) *User {
+	roles := request.Roles
+	if roles == nil {
+		roles = request.LegacyRoles // Clients written against the old "role" field
+	}
+	return &User{
+		Username:  request.Username,
+		Roles:     roles,
+		Enabled:   true, // Default to enabled on creation
+		CreatedBy: actor,
+	}
+}
+
+func handleUpdateUser(userService *UserService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		username := vars["username"]
@@ -163,6 +516,10 @@ func handleUpdateUser(userService *UserService, logger *log.Logger) http.Handler
 		}
 
 		if err := userService.UpdateUserPassword(username, updateUserRequest.Password); err != nil {
+			if errors.Is(err, ErrWeakPassword) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to update user: %v", err))
 			return
 		}
@@ -170,7 +527,7 @@ func handleUpdateUser(userService *UserService, logger *log.Logger) http.Handler
 	}
 }
 
-func handleDeleteUser(userService *UserService, logger *log.Logger) http.HandlerFunc {
+func handleDeleteUser(userService *UserService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		username := vars["username"]
@@ -183,7 +540,7 @@ func handleDeleteUser(userService *UserService, logger *log.Logger) http.Handler
 	}
 }
 
-func handleEnableDisableUser(userService *UserService, logger *log.Logger) http.HandlerFunc {
+func handleEnableDisableUser(userService *UserService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		username := vars["username"]
@@ -192,8 +549,7 @@ func handleEnableDisableUser(userService *UserService, logger *log.Logger) http.
 			return
 		}
 
-		if err := userService.EnableDisableUser(username, !statusRequest.Enabled); // Note the negation to toggle
-		err != nil {
+		if err := userService.EnableDisableUser(username, statusRequest.Enabled); err != nil {
 			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to enable/disable user: %v", err))
 			return
 		}
@@ -201,7 +557,76 @@ func handleEnableDisableUser(userService *UserService, logger *log.Logger) http.
 	}
 }
 
-func handleCreateSoftwarePackage(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleGetUserAccess(userService *UserService, authService *AuthService, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+
+		usr, err := userService.GetUserByUsername(username)
+		if err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("User not found: %s", username))
+			return
+		}
+		security, err := authService.GetUserSecurityStatus(username)
+		if err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("User not found: %s", username))
+			return
+		}
+		ownedPackages, err := releaseService.ListOwnedSoftwarePackages(username)
+		if err != nil {
+			logger.Error("Failed to list owned packages", "username", username, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list owned software packages")
+			return
+		}
+		tokens, err := authService.ListActiveAPITokens(username)
+		if err != nil {
+			logger.Error("Failed to list API tokens", "username", username, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list API tokens")
+			return
+		}
+
+		roles := usr.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		respondJSON(w, http.StatusOK, UserAccessReport{
+			Username:        usr.Username,
+			Enabled:         usr.Enabled,
+			Roles:           roles,
+			IsAdministrator: slices.Contains(roles, "administrator"),
+			OwnedPackages:   ownedPackages,
+			ActiveTokens:    toAPITokenInfos(tokens, authService.TokenStaleAfter()),
+			Security:        security,
+		})
+	}
+}
+
+func handleGetUserSecurity(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+
+		status, err := authService.GetUserSecurityStatus(username)
+		if err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("User not found: %s", username))
+			return
+		}
+		respondJSON(w, http.StatusOK, status)
+	}
+}
+
+func handleResetUserSecurity(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+		actor, _ := GetUsernameFromContext(r.Context())
+
+		if err := authService.ResetUserSecurityStatus(username, actor); err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("User not found: %s", username))
+			return
+		}
+		respondNoContent(w)
+	}
+}
+
+func handleCreateSoftwarePackage(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var newSoftwareRequest CreateSoftwareRequest
 		if err := decodeJSONBody(w, r, &newSoftwareRequest); err != nil {
@@ -213,6 +638,7 @@ func handleCreateSoftwarePackage(releaseService *ReleaseService, logger *log.Log
 			Description: newSoftwareRequest.Description,
 			Category:    newSoftwareRequest.Category,
 			Enabled:     true, // Default to enabled
+			Owner:       newSoftwareRequest.Owner,
 		}
 
 		if err := releaseService.CreateSoftwarePackage(software); err != nil {
@@ -223,7 +649,7 @@ func handleCreateSoftwarePackage(releaseService *ReleaseService, logger *log.Log
 	}
 }
 
-func handleUpdateSoftwarePackage(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleUpdateSoftwarePackage(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
@@ -240,7 +666,7 @@ func handleUpdateSoftwarePackage(releaseService *ReleaseService, logger *log.Log
 	}
 }
 
-func handleDeleteSoftwarePackage(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleDeleteSoftwarePackage(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
@@ -253,7 +679,7 @@ func handleDeleteSoftwarePackage(releaseService *ReleaseService, logger *log.Log
 	}
 }
 
-func handleEnableDisableSoftwarePackage(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleEnableDisableSoftwarePackage(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
@@ -262,8 +688,7 @@ func handleEnableDisableSoftwarePackage(releaseService *ReleaseService, logger *
 			return
 		}
 
-		if err := releaseService.EnableDisableSoftwarePackage(softwareName, !statusRequest.Enabled); // Toggle status
-		err != nil {
+		if err := releaseService.EnableDisableSoftwarePackage(softwareName, statusRequest.Enabled); err != nil {
 			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to enable/disable software package: %v", err))
 			return
 		}
@@ -271,111 +696,868 @@ func handleEnableDisableSoftwarePackage(releaseService *ReleaseService, logger *
 	}
 }
 
-// --- User Endpoints Handlers ---
-
-func handleCreateAPIToken(userService *UserService, authService *AuthService, logger *log.Logger) http.HandlerFunc {
+func handleGetVersionGaps(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		username, _, _ := r.BasicAuth() // Already authenticated by BasicAuthMiddleware
+		softwareName := mux.Vars(r)["software_name"]
 
-		token, err := authService.GenerateAPIToken(username)
+		report, err := releaseService.FindVersionGaps(softwareName)
 		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to generate API token")
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Software package not found: %s", softwareName))
 			return
 		}
-		respondJSON(w, http.StatusCreated, map[string]string{"api_key": token})
+		respondJSON(w, http.StatusOK, report)
 	}
 }
 
-// --- Token-Based Endpoints Handlers ---
+func handleTransferSoftwarePackage(releaseService *ReleaseService, userService *UserService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		softwareName := mux.Vars(r)["software_name"]
+		actor, _ := GetUsernameFromContext(r.Context())
+		var transferRequest TransferSoftwareRequest
+		if err := decodeJSONBody(w, r, &transferRequest); err != nil {
+			return
+		}
+
+		if _, err := userService.GetUserByUsername(transferRequest.NewOwner); err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Target user not found: %s", transferRequest.NewOwner))
+			return
+		}
+		if err := releaseService.TransferSoftwarePackage(softwareName, transferRequest.NewOwner, actor); err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to transfer software package: %v", err))
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Software package ownership transferred successfully"})
+	}
+}
 
-func handleUploadRelease(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleSetReleaseTag(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var uploadRequest UploadReleaseRequest
-		if err := decodeJSONBody(w, r, &uploadRequest); err != nil {
+		vars := mux.Vars(r)
+		softwareName, tag := vars["software_name"], vars["tag"]
+
+		var tagRequest ReleaseTagRequest
+		if err := decodeJSONBody(w, r, &tagRequest); err != nil {
+			return
+		}
+		if err := releaseService.SetReleaseTag(softwareName, tag, tagRequest.Version); err != nil {
+			if errors.Is(err, ErrInvalidTag) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to set tag: %v", err))
 			return
 		}
+		actor, _ := GetUsernameFromContext(r.Context())
+		logger.Info("AUDIT: release tag set", "actor", actor, "software_name", softwareName, "tag", tag, "version", tagRequest.Version)
+		respondJSON(w, http.StatusOK, map[string]string{"tag": tag, "version": tagRequest.Version})
+	}
+}
 
-		// Simulate downloading the file from file_url and creating a tgz (replace with actual logic)
-		tempDir, err := os.MkdirTemp("", "release-temp-")
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to create temporary directory")
+func handleDeleteReleaseTag(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName, tag := vars["software_name"], vars["tag"]
+
+		if err := releaseService.DeleteReleaseTag(softwareName, tag); err != nil {
+			if errors.Is(err, ErrTagNotFound) {
+				respondError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to delete tag")
 			return
 		}
-		defer os.RemoveAll(tempDir) // Clean up temp dir
+		actor, _ := GetUsernameFromContext(r.Context())
+		logger.Info("AUDIT: release tag deleted", "actor", actor, "software_name", softwareName, "tag", tag)
+		respondNoContent(w)
+	}
+}
 
-		downloadedFilePath := filepath.Join(tempDir, "downloaded-file") // Simulate downloaded file
-		if err := os.WriteFile(downloadedFilePath, []byte("This is a dummy release file content."), 0644); err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to create dummy release file")
+func handleSetSoftwarePackageFeatured(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		var featuredRequest FeaturedRequest
+		if err := decodeJSONBody(w, r, &featuredRequest); err != nil {
 			return
 		}
 
-		tgzFilePath := filepath.Join(tempDir, "release.tgz")                      // Simulate tgz creation
-		if err := createTGZArchive(downloadedFilePath, tgzFilePath); err != nil { // Dummy implementation below
-			respondError(w, http.StatusInternalServerError, "Failed to create TGZ archive")
+		if err := releaseService.SetSoftwarePackageFeatured(softwareName, featuredRequest.Featured); err != nil {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to update featured flag: %v", err))
 			return
 		}
-		defer os.Remove(tgzFilePath) // Clean up tgz file
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Software package featured flag updated successfully"})
+	}
+}
 
-		releaseMetadata := ReleaseMetadata{
-			SoftwareName:     uploadRequest.SoftwareName,
-			Version:          uploadRequest.Version,
-			ReleaseDate:      uploadRequest.ReleaseDate,
-			Changelog:        uploadRequest.Changelog,
-			FileSize:         1024, // Dummy size
-			ReleaseState:     "available",
-			ReleaseTimestamp: time.Now(), // Current Timestamp
+func handleSetSoftwarePackageStorageQuota(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		var quotaRequest StorageQuotaRequest
+		if err := decodeJSONBody(w, r, &quotaRequest); err != nil {
+			return
 		}
 
-		if err := releaseService.UploadRelease(tgzFilePath, releaseMetadata); err != nil {
-			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to upload release: %v", err))
+		if err := releaseService.SetSoftwarePackageStorageQuota(softwareName, quotaRequest.StorageQuotaBytes); err != nil {
+			if errors.Is(err, ErrInvalidStorageQuota) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to update storage quota: %v", err))
 			return
 		}
+		actor, _ := GetUsernameFromContext(r.Context())
+		logger.Info("AUDIT: package storage quota changed", "actor", actor, "software_name", softwareName, "storage_quota_bytes", quotaRequest.StorageQuotaBytes)
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Software package storage quota updated successfully"})
+	}
+}
 
-		respondJSON(w, http.StatusCreated, map[string]string{"message": "Release uploaded successfully"})
+func handleDeleteRelease(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		version := vars["version"]
+
+		if err := releaseService.DeleteRelease(softwareName, version); err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to delete release: %v", err))
+			return
+		}
+		respondNoContent(w)
 	}
 }
 
-func handleRetrieveRelease(releaseService *ReleaseService, logger *log.Logger) http.HandlerFunc {
+func handleSetReleaseState(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		softwareName := vars["software_name"]
 		version := vars["version"]
+		var stateRequest UpdateReleaseStateRequest
+		if err := decodeJSONBody(w, r, &stateRequest); err != nil {
+			return
+		}
 
-		releaseFilePath, err := releaseService.GetReleaseFilePath(softwareName, version)
+		release, err := releaseService.SetReleaseState(softwareName, version, stateRequest.State)
 		if err != nil {
+			if errors.Is(err, ErrInvalidReleaseState) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %v", err))
 			return
 		}
-
-		http.ServeFile(w, r, releaseFilePath) // Serve the TGZ file
+		actor, _ := GetUsernameFromContext(r.Context())
+		logger.Info("AUDIT: release state changed", "actor", actor, "software_name", release.SoftwareName, "version", release.Version, "state", release.ReleaseState)
+		respondJSON(w, http.StatusOK, release)
 	}
 }
 
-// --- Helper functions ---
+func handleSetServerNotice(notice *ServerNotice, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var noticeRequest ServerNoticeRequest
+		if err := decodeJSONBody(w, r, &noticeRequest); err != nil {
+			return
+		}
+		if err := validateServerNotice(noticeRequest.Message); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if payload != nil {
-		response, _ := json.Marshal(payload) // Ignoring error for simplicity in example
-		w.Write(response)                    // Ignoring error for simplicity in example
+		actor, _ := GetUsernameFromContext(r.Context())
+		notice.Set(noticeRequest.Message)
+		logger.Info("AUDIT: server notice set", "actor", actor, "message", noticeRequest.Message)
+		respondJSON(w, http.StatusOK, noticeRequest)
 	}
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
+func handleClearServerNotice(notice *ServerNotice, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, _ := GetUsernameFromContext(r.Context())
+		notice.Set("")
+		logger.Info("AUDIT: server notice cleared", "actor", actor)
+		respondNoContent(w)
+	}
 }
 
-func respondNoContent(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusNoContent)
+func handleReconcileReleases(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := releaseService.ReconcileReleases()
+		if err != nil {
+			if errors.Is(err, ErrReconcileInProgress) {
+				respondError(w, http.StatusConflict, "Reconciliation already in progress")
+				return
+			}
+			logger.Error("On-demand reconciliation failed", "error", err)
+			respondError(w, http.StatusInternalServerError, "Reconciliation failed")
+			return
+		}
+		actor, _ := GetUsernameFromContext(r.Context())
+		logger.Info("AUDIT: reconciliation run", "actor", actor, "checked", result.Checked, "newly_available", result.NewlyAvailable,
+			"newly_unavailable", result.NewlyUnavailable, "size_updated", result.SizeUpdated, "corrupt", result.Corrupt, "errored", result.Errored)
+		respondJSON(w, http.StatusOK, result)
+	}
 }
 
-func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	if r.Header.Get("Content-Type") != "" {
-		if r.Header.Get("Content-Type") != "application/json" {
+func handleExportCatalog(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := releaseService.ExportCatalog()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to export catalog")
+			return
+		}
+		if wantsNDJSON(r) {
+			respondNDJSON(w, catalog, logger)
+			return
+		}
+		respondJSON(w, http.StatusOK, catalog)
+	}
+}
+
+func handleDiffCatalog(releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var remoteCatalog []CatalogEntry // Catalog exported from another instance via GET /admin/catalog
+		if err := decodeJSONBody(w, r, &remoteCatalog); err != nil {
+			return
+		}
+
+		diff, err := releaseService.DiffCatalog(remoteCatalog)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to diff catalogs")
+			return
+		}
+		respondJSON(w, http.StatusOK, diff)
+	}
+}
+
+func handleListAllAPITokens(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := authService.ListAPITokens(r.URL.Query().Get("username")) // Optional filter by user
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to list API tokens")
+			return
+		}
+		respondJSON(w, http.StatusOK, toAPITokenInfos(tokens, authService.TokenStaleAfter()))
+	}
+}
+
+func handleAdminRevokeAPIToken(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenID := mux.Vars(r)["token_id"]
+
+		if err := authService.RevokeAPIToken(tokenID, ""); err != nil { // Admins may revoke any token
+			respondTokenError(w, err)
+			return
+		}
+		respondNoContent(w)
+	}
+}
+
+// --- User Endpoints Handlers ---
+
+// handleChangeOwnPassword lets an authenticated user set a new password for their own account.
+func handleChangeOwnPassword(userService *UserService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsernameFromContext(r.Context())
+		var updateUserRequest UpdateUserRequest
+		if err := decodeJSONBody(w, r, &updateUserRequest); err != nil {
+			return
+		}
+
+		if err := userService.UpdateUserPassword(username, updateUserRequest.Password); err != nil {
+			if errors.Is(err, ErrWeakPassword) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to change password: %v", err))
+			return
+		}
+		logger.Info("AUDIT: Password changed", "actor", username)
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Password changed successfully"})
+	}
+}
+
+func handleCreateAPIToken(userService *UserService, authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsernameFromContext(r.Context()) // Set by BasicAuthMiddleware from the authenticated identity
+		roles, _ := r.Context().Value(ContextKeyRoles).([]string)
+		source, _ := r.Context().Value(ContextKeyAuthSource).(string)
+
+		var createTokenRequest CreateAPITokenRequest
+		if r.ContentLength != 0 { // The body is optional, unnamed tokens need none
+			if err := decodeJSONBody(w, r, &createTokenRequest); err != nil {
+				return
+			}
+		}
+		if err := validateAPITokenRequest(createTokenRequest); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		token, err := authService.GenerateAPIToken(username, roles, source, createTokenRequest.Name, createTokenRequest.Description)
+		if err != nil {
+			if errors.Is(err, ErrTokenNameTaken) {
+				respondError(w, http.StatusConflict, fmt.Sprintf("You already have an active API token named %q", strings.TrimSpace(createTokenRequest.Name)))
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to generate API token")
+			return
+		}
+		respondJSON(w, http.StatusCreated, CreateAPITokenResponse{
+			APIKey:    token.Token,
+			TokenID:   token.ID,
+			Name:      token.Name,
+			ExpiresAt: token.ExpiresAt,
+		})
+	}
+}
+
+func handleRevokeAPIToken(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsernameFromContext(r.Context())
+		tokenID := mux.Vars(r)["token_id"]
+
+		if err := authService.RevokeAPIToken(tokenID, username); err != nil { // Users may only revoke their own tokens
+			respondTokenError(w, err)
+			return
+		}
+		respondNoContent(w)
+	}
+}
+
+// handleListOwnAPITokens lists the caller's API tokens, including revoked and expired ones, without their secrets.
+func handleListOwnAPITokens(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsernameFromContext(r.Context())
+		tokens, err := authService.ListAPITokens(username)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to list API tokens")
+			return
+		}
+		respondJSON(w, http.StatusOK, toAPITokenInfos(tokens, authService.TokenStaleAfter()))
+	}
+}
+
+func handleRevokeAPITokenByName(authService *AuthService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsernameFromContext(r.Context())
+		name := mux.Vars(r)["name"]
+
+		if err := authService.RevokeAPITokenByName(name, username); err != nil { // Names are only unique per user
+			respondTokenError(w, err)
+			return
+		}
+		respondNoContent(w)
+	}
+}
+
+// --- Token-Based Endpoints Handlers ---
+
+func handleUploadRelease(cfg *Config, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	fileURLClient := NewFileURLClient(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/form-data" {
+			respondError(w, http.StatusUnsupportedMediaType, "Content-Type header is not multipart/form-data")
+			return
+		}
+		if err := releaseService.EnsureStorageAvailable(r.ContentLength); err != nil {
+			respondUploadStorageError(w, err, logger)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxUploadBytes)
+
+		tempDir, err := os.MkdirTemp(cfg.TempPath, uploadTempDirPrefix+"*")
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to create temporary directory")
+			return
+		}
+		defer os.RemoveAll(tempDir) // Clean up temp dir
+
+		uploadRequest, uploadedFilePath, err := readUploadForm(r, filepath.Join(tempDir, "upload"))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds the maximum size of %d bytes", cfg.MaxUploadBytes))
+				return
+			}
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid upload: %v", err))
+			return
+		}
+		if err := ValidateSoftwareName(uploadRequest.SoftwareName); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := releaseService.ValidateReleaseVersion(uploadRequest.SoftwareName, uploadRequest.Version); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(uploadRequest.SBOM) > 0 {
+			if _, err := DetectSBOMFormat(uploadRequest.SBOM); err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid SBOM: %v", err))
+				return
+			}
+		}
+		uploader, _ := GetUsernameFromContext(r.Context())
+		if !releaseService.CanPublishRelease(uploadRequest.SoftwareName, uploader) {
+			respondForbidden(w, "Only the package owner can publish releases of this package")
+			return
+		}
+
+		if uploadRequest.FileUrl != "" {
+			uploadedFilePath, err = fetchReleaseFile(fileURLClient, uploadRequest.FileUrl, filepath.Join(tempDir, "upload"), cfg.MaxUploadBytes)
+			var maxBytesError *http.MaxBytesError
+			var upstreamError *UpstreamStatusError
+			switch {
+			case errors.Is(err, ErrInvalidFileURL):
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			case errors.As(err, &maxBytesError):
+				respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds the maximum size of %d bytes", cfg.MaxUploadBytes))
+				return
+			case errors.As(err, &upstreamError):
+				respondError(w, http.StatusBadGateway, fmt.Sprintf("Failed to fetch file_url: upstream returned status %d", upstreamError.StatusCode))
+				return
+			case err != nil:
+				logger.Error("Failed to fetch file_url", "software_name", uploadRequest.SoftwareName, "version", uploadRequest.Version, "file_url", uploadRequest.FileUrl, "error", err)
+				respondError(w, http.StatusBadGateway, "Failed to fetch file_url")
+				return
+			}
+		}
+
+		uploadedFileInfo, err := os.Stat(uploadedFilePath)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to read uploaded file")
+			return
+		}
+		if err := verifyUploadSignature(releaseService, uploadedFilePath, []byte(uploadRequest.Signature)); err != nil {
+			if errors.Is(err, ErrInvalidSignature) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to verify release signature")
+			return
+		}
+		tgz := newTGZArchiveReader(uploadedFilePath)
+		defer tgz.Close() // Stops the archiver if the upload is rejected before the archive is read
+
+		releaseMetadata := ReleaseMetadata{
+			SoftwareName: uploadRequest.SoftwareName,
+			Version:      uploadRequest.Version,
+			ReleaseDate:  uploadRequest.ReleaseDate,
+			Changelog:    uploadRequest.Changelog,
+			ReleaseState: "available",
+			UploadedBy:   uploader,
+
+			ChangelogEntries: uploadRequest.ChangelogEntries,
+		}
+
+		if err := releaseService.UploadRelease(tgz, uploadedFileInfo.Size(), releaseMetadata, uploadRequest.SBOM, []byte(uploadRequest.Signature), uploadRequest.Overwrite); err != nil {
+			if errors.Is(err, ErrInsufficientStorage) {
+				respondUploadStorageError(w, err, logger)
+				return
+			}
+			if errors.Is(err, ErrInvalidVersion) || errors.Is(err, ErrInvalidSoftwareName) {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, ErrReleaseExists) || errors.Is(err, ErrSoftwareNameConflict) {
+				respondError(w, http.StatusConflict, err.Error())
+				return
+			}
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to upload release: %v", err))
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, map[string]string{"message": "Release uploaded successfully"})
+	}
+}
+
+// verifyUploadSignature checks the signature sent with an upload against the uploaded file.
+func verifyUploadSignature(releaseService *ReleaseService, uploadedFilePath string, signature []byte) error {
+	file, err := os.Open(uploadedFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return releaseService.VerifyReleaseSignature(file, signature)
+}
+
+// respondUploadStorageError maps a failed free-space or quota check to 507, or 500 if the check itself failed.
+func respondUploadStorageError(w http.ResponseWriter, err error, logger *slog.Logger) {
+	if errors.Is(err, ErrStorageQuotaExceeded) {
+		logger.Warn("Rejected upload", "error", err)
+		respondError(w, http.StatusInsufficientStorage, err.Error())
+		return
+	}
+	if errors.Is(err, ErrInsufficientStorage) {
+		logger.Warn("Rejected upload", "error", err)
+		respondError(w, http.StatusInsufficientStorage, "Not enough free disk space to accept the upload")
+		return
+	}
+	respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check free disk space: %v", err))
+}
+
+func handleRetrieveRelease(cfg *Config, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		softwareName := vars["software_name"]
+		version := vars["version"]
+
+		if r.URL.Query().Get("signature") == "true" {
+			serveReleaseSignature(w, r, releaseService, softwareName, version)
+			return
+		}
+		release, releaseFilePath, err := releaseService.GetReleaseFilePath(softwareName, version)
+		if err != nil {
+			w.Header().Set("Cache-Control", "no-store") // Availability may change, never cache a miss
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %v", err))
+			return
+		}
+		if release.Version != version { // Resolved through a dist-tag, which can move to another version
+			apiPrefix := strings.TrimSuffix(r.URL.Path, "/releases/"+softwareName+"/"+version)
+			w.Header().Set("Content-Location", apiPrefix+"/releases/"+url.PathEscape(release.SoftwareName)+"/"+url.PathEscape(release.Version))
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		serveReleaseFile(w, r, cfg, releaseService, release, releaseFilePath, logger)
+	}
+}
+
+// handleDownloadLatestRelease serves the file of the package's latest available release.
+// Content-Location points at the versioned download URL of the release that was served.
+func handleDownloadLatestRelease(cfg *Config, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		softwareName := mux.Vars(r)["software_name"]
+
+		latest, err := releaseService.GetLatestReleaseForSoftware(softwareName)
+		if err != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			if errors.Is(err, ErrNotFound) {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("No releases found for software: %s", softwareName))
+				return
+			}
+			logger.Error("Failed to get latest release", "software_name", softwareName, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get latest release")
+			return
+		}
+		release, releaseFilePath, err := releaseService.GetReleaseFilePath(latest.SoftwareName, latest.Version)
+		if err != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %v", err))
+			return
+		}
+		apiPrefix := strings.TrimSuffix(r.URL.Path, "/packages/"+softwareName+"/latest/download")
+		w.Header().Set("Content-Location", apiPrefix+"/releases/"+url.PathEscape(release.SoftwareName)+"/"+url.PathEscape(release.Version))
+		w.Header().Set("Cache-Control", "no-cache") // The latest version changes, so unlike a versioned download this must be revalidated
+		serveReleaseFile(w, r, cfg, releaseService, release, releaseFilePath, logger)
+	}
+}
+
+func handleRetrieveReleaseByID(cfg *Config, releaseService *ReleaseService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		release, err := releaseService.GetReleaseByID(id)
+		if err != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %s", id))
+			return
+		}
+		if r.URL.Query().Get("signature") == "true" {
+			serveReleaseSignature(w, r, releaseService, release.SoftwareName, release.Version)
+			return
+		}
+		release, releaseFilePath, err := releaseService.GetReleaseFilePath(release.SoftwareName, release.Version)
+		if err != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Release not found: %v", err))
+			return
+		}
+		serveReleaseFile(w, r, cfg, releaseService, release, releaseFilePath, logger)
+	}
+}
+
+// serveReleaseSignature sends the minisign signature attached to a release, requested with ?signature=true.
+func serveReleaseSignature(w http.ResponseWriter, r *http.Request, releaseService *ReleaseService, softwareName string, version string) {
+	signatureFilePath, err := releaseService.GetReleaseSignatureFilePath(softwareName, version)
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Signature not found: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, signatureFilePath)
+}
+
+// serveReleaseFile sends a release TGZ file, directly or through the configured front proxy offload.
+// The release checksum is sent as ETag so clients can revalidate with If-None-Match.
+// A yanked release is still served, with a Warning header telling the client it was yanked and
+// Cache-Control: no-store; mutable versions get no-cache. Only other releases are cached as immutable.
+// HEAD requests get the same headers, including Content-Length and Last-Modified, without the body.
+// Byte ranges are served from the seekable release reader rather than from the file path.
+// Only responses carrying the whole file count as downloads, not HEAD, 304 or partial content.
+func serveReleaseFile(w http.ResponseWriter, r *http.Request, cfg *Config, releaseService *ReleaseService, release *ReleaseMetadata, releaseFilePath string, logger *slog.Logger) {
+	if release.ReleaseState == "yanked" {
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "release %s %s has been yanked"`, release.SoftwareName, release.Version))
+	}
+	switch {
+	case release.ReleaseState == "yanked":
+		w.Header().Set("Cache-Control", "no-store") // A yanked artifact must not keep being served from caches
+	case releaseService.isMutableVersion(release.SoftwareName, release.Version):
+		w.Header().Set("Cache-Control", "no-cache") // Can be replaced in place, so caches must revalidate the ETag
+	case w.Header().Get("Cache-Control") == "": // Callers serving a moving target set their own
+		// Published artifacts are immutable, so clients and proxies may cache them indefinitely.
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", cfg.DownloadCacheMaxAge))
+	}
+	etag := release.ETag()
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if cfg.FileOffloadHeader != "" && r.Method != http.MethodHead { // HEAD is answered here, there is no body to offload
+		if etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		setReleaseDownloadHeaders(w, release)
+		if err := offloadReleaseFile(w, cfg, releaseFilePath); err != nil {
+			logger.Error("Failed to offload release file", "software_name", release.SoftwareName, "version", release.Version, "path", releaseFilePath, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to serve release file")
+			return
+		}
+		if r.Header.Get("Range") == "" { // The proxy serves ranges itself, so a ranged request is a partial download
+			releaseService.RecordDownload(release)
+		}
+		return
+	}
+	file, err := releaseService.OpenReleaseFile(release)
+	if err != nil {
+		logger.Error("Failed to open release file", "software_name", release.SoftwareName, "version", release.Version, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to serve release file")
+		return
+	}
+	defer file.Close()
+	setReleaseDownloadHeaders(w, release)
+	recorder := &statusRecorder{ResponseWriter: w}
+	// ServeContent answers Range requests with 206 and Content-Range, and evaluates If-None-Match and If-Range.
+	http.ServeContent(recorder, r, filepath.Base(releaseFilePath), release.ReleaseTimestamp, file)
+	if recorder.status == http.StatusOK && r.Method == http.MethodGet {
+		releaseService.RecordDownload(release)
+	}
+}
+
+// setReleaseDownloadHeaders marks a release file response as a gzip attachment named after the release.
+// The type is set explicitly rather than guessed by ServeContent from the storage file's extension.
+func setReleaseDownloadHeaders(w http.ResponseWriter, release *ReleaseMetadata) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": release.DownloadFileName()}))
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag, using the weak
+// comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// offloadReleaseFile hands the byte-serving of a release file to the front proxy.
+// X-Sendfile receives the absolute file path; X-Accel-Redirect receives an internal
+// URI made of the configured prefix and the file path relative to the repository.
+func offloadReleaseFile(w http.ResponseWriter, cfg *Config, releaseFilePath string) error {
+	var target string
+	switch cfg.FileOffloadHeader {
+	case FileOffloadXSendfile:
+		absPath, err := filepath.Abs(releaseFilePath)
+		if err != nil {
+			return err
+		}
+		target = absPath
+	case FileOffloadXAccelRedirect:
+		relPath, err := filepath.Rel(cfg.RepositoryPath, releaseFilePath)
+		if err != nil {
+			return err
+		}
+		target = path.Join(cfg.FileOffloadPrefix, filepath.ToSlash(relPath))
+	default:
+		return fmt.Errorf("unsupported file offload header: %s", cfg.FileOffloadHeader)
+	}
+	w.Header().Set(cfg.FileOffloadHeader, target)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// --- Helper functions ---
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if payload != nil {
+		response, _ := json.Marshal(payload) // Ignoring error for simplicity in example
+		w.Write(response)                    // Ignoring error for simplicity in example
+	}
+}
+
+// wantsNDJSON reports whether the client asked for newline-delimited JSON, via ?format=ndjson or the Accept header.
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// respondNDJSON streams items as newline-delimited JSON, one record per line, flushing as it goes
+// so clients can process records before the whole response has been written.
+func respondNDJSON[T any](w http.ResponseWriter, items []T, logger *slog.Logger) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w) // Reaches the Flusher through wrapping middleware
+	encoder := json.NewEncoder(w)               // Encode terminates every record with a newline
+	for i, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			logger.Warn("Failed to stream NDJSON record", "error", err) // Headers are sent, the client sees a truncated stream
+			return
+		}
+		if (i+1)%ndjsonFlushInterval == 0 {
+			controller.Flush()
+		}
+	}
+}
+
+// validateAPITokenRequest checks the length of a token's name and description.
+func validateAPITokenRequest(req CreateAPITokenRequest) error {
+	if len(strings.TrimSpace(req.Name)) > maxTokenNameLength {
+		return fmt.Errorf("token name must be at most %d characters", maxTokenNameLength)
+	}
+	if len(strings.TrimSpace(req.Description)) > maxTokenDescriptionLength {
+		return fmt.Errorf("token description must be at most %d characters", maxTokenDescriptionLength)
+	}
+	return nil
+}
+
+// validateServerNotice checks that a notice can be sent as a single-line header value.
+func validateServerNotice(message string) error {
+	if strings.TrimSpace(message) == "" {
+		return fmt.Errorf("notice message cannot be empty, use DELETE to clear it")
+	}
+	for _, c := range message {
+		if c < 0x20 || c == 0x7f {
+			return fmt.Errorf("notice message cannot contain control characters")
+		}
+	}
+	return nil
+}
+
+// defaultPageLimit is the page size used when a listing request has no limit parameter.
+const defaultPageLimit = 50
+
+// Length limits for API token names and descriptions.
+const (
+	maxTokenNameLength        = 64
+	maxTokenDescriptionLength = 256
+)
+
+// ndjsonContentType is the media type of newline-delimited JSON responses.
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushInterval is the number of NDJSON records written between flushes.
+const ndjsonFlushInterval = 100
+
+// parsePagination reads the limit and offset query parameters, applying the default
+// limit and capping it at maxLimit. Negative or non-numeric values are rejected.
+func parsePagination(r *http.Request, maxLimit int) (int, int, error) {
+	limit := min(defaultPageLimit, maxLimit)
+	offset := 0
+	if val := r.URL.Query().Get("limit"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: must be a positive integer")
+		}
+		limit = min(parsed, maxLimit)
+	}
+	if val := r.URL.Query().Get("offset"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: must be a non-negative integer")
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+
+// parseReleaseFilter reads the state, since and until query parameters of a release listing.
+// Dates are RFC3339 timestamps.
+func parseReleaseFilter(r *http.Request) (ReleaseFilter, error) {
+	query := r.URL.Query()
+	filter := ReleaseFilter{State: query.Get("state")}
+	for _, bound := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		val := query.Get(bound.name)
+		if val == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return ReleaseFilter{}, fmt.Errorf("invalid %s, expected an RFC3339 timestamp: %s", bound.name, val)
+		}
+		*bound.dest = parsed
+	}
+	return filter, nil
+}
+
+// newPaginatedResponse slices one page out of an already sorted listing.
+func newPaginatedResponse[T any](items []T, limit int, offset int) PaginatedResponse[T] {
+	page := make([]T, 0, limit)
+	if offset < len(items) {
+		page = append(page, items[offset:min(offset+limit, len(items))]...)
+	}
+	return PaginatedResponse[T]{Items: page, Total: len(items), Limit: limit, Offset: offset}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func respondTokenError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrTokenNotFound) {
+		respondError(w, http.StatusNotFound, "API token not found")
+		return
+	}
+	respondError(w, http.StatusInternalServerError, "Failed to revoke API token")
+}
+
+// toAPITokenInfos converts token records to their public representation, dropping the secrets.
+// Tokens unused for longer than staleAfter are flagged as stale.
+func toAPITokenInfos(tokens []*APIToken, staleAfter time.Duration) []APITokenInfo {
+	now := time.Now()
+	infos := make([]APITokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		infos = append(infos, APITokenInfo{
+			ID:          token.ID,
+			Username:    token.Username,
+			Name:        token.Name,
+			Prefix:      token.Prefix(),
+			Description: token.Description,
+			CreatedAt:   token.CreatedAt,
+			ExpiresAt:   token.ExpiresAt,
+			LastUsedAt:  token.LastUsedAt,
+			Revoked:     token.Revoked,
+			Stale:       token.IsStale(now, staleAfter),
+		})
+	}
+	return infos
+}
+
+func respondNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if r.Header.Get("Content-Type") != "" {
+		if r.Header.Get("Content-Type") != "application/json" {
 			msg := "Content-Type header is not application/json"
 			respondError(w, http.StatusUnsupportedMediaType, msg)
-			return fmt.Errorf(msg)
+			return errors.New(msg)
 		}
 	} else {
 		msg := "Content-Type header is not present"
@@ -388,6 +1570,7 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 	if err := decoder.Decode(&dst); err != nil {
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
 
 		switch {
 		case errors.As(err, &syntaxError):
@@ -411,12 +1594,12 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 			msg := "Request body must not be empty"
 			respondError(w, http.StatusBadRequest, msg)
 
-		case err.Error() == "http: request body too large":
-			msg := "Request body too large"
+		case errors.As(err, &maxBytesError):
+			msg := fmt.Sprintf("Request body exceeds the maximum size of %d bytes", maxBytesError.Limit)
 			respondError(w, http.StatusRequestEntityTooLarge, msg)
 
 		default:
-			log.Printf("Error decoding JSON: %v", err.Error()) // Log unexpected errors for debugging
+			slog.Warn("Error decoding JSON", "error", err) // Log unexpected errors for debugging
 			respondError(w, http.StatusBadRequest, "Bad request")
 		}
 		return err
@@ -425,28 +1608,151 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 	if decoder.More() {
 		msg := "Request body must only contain a single JSON object"
 		respondError(w, http.StatusBadRequest, msg)
-		return fmt.Errorf(msg)
+		return errors.New(msg)
 	}
 
 	return nil
 }
 
-// Dummy TGZ creation function - replace with actual implementation
-func createTGZArchive(sourceFile string, destFile string) error {
-	file, err := os.Create(destFile)
+// maxUploadFieldBytes bounds the size of a single non-file field in a multipart release upload.
+const maxUploadFieldBytes = 1 << 20
+
+// readUploadForm streams a multipart release upload. The "file" part is written into dir,
+// the optional "sbom" part is read as the SBOM document, and the remaining parts are
+// the release metadata fields of UploadReleaseRequest. Instead of a file part the client
+// may send file_url, in which case the returned file path is empty.
+func readUploadForm(r *http.Request, dir string) (*UploadReleaseRequest, string, error) {
+	reader, err := r.MultipartReader()
 	if err != nil {
-		return err
+		return nil, "", fmt.Errorf("failed to read multipart body: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	uploadRequest := &UploadReleaseRequest{}
+	uploadedFilePath := ""
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read multipart part: %w", err)
+		}
+
+		if part.FormName() == "file" {
+			fileName := filepath.Base(part.FileName())
+			if fileName == "." || fileName == string(filepath.Separator) {
+				fileName = "release-file" // No usable client file name
+			}
+			uploadedFilePath = filepath.Join(dir, fileName)
+			err = writeToFile(part, uploadedFilePath)
+			part.Close()
+			if err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldBytes))
+		part.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read field %q: %w", part.FormName(), err)
+		}
+		if err := setUploadField(uploadRequest, part.FormName(), value); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if uploadedFilePath == "" && uploadRequest.FileUrl == "" {
+		return nil, "", fmt.Errorf("missing file part or file_url")
+	}
+	if uploadedFilePath != "" && uploadRequest.FileUrl != "" {
+		return nil, "", fmt.Errorf("provide either a file part or file_url, not both")
+	}
+	return uploadRequest, uploadedFilePath, nil
+}
+
+// setUploadField assigns a multipart form value to the matching UploadReleaseRequest field.
+func setUploadField(uploadRequest *UploadReleaseRequest, name string, value []byte) error {
+	switch name {
+	case "software_name":
+		uploadRequest.SoftwareName = strings.TrimSpace(string(value))
+	case "version":
+		uploadRequest.Version = string(value)
+	case "changelog":
+		uploadRequest.Changelog = string(value)
+	case "changelog_entries":
+		if err := json.Unmarshal(value, &uploadRequest.ChangelogEntries); err != nil {
+			return fmt.Errorf("invalid changelog_entries, expected a JSON array of entries: %w", err)
+		}
+		if err := ValidateChangelogEntries(uploadRequest.ChangelogEntries); err != nil {
+			return fmt.Errorf("invalid changelog_entries: %w", err)
+		}
+	case "file_url":
+		uploadRequest.FileUrl = string(value)
+	case "sbom":
+		uploadRequest.SBOM = value
+	case "signature":
+		uploadRequest.Signature = string(value)
+	case "overwrite":
+		overwrite, err := strconv.ParseBool(string(value))
+		if err != nil {
+			return fmt.Errorf("invalid overwrite, expected true or false: %w", err)
+		}
+		uploadRequest.Overwrite = overwrite
+	case "release_date":
+		if len(value) == 0 {
+			return nil
+		}
+		releaseDate, err := time.Parse(time.RFC3339, string(value))
+		if err != nil {
+			return fmt.Errorf("invalid release_date, expected RFC3339: %w", err)
+		}
+		uploadRequest.ReleaseDate = releaseDate
+	default:
+		return fmt.Errorf("unknown field %q", name)
+	}
+	return nil
+}
+
+// writeToFile streams a reader into a newly created file.
+func writeToFile(src io.Reader, destPath string) error {
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create upload file: %w", err)
 	}
 	defer file.Close()
 
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
+	if _, err := io.Copy(file, src); err != nil {
+		return fmt.Errorf("failed to write upload file: %w", err)
+	}
+	return nil
+}
 
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
+// tgzArchiveBufferSize is the size of the write buffer between the tar and gzip writers.
+const tgzArchiveBufferSize = 64 * 1024
+
+// newTGZArchiveReader returns a reader producing a gzip-compressed tar archive of a single file.
+// The archive is written by a goroutine as it is read, so it is never held in memory or on disk
+// as a whole. Closing the reader early stops the goroutine.
+func newTGZArchiveReader(sourceFile string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTGZArchive(pw, sourceFile))
+	}()
+	return pr
+}
 
-	// Add source file to the archive
-	info, err := os.Stat(sourceFile)
+// writeTGZArchive streams a single file into w as a gzip-compressed tar archive.
+func writeTGZArchive(w io.Writer, sourceFile string) error {
+	file, err := os.Open(sourceFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
@@ -454,14 +1760,21 @@ func createTGZArchive(sourceFile string, destFile string) error {
 	if err != nil {
 		return err
 	}
+
+	bw := bufio.NewWriterSize(w, tgzArchiveBufferSize)
+	gw := gzip.NewWriter(bw)
+	tw := tar.NewWriter(gw)
 	if err := tw.WriteHeader(header); err != nil {
 		return err
 	}
-
-	data, err := os.ReadFile(sourceFile)
-	if err != nil {
+	if _, err := io.Copy(tw, file); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
 		return err
 	}
-	_, err = tw.Write(data)
-	return err
+	return bw.Flush()
 }
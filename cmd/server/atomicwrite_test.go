@@ -0,0 +1,81 @@
+// internal/repository/atomicwrite_test.go - Tests of atomic JSON database writes.
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingJSONValue fails to encode, interrupting a save part way through its records.
+type failingJSONValue struct{}
+
+func (failingJSONValue) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("interrupted")
+}
+
+func TestWriteJSONFileAtomicInterrupted(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"failing record", []any{map[string]string{"name": "first"}, failingJSONValue{}}},
+		{"unencodable value", map[string]any{"channel": make(chan int)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "releases.json")
+			if err := writeJSONFileAtomic(path, []string{"original"}); err != nil {
+				t.Fatal(err)
+			}
+			original, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := writeJSONFileAtomic(path, tt.value); err == nil {
+				t.Fatal("interrupted save succeeded")
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != string(original) {
+				t.Errorf("file after an interrupted save = %q, want the original %q", content, original)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("directory holds %d entries after an interrupted save, want only the database", len(entries))
+			}
+		})
+	}
+}
+
+func TestJSONDatabaseIgnoresAbandonedTemporaryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "releases.json")
+	db, err := NewJSONReleaseDatabase(path, filepath.Join(dir, "software_ids.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateReleaseMetadata(&ReleaseMetadata{SoftwareName: "MyApp", Version: "1.0.0", ReleaseState: "available"}); err != nil {
+		t.Fatal(err)
+	}
+	// A crash between writing the temporary file and renaming it leaves a partial file behind.
+	if err := os.WriteFile(filepath.Join(dir, ".releases.json.123.tmp"), []byte(`[{"software_name": "MyA`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewJSONReleaseDatabase(path, filepath.Join(dir, "software_ids.json"))
+	if err != nil {
+		t.Fatalf("reopening after a crashed save failed: %v", err)
+	}
+	if _, err := reopened.GetReleaseMetadata("MyApp", "1.0.0"); err != nil {
+		t.Errorf("release saved before the crash is missing: %v", err)
+	}
+}
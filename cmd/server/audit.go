@@ -0,0 +1,129 @@
+// internal/audit/audit.go - Audit log of administrative actions.
+//
+// This file implements an append-only JSON Lines audit log and the middleware that
+// records every state-changing request to the admin API in it.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Actor     string            `json:"actor"`            // Authenticated username
+	Action    string            `json:"action"`           // Method and route, e.g. "DELETE /api/v1/admin/users/{username}"
+	Target    map[string]string `json:"target,omitempty"` // Route variables naming the affected entity
+	Outcome   string            `json:"outcome"`          // "success" or "failure"
+	Status    int               `json:"status"`           // HTTP status code of the response
+}
+
+// AuditLog appends audit entries to a JSON Lines file. A nil *AuditLog records nothing.
+// It is safe for concurrent use.
+type AuditLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	logger *slog.Logger
+}
+
+// OpenAuditLog opens path for appending audit entries, or returns nil if path is empty (auditing disabled).
+func OpenAuditLog(path string, logger *slog.Logger) (*AuditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{file: file, logger: logger}, nil
+}
+
+// Record appends an entry and syncs it to disk. Write errors are logged rather than returned
+// so that a failing audit log never fails the audited request.
+func (al *AuditLog) Record(entry AuditEntry) {
+	if al == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		al.logger.Error("Failed to encode audit entry", "action", entry.Action, "error", err)
+		return
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.file == nil {
+		al.logger.Error("Audit log closed, entry dropped", "actor", entry.Actor, "action", entry.Action)
+		return
+	}
+	if _, err := al.file.Write(append(line, '\n')); err != nil {
+		al.logger.Error("Failed to write audit entry", "actor", entry.Actor, "action", entry.Action, "error", err)
+		return
+	}
+	if err := al.file.Sync(); err != nil {
+		al.logger.Error("Failed to sync audit log", "error", err)
+	}
+}
+
+// Close closes the audit log file.
+func (al *AuditLog) Close() error {
+	if al == nil {
+		return nil
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
+
+// Middleware records every request that may change state (anything but GET and HEAD) once it
+// has been handled. It must run after authentication so the actor is known.
+func (al *AuditLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if al == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		actor, _ := GetUsernameFromContext(r.Context())
+		action := r.Method + " " + r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				action = r.Method + " " + template
+			}
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK // Nothing written, net/http sends 200
+		}
+		outcome := "success"
+		if status >= http.StatusBadRequest {
+			outcome = "failure"
+		}
+		al.Record(AuditEntry{
+			Timestamp: time.Now().UTC(),
+			Actor:     actor,
+			Action:    action,
+			Target:    mux.Vars(r),
+			Outcome:   outcome,
+			Status:    status,
+		})
+	})
+}
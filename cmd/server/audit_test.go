@@ -0,0 +1,85 @@
+// internal/audit/audit_test.go - Tests of the audit log of administrative actions.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readAuditLog returns the entries of an audit log file.
+func readAuditLog(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line %q is not an entry: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLogRecordsUserDeletion(t *testing.T) {
+	auditLogPath := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	s := newTestServer(t, func(cfg *Config) { cfg.AuditLogPath = auditLogPath })
+	s.createUser("alice", "Alice-Pass-123", "user")
+	before := time.Now().UTC()
+
+	requests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/api/v1/admin/users/alice", http.StatusNoContent},
+		{"/api/v1/admin/users/alice", http.StatusBadRequest},
+	}
+	for _, request := range requests {
+		if resp := s.do(asAdmin(s.request(http.MethodDelete, request.path, nil))); resp.Code != request.wantStatus {
+			t.Fatalf("DELETE %s = %d, want %d: %s", request.path, resp.Code, request.wantStatus, resp.Body)
+		}
+	}
+	s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users", nil))) // Reads are not audited
+
+	entries := readAuditLog(t, auditLogPath)
+	if len(entries) != 2 {
+		t.Fatalf("audit log has %d entries, want one per deletion: %+v", len(entries), entries)
+	}
+	for i, want := range []struct {
+		outcome string
+		status  int
+	}{{"success", http.StatusNoContent}, {"failure", http.StatusBadRequest}} {
+		entry := entries[i]
+		if entry.Actor != "admin" || entry.Action != "DELETE /api/v1/admin/users/{username}" || !maps.Equal(entry.Target, map[string]string{"username": "alice"}) {
+			t.Errorf("entry %d = %+v, want admin deleting user alice", i+1, entry)
+		}
+		if entry.Outcome != want.outcome || entry.Status != want.status {
+			t.Errorf("entry %d outcome = %s %d, want %s %d", i+1, entry.Outcome, entry.Status, want.outcome, want.status)
+		}
+		if entry.Timestamp.Before(before.Add(-time.Second)) || entry.Timestamp.After(time.Now().Add(time.Second)) {
+			t.Errorf("entry %d timestamp = %v, want the time of the request", i+1, entry.Timestamp)
+		}
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	auditLog, err := OpenAuditLog("", discardLogger())
+	if err != nil || auditLog != nil {
+		t.Fatalf("OpenAuditLog without a path = %v, %v; want a nil log", auditLog, err)
+	}
+	auditLog.Record(AuditEntry{Action: "DELETE /"}) // Must not panic
+	if err := auditLog.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,157 @@
+// internal/security/authenticator.go - Pluggable credential verification.
+//
+// This file defines the Authenticator interface used by BasicAuthMiddleware,
+// with a local implementation backed by the user database and an external
+// implementation that verifies credentials against an HTTP identity endpoint.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Authentication providers selectable via configuration.
+const (
+	AuthProviderLocal    = "local"
+	AuthProviderExternal = "external"
+)
+
+var (
+	// ErrInvalidCredentials is returned when a username/password pair is rejected.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrAccountDisabled is returned when the credentials are valid but the account is disabled.
+	ErrAccountDisabled = errors.New("account disabled")
+)
+
+// Identity is the result of a successful authentication.
+type Identity struct {
+	Username string
+	Roles    []string
+	Source   string // AuthProviderLocal or AuthProviderExternal
+
+	MustChangePassword bool // Only the password change endpoint may be used
+}
+
+// Authenticator verifies a username/password pair and resolves the caller's identity.
+type Authenticator interface {
+	Authenticate(username string, password string) (*Identity, error)
+}
+
+// NewAuthenticator creates the Authenticator selected by the configuration.
+func NewAuthenticator(cfg *Config, userService *UserService, logger *slog.Logger) (Authenticator, error) {
+	switch cfg.AuthProvider {
+	case "", AuthProviderLocal:
+		return NewLocalAuthenticator(userService, logger), nil
+	case AuthProviderExternal:
+		return NewExternalAuthenticator(cfg, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown auth provider: %s", cfg.AuthProvider)
+	}
+}
+
+// LocalAuthenticator verifies credentials against the local user database.
+type LocalAuthenticator struct {
+	userService *UserService
+	logger      *slog.Logger
+}
+
+// NewLocalAuthenticator creates a new LocalAuthenticator instance.
+func NewLocalAuthenticator(userService *UserService, logger *slog.Logger) *LocalAuthenticator {
+	return &LocalAuthenticator{userService: userService, logger: logger}
+}
+
+// Authenticate checks the password against the stored hash of an enabled local user.
+func (a *LocalAuthenticator) Authenticate(username string, password string) (*Identity, error) {
+	usr, err := a.userService.GetUserByUsername(username)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if !usr.Enabled {
+		return nil, ErrAccountDisabled
+	}
+	if !CompareHashAndPassword(usr.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	if PasswordNeedsRehash(usr.PasswordHash) {
+		// Transparently upgrade legacy MD5 (or outdated-cost) hashes now that the plaintext is known.
+		if err := a.userService.RehashPassword(usr, password); err != nil {
+			a.logger.Warn("Failed to upgrade password hash", "username", usr.Username, "error", err)
+		}
+	}
+	return &Identity{Username: usr.Username, Roles: usr.Roles, Source: AuthProviderLocal, MustChangePassword: usr.MustChangePassword}, nil
+}
+
+// ExternalAuthenticator verifies credentials by calling an external HTTP identity endpoint
+// (e.g. an OIDC userinfo endpoint or an auth gateway) with the caller's Basic Auth credentials.
+// The endpoint must answer 200 with a JSON object on success; the configured claim lists the
+// external groups, which are mapped to local roles.
+type ExternalAuthenticator struct {
+	url         string
+	rolesClaim  string
+	roleMapping map[string]string // External group -> local role
+	client      *http.Client
+	logger      *slog.Logger
+}
+
+// NewExternalAuthenticator creates a new ExternalAuthenticator instance.
+func NewExternalAuthenticator(cfg *Config, logger *slog.Logger) *ExternalAuthenticator {
+	return &ExternalAuthenticator{
+		url:         cfg.ExternalAuthURL,
+		rolesClaim:  cfg.ExternalAuthRolesClaim,
+		roleMapping: cfg.ExternalAuthRoleMapping,
+		client:      &http.Client{Timeout: time.Duration(cfg.ExternalAuthTimeout) * time.Second},
+		logger:      logger,
+	}
+}
+
+// Authenticate forwards the credentials to the external endpoint and maps the returned groups to roles.
+func (a *ExternalAuthenticator) Authenticate(username string, password string) (*Identity, error) {
+	req, err := http.NewRequest(http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build external auth request: %w", err)
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		a.logger.Error("External authentication request failed", "error", err)
+		return nil, fmt.Errorf("external auth request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, ErrInvalidCredentials
+	case resp.StatusCode != http.StatusOK:
+		a.logger.Error("External authentication endpoint returned an error", "status", resp.StatusCode)
+		return nil, fmt.Errorf("external auth endpoint returned status %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode external auth response: %w", err)
+	}
+
+	return &Identity{Username: username, Roles: a.mapRoles(claims[a.rolesClaim]), Source: AuthProviderExternal}, nil
+}
+
+// mapRoles translates the external groups claim into local roles; unmapped groups are ignored.
+func (a *ExternalAuthenticator) mapRoles(claim interface{}) []string {
+	roles := []string{"user"} // Every externally authenticated identity is at least a user
+	groups, _ := claim.([]interface{})
+	for _, group := range groups {
+		name, ok := group.(string)
+		if !ok {
+			continue
+		}
+		if role, ok := a.roleMapping[name]; ok && role != "user" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
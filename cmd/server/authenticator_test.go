@@ -0,0 +1,170 @@
+// internal/security/authenticator_test.go - Tests of the local and external authenticators.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// externalIdentity is an account known to the mock identity endpoint.
+type externalIdentity struct {
+	password string
+	groups   []string
+}
+
+// newMockIdentityEndpoint starts an identity endpoint that answers like an OIDC userinfo endpoint:
+// 200 with the groups claim for known credentials, 401 otherwise. An account named "broken" gets a 500.
+func newMockIdentityEndpoint(t *testing.T, identities map[string]externalIdentity) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if username == "broken" {
+			http.Error(w, "identity provider failure", http.StatusInternalServerError)
+			return
+		}
+		identity, ok := identities[username]
+		if !ok || identity.password != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"sub": username, "groups": identity.groups})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// useMockIdentityEndpoint configures a test server to authenticate against endpoint, mapping the
+// "release-managers" group to publisher and "ops" to administrator.
+func useMockIdentityEndpoint(endpoint *httptest.Server) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.AuthProvider = AuthProviderExternal
+		cfg.ExternalAuthURL = endpoint.URL
+		cfg.ExternalAuthRolesClaim = "groups"
+		cfg.ExternalAuthRoleMapping = map[string]string{"release-managers": "publisher", "ops": "administrator"}
+	}
+}
+
+func TestExternalAuthenticator(t *testing.T) {
+	endpoint := newMockIdentityEndpoint(t, map[string]externalIdentity{
+		"alice": {password: "alice-secret", groups: []string{"release-managers", "unmapped"}},
+		"bob":   {password: "bob-secret"},
+	})
+	cfg := DefaultConfig()
+	useMockIdentityEndpoint(endpoint)(cfg)
+	authenticator := NewExternalAuthenticator(cfg, discardLogger())
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		wantRoles  []string
+		wantErr    bool
+		wantDenied bool // The error is ErrInvalidCredentials rather than a provider failure
+	}{
+		{"mapped groups", "alice", "alice-secret", []string{"user", "publisher"}, false, false},
+		{"no groups", "bob", "bob-secret", []string{"user"}, false, false},
+		{"wrong password", "alice", "wrong", nil, true, true},
+		{"unknown user", "carol", "carol-secret", nil, true, true},
+		{"provider failure", "broken", "whatever", nil, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := authenticator.Authenticate(tt.username, tt.password)
+			if tt.wantErr {
+				if err == nil || errors.Is(err, ErrInvalidCredentials) != tt.wantDenied {
+					t.Fatalf("Authenticate = %v, want an error (invalid credentials: %v)", err, tt.wantDenied)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate failed: %v", err)
+			}
+			if identity.Username != tt.username || identity.Source != AuthProviderExternal || !slices.Equal(identity.Roles, tt.wantRoles) {
+				t.Errorf("identity = %+v, want %s from %s with roles %v", identity, tt.username, AuthProviderExternal, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestExternalIdentityAPITokens(t *testing.T) {
+	endpoint := newMockIdentityEndpoint(t, map[string]externalIdentity{
+		"admin":    {password: "external-admin-secret"}, // Same name as the local bootstrap administrator
+		"releaser": {password: "releaser-secret", groups: []string{"release-managers"}},
+		"operator": {password: "operator-secret", groups: []string{"ops"}},
+	})
+	s := newTestServer(t, useMockIdentityEndpoint(endpoint))
+
+	tests := []struct {
+		name             string
+		username         string
+		password         string
+		wantTokenStatus  int
+		wantUploadStatus int
+		wantAdminStatus  int
+	}{
+		{"user sharing a local administrator's name", "admin", "external-admin-secret", http.StatusCreated, http.StatusForbidden, http.StatusForbidden},
+		{"publisher", "releaser", "releaser-secret", http.StatusCreated, http.StatusCreated, http.StatusForbidden},
+		{"administrator", "operator", "operator-secret", http.StatusCreated, http.StatusCreated, http.StatusOK},
+		{"local password rejected by the provider", "admin", testAdminPassword, http.StatusUnauthorized, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := s.request(http.MethodPost, "/api/v1/auth/token", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			resp := s.do(req)
+			if resp.Code != tt.wantTokenStatus {
+				t.Fatalf("creating a token = %d, want %d: %s", resp.Code, tt.wantTokenStatus, resp.Body)
+			}
+			if resp.Code != http.StatusCreated {
+				return
+			}
+			var created CreateAPITokenResponse
+			decodeResponse(t, resp, &created)
+			record, err := s.tokenDB.GetAPIToken(created.APIKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Source != AuthProviderExternal {
+				t.Errorf("token source = %q, want %q", record.Source, AuthProviderExternal)
+			}
+
+			if resp := s.upload(created.APIKey, "App-"+tt.username, "1.0.0", []byte("content"), nil); resp.Code != tt.wantUploadStatus {
+				t.Errorf("upload with the token = %d, want %d: %s", resp.Code, tt.wantUploadStatus, resp.Body)
+			}
+			req = s.request(http.MethodGet, "/api/v1/admin/users", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			if resp := s.do(req); resp.Code != tt.wantAdminStatus {
+				t.Errorf("admin request = %d, want %d", resp.Code, tt.wantAdminStatus)
+			}
+		})
+	}
+}
+
+func TestLegacyExternalTokensKeepTheirRoles(t *testing.T) {
+	s := newTestServer(t, nil)
+	// A token issued to an external "admin" before tokens recorded their source: only its roles were stored.
+	legacy := &APIToken{ID: "legacy", Token: "legacy-secret", Username: "admin", Roles: []string{"user"}}
+	if err := s.tokenDB.CreateAPIToken(legacy); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewJSONTokenDatabase(filepath.Join(s.cfg.DataPath, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := reopened.GetAPIToken("legacy-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Source != AuthProviderExternal {
+		t.Errorf("source of a reloaded token with roles = %q, want %q", record.Source, AuthProviderExternal)
+	}
+	authService := NewAuthService(s.cfg, s.userService, nil, reopened, discardLogger())
+	if _, roles, err := authService.validateAPIKey("legacy-secret"); err != nil || !slices.Equal(roles, []string{"user"}) {
+		t.Errorf("legacy token grants %v (%v), want its stored roles [user] rather than the local administrator's", roles, err)
+	}
+}
@@ -0,0 +1,50 @@
+// internal/middleware/bodylimit_test.go - Tests of request body size limits.
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyLimits(t *testing.T) {
+	const jsonLimit, uploadLimit = 1024, 64 * 1024
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.MaxJSONBodyBytes = jsonLimit
+		cfg.MaxUploadBytes = uploadLimit
+	})
+	token := s.token("admin", testAdminPassword)
+
+	createUser := func(description string) int {
+		body := CreateUserRequest{Username: "alice", Password: "Alice-Pass-123" + description, Roles: []string{"user"}}
+		return s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", body))).Code
+	}
+	tests := []struct {
+		name       string
+		do         func() int
+		wantStatus int
+	}{
+		{"oversized JSON body", func() int { return createUser(strings.Repeat("x", jsonLimit)) }, http.StatusRequestEntityTooLarge},
+		{"JSON body within the limit", func() int { return createUser("") }, http.StatusCreated},
+		{"upload above the JSON limit", func() int {
+			return s.upload(token, "MyApp", "1.0.0", bytes.Repeat([]byte("x"), 4*jsonLimit), nil).Code
+		}, http.StatusCreated},
+		{"upload above its own limit", func() int {
+			return s.upload(token, "MyApp", "1.1.0", bytes.Repeat([]byte("x"), 2*uploadLimit), nil).Code
+		}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.do(); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+	if _, err := s.userService.GetUserByUsername("alice"); err != nil {
+		t.Errorf("user created within the limit is missing: %v", err)
+	}
+	if _, err := s.releaseService.GetRelease("MyApp", "1.1.0"); err == nil {
+		t.Error("oversized upload was stored")
+	}
+}
@@ -0,0 +1,65 @@
+// api/catalog_test.go - Tests of the catalog export.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestExportCatalogNDJSON(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, release := range []struct{ name, version string }{
+		{"MyApp", "1.0.0"}, {"MyApp", "1.1.0"}, {"MyApp", "2.0.0"}, {"Other", "0.1.0"}, {"Tool", "3.0.0"},
+	} {
+		s.mustUpload(token, release.name, release.version, []byte(release.name+" "+release.version))
+	}
+	resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/catalog", nil)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("catalog = %d: %s", resp.Code, resp.Body)
+	}
+	var want []CatalogEntry
+	decodeResponse(t, resp, &want)
+	if len(want) != 5 {
+		t.Fatalf("catalog has %d entries, want 5", len(want))
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+	}{
+		{"format parameter", "/api/v1/admin/catalog?format=ndjson", ""},
+		{"accept header", "/api/v1/admin/catalog", ndjsonContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := asAdmin(s.request(http.MethodGet, tt.path, nil))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp := s.do(req)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			if got := resp.Header().Get("Content-Type"); got != ndjsonContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ndjsonContentType)
+			}
+			var got []CatalogEntry
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				var entry CatalogEntry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					t.Fatalf("line %d %q is not a JSON record: %v", len(got)+1, scanner.Text(), err)
+				}
+				got = append(got, entry)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("NDJSON records = %+v, want the JSON catalog %+v", got, want)
+			}
+		})
+	}
+}
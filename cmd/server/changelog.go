@@ -0,0 +1,97 @@
+// internal/release/changelog.go - Structured changelog entries.
+//
+// This file defines structured changelog entries that can be uploaded alongside
+// the free-text changelog, their validation, and rendering to text, Markdown or HTML.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Change types accepted in structured changelog entries, in rendering order.
+const (
+	ChangeTypeAdded   = "added"
+	ChangeTypeChanged = "changed"
+	ChangeTypeFixed   = "fixed"
+	ChangeTypeRemoved = "removed"
+)
+
+// Output formats supported by RenderChangelog.
+const (
+	ChangelogFormatText     = "text"
+	ChangelogFormatMarkdown = "markdown"
+	ChangelogFormatHTML     = "html"
+)
+
+var changeTypeOrder = []string{ChangeTypeAdded, ChangeTypeChanged, ChangeTypeFixed, ChangeTypeRemoved}
+
+// ErrUnsupportedChangelogFormat is returned when a changelog is rendered to an unknown format.
+var ErrUnsupportedChangelogFormat = errors.New("unsupported changelog format")
+
+// ChangelogEntry is a single structured changelog item.
+type ChangelogEntry struct {
+	Type        string `json:"type"`        // One of added, changed, fixed, removed
+	Description string `json:"description"` // What changed
+}
+
+// ValidateChangelogEntries checks that every entry has a known type and a description.
+func ValidateChangelogEntries(entries []ChangelogEntry) error {
+	for i, entry := range entries {
+		switch entry.Type {
+		case ChangeTypeAdded, ChangeTypeChanged, ChangeTypeFixed, ChangeTypeRemoved:
+		default:
+			return fmt.Errorf("changelog entry %d: invalid type %q, expected one of %s", i, entry.Type, strings.Join(changeTypeOrder, ", "))
+		}
+		if strings.TrimSpace(entry.Description) == "" {
+			return fmt.Errorf("changelog entry %d: description cannot be empty", i)
+		}
+	}
+	return nil
+}
+
+// RenderChangelog renders structured entries grouped by change type in the requested format.
+func RenderChangelog(entries []ChangelogEntry, format string) (string, error) {
+	switch format {
+	case ChangelogFormatText, ChangelogFormatMarkdown, ChangelogFormatHTML:
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedChangelogFormat, format)
+	}
+
+	var b strings.Builder
+	for _, changeType := range changeTypeOrder {
+		var descriptions []string
+		for _, entry := range entries {
+			if entry.Type == changeType {
+				descriptions = append(descriptions, entry.Description)
+			}
+		}
+		if len(descriptions) == 0 {
+			continue
+		}
+
+		heading := strings.ToUpper(changeType[:1]) + changeType[1:]
+		switch format {
+		case ChangelogFormatText:
+			fmt.Fprintf(&b, "%s:\n", heading)
+			for _, description := range descriptions {
+				fmt.Fprintf(&b, "- %s\n", description)
+			}
+		case ChangelogFormatMarkdown:
+			fmt.Fprintf(&b, "### %s\n\n", heading)
+			for _, description := range descriptions {
+				fmt.Fprintf(&b, "- %s\n", description)
+			}
+			b.WriteString("\n")
+		case ChangelogFormatHTML:
+			fmt.Fprintf(&b, "<h3>%s</h3>\n<ul>\n", heading)
+			for _, description := range descriptions {
+				fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(description))
+			}
+			b.WriteString("</ul>\n")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,136 @@
+// internal/release/changelog_test.go - Tests of structured changelog entries.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testChangelogEntries are out of rendering order, with two entries of one type and markup to escape.
+var testChangelogEntries = []ChangelogEntry{
+	{Type: ChangeTypeFixed, Description: "Crash on <Enter>"},
+	{Type: ChangeTypeAdded, Description: "Dark mode"},
+	{Type: ChangeTypeFixed, Description: "Typo in the help & about page"},
+	{Type: ChangeTypeRemoved, Description: "Legacy API"},
+}
+
+func TestValidateChangelogEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []ChangelogEntry
+		valid   bool
+	}{
+		{"none", nil, true},
+		{"every type", testChangelogEntries, true},
+		{"changed", []ChangelogEntry{{Type: ChangeTypeChanged, Description: "Faster start"}}, true},
+		{"unknown type", []ChangelogEntry{{Type: "security", Description: "Patched"}}, false},
+		{"type in other casing", []ChangelogEntry{{Type: "Added", Description: "Dark mode"}}, false},
+		{"missing type", []ChangelogEntry{{Description: "Dark mode"}}, false},
+		{"empty description", []ChangelogEntry{{Type: ChangeTypeAdded, Description: " "}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateChangelogEntries(tt.entries); (err == nil) != tt.valid {
+				t.Errorf("ValidateChangelogEntries = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestRenderChangelog(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{ChangelogFormatText, "Added:\n- Dark mode\nFixed:\n- Crash on <Enter>\n- Typo in the help & about page\nRemoved:\n- Legacy API\n"},
+		{ChangelogFormatMarkdown, "### Added\n\n- Dark mode\n\n### Fixed\n\n- Crash on <Enter>\n- Typo in the help & about page\n\n### Removed\n\n- Legacy API\n\n"},
+		{ChangelogFormatHTML, "<h3>Added</h3>\n<ul>\n<li>Dark mode</li>\n</ul>\n<h3>Fixed</h3>\n<ul>\n<li>Crash on &lt;Enter&gt;</li>\n<li>Typo in the help &amp; about page</li>\n</ul>\n<h3>Removed</h3>\n<ul>\n<li>Legacy API</li>\n</ul>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := RenderChangelog(testChangelogEntries, tt.format)
+			if err != nil {
+				t.Fatalf("RenderChangelog failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("rendered\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+	if _, err := RenderChangelog(testChangelogEntries, "pdf"); !errors.Is(err, ErrUnsupportedChangelogFormat) {
+		t.Errorf("rendering to pdf = %v, want ErrUnsupportedChangelogFormat", err)
+	}
+}
+
+func TestStructuredChangelogRoundTrip(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	encoded, err := json.Marshal(testChangelogEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := s.upload(token, "MyApp", "1.0.0", []byte("release 1.0.0"), map[string]string{"changelog_entries": string(encoded)}); resp.Code != http.StatusCreated {
+		t.Fatalf("upload with changelog entries = %d: %s", resp.Code, resp.Body)
+	}
+	if resp := s.upload(token, "MyApp", "1.1.0", []byte("release 1.1.0"), map[string]string{"changelog": "Fixes <b>bugs</b>"}); resp.Code != http.StatusCreated {
+		t.Fatalf("upload with a free-text changelog = %d: %s", resp.Code, resp.Body)
+	}
+
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/1.0.0", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("getting 1.0.0 = %d: %s", resp.Code, resp.Body)
+	}
+	var info ReleaseVersionInfo
+	decodeResponse(t, resp, &info)
+	if !reflect.DeepEqual(info.ChangelogEntries, testChangelogEntries) {
+		t.Errorf("changelog entries = %+v, want %+v", info.ChangelogEntries, testChangelogEntries)
+	}
+	if want, _ := RenderChangelog(testChangelogEntries, ChangelogFormatText); info.Changelog != want {
+		t.Errorf("changelog = %q, want the text rendering %q", info.Changelog, want)
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		wantContentType string
+		wantBody        string
+	}{
+		{"default format", "/api/v1/packages/MyApp/1.0.0/changelog", "text/plain; charset=utf-8", "Added:\n- Dark mode\n"},
+		{"markdown", "/api/v1/packages/MyApp/1.0.0/changelog?format=markdown", "text/markdown; charset=utf-8", "### Fixed\n\n- Crash on <Enter>\n"},
+		{"html", "/api/v1/packages/MyApp/1.0.0/changelog?format=html", "text/html; charset=utf-8", "<li>Crash on &lt;Enter&gt;</li>"},
+		{"free text", "/api/v1/packages/MyApp/1.1.0/changelog?format=markdown", "text/markdown; charset=utf-8", "Fixes <b>bugs</b>"},
+		{"free text as html", "/api/v1/packages/MyApp/1.1.0/changelog?format=html", "text/html; charset=utf-8", "<p>Fixes &lt;b&gt;bugs&lt;/b&gt;</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, tt.path, nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			if got := resp.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("body %q does not contain %q", resp.Body, tt.wantBody)
+			}
+		})
+	}
+	if resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/1.0.0/changelog?format=pdf", nil)); resp.Code != http.StatusBadRequest {
+		t.Errorf("unknown format = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+
+	for _, invalid := range []string{`[{"type":"security","description":"Patched"}]`, `[{"type":"added","description":""}]`, `{"type":"added"}`, `not json`} {
+		t.Run("invalid "+invalid, func(t *testing.T) {
+			if resp := s.upload(token, "MyApp", "2.0.0", []byte("release 2.0.0"), map[string]string{"changelog_entries": invalid}); resp.Code != http.StatusBadRequest {
+				t.Errorf("upload = %d, want %d: %s", resp.Code, http.StatusBadRequest, resp.Body)
+			}
+			if _, err := s.releaseService.GetRelease("MyApp", "2.0.0"); err == nil {
+				t.Error("release with invalid changelog entries was stored")
+			}
+		})
+	}
+}
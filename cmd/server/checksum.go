@@ -0,0 +1,119 @@
+// internal/release/checksum.go - Release artifact digests.
+//
+// This file defines the supported checksum algorithms and computes one or more
+// hex-encoded digests of a release file in a single pass.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"sort"
+)
+
+// Checksum algorithms supported for release artifacts.
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+)
+
+// defaultChecksumAlgorithm is assumed for releases stored before the algorithm was recorded.
+const defaultChecksumAlgorithm = ChecksumSHA256
+
+var checksumHashes = map[string]func() hash.Hash{
+	ChecksumSHA256: sha256.New,
+	ChecksumSHA512: sha512.New,
+}
+
+// supportedChecksumAlgorithms returns the names of the supported algorithms, sorted.
+func supportedChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumHashes))
+	for name := range checksumHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateChecksumAlgorithms checks that at least one algorithm is configured, each is supported and none repeats.
+func validateChecksumAlgorithms(algorithms []string) error {
+	if len(algorithms) == 0 {
+		return fmt.Errorf("at least one checksum algorithm must be configured")
+	}
+	for i, algorithm := range algorithms {
+		if _, ok := checksumHashes[algorithm]; !ok {
+			return fmt.Errorf("unsupported checksum algorithm %q, expected one of %v", algorithm, supportedChecksumAlgorithms())
+		}
+		if slices.Contains(algorithms[:i], algorithm) {
+			return fmt.Errorf("checksum algorithm %q is listed twice", algorithm)
+		}
+	}
+	return nil
+}
+
+// digestWriter computes the digests of everything written to it for several algorithms at once.
+type digestWriter struct {
+	io.Writer
+	hashers map[string]hash.Hash
+}
+
+// newDigestWriter returns a digestWriter for the given algorithms.
+func newDigestWriter(algorithms []string) (*digestWriter, error) {
+	hashers := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		newHash, ok := checksumHashes[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+		}
+		hashers[algorithm] = newHash()
+		writers = append(writers, hashers[algorithm])
+	}
+	return &digestWriter{Writer: io.MultiWriter(writers...), hashers: hashers}, nil
+}
+
+// Digests returns the hex-encoded digests of the data written so far, keyed by algorithm.
+func (dw *digestWriter) Digests() map[string]string {
+	digests := make(map[string]string, len(dw.hashers))
+	for algorithm, hasher := range dw.hashers {
+		digests[algorithm] = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return digests
+}
+
+// computeDigests returns the hex-encoded digests of everything read from r, keyed by algorithm.
+// All digests are computed in a single pass over the data.
+func computeDigests(r io.Reader, algorithms []string) (map[string]string, error) {
+	dw, err := newDigestWriter(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(dw, r); err != nil {
+		return nil, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return dw.Digests(), nil
+}
+
+// computeFileDigests returns the hex-encoded digests of a file, keyed by algorithm.
+func computeFileDigests(path string, algorithms []string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for checksum: %w", err)
+	}
+	defer file.Close()
+	return computeDigests(file, algorithms)
+}
+
+// computeFileChecksum returns the hex-encoded digest of a file using one algorithm.
+func computeFileChecksum(path string, algorithm string) (string, error) {
+	digests, err := computeFileDigests(path, []string{algorithm})
+	if err != nil {
+		return "", err
+	}
+	return digests[algorithm], nil
+}
@@ -0,0 +1,108 @@
+// internal/release/checksum_test.go - Tests of release artifact digests.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestUploadStoresConfiguredDigests(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) { cfg.ChecksumAlgorithms = []string{ChecksumSHA512, ChecksumSHA256} })
+	s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release content"))
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum256, sum512 := sha256.Sum256(content), sha512.Sum512(content)
+	want := map[string]string{
+		ChecksumSHA256: hex.EncodeToString(sum256[:]),
+		ChecksumSHA512: hex.EncodeToString(sum512[:]),
+	}
+	for algorithm, digest := range want {
+		if got := release.Digests[algorithm]; got != digest {
+			t.Errorf("%s digest = %q, want %q", algorithm, got, digest)
+		}
+	}
+	if len(release.Digests) != len(want) {
+		t.Errorf("digests = %v, want exactly %v", release.Digests, want)
+	}
+	if release.PrimaryChecksumAlgorithm() != ChecksumSHA512 || release.Checksum != want[ChecksumSHA512] {
+		t.Errorf("checksum = %s %q, want the first configured algorithm, sha512 %q", release.PrimaryChecksumAlgorithm(), release.Checksum, want[ChecksumSHA512])
+	}
+	if got, want := release.ETag(), `"`+want[ChecksumSHA256]+`"`; got != want {
+		t.Errorf("ETag = %s, want the SHA-256 digest %s", got, want)
+	}
+}
+
+func TestValidateChecksumAlgorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithms []string
+		wantErr    bool
+	}{
+		{"default", []string{ChecksumSHA256}, false},
+		{"both", []string{ChecksumSHA256, ChecksumSHA512}, false},
+		{"none", nil, true},
+		{"unsupported", []string{"md5"}, true},
+		{"repeated", []string{ChecksumSHA512, ChecksumSHA512}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateChecksumAlgorithms(tt.algorithms); (err != nil) != tt.wantErr {
+				t.Errorf("validateChecksumAlgorithms(%v) = %v, want error %v", tt.algorithms, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTamperedReleaseIsMarkedCorrupt(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "MyApp", "1.1.0", []byte("release 1.1.0"))
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+
+	var page PaginatedResponse[*ReleaseMetadata]
+	decodeResponse(t, s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases?order=asc", nil)), &page)
+	if len(page.Items) != 2 || page.Items[0].Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("listing %+v does not expose the SHA-256 %x of 1.0.0", page.Items, sum)
+	}
+
+	content[len(content)/2] ^= 0xFF // Same size, so only the checksum tells
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.releaseService.ReconcileReleases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 2 || result.Corrupt != 1 {
+		t.Errorf("reconcile = %+v, want 2 checked and 1 corrupt", result)
+	}
+	for version, wantState := range map[string]string{"1.0.0": "corrupt", "1.1.0": "available"} {
+		release, err := s.releaseService.GetRelease("MyApp", version)
+		if err != nil || release.ReleaseState != wantState {
+			t.Errorf("%s = %+v, %v; want state %s", version, release, err, wantState)
+		}
+	}
+	if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)); resp.Code != http.StatusNotFound {
+		t.Errorf("download of a corrupt release = %d, want 404", resp.Code)
+	}
+}
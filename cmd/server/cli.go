@@ -0,0 +1,304 @@
+// internal/cli/cli.go - Maintenance subcommands.
+//
+// This file implements the subcommands that operate on the configured storage directly,
+// without starting the HTTP server, e.g. `server useradd` to bootstrap a deployment or `server reconcile` from cron.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit statuses of subcommands.
+const (
+	exitCodeOK       = 0
+	exitCodeError    = 1
+	exitCodeUsage    = 2
+	exitCodeFindings = 3 // Reconciliation found corrupt or unreadable releases
+)
+
+// runCommand runs the subcommand named by args[0] and returns the process exit status.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "useradd":
+		return runUserAdd(args[1:], os.Stdin, os.Stdout, os.Stderr)
+	case "reconcile":
+		return runReconcile(args[1:], os.Stdout, os.Stderr)
+	case "export":
+		return runExport(args[1:], os.Stdout, os.Stderr)
+	case "import":
+		return runImport(args[1:], os.Stdout, os.Stderr)
+	case "help", "-h", "-help", "--help":
+		printCommandUsage(os.Stdout)
+		return exitCodeOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		printCommandUsage(os.Stderr)
+		return exitCodeUsage
+	}
+}
+
+// printCommandUsage lists the available subcommands.
+func printCommandUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  server                 Start the API server")
+	fmt.Fprintln(w, "  server useradd [flags] Create a user in the configured storage")
+	fmt.Fprintln(w, "  server reconcile       Reconcile release metadata with the repository files and exit")
+	fmt.Fprintln(w, "  server export --out F  Write users, packages, releases and release files to a tar.gz archive")
+	fmt.Fprintln(w, "  server import --in F   Restore an export archive into empty storage (--force to replace existing data)")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run a subcommand with -h for its flags. Subcommands read the same configuration file and environment as the server.")
+}
+
+// runUserAdd creates a user directly in the configured user database. The password is read from stdin,
+// without echo when stdin is a terminal. The JSON backend keeps users in memory, so a running server
+// only sees the new user after a restart and may overwrite it; stop the server first.
+func runUserAdd(args []string, stdin *os.File, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("useradd", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	username := flags.String("username", "", "Name of the new user (required)")
+	roles := flags.String("role", "user", "Comma-separated roles: administrator, publisher, user")
+	mustChange := flags.Bool("must-change-password", false, "Require the user to change the password on first login")
+	if code, ok := parseCommandFlags(flags, args, stderr); !ok {
+		return code
+	}
+	user := &User{
+		Username:           *username,
+		Roles:              splitRoles(*roles),
+		Enabled:            true,
+		MustChangePassword: *mustChange,
+	}
+	if err := ValidateUsername(user.Username); err != nil { // Checked before prompting, CreateUser checks again
+		fmt.Fprintln(stderr, err)
+		return exitCodeUsage
+	}
+	if err := ValidateRoles(user.Roles); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeUsage
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return exitCodeError
+	}
+	SetPasswordHashCost(cfg.BcryptCost)
+	SetPasswordPolicy(PasswordPolicy{MinLength: cfg.PasswordMinLength, RequiredClasses: cfg.PasswordRequiredClasses})
+
+	password, err := promptNewPassword(stdin, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeError
+	}
+
+	userDB, _, _, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open databases: %v\n", err)
+		return exitCodeError
+	}
+	defer closeDatabases()
+	tokenDB, err := NewJSONTokenDatabase(cfg.DataPath + "/tokens.json")
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open token database: %v\n", err)
+		return exitCodeError
+	}
+	defer tokenDB.Close()
+
+	userService := NewUserService(userDB, tokenDB, slog.New(slog.NewTextHandler(stderr, nil)))
+	if err := userService.CreateUser(user, password); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeError
+	}
+	fmt.Fprintf(stdout, "User %s created with roles %v\n", user.Username, user.Roles)
+	return exitCodeOK
+}
+
+// runReconcile reconciles the release metadata with the repository files, like the server does at startup,
+// and prints the summary as JSON. It exits with exitCodeFindings when releases are corrupt or could not be checked.
+// SQLite serializes writers, so it can run next to a server using that backend; the JSON backend keeps releases
+// in memory and a running server would overwrite the result, so stop the server first.
+func runReconcile(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if code, ok := parseCommandFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return exitCodeError
+	}
+	_, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open databases: %v\n", err)
+		return exitCodeError
+	}
+	defer closeDatabases()
+
+	releaseService := NewReleaseService(cfg, releaseDB, packageDB, slog.New(slog.NewTextHandler(stderr, nil)))
+	result, err := releaseService.ReconcileReleases()
+	if err != nil {
+		fmt.Fprintf(stderr, "Release database reconciliation failed: %v\n", err)
+		return exitCodeError
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(stderr, "Failed to write reconciliation summary: %v\n", err)
+		return exitCodeError
+	}
+	if result.Corrupt > 0 || result.Errored > 0 {
+		return exitCodeFindings
+	}
+	return exitCodeOK
+}
+
+// runExport writes the configured storage to an archive. The archive is written to a temporary file
+// next to the destination and renamed into place, so a failed export leaves no partial archive.
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	out := flags.String("out", "", "Path of the archive to write, e.g. backup.tar.gz (required)")
+	if code, ok := parseCommandFlags(flags, args, stderr); !ok {
+		return code
+	}
+	if *out == "" {
+		fmt.Fprintln(stderr, "The --out flag is required")
+		return exitCodeUsage
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return exitCodeError
+	}
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open databases: %v\n", err)
+		return exitCodeError
+	}
+	defer closeDatabases()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(*out), "."+filepath.Base(*out)+".*.tmp")
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create archive: %v\n", err)
+		return exitCodeError
+	}
+	defer os.Remove(tmpFile.Name()) // No-op once renamed
+	summary, err := ExportRepository(cfg, userDB, releaseDB, packageDB, tmpFile)
+	if closeErr := tmpFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write archive: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), *out)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Export failed: %v\n", err)
+		return exitCodeError
+	}
+	fmt.Fprintf(stdout, "Exported %d users, %d software packages, %d releases and %d release files to %s\n",
+		summary.Users, summary.Packages, summary.Releases, summary.Files, *out)
+	return exitCodeOK
+}
+
+// runImport restores an archive written by runExport into the configured storage. The JSON backend keeps
+// its data in memory, so the server must be stopped while importing.
+func runImport(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	in := flags.String("in", "", "Path of the archive to restore (required)")
+	force := flags.Bool("force", false, "Delete all existing users, packages and releases before restoring")
+	if code, ok := parseCommandFlags(flags, args, stderr); !ok {
+		return code
+	}
+	if *in == "" {
+		fmt.Fprintln(stderr, "The --in flag is required")
+		return exitCodeUsage
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return exitCodeError
+	}
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open databases: %v\n", err)
+		return exitCodeError
+	}
+	defer closeDatabases()
+
+	summary, err := ImportRepository(cfg, userDB, releaseDB, packageDB, *in, *force)
+	if err != nil {
+		fmt.Fprintf(stderr, "Import failed: %v\n", err)
+		return exitCodeError
+	}
+	fmt.Fprintf(stdout, "Imported %d users, %d software packages, %d releases and %d release files from %s\n",
+		summary.Users, summary.Packages, summary.Releases, summary.Files, *in)
+	return exitCodeOK
+}
+
+// parseCommandFlags parses the flags of a subcommand, which takes no positional arguments.
+// It reports false together with the exit status when the command should stop, e.g. after -h.
+func parseCommandFlags(flags *flag.FlagSet, args []string, stderr io.Writer) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitCodeOK, false
+		}
+		return exitCodeUsage, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "Unexpected arguments: %v\n", flags.Args())
+		return exitCodeUsage, false
+	}
+	return exitCodeOK, true
+}
+
+// splitRoles parses a comma-separated role list, ignoring empty entries.
+func splitRoles(list string) []string {
+	roles := make([]string, 0)
+	for _, role := range strings.Split(list, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// promptNewPassword reads a new password. On a terminal it is read twice without echo and must match;
+// otherwise the first line of stdin is used, so scripts can pipe the password in.
+func promptNewPassword(stdin *os.File, prompts io.Writer) (string, error) {
+	if !isTerminal(stdin) {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(prompts, "Password: ")
+	password, err := readPasswordNoEcho(stdin)
+	fmt.Fprintln(prompts)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	fmt.Fprint(prompts, "Repeat password: ")
+	repeated, err := readPasswordNoEcho(stdin)
+	fmt.Fprintln(prompts)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if password != repeated {
+		return "", errors.New("passwords do not match")
+	}
+	return password, nil
+}
@@ -0,0 +1,141 @@
+// internal/cli/cli_test.go - Tests of the maintenance subcommands.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useCommandConfig writes a configuration file with the storage paths of cfg and points the
+// subcommands' LoadConfig at it for the rest of the test.
+func useCommandConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	encoded, err := json.Marshal(map[string]any{
+		"data_path":       cfg.DataPath,
+		"repository_path": cfg.RepositoryPath,
+		"temp_path":       cfg.TempPath,
+		"bcrypt_cost":     bcrypt.MinCost,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configPath, encoded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QFT_RELMAN_CONFIG_PATH", configPath)
+}
+
+// passwordInput returns a file to pass as the standard input of useradd, holding a password line.
+func passwordInput(t *testing.T, password string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(password+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestUserAddCommand(t *testing.T) {
+	storage := newTestStorage(t)
+	useCommandConfig(t, storage.cfg)
+
+	tests := []struct {
+		name     string
+		args     []string
+		password string
+		wantCode int
+	}{
+		{"new user", []string{"-username", "alice", "-role", "publisher,user"}, "Alice-Pass-123", exitCodeOK},
+		{"existing user", []string{"-username", "alice"}, "Alice-Pass-123", exitCodeError},
+		{"missing username", nil, "Valid-Pass-123", exitCodeUsage},
+		{"invalid username", []string{"-username", "../bob"}, "Valid-Pass-123", exitCodeUsage},
+		{"unknown role", []string{"-username", "bob", "-role", "superuser"}, "Valid-Pass-123", exitCodeUsage},
+		{"weak password", []string{"-username", "bob"}, "short", exitCodeError},
+		{"positional argument", []string{"-username", "bob", "extra"}, "Valid-Pass-123", exitCodeUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runUserAdd(tt.args, passwordInput(t, tt.password), &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d: %s", code, tt.wantCode, stderr.String())
+			}
+		})
+	}
+
+	userDB, err := NewJSONUserDatabase(filepath.Join(storage.cfg.DataPath, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userDB.Close()
+	users, err := userDB.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("users.json holds %d users, want only alice", len(users))
+	}
+	alice := users[0]
+	if alice.Username != "alice" || !slices.Equal(alice.Roles, []string{"publisher", "user"}) || !alice.Enabled {
+		t.Errorf("stored user = %+v, want enabled alice with roles publisher and user", alice)
+	}
+	if !CompareHashAndPassword(alice.PasswordHash, "Alice-Pass-123") {
+		t.Error("stored password hash does not match the password read from stdin")
+	}
+}
+
+func TestReconcileCommand(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "MyApp", "1.1.0", []byte("release 1.1.0"))
+	useCommandConfig(t, s.cfg)
+
+	reconcile := func() (int, ReconcileResult) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := runReconcile(nil, &stdout, &stderr)
+		var result ReconcileResult
+		if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+			t.Fatalf("summary %q is not JSON: %v (%s)", stdout.String(), err, stderr.String())
+		}
+		return code, result
+	}
+
+	if code, result := reconcile(); code != exitCodeOK || result.Checked != 2 || result.Corrupt != 0 {
+		t.Errorf("reconcile of an intact repository = %d %+v, want %d with 2 checked", code, result, exitCodeOK)
+	}
+
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[len(content)/2] ^= 0xFF // Same size, different checksum
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, result := reconcile(); code != exitCodeFindings || result.Corrupt != 1 {
+		t.Errorf("reconcile of a corrupt repository = %d %+v, want %d with 1 corrupt", code, result, exitCodeFindings)
+	}
+
+	var stderr bytes.Buffer
+	if code := runReconcile([]string{"extra"}, &bytes.Buffer{}, &stderr); code != exitCodeUsage {
+		t.Errorf("reconcile with an argument = %d, want %d", code, exitCodeUsage)
+	}
+}
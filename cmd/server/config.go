@@ -7,10 +7,16 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 const ServerVersion = "0.1.0" // Define software version
@@ -18,23 +24,175 @@ const ServerVersion = "0.1.0" // Define software version
 // Config holds the application configuration.
 type Config struct {
 	LogFilePath      string `json:"log_file_path"`
-	APIServerAddress string `json:"api_listener"`
+	LogFormat        string `json:"log_format"`      // "text" for key=value lines or "json" for one JSON object per line
+	LogMaxSizeMB     int    `json:"log_max_size_mb"` // Size at which the log file is rotated, 0 disables rotation
+	LogMaxBackups    int    `json:"log_max_backups"` // Number of rotated log files kept
+	AuditLogPath     string `json:"audit_log_path"`  // JSON Lines log of admin actions, empty disables auditing
+	APIServerAddress string `json:"api_listener" env:"QFT_RELMAN_API_ADDRESS"`
 	DataPath         string `json:"data_path"`
-	RepositoryPath   string `json:"repository_path"`
-	ShutdownDelay    int    `json:"shutdown_delay_seconds"`
+	StorageBackend   string `json:"storage_backend"` // Metadata storage: "json" files or a "sqlite" database in DataPath
+	RepositoryPath   string `json:"repository_path" env:"QFT_RELMAN_REPO_PATH"`
+	ShutdownDelay    int    `json:"shutdown_delay_seconds" env:"QFT_RELMAN_SHUTDOWN_DELAY"`
 	ConfigFileUsed   string `json:"-"` // Not from config file, but tracked for info
+
+	RejectUploadsOnShutdown bool `json:"reject_uploads_on_shutdown"`                                             // Reject new uploads with 503 once shutdown begins
+	DownloadCacheMaxAge     int  `json:"download_cache_max_age_seconds" env:"QFT_RELMAN_DOWNLOAD_CACHE_MAX_AGE"` // max-age of the immutable Cache-Control header on downloads
+
+	MaxUploadBytes      int64 `json:"max_upload_bytes"`         // Maximum size of a release upload request body
+	MaxJSONBodyBytes    int64 `json:"max_json_body_bytes"`      // Maximum size of any other request body
+	FileURLTimeout      int   `json:"file_url_timeout_seconds"` // Timeout for fetching a release from file_url
+	FileURLMaxRedirects int   `json:"file_url_max_redirects"`   // Redirects followed when fetching file_url
+	MinFreeDiskBytes    int64 `json:"min_free_disk_bytes"`      // Free space the repository filesystem must keep after an upload, 0 disables the guard
+
+	StorageQuotaBytes        int64 `json:"storage_quota_bytes"`         // Total size of all release files, SBOMs and signatures the repository may hold, 0 for no quota
+	PackageStorageQuotaBytes int64 `json:"package_storage_quota_bytes"` // Size of release files, SBOMs and signatures each package may hold unless the package sets its own quota, 0 for no quota
+
+	ChecksumAlgorithms []string `json:"checksum_algorithms"` // Digests computed for uploaded releases ("sha256", "sha512"); the first is used for integrity checks
+
+	VersionComponents int `json:"version_components"` // Numeric components uploaded versions must have, e.g. 3 for X.Y.Z or 4 for X.Y.Z.BUILD; 0 accepts 1 to 6
+
+	WebhookURLs       []string `json:"webhook_urls"`            // Endpoints that receive a POST when a release is published
+	WebhookSecret     string   `json:"webhook_secret"`          // HMAC-SHA256 key signing webhook bodies, empty sends them unsigned
+	WebhookTimeout    int      `json:"webhook_timeout_seconds"` // Timeout of each webhook delivery attempt
+	WebhookMaxRetries int      `json:"webhook_max_retries"`     // Retries with exponential backoff after a failed delivery, 0 for none
+
+	SignaturePublicKey    string `json:"signature_public_key"`    // Minisign public key release signatures are verified against, empty disables signatures
+	RequireSignedReleases bool   `json:"require_signed_releases"` // Reject uploads without a valid signature
+
+	DeepHealthCheck      bool   `json:"deep_health_check"`      // Readiness also streams a canary release to verify retrieval
+	HealthCanarySoftware string `json:"health_canary_software"` // Canary release software name, newest release if empty
+	HealthCanaryVersion  string `json:"health_canary_version"`  // Canary release version
+
+	AuthProvider            string            `json:"auth_provider"`              // Credential verification: "local" or "external"
+	ExternalAuthURL         string            `json:"external_auth_url"`          // Identity endpoint called with the caller's Basic Auth credentials
+	ExternalAuthRolesClaim  string            `json:"external_auth_roles_claim"`  // Response claim listing the external groups
+	ExternalAuthRoleMapping map[string]string `json:"external_auth_role_mapping"` // External group -> local role
+	ExternalAuthTimeout     int               `json:"external_auth_timeout_seconds"`
+
+	BcryptCost     int `json:"bcrypt_cost"`            // Cost factor for bcrypt password hashing
+	TokenTTLHours  int `json:"token_ttl_hours"`        // Lifetime of generated API tokens in hours, 0 for no expiry
+	TokenStaleDays int `json:"token_stale_after_days"` // Days without use after which token listings flag a token as stale, 0 to never flag
+
+	TokenCleanupIntervalMinutes int `json:"token_cleanup_interval_minutes"` // How often revoked and expired API tokens are deleted, 0 disables the cleanup
+
+	PasswordMinLength       int      `json:"password_min_length"`       // Minimum length of new passwords
+	PasswordRequiredClasses []string `json:"password_required_classes"` // Character classes new passwords must contain ("lower", "upper", "digit", "symbol")
+
+	MaxFailedLogins int `json:"max_failed_logins"` // Consecutive failed logins before a temporary lock, 0 disables lockout
+	LockoutMinutes  int `json:"lockout_minutes"`   // Duration of the temporary lock
+
+	ReconcileConcurrency int `json:"reconcile_concurrency"` // Number of workers checking release files during reconciliation
+
+	FileOffloadHeader string `json:"file_offload_header"` // "X-Accel-Redirect" or "X-Sendfile" to let the front proxy serve release files, empty to serve directly
+	FileOffloadPrefix string `json:"file_offload_prefix"` // Internal location prefix used with X-Accel-Redirect
+
+	TempPath                 string `json:"temp_path"`                   // Dedicated root for temporary upload directories
+	TempDirMaxAgeMinutes     int    `json:"temp_dir_max_age_minutes"`    // Upload temp directories older than this are considered abandoned
+	TempSweepIntervalMinutes int    `json:"temp_sweep_interval_minutes"` // How often abandoned temp directories are swept, 0 for startup only
+
+	AuthRateLimitRPS   float64 `json:"auth_rate_limit_rps"`   // Per-IP requests per second on /auth and /admin endpoints, 0 disables
+	AuthRateLimitBurst int     `json:"auth_rate_limit_burst"` // Requests allowed in a burst on /auth and /admin endpoints
+	ReadRateLimitRPS   float64 `json:"read_rate_limit_rps"`   // Per-IP requests per second on all other API endpoints, 0 disables
+	ReadRateLimitBurst int     `json:"read_rate_limit_burst"` // Requests allowed in a burst on all other API endpoints
+
+	ServerNotice string `json:"server_notice"` // Initial message of the day sent to clients, can be changed at runtime by admins
+
+	IdempotencyWindowMinutes int `json:"idempotency_window_minutes"` // How long responses to admin POSTs with an Idempotency-Key are replayed, 0 disables
+
+	MaxPageLimit int `json:"max_page_limit"` // Upper bound for the limit parameter of paginated listings
+
+	FeaturedPackagesOrder string `json:"featured_packages_order"` // Order of the featured listing: "name" or "latest_release"
+
+	PackageSettings map[string]PackageSettings `json:"package_settings"` // Per-package overrides keyed by software name
+
+	DeprecatedRoutes map[string]RouteDeprecation `json:"deprecated_routes"` // Routes answered with deprecation headers, keyed by "[METHOD ]/path/template"
+}
+
+// PackageSettings holds per-package behavior overrides.
+type PackageSettings struct {
+	LatestStrategy  string   `json:"latest_strategy"`  // How "latest" is resolved: highest_version, most_recent or pinned
+	PinnedVersion   string   `json:"pinned_version"`   // Version returned as latest when LatestStrategy is "pinned"
+	MutableVersions []string `json:"mutable_versions"` // Version patterns (path.Match syntax, e.g. "nightly") that re-uploads replace in place
 }
 
+// Strategies for resolving the latest release of a package.
+const (
+	LatestStrategyHighestVersion = "highest_version"
+	LatestStrategyMostRecent     = "most_recent"
+	LatestStrategyPinned         = "pinned"
+)
+
+// Headers for offloading release downloads to a front proxy.
+const (
+	FileOffloadXAccelRedirect = "X-Accel-Redirect" // nginx
+	FileOffloadXSendfile      = "X-Sendfile"       // Apache mod_xsendfile, lighttpd
+)
+
+// Formats of the log file.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Storage backends for users, packages and release metadata.
+const (
+	StorageBackendJSON   = "json"
+	StorageBackendSQLite = "sqlite"
+)
+
+// Orders for the featured package listing.
+const (
+	FeaturedOrderName          = "name"
+	FeaturedOrderLatestRelease = "latest_release"
+)
+
 // Default configuration values if not provided in file or env vars.
 const (
 	defaultLogFilePath      = "gemini.rel-man.log"
+	defaultLogFormat        = LogFormatText
+	defaultLogMaxSizeMB     = 100
+	defaultLogMaxBackups    = 5
+	defaultAuditLogPath     = "gemini.rel-man.audit.jsonl"
 	defaultAPIServerAddress = ":8080"
 	defaultDataPath         = "./data"
+	defaultStorageBackend   = StorageBackendJSON
 	defaultRepositoryPath   = "./repository"
+	defaultTempPath         = "./tmp"
+	defaultTempDirMaxAge    = 24 * 60
+	defaultTempSweepMinutes = 60
 	defaultShutdownDelay    = 5
+	defaultRejectUploads    = true
+	defaultCacheMaxAge      = 31536000 // One year, release artifacts never change once published
+	defaultMaxUploadBytes   = 1 << 30  // 1 GiB
+	defaultMaxJSONBody      = 1 << 20  // 1 MiB
+	defaultMinFreeDisk      = 1 << 30  // 1 GiB
+	defaultFileURLTimeout   = 60
+	defaultFileURLRedirects = 5
+	defaultWebhookTimeout   = 10
+	defaultWebhookRetries   = 3
+	defaultVersionParts     = 3
+	defaultAuthProvider     = AuthProviderLocal
+	defaultAuthRolesClaim   = "groups"
+	defaultAuthTimeout      = 5
+	defaultBcryptCost       = bcrypt.DefaultCost
+	defaultPasswordMinLen   = 12
+	defaultTokenStaleDays   = 90
+	defaultTokenCleanupMins = 60
+	defaultLockoutMinutes   = 15
+	defaultReconcileWorkers = 4
+	defaultOffloadPrefix    = "/protected"
+	defaultMaxPageLimit     = 500
+	defaultIdempotencyMins  = 24 * 60
+	defaultAuthRateRPS      = 5
+	defaultAuthRateBurst    = 10
+	defaultReadRateRPS      = 50
+	defaultReadRateBurst    = 100
 	configFileName          = "gemini.rel-man.config.json"
 )
 
+// defaultPasswordClasses are the character classes new passwords must contain by default.
+var defaultPasswordClasses = []string{PasswordClassLower, PasswordClassUpper, PasswordClassDigit}
+
 // LoadConfig loads the configuration from a JSON file and environment variables.
 func LoadConfig() (*Config, error) {
 	cfg := DefaultConfig()
@@ -44,10 +202,10 @@ func LoadConfig() (*Config, error) {
 		if !os.IsNotExist(err) { // Ignore file not found error, use defaults or env vars
 			return nil, fmt.Errorf("failed to load config file: %w", err)
 		}
-		fmt.Println("Configuration file not found, using default values and environment variables.")
+		fmt.Fprintln(os.Stderr, "Configuration file not found, using default values and environment variables.")
 	} else {
 		cfg.ConfigFileUsed = configFilePath // Track config file used if loaded successfully
-		fmt.Printf("Configuration loaded from file: %s\n", configFilePath)
+		fmt.Fprintf(os.Stderr, "Configuration loaded from file: %s\n", configFilePath)
 	}
 
 	applyEnvironmentVariables(cfg) // Override with environment variables if set
@@ -55,6 +213,9 @@ func LoadConfig() (*Config, error) {
 	if err := validateConfig(cfg); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
+	if err := prepareStorageDirectories(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
 
 	return cfg, nil
 }
@@ -63,10 +224,63 @@ func LoadConfig() (*Config, error) {
 func DefaultConfig() *Config {
 	return &Config{
 		LogFilePath:      defaultLogFilePath,
+		LogFormat:        defaultLogFormat,
+		LogMaxSizeMB:     defaultLogMaxSizeMB,
+		LogMaxBackups:    defaultLogMaxBackups,
+		AuditLogPath:     defaultAuditLogPath,
 		APIServerAddress: defaultAPIServerAddress,
 		DataPath:         defaultDataPath,
+		StorageBackend:   defaultStorageBackend,
 		RepositoryPath:   defaultRepositoryPath,
 		ShutdownDelay:    defaultShutdownDelay,
+
+		RejectUploadsOnShutdown: defaultRejectUploads,
+		DownloadCacheMaxAge:     defaultCacheMaxAge,
+
+		MaxUploadBytes:      defaultMaxUploadBytes,
+		MaxJSONBodyBytes:    defaultMaxJSONBody,
+		MinFreeDiskBytes:    defaultMinFreeDisk,
+		ChecksumAlgorithms:  []string{defaultChecksumAlgorithm},
+		FileURLTimeout:      defaultFileURLTimeout,
+		FileURLMaxRedirects: defaultFileURLRedirects,
+
+		VersionComponents: defaultVersionParts,
+
+		WebhookTimeout:    defaultWebhookTimeout,
+		WebhookMaxRetries: defaultWebhookRetries,
+
+		AuthProvider:           defaultAuthProvider,
+		ExternalAuthRolesClaim: defaultAuthRolesClaim,
+		ExternalAuthTimeout:    defaultAuthTimeout,
+
+		BcryptCost:     defaultBcryptCost,
+		TokenStaleDays: defaultTokenStaleDays,
+
+		TokenCleanupIntervalMinutes: defaultTokenCleanupMins,
+
+		PasswordMinLength:       defaultPasswordMinLen,
+		PasswordRequiredClasses: slices.Clone(defaultPasswordClasses),
+
+		LockoutMinutes: defaultLockoutMinutes,
+
+		ReconcileConcurrency: defaultReconcileWorkers,
+
+		FileOffloadPrefix: defaultOffloadPrefix,
+
+		TempPath:                 defaultTempPath,
+		TempDirMaxAgeMinutes:     defaultTempDirMaxAge,
+		TempSweepIntervalMinutes: defaultTempSweepMinutes,
+
+		AuthRateLimitRPS:   defaultAuthRateRPS,
+		AuthRateLimitBurst: defaultAuthRateBurst,
+		ReadRateLimitRPS:   defaultReadRateRPS,
+		ReadRateLimitBurst: defaultReadRateBurst,
+
+		IdempotencyWindowMinutes: defaultIdempotencyMins,
+
+		MaxPageLimit: defaultMaxPageLimit,
+
+		FeaturedPackagesOrder: FeaturedOrderName,
 	}
 }
 
@@ -94,26 +308,90 @@ func loadConfigFile(cfg *Config, path string) error {
 	return nil
 }
 
-// applyEnvironmentVariables overrides configuration with environment variables.
+// envPrefix starts the name of every configuration environment variable.
+const envPrefix = "QFT_RELMAN_"
+
+// applyEnvironmentVariables overrides configuration fields from QFT_RELMAN_* environment variables.
+// Every field with a JSON name can be overridden: the variable is envPrefix followed by the upper-cased
+// JSON name, unless the field's env tag names it. Lists are comma-separated and maps are JSON objects.
+// An invalid value prints a warning and leaves the field unchanged.
 func applyEnvironmentVariables(cfg *Config) {
-	setIfEnvExists(&cfg.LogFilePath, "QFT_RELMAN_LOG_FILE_PATH")
-	setIfEnvExists(&cfg.APIServerAddress, "QFT_RELMAN_API_ADDRESS")
-	setIfEnvExists(&cfg.DataPath, "QFT_RELMAN_DATA_PATH")
-	setIfEnvExists(&cfg.RepositoryPath, "QFT_RELMAN_REPO_PATH")
-	if val := os.Getenv("QFT_RELMAN_SHUTDOWN_DELAY"); val != "" {
-		if delay, err := strconv.Atoi(val); err == nil {
-			cfg.ShutdownDelay = delay
-		} else {
-			fmt.Printf("Warning: Invalid value for QFT_RELMAN_SHUTDOWN_DELAY, using default. Error: %v\n", err)
+	value := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		envName := configEnvName(value.Type().Field(i))
+		if envName == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setConfigField(value.Field(i), val); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Invalid value for %s, using default. Error: %v\n", envName, err)
 		}
 	}
 }
 
-// setIfEnvExists sets the config value from environment variable if it exists.
-func setIfEnvExists(configValue *string, envName string) {
-	if val := os.Getenv(envName); val != "" {
-		*configValue = val
+// configEnvName returns the environment variable overriding a Config field, empty if it has none.
+func configEnvName(field reflect.StructField) string {
+	if name := field.Tag.Get("env"); name != "" {
+		return name
 	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return ""
+	}
+	return envPrefix + strings.ToUpper(name)
+}
+
+// setConfigField parses an environment variable value into a Config field.
+// An empty value leaves scalar and map fields unchanged and empties list fields.
+func setConfigField(field reflect.Value, val string) error {
+	if val == "" && field.Kind() != reflect.Slice {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		parsed := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(val), parsed.Interface()); err != nil {
+			return err
+		}
+		field.Set(parsed.Elem())
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
 }
 
 // validateConfig performs basic validation of the configuration.
@@ -124,31 +402,197 @@ func validateConfig(cfg *Config) error {
 	if cfg.DataPath == "" {
 		return fmt.Errorf("data path cannot be empty")
 	}
+	if cfg.LogFormat != LogFormatText && cfg.LogFormat != LogFormatJSON {
+		return fmt.Errorf("log format must be %q or %q", LogFormatText, LogFormatJSON)
+	}
+	if cfg.LogMaxSizeMB < 0 {
+		return fmt.Errorf("log max size cannot be negative")
+	}
+	if cfg.LogMaxBackups < 0 {
+		return fmt.Errorf("log max backups cannot be negative")
+	}
+	if cfg.StorageBackend != StorageBackendJSON && cfg.StorageBackend != StorageBackendSQLite {
+		return fmt.Errorf("storage backend must be %q or %q", StorageBackendJSON, StorageBackendSQLite)
+	}
 	if cfg.RepositoryPath == "" {
 		return fmt.Errorf("repository path cannot be empty")
 	}
+	if cfg.TempPath == "" {
+		return fmt.Errorf("temp path cannot be empty")
+	}
+	if cfg.TempDirMaxAgeMinutes < 1 || cfg.TempSweepIntervalMinutes < 0 {
+		return fmt.Errorf("temp dir max age must be positive and temp sweep interval non-negative")
+	}
 	if cfg.ShutdownDelay < 0 {
 		return fmt.Errorf("shutdown delay must be non-negative")
 	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if cfg.DownloadCacheMaxAge < 0 {
+		return fmt.Errorf("download cache max age must be non-negative")
+	}
+	if cfg.MaxUploadBytes <= 0 {
+		return fmt.Errorf("max upload bytes must be positive")
+	}
+	if cfg.MaxJSONBodyBytes <= 0 {
+		return fmt.Errorf("max JSON body bytes must be positive")
+	}
+	if cfg.MinFreeDiskBytes < 0 {
+		return fmt.Errorf("min free disk bytes must be non-negative")
+	}
+	if cfg.StorageQuotaBytes < 0 || cfg.PackageStorageQuotaBytes < 0 {
+		return fmt.Errorf("storage quotas must be non-negative")
+	}
+	if (cfg.HealthCanarySoftware == "") != (cfg.HealthCanaryVersion == "") {
+		return fmt.Errorf("health canary software and version must be set together")
+	}
+	if cfg.FileURLTimeout < 0 || cfg.FileURLMaxRedirects < 0 {
+		return fmt.Errorf("file_url timeout and max redirects must be non-negative")
+	}
+	if cfg.VersionComponents < 0 || cfg.VersionComponents > maxVersionComponents {
+		return fmt.Errorf("version components must be between 0 and %d", maxVersionComponents)
+	}
+	if err := validateWebhookURLs(cfg.WebhookURLs); err != nil {
+		return err
+	}
+	if cfg.WebhookTimeout < 1 || cfg.WebhookMaxRetries < 0 {
+		return fmt.Errorf("webhook timeout must be positive and webhook max retries non-negative")
+	}
+	if cfg.TokenTTLHours < 0 {
+		return fmt.Errorf("token TTL must be non-negative")
+	}
+	if cfg.TokenStaleDays < 0 {
+		return fmt.Errorf("token stale age must be non-negative")
+	}
+	if cfg.TokenCleanupIntervalMinutes < 0 {
+		return fmt.Errorf("token cleanup interval must be non-negative")
+	}
+	if cfg.MaxFailedLogins < 0 || cfg.LockoutMinutes < 0 {
+		return fmt.Errorf("max failed logins and lockout minutes must be non-negative")
+	}
+	if cfg.AuthRateLimitRPS < 0 || cfg.ReadRateLimitRPS < 0 {
+		return fmt.Errorf("rate limits must be non-negative")
+	}
+	if (cfg.AuthRateLimitRPS > 0 && cfg.AuthRateLimitBurst < 1) || (cfg.ReadRateLimitRPS > 0 && cfg.ReadRateLimitBurst < 1) {
+		return fmt.Errorf("rate limit burst must be at least 1 when the rate limit is enabled")
+	}
+	if cfg.ServerNotice != "" {
+		if err := validateServerNotice(cfg.ServerNotice); err != nil {
+			return fmt.Errorf("invalid server notice: %w", err)
+		}
+	}
+	if cfg.IdempotencyWindowMinutes < 0 {
+		return fmt.Errorf("idempotency window must be non-negative")
+	}
+	if cfg.MaxPageLimit < 1 {
+		return fmt.Errorf("max page limit must be at least 1")
+	}
+	if cfg.ReconcileConcurrency < 1 {
+		return fmt.Errorf("reconcile concurrency must be at least 1")
+	}
+	switch cfg.FileOffloadHeader {
+	case "", FileOffloadXAccelRedirect, FileOffloadXSendfile:
+	default:
+		return fmt.Errorf("file offload header must be empty, %q or %q", FileOffloadXAccelRedirect, FileOffloadXSendfile)
+	}
+	if cfg.FeaturedPackagesOrder != FeaturedOrderName && cfg.FeaturedPackagesOrder != FeaturedOrderLatestRelease {
+		return fmt.Errorf("featured packages order must be %q or %q", FeaturedOrderName, FeaturedOrderLatestRelease)
+	}
+	if cfg.AuthProvider == AuthProviderExternal && cfg.ExternalAuthURL == "" {
+		return fmt.Errorf("external auth URL cannot be empty when the external auth provider is selected")
+	}
+	if cfg.ExternalAuthTimeout < 0 {
+		return fmt.Errorf("external auth timeout must be non-negative")
+	}
+	if err := validateChecksumAlgorithms(cfg.ChecksumAlgorithms); err != nil {
+		return err
+	}
+	if err := validatePasswordPolicy(cfg.PasswordMinLength, cfg.PasswordRequiredClasses); err != nil {
+		return err
+	}
+	if cfg.SignaturePublicKey != "" {
+		if _, err := ParseMinisignPublicKey(cfg.SignaturePublicKey); err != nil {
+			return fmt.Errorf("invalid signature public key: %w", err)
+		}
+	} else if cfg.RequireSignedReleases {
+		return fmt.Errorf("require_signed_releases needs a signature public key")
+	}
+	if err := validateRouteDeprecations(cfg.DeprecatedRoutes); err != nil {
+		return err
+	}
+	for name, settings := range cfg.PackageSettings {
+		switch settings.LatestStrategy {
+		case "", LatestStrategyHighestVersion, LatestStrategyMostRecent:
+		case LatestStrategyPinned:
+			if settings.PinnedVersion == "" {
+				return fmt.Errorf("package %s uses the pinned latest strategy but has no pinned version", name)
+			}
+		default:
+			return fmt.Errorf("package %s has unknown latest strategy: %s", name, settings.LatestStrategy)
+		}
+		for _, pattern := range settings.MutableVersions {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("package %s has invalid mutable version pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// prepareStorageDirectories creates the data, repository and temp directories if missing and checks
+// that each is writable, so permission problems are reported at startup instead of on the first upload.
+func prepareStorageDirectories(cfg *Config) error {
+	for _, dir := range []struct{ name, path string }{
+		{"data path", cfg.DataPath},
+		{"repository path", cfg.RepositoryPath},
+		{"temp path", cfg.TempPath},
+	} {
+		if err := ensureWritableDir(dir.path); err != nil {
+			return fmt.Errorf("%s %s: %w", dir.name, dir.path, err)
+		}
+	}
+	return nil
+}
+
+// ensureWritableDir creates a directory if missing and verifies it is writable by creating and removing a file in it.
+func ensureWritableDir(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("cannot be created: %w", err)
+	}
+	probe, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("is not writable: %w", err)
+	}
+	probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("is not writable: %w", err)
+	}
 	return nil
 }
 
-// SetupLogger initializes the logger and log file.
-func SetupLogger(logFilePath string) (*log.Logger, *os.File, error) {
-	logDir := filepath.Dir(logFilePath)
+// SetupLogger initializes the logger and its log file, which is rotated by size when configured.
+func SetupLogger(cfg *Config) (*slog.Logger, *RotatingFile, error) {
+	logDir := filepath.Dir(cfg.LogFilePath)
 	if _, err := os.Stat(logDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(logDir, 0755); err != nil {
 			return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
 		}
 	}
 
-	logFile, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logFile, err := OpenRotatingFile(cfg.LogFilePath, int64(cfg.LogMaxSizeMB)*1024*1024, cfg.LogMaxBackups)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		return nil, nil, err
 	}
 
-	logger := log.New(logFile, "QFT RelMan: ", log.Ldate|log.Ltime|log.Lshortfile)
-	logger.Println("Logger initialized.") // Initial log message
+	var handler slog.Handler
+	if cfg.LogFormat == LogFormatJSON {
+		handler = slog.NewJSONHandler(logFile, nil)
+	} else {
+		handler = slog.NewTextHandler(logFile, nil)
+	}
+	logger := slog.New(handler)
+	logger.Info("Logger initialized", "format", cfg.LogFormat) // Initial log message
 
 	return logger, logFile, nil
 }
@@ -0,0 +1,147 @@
+// config/config_test.go - Tests of configuration environment overrides and storage directory checks.
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnvironmentVariables(t *testing.T) {
+	defaults := DefaultConfig()
+	tests := []struct {
+		name  string
+		env   string
+		value string
+		get   func(cfg *Config) any
+		want  any
+	}{
+		{"string from env tag", "QFT_RELMAN_API_ADDRESS", ":9999", func(cfg *Config) any { return cfg.APIServerAddress }, ":9999"},
+		{"path from env tag", "QFT_RELMAN_REPO_PATH", "/srv/releases", func(cfg *Config) any { return cfg.RepositoryPath }, "/srv/releases"},
+		{"json tag name ignored when env tag is set", "QFT_RELMAN_REPOSITORY_PATH", "/srv/other", func(cfg *Config) any { return cfg.RepositoryPath }, defaults.RepositoryPath},
+		{"int from env tag", "QFT_RELMAN_SHUTDOWN_DELAY", "7", func(cfg *Config) any { return cfg.ShutdownDelay }, 7},
+		{"string from json tag", "QFT_RELMAN_STORAGE_BACKEND", "sqlite", func(cfg *Config) any { return cfg.StorageBackend }, "sqlite"},
+		{"int from json tag", "QFT_RELMAN_MAX_FAILED_LOGINS", "5", func(cfg *Config) any { return cfg.MaxFailedLogins }, 5},
+		{"int64", "QFT_RELMAN_STORAGE_QUOTA_BYTES", "1073741824", func(cfg *Config) any { return cfg.StorageQuotaBytes }, int64(1 << 30)},
+		{"bool", "QFT_RELMAN_REJECT_UPLOADS_ON_SHUTDOWN", "true", func(cfg *Config) any { return cfg.RejectUploadsOnShutdown }, true},
+		{"float", "QFT_RELMAN_AUTH_RATE_LIMIT_RPS", "2.5", func(cfg *Config) any { return cfg.AuthRateLimitRPS }, 2.5},
+		{"list", "QFT_RELMAN_CHECKSUM_ALGORITHMS", "sha256, sha512,", func(cfg *Config) any { return cfg.ChecksumAlgorithms }, []string{"sha256", "sha512"}},
+		{"empty list", "QFT_RELMAN_WEBHOOK_URLS", "", func(cfg *Config) any { return cfg.WebhookURLs }, []string{}},
+		{"map", "QFT_RELMAN_EXTERNAL_AUTH_ROLE_MAPPING", `{"devs":"publisher"}`, func(cfg *Config) any { return cfg.ExternalAuthRoleMapping }, map[string]string{"devs": "publisher"}},
+		{"invalid int keeps default", "QFT_RELMAN_MAX_FAILED_LOGINS", "many", func(cfg *Config) any { return cfg.MaxFailedLogins }, defaults.MaxFailedLogins},
+		{"invalid bool keeps default", "QFT_RELMAN_DEEP_HEALTH_CHECK", "sometimes", func(cfg *Config) any { return cfg.DeepHealthCheck }, defaults.DeepHealthCheck},
+		{"empty scalar keeps default", "QFT_RELMAN_LOG_FORMAT", "", func(cfg *Config) any { return cfg.LogFormat }, defaults.LogFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			cfg := DefaultConfig()
+			applyEnvironmentVariables(cfg)
+			if got := tt.get(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s=%q gives %#v, want %#v", tt.env, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvironmentOverridesFile(t *testing.T) {
+	dir := t.TempDir()
+	file := map[string]any{
+		"data_path":         filepath.Join(dir, "data"),
+		"repository_path":   filepath.Join(dir, "repository"),
+		"temp_path":         filepath.Join(dir, "tmp"),
+		"max_failed_logins": 3,
+		"lockout_minutes":   20,
+	}
+	encoded, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, encoded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QFT_RELMAN_CONFIG_PATH", configPath)
+	t.Setenv("QFT_RELMAN_MAX_FAILED_LOGINS", "4")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.ConfigFileUsed != configPath {
+		t.Errorf("config file used = %q, want %q", cfg.ConfigFileUsed, configPath)
+	}
+	if cfg.MaxFailedLogins != 4 {
+		t.Errorf("max failed logins = %d, want the environment's 4", cfg.MaxFailedLogins)
+	}
+	if cfg.LockoutMinutes != 20 {
+		t.Errorf("lockout minutes = %d, want the file's 20", cfg.LockoutMinutes)
+	}
+}
+
+func TestPrepareStorageDirectories(t *testing.T) {
+	storageConfig := func(dir string) *Config {
+		cfg := DefaultConfig()
+		cfg.DataPath = filepath.Join(dir, "data")
+		cfg.RepositoryPath = filepath.Join(dir, "repository")
+		cfg.TempPath = filepath.Join(dir, "tmp")
+		return cfg
+	}
+
+	t.Run("missing directories", func(t *testing.T) {
+		cfg := storageConfig(filepath.Join(t.TempDir(), "new"))
+		if err := prepareStorageDirectories(cfg); err != nil {
+			t.Fatal(err)
+		}
+		for _, dir := range []string{cfg.DataPath, cfg.RepositoryPath, cfg.TempPath} {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				t.Errorf("%s was not created: %v", dir, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("write check left files in %s", dir)
+			}
+		}
+	})
+
+	tests := []struct {
+		name       string
+		prepare    func(t *testing.T, cfg *Config) string // Breaks one directory and returns its description
+		wantReason string
+	}{
+		{"path is a file", func(t *testing.T, cfg *Config) string {
+			if err := os.WriteFile(cfg.RepositoryPath, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			return "repository path " + cfg.RepositoryPath
+		}, "cannot be created"},
+		{"parent is a file", func(t *testing.T, cfg *Config) string {
+			if err := os.WriteFile(filepath.Join(filepath.Dir(cfg.DataPath), "blocker"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg.DataPath = filepath.Join(filepath.Dir(cfg.DataPath), "blocker", "data")
+			return "data path " + cfg.DataPath
+		}, "cannot be created"},
+		{"read-only directory", func(t *testing.T, cfg *Config) string {
+			if os.Geteuid() == 0 {
+				t.Skip("root can write to read-only directories")
+			}
+			if err := os.MkdirAll(cfg.TempPath, 0555); err != nil {
+				t.Fatal(err)
+			}
+			return "temp path " + cfg.TempPath
+		}, "is not writable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := storageConfig(t.TempDir())
+			broken := tt.prepare(t, cfg)
+			err := prepareStorageDirectories(cfg)
+			if want := broken + ": " + tt.wantReason; err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("prepareStorageDirectories = %v, want an error containing %q", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// internal/repository/database_test.go - Tests of the lock discipline of the JSON databases.
+//
+// Run with -race: the writers mutate and save the same database at once, and every record
+// must be in the file when it is reopened.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrentWrites runs write n times at once, with readers running alongside.
+func concurrentWrites(t *testing.T, n int, write func(i int) error, read func() error) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := write(i); err != nil {
+				t.Errorf("write %d failed: %v", i, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := read(); err != nil {
+				t.Errorf("read failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestJSONDatabasesConcurrentSaves(t *testing.T) {
+	const writers = 25
+	tests := []struct {
+		name string
+		// write opens the database in dir and writes to it from writers goroutines at once.
+		write func(t *testing.T, dir string)
+		// reopen opens the database in dir again and returns the number of persisted writes.
+		reopen func(t *testing.T, dir string) int
+	}{
+		{
+			name: "releases",
+			write: func(t *testing.T, dir string) {
+				db, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				concurrentWrites(t, writers, func(i int) error {
+					return db.CreateReleaseMetadata(&ReleaseMetadata{SoftwareName: "MyApp", Version: fmt.Sprintf("1.0.%d", i), ReleaseState: "available"})
+				}, func() error {
+					_, err := db.ListAllReleasesMetadata()
+					return err
+				})
+			},
+			reopen: func(t *testing.T, dir string) int {
+				db, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				releases, err := db.ListAllReleasesMetadata()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(releases)
+			},
+		},
+		{
+			name: "download counts",
+			write: func(t *testing.T, dir string) {
+				db, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := db.CreateReleaseMetadata(&ReleaseMetadata{SoftwareName: "MyApp", Version: "1.0.0", ReleaseState: "available"}); err != nil {
+					t.Fatal(err)
+				}
+				concurrentWrites(t, writers, func(int) error {
+					return db.AddDownloadCounts(map[ReleaseRef]int64{{SoftwareName: "MyApp", Version: "1.0.0"}: 1})
+				}, func() error {
+					_, err := db.GetReleaseMetadata("MyApp", "1.0.0")
+					return err
+				})
+			},
+			reopen: func(t *testing.T, dir string) int {
+				db, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				release, err := db.GetReleaseMetadata("MyApp", "1.0.0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return int(release.DownloadCount)
+			},
+		},
+		{
+			name: "packages",
+			write: func(t *testing.T, dir string) {
+				db, err := NewJSONSoftwarePackageDatabase(filepath.Join(dir, "packages.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				concurrentWrites(t, writers, func(i int) error {
+					return db.CreateSoftwarePackage(&SoftwarePackage{Name: fmt.Sprintf("package-%d", i), Enabled: true})
+				}, func() error {
+					_, err := db.ListSoftwarePackages()
+					return err
+				})
+			},
+			reopen: func(t *testing.T, dir string) int {
+				db, err := NewJSONSoftwarePackageDatabase(filepath.Join(dir, "packages.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				packages, err := db.ListSoftwarePackages()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(packages)
+			},
+		},
+		{
+			name: "users",
+			write: func(t *testing.T, dir string) {
+				db, err := NewJSONUserDatabase(filepath.Join(dir, "users.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				concurrentWrites(t, writers, func(i int) error {
+					return db.CreateUser(&User{Username: fmt.Sprintf("user%d", i), Roles: []string{"user"}, Enabled: true})
+				}, func() error {
+					_, err := db.ListUsers()
+					return err
+				})
+			},
+			reopen: func(t *testing.T, dir string) int {
+				db, err := NewJSONUserDatabase(filepath.Join(dir, "users.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				users, err := db.ListUsers()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(users)
+			},
+		},
+		{
+			name: "tokens",
+			write: func(t *testing.T, dir string) {
+				db, err := NewJSONTokenDatabase(filepath.Join(dir, "tokens.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				concurrentWrites(t, writers, func(i int) error {
+					secret := fmt.Sprintf("secret-%d", i)
+					if err := db.CreateAPIToken(&APIToken{ID: fmt.Sprintf("id-%d", i), Token: secret, Username: "admin", CreatedAt: time.Now()}); err != nil {
+						return err
+					}
+					return db.RecordAPITokenUse(secret, time.Now())
+				}, func() error {
+					_, err := db.ListAPITokens()
+					return err
+				})
+			},
+			reopen: func(t *testing.T, dir string) int {
+				db, err := NewJSONTokenDatabase(filepath.Join(dir, "tokens.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens, err := db.ListAPITokens()
+				if err != nil {
+					t.Fatal(err)
+				}
+				used := 0
+				for _, token := range tokens {
+					if token.LastUsedAt != nil {
+						used++
+					}
+				}
+				return used
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.write(t, dir)
+			if got := tt.reopen(t, dir); got != writers {
+				t.Errorf("%d writes persisted after reopening, want %d", got, writers)
+			}
+		})
+	}
+}
+
+func TestJSONUserDatabaseConcurrentUpdates(t *testing.T) {
+	const writers = 25
+	path := filepath.Join(t.TempDir(), "users.json")
+	db, err := NewJSONUserDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateUser(&User{Username: "alice", PasswordHash: "initial", Roles: []string{"user"}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writers update alice and create and delete other users while readers use alice's record
+	// the way authentication does, reading its fields without holding the database lock.
+	concurrentWrites(t, writers, func(i int) error {
+		switch i % 3 {
+		case 0:
+			return db.UpdateUserPassword("alice", fmt.Sprintf("hash-%d", i), i%2 == 0)
+		case 1:
+			return db.EnableDisableUser("alice", i%2 == 0)
+		default:
+			username := fmt.Sprintf("temp%d", i)
+			if err := db.CreateUser(&User{Username: username, Roles: []string{"user"}, Enabled: true}); err != nil {
+				return err
+			}
+			return db.DeleteUser(username)
+		}
+	}, func() error {
+		user, err := db.GetUserByUsername("alice")
+		if err != nil {
+			return err
+		}
+		if user.PasswordHash == "" && user.Enabled && user.MustChangePassword {
+			return fmt.Errorf("impossible user state %+v", user)
+		}
+		return nil
+	})
+
+	reopened, err := NewJSONUserDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := reopened.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("users after reopening = %v, want only alice", users)
+	}
+}
@@ -0,0 +1,87 @@
+// internal/middleware/deprecation.go - Deprecated route warnings.
+//
+// This file implements the middleware that flags configured routes as deprecated
+// with Deprecation, Warning and Sunset headers and logs their continued use.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteDeprecation describes why a route is deprecated and when it goes away.
+type RouteDeprecation struct {
+	Message string `json:"message"` // Warning text sent to clients, e.g. which route to use instead
+	Sunset  string `json:"sunset"`  // Optional HTTP date after which the route may be removed
+}
+
+// validateRouteDeprecations checks that every key is a route template, optionally prefixed
+// with a method, and that sunset dates are HTTP dates.
+func validateRouteDeprecations(deprecations map[string]RouteDeprecation) error {
+	for route, deprecation := range deprecations {
+		template := route
+		if method, rest, ok := strings.Cut(route, " "); ok {
+			if method == "" || strings.ToUpper(method) != method {
+				return fmt.Errorf("deprecated route %q has an invalid method", route)
+			}
+			template = rest
+		}
+		if !strings.HasPrefix(template, "/") {
+			return fmt.Errorf("deprecated route %q must be a path template starting with /", route)
+		}
+		if strings.ContainsAny(deprecation.Message, "\"\r\n") {
+			return fmt.Errorf("deprecated route %q has a message with quotes or line breaks", route)
+		}
+		if deprecation.Sunset != "" {
+			if _, err := http.ParseTime(deprecation.Sunset); err != nil {
+				return fmt.Errorf("deprecated route %q has an invalid sunset date: %w", route, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeprecationMiddleware marks responses of deprecated routes and logs each use. Routes are looked up
+// by their path template, first as "METHOD /template" and then as "/template" for all methods.
+// The request itself is served normally.
+func DeprecationMiddleware(deprecations map[string]RouteDeprecation, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil || len(deprecations) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			deprecation, ok := deprecations[r.Method+" "+template]
+			if !ok {
+				deprecation, ok = deprecations[template]
+			}
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message := deprecation.Message
+			if message == "" {
+				message = "This endpoint is deprecated"
+			}
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Warning", fmt.Sprintf(`299 - "%s"`, message))
+			if deprecation.Sunset != "" {
+				sunset, _ := http.ParseTime(deprecation.Sunset) // Validated at startup
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			logger.Warn("Deprecated route used", "method", r.Method, "route", template, "client_ip", clientIP(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// internal/middleware/deprecation_test.go - Tests of deprecated route warnings.
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeprecatedRoutes(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.DeprecatedRoutes = map[string]RouteDeprecation{
+			"GET /api/v1/packages/{software_name}/releases": {Message: "Use /api/v1/releases instead", Sunset: "Wed, 01 Jul 2026 00:00:00 GMT"},
+			"/api/v1/status": {},
+		}
+	})
+	s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release content"))
+
+	tests := []struct {
+		name        string
+		path        string
+		wantWarning string
+		wantSunset  string
+	}{
+		{"deprecated for one method", "/api/v1/packages/MyApp/releases", `299 - "Use /api/v1/releases instead"`, "Wed, 01 Jul 2026 00:00:00 GMT"},
+		{"deprecated for all methods", "/api/v1/status", `299 - "This endpoint is deprecated"`, ""},
+		{"not deprecated", "/api/v1/packages", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, tt.path, nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want the route to keep working: %s", tt.path, resp.Code, resp.Body)
+			}
+			if got := resp.Header().Get("Warning"); got != tt.wantWarning {
+				t.Errorf("Warning = %q, want %q", got, tt.wantWarning)
+			}
+			if got, want := resp.Header().Get("Deprecation") == "true", tt.wantWarning != ""; got != want {
+				t.Errorf("Deprecation header present = %v, want %v", got, want)
+			}
+			if got := resp.Header().Get("Sunset"); got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+		})
+	}
+}
+
+func TestValidateRouteDeprecations(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   string
+		value   RouteDeprecation
+		wantErr bool
+	}{
+		{"template", "/api/v1/status", RouteDeprecation{}, false},
+		{"method and template", "GET /api/v1/status", RouteDeprecation{Message: "Use /healthz"}, false},
+		{"lower-case method", "get /api/v1/status", RouteDeprecation{}, true},
+		{"relative path", "api/v1/status", RouteDeprecation{}, true},
+		{"quote in message", "/api/v1/status", RouteDeprecation{Message: `Use "healthz"`}, true},
+		{"invalid sunset", "/api/v1/status", RouteDeprecation{Sunset: "next year"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRouteDeprecations(map[string]RouteDeprecation{tt.route: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRouteDeprecations = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+// internal/storage/diskspace_other.go - Free disk space lookup fallback.
+//
+// This file is used on platforms without statfs; the free-disk guard is skipped there.
+
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+// freeDiskSpace reports that free space cannot be determined on this platform.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, ErrDiskSpaceUnsupported
+}
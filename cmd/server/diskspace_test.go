@@ -0,0 +1,103 @@
+// internal/storage/diskspace_test.go - Tests of the free disk space guard on uploads.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	free, err := freeDiskSpace(t.TempDir())
+	if errors.Is(err, ErrDiskSpaceUnsupported) {
+		t.Skip("free disk space lookup is not supported on this platform")
+	}
+	if err != nil || free == 0 {
+		t.Errorf("freeDiskSpace = %d, %v; want the free bytes of the temp filesystem", free, err)
+	}
+	if _, err := freeDiskSpace(t.TempDir() + "/missing"); err == nil {
+		t.Error("freeDiskSpace of a missing path succeeded")
+	}
+}
+
+func TestEnsureStorageAvailable(t *testing.T) {
+	lookupFailure := errors.New("statfs failed")
+	tests := []struct {
+		name      string
+		minFree   int64
+		free      uint64
+		lookupErr error
+		incoming  int64
+		wantErr   error // nil when the upload is accepted
+	}{
+		{"plenty", 1000, 1 << 20, nil, 4096, nil},
+		{"exactly enough", 1000, 5096, nil, 4096, nil},
+		{"one byte short", 1000, 5095, nil, 4096, ErrInsufficientStorage},
+		{"below the minimum", 1000, 500, nil, 0, ErrInsufficientStorage},
+		{"unknown size", 1000, 1000, nil, -1, nil},
+		{"guard disabled", 0, 0, nil, 4096, nil},
+		{"lookup unsupported", 1000, 0, ErrDiskSpaceUnsupported, 4096, nil},
+		{"lookup failed", 1000, 0, lookupFailure, 4096, lookupFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) { cfg.MinFreeDiskBytes = tt.minFree })
+			var checkedPath string
+			s.releaseService.freeSpace = func(path string) (uint64, error) {
+				checkedPath = path
+				return tt.free, tt.lookupErr
+			}
+			err := s.releaseService.EnsureStorageAvailable(tt.incoming)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("EnsureStorageAvailable(%d) = %v, want %v", tt.incoming, err, tt.wantErr)
+			}
+			if tt.minFree > 0 && checkedPath != s.cfg.RepositoryPath {
+				t.Errorf("free space looked up for %q, want the repository path %q", checkedPath, s.cfg.RepositoryPath)
+			}
+		})
+	}
+}
+
+func TestUploadRejectedOnLowDiskSpace(t *testing.T) {
+	content := []byte(strings.Repeat("release content ", 256)) // 4 KiB
+	tests := []struct {
+		name       string
+		free       uint64
+		lookupErr  error
+		wantStatus int
+	}{
+		{"enough space", 1 << 20, nil, http.StatusCreated},
+		{"below the minimum", 500, nil, http.StatusInsufficientStorage},
+		{"no room for the file", 1000 + uint64(len(content))/2, nil, http.StatusInsufficientStorage},
+		{"lookup failed", 0, errors.New("statfs failed"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) { cfg.MinFreeDiskBytes = 1000 })
+			token := s.token("admin", testAdminPassword)
+			s.releaseService.freeSpace = func(string) (uint64, error) { return tt.free, tt.lookupErr }
+
+			resp := s.upload(token, "MyApp", "1.0.0", content, nil)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("upload = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			_, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+			if stored := err == nil; stored != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("release stored = %v after status %d", stored, resp.Code)
+			}
+			if tt.wantStatus == http.StatusCreated {
+				return
+			}
+			if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 0 {
+				t.Errorf("repository holds %v after a rejected upload", files)
+			}
+			if files := listFiles(t, s.cfg.TempPath); len(files) != 0 {
+				t.Errorf("temp directory holds %v after a rejected upload", files)
+			}
+			if strings.Contains(resp.Body.String(), "bytes free") {
+				t.Errorf("response %s exposes the free space", resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// internal/storage/diskspace_unix.go - Free disk space lookup on Unix-like systems.
+//
+// This file reports the space available to unprivileged users on the filesystem holding a path.
+
+//go:build linux || darwin || freebsd
+
+package main
+
+import "syscall"
+
+// freeDiskSpace returns the number of bytes available to unprivileged users on the filesystem holding path.
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,464 @@
+// api/download_test.go - Tests of release download headers.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDownloadHeaders(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.PackageSettings = map[string]PackageSettings{"MyApp": {MutableVersions: []string{"nightly"}}}
+	})
+	token := s.token("admin", testAdminPassword)
+	content := []byte("release content")
+	s.mustUpload(token, "MyApp", "1.0.0", content)
+	s.mustUpload(token, "MyApp", "1.1.0", content)
+	s.mustUpload(token, "MyApp", "nightly", content)
+	yank := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/releases/1.1.0/state", UpdateReleaseStateRequest{State: "yanked"}))
+	if resp := s.do(yank); resp.Code != http.StatusOK {
+		t.Fatalf("yanking 1.1.0 = %d: %s", resp.Code, resp.Body)
+	}
+	immutable := fmt.Sprintf("public, max-age=%d, immutable", s.cfg.DownloadCacheMaxAge)
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		wantStatus       int
+		wantCacheControl string
+		wantFileName     string
+		wantWarning      bool
+	}{
+		{"published release", http.MethodGet, "/api/v1/releases/MyApp/1.0.0", http.StatusOK, immutable, "MyApp_1.0.0.tgz", false},
+		{"published release in other casing", http.MethodGet, "/api/v1/releases/myapp/1.0.0", http.StatusOK, immutable, "MyApp_1.0.0.tgz", false},
+		{"head of published release", http.MethodHead, "/api/v1/releases/MyApp/1.0.0", http.StatusOK, immutable, "MyApp_1.0.0.tgz", false},
+		{"yanked release", http.MethodGet, "/api/v1/releases/MyApp/1.1.0", http.StatusOK, "no-store", "MyApp_1.1.0.tgz", true},
+		{"head of yanked release", http.MethodHead, "/api/v1/releases/MyApp/1.1.0", http.StatusOK, "no-store", "MyApp_1.1.0.tgz", true},
+		{"mutable version", http.MethodGet, "/api/v1/releases/MyApp/nightly", http.StatusOK, "no-cache", "MyApp_nightly.tgz", false},
+		{"latest release", http.MethodGet, "/api/v1/packages/MyApp/latest/download", http.StatusOK, "no-cache", "MyApp_1.0.0.tgz", false},
+		{"missing release", http.MethodGet, "/api/v1/releases/MyApp/9.9.9", http.StatusNotFound, "no-store", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(withToken(s.request(tt.method, tt.path, nil), token))
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, resp.Code, tt.wantStatus, resp.Body)
+			}
+			if got := resp.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if got := resp.Header().Get("Warning") != ""; got != tt.wantWarning {
+				t.Errorf("Warning header present = %v, want %v", got, tt.wantWarning)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := resp.Header().Get("Content-Type"); got != "application/gzip" {
+				t.Errorf("Content-Type = %q, want application/gzip", got)
+			}
+			if got, want := resp.Header().Get("Content-Disposition"), "attachment; filename="+tt.wantFileName; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+			if resp.Header().Get("ETag") == "" {
+				t.Error("ETag is missing")
+			}
+			if tt.method == http.MethodHead && resp.Body.Len() != 0 {
+				t.Errorf("HEAD response has a body of %d bytes", resp.Body.Len())
+			}
+			if tt.method == http.MethodGet && resp.Body.Len() == 0 {
+				t.Error("GET response has no body")
+			}
+		})
+	}
+}
+
+func TestDownloadIfNoneMatch(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token))
+	etag := resp.Header().Get("ETag")
+	sum := sha256.Sum256(resp.Body.Bytes())
+	if want := `"` + hex.EncodeToString(sum[:]) + `"`; etag != want {
+		t.Fatalf("ETag = %s, want the quoted SHA-256 of the file %s", etag, want)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		rangeHeader string
+		wantStatus  int
+	}{
+		{"matching", etag, "", http.StatusNotModified},
+		{"weak matching", "W/" + etag, "", http.StatusNotModified},
+		{"in a list", `"other", ` + etag, "", http.StatusNotModified},
+		{"wildcard", "*", "", http.StatusNotModified},
+		{"different", `"other"`, "", http.StatusOK},
+		{"unquoted", strings.Trim(etag, `"`), "", http.StatusOK},
+		{"matching with a range", etag, "bytes=0-9", http.StatusNotModified},
+		{"different with a range", `"other"`, "bytes=0-9", http.StatusPartialContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			resp := s.do(req)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("If-None-Match %s = %d, want %d", tt.ifNoneMatch, resp.Code, tt.wantStatus)
+			}
+			if got := resp.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %s, want %s", got, etag)
+			}
+			if resp.Code == http.StatusNotModified && resp.Body.Len() != 0 {
+				t.Errorf("304 response has a %d-byte body", resp.Body.Len())
+			}
+		})
+	}
+}
+
+func TestDownloadLatestRelease(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+	setState := func(version string, state string) func(t *testing.T) {
+		return func(t *testing.T) {
+			req := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/releases/"+version+"/state", UpdateReleaseStateRequest{State: state}))
+			if resp := s.do(req); resp.Code != http.StatusOK {
+				t.Fatalf("setting %s to %s = %d: %s", version, state, resp.Code, resp.Body)
+			}
+		}
+	}
+	removeFile := func(version string) func(t *testing.T) {
+		return func(t *testing.T) {
+			release, err := s.releaseService.GetRelease("MyApp", version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)); err != nil {
+				t.Fatal(err)
+			}
+			if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil))); resp.Code != http.StatusOK {
+				t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+			}
+		}
+	}
+
+	// The steps run in order, each withdrawing or restoring a release before downloading the latest one.
+	tests := []struct {
+		name        string
+		change      func(t *testing.T)
+		wantVersion string
+	}{
+		{"highest version", nil, "2.0.0"},
+		{"highest version yanked", setState("2.0.0", "yanked"), "1.2.0"},
+		{"next version unavailable", removeFile("1.2.0"), "1.1.0"},
+		{"yanked version restored", setState("2.0.0", "available"), "2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				tt.change(t)
+			}
+			resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/packages/MyApp/latest/download", nil), token))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("latest download = %d: %s", resp.Code, resp.Body)
+			}
+			if got, want := resp.Header().Get("Content-Location"), "/api/v1/releases/MyApp/"+tt.wantVersion; got != want {
+				t.Errorf("Content-Location = %q, want %q", got, want)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", tt.wantVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(resp.Body.Bytes(), want) {
+				t.Errorf("latest download did not serve the file of %s", tt.wantVersion)
+			}
+		})
+	}
+
+	t.Run("without token", func(t *testing.T) {
+		if resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/latest/download", nil)); resp.Code != http.StatusUnauthorized {
+			t.Errorf("latest download without a token = %d, want %d", resp.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestDownloadByReleaseID(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "MyApp", "1.1.0", []byte("release 1.1.0"))
+	ids := map[string]string{}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		release, err := s.releaseService.GetRelease("MyApp", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := uuid.Parse(release.ID); err != nil {
+			t.Fatalf("release %s has ID %q, want a UUID", version, release.ID)
+		}
+		ids[version] = release.ID
+	}
+	if ids["1.0.0"] == ids["1.1.0"] {
+		t.Fatalf("both releases have ID %s", ids["1.0.0"])
+	}
+	for i := 0; i < 2; i++ {
+		if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil))); resp.Code != http.StatusOK {
+			t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+		}
+	}
+
+	for version, id := range ids {
+		t.Run(version, func(t *testing.T) {
+			release, err := s.releaseService.GetRelease("MyApp", version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.ID != id {
+				t.Errorf("ID changed from %s to %s after reconcile", id, release.ID)
+			}
+			resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/by-id/"+id, nil), token))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("download by ID = %d: %s", resp.Code, resp.Body)
+			}
+			if got, want := resp.Header().Get("Content-Disposition"), "attachment; filename=MyApp_"+version+".tgz"; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+			want, err := os.ReadFile(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(resp.Body.Bytes(), want) {
+				t.Error("download by ID did not serve the release file")
+			}
+		})
+	}
+	t.Run("after reopening", func(t *testing.T) {
+		reopened, err := NewJSONReleaseDatabase(filepath.Join(s.cfg.DataPath, "releases.json"), filepath.Join(s.cfg.DataPath, "software_ids.json"))
+		if err != nil {
+			t.Fatalf("failed to reopen release database: %v", err)
+		}
+		for version, id := range ids {
+			if release, err := reopened.GetReleaseByID(id); err != nil || release.Version != version {
+				t.Errorf("release with ID %s after reopening = %+v, %v; want %s", id, release, err, version)
+			}
+		}
+	})
+	t.Run("unknown ID", func(t *testing.T) {
+		if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/by-id/"+uuid.New().String(), nil), token)); resp.Code != http.StatusNotFound {
+			t.Errorf("download of an unknown ID = %d, want %d", resp.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestDownloadHead(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token))
+
+	head := s.do(withToken(s.request(http.MethodHead, "/api/v1/releases/MyApp/1.0.0", nil), token))
+	if head.Code != http.StatusOK {
+		t.Fatalf("HEAD = %d", head.Code)
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.FormatInt(release.FileSize, 10); got != want || int64(get.Body.Len()) != release.FileSize {
+		t.Errorf("HEAD Content-Length = %s and GET body = %d bytes, want both %s", got, get.Body.Len(), want)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD response has a body of %d bytes", head.Body.Len())
+	}
+	for _, header := range []string{"ETag", "Last-Modified"} {
+		if got, want := head.Header().Get(header), get.Header().Get(header); got == "" || got != want {
+			t.Errorf("HEAD %s = %q, want the GET value %q", header, got, want)
+		}
+	}
+	if missing := s.do(withToken(s.request(http.MethodHead, "/api/v1/releases/MyApp/9.9.9", nil), token)); missing.Code != http.StatusNotFound {
+		t.Errorf("HEAD of a missing release = %d, want 404", missing.Code)
+	}
+}
+
+func TestDownloadRanges(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", largePayload(4096)) // Incompressible, so the file is larger than the ranges
+	full := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)).Body.Bytes()
+	size := len(full)
+
+	tests := []struct {
+		name             string
+		rangeHeader      string
+		wantStatus       int
+		wantContentRange string
+		wantBody         []byte
+	}{
+		{"first 100 bytes", "bytes=0-99", http.StatusPartialContent, fmt.Sprintf("bytes 0-99/%d", size), full[:100]},
+		{"suffix", "bytes=-50", http.StatusPartialContent, fmt.Sprintf("bytes %d-%d/%d", size-50, size-1, size), full[size-50:]},
+		{"open-ended", fmt.Sprintf("bytes=%d-", size-10), http.StatusPartialContent, fmt.Sprintf("bytes %d-%d/%d", size-10, size-1, size), full[size-10:]},
+		{"past the end", fmt.Sprintf("bytes=%d-", size), http.StatusRequestedRangeNotSatisfiable, fmt.Sprintf("bytes */%d", size), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)
+			req.Header.Set("Range", tt.rangeHeader)
+			resp := s.do(req)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("Range %s = %d, want %d", tt.rangeHeader, resp.Code, tt.wantStatus)
+			}
+			if got := resp.Header().Get("Content-Range"); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantContentRange)
+			}
+			if tt.wantBody != nil && !bytes.Equal(resp.Body.Bytes(), tt.wantBody) {
+				t.Errorf("body = %d bytes, want the %d-byte slice of the file", resp.Body.Len(), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestDownloadContentDisposition(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+
+	tests := []struct {
+		softwareName string
+		version      string
+		want         string
+	}{
+		{"MyApp", "1.0.0", "attachment; filename=MyApp_1.0.0.tgz"},
+		{"MyApp", "1.0.0+build.7", "attachment; filename=MyApp_1.0.0+build.7.tgz"},
+		{"My App", "1.0.0", `attachment; filename="My App_1.0.0.tgz"`},
+		{"Äpp", "1.0.0", "attachment; filename*=utf-8''%C3%84pp_1.0.0.tgz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.softwareName+" "+tt.version, func(t *testing.T) {
+			s.mustUpload(token, tt.softwareName, tt.version, []byte("release"))
+			resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/"+url.PathEscape(tt.softwareName)+"/"+url.PathEscape(tt.version), nil), token))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("download = %d: %s", resp.Code, resp.Body)
+			}
+			header := resp.Header().Get("Content-Disposition")
+			if header != tt.want {
+				t.Errorf("Content-Disposition = %q, want %q", header, tt.want)
+			}
+			_, params, err := mime.ParseMediaType(header)
+			if err != nil {
+				t.Fatalf("Content-Disposition %q does not parse: %v", header, err)
+			}
+			release, err := s.releaseService.GetRelease(tt.softwareName, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			storageName := filepath.Base(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release))
+			if params["filename"] != tt.softwareName+"_"+tt.version+".tgz" || params["filename"] == storageName {
+				t.Errorf("offered file name = %q, want the software name and version rather than the storage name %q", params["filename"], storageName)
+			}
+		})
+	}
+}
+
+func TestDownloadOffload(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		prefix     string
+		wantTarget func(filePath string) string // Empty when served directly
+	}{
+		{"disabled", "", "", func(string) string { return "" }},
+		{"X-Accel-Redirect", FileOffloadXAccelRedirect, defaultOffloadPrefix, func(filePath string) string {
+			return "/protected/" + filepath.Base(filepath.Dir(filePath)) + "/" + filepath.Base(filePath)
+		}},
+		{"X-Accel-Redirect with prefix", FileOffloadXAccelRedirect, "/internal/files/", func(filePath string) string {
+			return "/internal/files/" + filepath.Base(filepath.Dir(filePath)) + "/" + filepath.Base(filePath)
+		}},
+		{"X-Sendfile", FileOffloadXSendfile, defaultOffloadPrefix, func(filePath string) string {
+			absPath, _ := filepath.Abs(filePath)
+			return absPath
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) {
+				cfg.FileOffloadHeader = tt.header
+				cfg.FileOffloadPrefix = tt.prefix
+			})
+			token := s.token("admin", testAdminPassword)
+			s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+			release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			filePath := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantTarget := tt.wantTarget(filePath)
+
+			resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("download = %d: %s", resp.Code, resp.Body)
+			}
+			for _, header := range []string{FileOffloadXAccelRedirect, FileOffloadXSendfile} {
+				want := ""
+				if header == tt.header {
+					want = wantTarget
+				}
+				if got := resp.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if wantTarget == "" && !bytes.Equal(resp.Body.Bytes(), content) {
+				t.Errorf("body = %d bytes, want the %d bytes of the release file", resp.Body.Len(), len(content))
+			}
+			if wantTarget != "" && resp.Body.Len() != 0 {
+				t.Errorf("offloaded response has a body of %d bytes, want none", resp.Body.Len())
+			}
+			if got := resp.Header().Get("Content-Disposition"); got != "attachment; filename=MyApp_1.0.0.tgz" {
+				t.Errorf("Content-Disposition = %q, want the release file name", got)
+			}
+			s.releaseService.FlushDownloadCounts()
+			if counted, err := s.releaseService.GetRelease("MyApp", "1.0.0"); err != nil || counted.DownloadCount != 1 {
+				t.Errorf("download count = %+v, %v; want 1", counted, err)
+			}
+
+			// Authentication, revalidation and HEAD are answered before anything is offloaded.
+			if resp := s.do(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil)); resp.Code != http.StatusUnauthorized || resp.Header().Get(tt.header) != "" {
+				t.Errorf("download without a token = %d with %s %q, want 401 without it", resp.Code, tt.header, resp.Header().Get(tt.header))
+			}
+			revalidate := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)
+			revalidate.Header.Set("If-None-Match", release.ETag())
+			if resp := s.do(revalidate); resp.Code != http.StatusNotModified || resp.Header().Get(tt.header) != "" {
+				t.Errorf("revalidation = %d, want 304 without offloading", resp.Code)
+			}
+			head := s.do(withToken(s.request(http.MethodHead, "/api/v1/releases/MyApp/1.0.0", nil), token))
+			if head.Code != http.StatusOK || head.Header().Get(tt.header) != "" || head.Header().Get("Content-Length") != strconv.Itoa(len(content)) {
+				t.Errorf("HEAD = %d with Content-Length %s, want 200 with %d and without offloading", head.Code, head.Header().Get("Content-Length"), len(content))
+			}
+		})
+	}
+}
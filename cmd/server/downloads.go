@@ -0,0 +1,85 @@
+// internal/service/downloads.go - Batched download counting.
+//
+// This file counts release downloads in memory and writes them to the release
+// database in batches, so a burst of downloads costs one metadata save rather
+// than one per download.
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// downloadCountFlushInterval is how long recorded downloads wait before they are written, so
+// downloads arriving in the meantime are saved together.
+const downloadCountFlushInterval = time.Second
+
+// ReleaseRef identifies a release by software name and version.
+type ReleaseRef struct {
+	SoftwareName string
+	Version      string
+}
+
+// DownloadCounter accumulates download counts and writes them to the release database in batches.
+// A write is scheduled once downloads are pending; Flush writes them immediately.
+type DownloadCounter struct {
+	releaseDB ReleaseDatabase
+	interval  time.Duration
+	logger    *slog.Logger
+
+	mu        sync.Mutex
+	pending   map[ReleaseRef]int64
+	scheduled *time.Timer // Non-nil while a write of the pending counts is scheduled
+	flushing  sync.Mutex  // Held while counts are written, so at most one batch is written at a time
+}
+
+// NewDownloadCounter creates a new DownloadCounter instance.
+func NewDownloadCounter(releaseDB ReleaseDatabase, interval time.Duration, logger *slog.Logger) *DownloadCounter {
+	return &DownloadCounter{
+		releaseDB: releaseDB,
+		interval:  interval,
+		logger:    logger,
+		pending:   make(map[ReleaseRef]int64),
+	}
+}
+
+// Record counts one download of a release and schedules a write unless one is already scheduled.
+func (c *DownloadCounter) Record(softwareName string, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[ReleaseRef{SoftwareName: softwareName, Version: version}]++
+	if c.scheduled == nil {
+		c.scheduled = time.AfterFunc(c.interval, c.Flush)
+	}
+}
+
+// Flush writes all pending download counts. Counts that cannot be written stay pending for the next write.
+func (c *DownloadCounter) Flush() {
+	c.flushing.Lock()
+	defer c.flushing.Unlock()
+
+	c.mu.Lock()
+	counts := c.pending
+	c.pending = make(map[ReleaseRef]int64)
+	if c.scheduled != nil {
+		c.scheduled.Stop()
+		c.scheduled = nil
+	}
+	c.mu.Unlock()
+	if len(counts) == 0 {
+		return
+	}
+
+	if err := c.releaseDB.AddDownloadCounts(counts); err != nil {
+		c.logger.Warn("Failed to record release downloads, retrying later", "releases", len(counts), "error", err)
+		c.mu.Lock()
+		for ref, n := range counts {
+			c.pending[ref] += n
+		}
+		if c.scheduled == nil {
+			c.scheduled = time.AfterFunc(c.interval, c.Flush)
+		}
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,159 @@
+// internal/service/downloads_test.go - Tests of download counting.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadCount(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+
+	full := func(path string) *http.Request { return s.request(http.MethodGet, path, nil) }
+	head := func(path string) *http.Request { return s.request(http.MethodHead, path, nil) }
+	ranged := func(path string) *http.Request {
+		req := s.request(http.MethodGet, path, nil)
+		req.Header.Set("Range", "bytes=0-9")
+		return req
+	}
+	tests := []struct {
+		name      string
+		requests  []func(path string) *http.Request
+		wantCount int64
+	}{
+		{"retrieved twice", []func(string) *http.Request{full, full}, 2},
+		{"head requests", []func(string) *http.Request{head, head}, 0},
+		{"partial content", []func(string) *http.Request{ranged, full}, 1},
+		{"never retrieved", nil, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := "1.0." + string(rune('0'+i))
+			s.mustUpload(token, "MyApp", version, []byte("release content of some length"))
+			path := "/api/v1/releases/MyApp/" + version
+			for _, request := range tt.requests {
+				if resp := s.do(withToken(request(path), token)); resp.Code != http.StatusOK && resp.Code != http.StatusPartialContent {
+					t.Fatalf("download = %d: %s", resp.Code, resp.Body)
+				}
+			}
+			s.releaseService.FlushDownloadCounts()
+
+			resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/"+version, nil))
+			var release ReleaseMetadata
+			decodeResponse(t, resp, &release)
+			if release.DownloadCount != tt.wantCount {
+				t.Errorf("download count = %d, want %d", release.DownloadCount, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestDownloadCountNotModified(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	etag := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)).Header().Get("ETag")
+	req := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)
+	req.Header.Set("If-None-Match", etag)
+	if resp := s.do(req); resp.Code != http.StatusNotModified {
+		t.Fatalf("conditional download = %d, want %d", resp.Code, http.StatusNotModified)
+	}
+	s.releaseService.FlushDownloadCounts()
+
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.DownloadCount != 1 {
+		t.Errorf("download count = %d, want 1 for one full download and one 304", release.DownloadCount)
+	}
+}
+
+// countingReleaseDB counts batched download count writes and can fail them.
+type countingReleaseDB struct {
+	ReleaseDatabase
+	mu     sync.Mutex
+	writes int
+	fail   bool
+}
+
+func (db *countingReleaseDB) AddDownloadCounts(counts map[ReleaseRef]int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.writes++
+	if db.fail {
+		return errors.New("disk full")
+	}
+	return db.ReleaseDatabase.AddDownloadCounts(counts)
+}
+
+func TestDownloadCounterBatchesWrites(t *testing.T) {
+	dir := t.TempDir()
+	jsonDB, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		if err := jsonDB.CreateReleaseMetadata(&ReleaseMetadata{SoftwareName: "MyApp", Version: version, ReleaseState: "available"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &countingReleaseDB{ReleaseDatabase: jsonDB, fail: true}
+	counter := NewDownloadCounter(db, time.Hour, discardLogger()) // Only explicit flushes write
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.Record("MyApp", []string{"1.0.0", "1.1.0"}[i%2])
+		}()
+	}
+	wg.Wait()
+	counter.Record("MyApp", "9.9.9") // Deleted before the write, skipped
+
+	counter.Flush() // Fails, the counts stay pending
+	db.fail = false
+	counter.Flush()
+	counter.Flush() // Nothing pending, nothing written
+
+	if db.writes != 2 {
+		t.Errorf("%d writes for 101 downloads, want one failed and one successful batch", db.writes)
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		release, err := jsonDB.GetReleaseMetadata("MyApp", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if release.DownloadCount != 50 {
+			t.Errorf("download count of %s = %d, want 50", version, release.DownloadCount)
+		}
+	}
+}
+
+func TestDownloadCounterWritesAfterInterval(t *testing.T) {
+	dir := t.TempDir()
+	jsonDB, err := NewJSONReleaseDatabase(filepath.Join(dir, "releases.json"), filepath.Join(dir, "software_ids.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonDB.CreateReleaseMetadata(&ReleaseMetadata{SoftwareName: "MyApp", Version: "1.0.0", ReleaseState: "available"}); err != nil {
+		t.Fatal(err)
+	}
+	counter := NewDownloadCounter(jsonDB, 10*time.Millisecond, discardLogger())
+	counter.Record("MyApp", "1.0.0")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if release, _ := jsonDB.GetReleaseMetadata("MyApp", "1.0.0"); release.DownloadCount == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("download count was not written after the flush interval")
+}
@@ -0,0 +1,406 @@
+// internal/backup/export.go - Full repository export and import.
+//
+// This file writes the users, software packages, release metadata and release files of a deployment
+// into a single gzip-compressed tarball and restores such an archive into another deployment's storage.
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportFormatVersion is the version of the archive layout written by ExportRepository.
+const exportFormatVersion = 1
+
+// Names of the documents at the start of an export archive, in the order they are written.
+const (
+	exportManifestName = "manifest.json"
+	exportUsersName    = "users.json"
+	exportPackagesName = "packages.json"
+	exportReleasesName = "releases.json"
+)
+
+// exportFilesDir holds the release files of an archive, named by release ID so no stored name ends up in a path.
+const exportFilesDir = "files/"
+
+// Suffixes of the release files in an archive.
+const (
+	exportTGZSuffix       = ".tgz"
+	exportSBOMSuffix      = ".sbom.json"
+	exportSignatureSuffix = ".minisig"
+)
+
+// ErrStorageNotEmpty is returned when importing into storage that already holds data without forcing it.
+var ErrStorageNotEmpty = errors.New("storage is not empty")
+
+// ErrInvalidArchive is returned when an import archive is malformed or its contents fail verification.
+var ErrInvalidArchive = errors.New("invalid export archive")
+
+// exportManifest is the first document of an archive and identifies its layout.
+type exportManifest struct {
+	FormatVersion int       `json:"format_version"`
+	ServerVersion string    `json:"server_version"` // Version of the server that wrote the archive
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ArchiveSummary counts what an export or import processed.
+type ArchiveSummary struct {
+	Users    int `json:"users"`
+	Packages int `json:"packages"`
+	Releases int `json:"releases"`
+	Files    int `json:"files"` // Release TGZ, SBOM and signature files
+}
+
+// ExportRepository writes all users, software packages, release metadata and release files to w as a
+// gzip-compressed tarball. API tokens are not exported. Releases whose file is missing are exported
+// without it. For a consistent snapshot, nothing should modify the storage while the export runs.
+func ExportRepository(cfg *Config, userDB UserDatabase, releaseDB ReleaseDatabase, packageDB SoftwarePackageDatabase, w io.Writer) (*ArchiveSummary, error) {
+	users, err := userDB.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	packages, err := packageDB.ListSoftwarePackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list software packages: %w", err)
+	}
+	releases, err := releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	for _, release := range releases {
+		if release.ID == "" {
+			return nil, fmt.Errorf("release %s %s has no ID yet, run reconcile first", release.SoftwareName, release.Version)
+		}
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	summary := &ArchiveSummary{Users: len(users), Packages: len(packages), Releases: len(releases)}
+	documents := []struct {
+		name  string
+		value interface{}
+	}{
+		{exportManifestName, exportManifest{FormatVersion: exportFormatVersion, ServerVersion: ServerVersion, CreatedAt: time.Now().UTC()}},
+		{exportUsersName, users},
+		{exportPackagesName, packages},
+		{exportReleasesName, releases},
+	}
+	for _, document := range documents {
+		if err := writeArchiveDocument(tarWriter, document.name, document.value); err != nil {
+			return nil, err
+		}
+	}
+	for _, release := range releases {
+		files := map[string]string{exportTGZSuffix: releaseDB.GetReleaseFilePath(cfg.RepositoryPath, release)}
+		if release.HasSBOM {
+			files[exportSBOMSuffix] = releaseDB.GetReleaseSBOMFilePath(cfg.RepositoryPath, release)
+		}
+		if release.HasSignature {
+			files[exportSignatureSuffix] = releaseDB.GetReleaseSignatureFilePath(cfg.RepositoryPath, release)
+		}
+		for _, suffix := range []string{exportTGZSuffix, exportSBOMSuffix, exportSignatureSuffix} {
+			path, ok := files[suffix]
+			if !ok {
+				continue
+			}
+			written, err := writeArchiveFile(tarWriter, exportFilesDir+release.ID+suffix, path)
+			if err != nil {
+				return nil, fmt.Errorf("release %s %s: %w", release.SoftwareName, release.Version, err)
+			}
+			if written {
+				summary.Files++
+			}
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return summary, nil
+}
+
+// writeArchiveDocument adds a value to the archive as an indented JSON document.
+func writeArchiveDocument(tarWriter *tar.Writer, name string, value interface{}) error {
+	document, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(document)), ModTime: time.Now()}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tarWriter.Write(document); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeArchiveFile adds a file to the archive under name. It reports false when the file does not exist.
+func writeArchiveFile(tarWriter *tar.Writer, name string, path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tarWriter, file); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// exportArchive is the parsed document part of an archive.
+type exportArchive struct {
+	users      []*User
+	packages   []*SoftwarePackage
+	releases   []*ReleaseMetadata
+	releaseIDs map[string]*ReleaseMetadata // Release ID -> metadata
+}
+
+// ImportRepository restores an archive written by ExportRepository. The whole archive is read and
+// verified first, including the checksum of every release file, so a damaged archive leaves the storage
+// untouched. Storage that already holds users, packages, releases or repository files is only replaced
+// when force is set; the existing records and their release files are deleted first.
+func ImportRepository(cfg *Config, userDB UserDatabase, releaseDB ReleaseDatabase, packageDB SoftwarePackageDatabase, archivePath string, force bool) (*ArchiveSummary, error) {
+	archive, err := readExportArchive(archivePath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureImportTarget(cfg, userDB, releaseDB, packageDB, force); err != nil {
+		return nil, err
+	}
+	for _, user := range archive.users {
+		if err := userDB.CreateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to import user %s: %w", user.Username, err)
+		}
+	}
+	for _, software := range archive.packages {
+		if err := packageDB.CreateSoftwarePackage(software); err != nil {
+			return nil, fmt.Errorf("failed to import software package %s: %w", software.Name, err)
+		}
+	}
+	for _, release := range archive.releases {
+		if err := releaseDB.CreateReleaseMetadata(release); err != nil {
+			return nil, fmt.Errorf("failed to import release %s %s: %w", release.SoftwareName, release.Version, err)
+		}
+	}
+
+	summary := &ArchiveSummary{Users: len(archive.users), Packages: len(archive.packages), Releases: len(archive.releases)}
+	_, err = readExportArchive(archivePath, func(release *ReleaseMetadata, suffix string, content io.Reader) error {
+		if err := storeImportedFile(cfg, releaseDB, release, suffix, content); err != nil {
+			return fmt.Errorf("failed to import file of release %s %s: %w", release.SoftwareName, release.Version, err)
+		}
+		summary.Files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// readExportArchive parses and verifies an archive. Release files are passed to storeFile when it is
+// set; otherwise their checksums are verified against the release metadata.
+func readExportArchive(archivePath string, storeFile func(release *ReleaseMetadata, suffix string, content io.Reader) error) (*exportArchive, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	archive := &exportArchive{releaseIDs: make(map[string]*ReleaseMetadata)}
+	var manifest exportManifest
+	documents := []struct {
+		name  string
+		value interface{}
+	}{
+		{exportManifestName, &manifest},
+		{exportUsersName, &archive.users},
+		{exportPackagesName, &archive.packages},
+		{exportReleasesName, &archive.releases},
+	}
+	for _, document := range documents {
+		header, err := tarReader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("%w: missing %s: %v", ErrInvalidArchive, document.name, err)
+		}
+		if header.Name != document.name {
+			return nil, fmt.Errorf("%w: expected %s, found %s", ErrInvalidArchive, document.name, header.Name)
+		}
+		if err := json.NewDecoder(tarReader).Decode(document.value); err != nil {
+			return nil, fmt.Errorf("%w: failed to decode %s: %v", ErrInvalidArchive, document.name, err)
+		}
+		if document.name == exportManifestName && manifest.FormatVersion != exportFormatVersion {
+			return nil, fmt.Errorf("%w: unsupported format version %d, expected %d", ErrInvalidArchive, manifest.FormatVersion, exportFormatVersion)
+		}
+	}
+	for _, release := range archive.releases {
+		if release.ID == "" || archive.releaseIDs[release.ID] != nil {
+			return nil, fmt.Errorf("%w: release %s %s has a missing or duplicate ID", ErrInvalidArchive, release.SoftwareName, release.Version)
+		}
+		archive.releaseIDs[release.ID] = release
+	}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		release, suffix, err := archive.releaseFileEntry(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if storeFile != nil {
+			err = storeFile(release, suffix, tarReader)
+		} else if suffix == exportTGZSuffix {
+			err = verifyImportedReleaseFile(release, tarReader)
+		} else {
+			_, err = io.Copy(io.Discard, tarReader) // Read through so the gzip checksum covers it
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+// releaseFileEntry maps the name of a release file entry to its release and file suffix.
+func (archive *exportArchive) releaseFileEntry(name string) (*ReleaseMetadata, string, error) {
+	for _, suffix := range []string{exportSBOMSuffix, exportSignatureSuffix, exportTGZSuffix} {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(name, exportFilesDir), suffix)
+		if !ok || !strings.HasPrefix(name, exportFilesDir) {
+			continue
+		}
+		if release := archive.releaseIDs[id]; release != nil {
+			return release, suffix, nil
+		}
+		return nil, "", fmt.Errorf("%w: %s belongs to no release", ErrInvalidArchive, name)
+	}
+	return nil, "", fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, name)
+}
+
+// verifyImportedReleaseFile checks a release TGZ file against the primary checksum of its metadata.
+// Releases recorded as corrupt are known not to match and are restored as they are.
+func verifyImportedReleaseFile(release *ReleaseMetadata, content io.Reader) error {
+	digests, err := computeDigests(content, []string{release.PrimaryChecksumAlgorithm()})
+	if err != nil {
+		return err
+	}
+	return checkImportedDigests(release, digests)
+}
+
+// checkImportedDigests compares the digests of an imported release file with its metadata.
+func checkImportedDigests(release *ReleaseMetadata, digests map[string]string) error {
+	if release.Checksum == "" || release.ReleaseState == "corrupt" {
+		return nil
+	}
+	if digests[release.PrimaryChecksumAlgorithm()] != release.Checksum {
+		return fmt.Errorf("%w: checksum mismatch for release %s %s", ErrInvalidArchive, release.SoftwareName, release.Version)
+	}
+	return nil
+}
+
+// storeImportedFile writes a release file from an archive into the repository.
+func storeImportedFile(cfg *Config, releaseDB ReleaseDatabase, release *ReleaseMetadata, suffix string, content io.Reader) error {
+	if suffix == exportTGZSuffix {
+		stored, err := releaseDB.StoreReleaseFile(cfg.RepositoryPath, content, release, []string{release.PrimaryChecksumAlgorithm()})
+		if err != nil {
+			return err
+		}
+		if err := checkImportedDigests(release, stored.Digests); err != nil { // The archive changed since it was verified
+			os.Remove(stored.Path)
+			return err
+		}
+		return nil
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	if suffix == exportSBOMSuffix {
+		return releaseDB.StoreReleaseSBOM(cfg.RepositoryPath, release, data)
+	}
+	return releaseDB.StoreReleaseSignature(cfg.RepositoryPath, release, data)
+}
+
+// ensureImportTarget checks that the storage is empty, or with force empties it by deleting every user,
+// software package and release together with the release files.
+func ensureImportTarget(cfg *Config, userDB UserDatabase, releaseDB ReleaseDatabase, packageDB SoftwarePackageDatabase, force bool) error {
+	users, err := userDB.ListUsers()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	packages, err := packageDB.ListSoftwarePackages()
+	if err != nil {
+		return fmt.Errorf("failed to list software packages: %w", err)
+	}
+	releases, err := releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+	entries, err := os.ReadDir(cfg.RepositoryPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read repository directory: %w", err)
+	}
+	if len(users)+len(packages)+len(releases)+len(entries) == 0 {
+		return nil
+	}
+	if !force {
+		return fmt.Errorf("%w: %d users, %d software packages, %d releases and %d entries in %s; use --force to replace them",
+			ErrStorageNotEmpty, len(users), len(packages), len(releases), len(entries), filepath.Clean(cfg.RepositoryPath))
+	}
+
+	softwareNames := make(map[string]bool)
+	for _, release := range releases {
+		if err := releaseDB.DeleteReleaseFile(cfg.RepositoryPath, release); err != nil {
+			return err
+		}
+		if err := releaseDB.DeleteReleaseMetadata(release.SoftwareName, release.Version); err != nil {
+			return err
+		}
+		softwareNames[release.SoftwareName] = true
+	}
+	for softwareName := range softwareNames {
+		releaseDB.RemoveSoftwareDir(cfg.RepositoryPath, softwareName) // Best effort: a directory holding unknown files stays
+	}
+	for _, software := range packages {
+		if err := packageDB.DeleteSoftwarePackage(software.Name); err != nil {
+			return err
+		}
+	}
+	for _, user := range users {
+		if err := userDB.DeleteUser(user.Username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
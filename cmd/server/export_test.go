@@ -0,0 +1,224 @@
+// internal/backup/export_test.go - Tests of the repository export and import.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testStorage is a deployment's storage without a server in front of it.
+type testStorage struct {
+	cfg       *Config
+	userDB    UserDatabase
+	releaseDB ReleaseDatabase
+	packageDB SoftwarePackageDatabase
+}
+
+// newTestStorage opens empty JSON databases and repository directories in a temporary directory.
+func newTestStorage(t *testing.T) *testStorage {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.DataPath = filepath.Join(dir, "data")
+	cfg.RepositoryPath = filepath.Join(dir, "repository")
+	cfg.TempPath = filepath.Join(dir, "tmp")
+	if err := prepareStorageDirectories(cfg); err != nil {
+		t.Fatalf("failed to prepare storage directories: %v", err)
+	}
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		t.Fatalf("failed to open databases: %v", err)
+	}
+	t.Cleanup(closeDatabases)
+	return &testStorage{cfg: cfg, userDB: userDB, releaseDB: releaseDB, packageDB: packageDB}
+}
+
+// exportTestServer exports a test server's storage into an archive file and returns its path.
+func exportTestServer(t *testing.T, s *testServer) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "backup.tar.gz")
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if _, err := ExportRepository(s.cfg, s.userDB, s.releaseDB, s.packageDB, archive); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	return archivePath
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	sbom := map[string]string{"sbom": `{"bomFormat":"CycloneDX","specVersion":"1.5"}`}
+	if resp := s.upload(token, "MyApp", "1.1.0", []byte("release 1.1.0"), sbom); resp.Code != http.StatusCreated {
+		t.Fatalf("uploading with an SBOM = %d: %s", resp.Code, resp.Body)
+	}
+	s.mustUpload(token, "Other", "2.0.0", []byte("other release"))
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "Planned", Description: "Not released yet"}))); resp.Code != http.StatusCreated {
+		t.Fatalf("creating a package = %d: %s", resp.Code, resp.Body)
+	}
+	archivePath := exportTestServer(t, s)
+
+	target := newTestStorage(t)
+	summary, err := ImportRepository(target.cfg, target.userDB, target.releaseDB, target.packageDB, archivePath, false)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if want := (ArchiveSummary{Users: 2, Packages: 1, Releases: 3, Files: 4}); *summary != want {
+		t.Errorf("import summary = %+v, want %+v", *summary, want)
+	}
+
+	users, err := target.userDB.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("imported %d users, want 2", len(users))
+	}
+	alice, err := target.userDB.GetUserByUsername("alice")
+	if err != nil {
+		t.Fatalf("alice was not imported: %v", err)
+	}
+	if !CompareHashAndPassword(alice.PasswordHash, "Alice-Pass-123") {
+		t.Error("alice's password hash was not imported")
+	}
+	if planned, err := target.packageDB.GetSoftwarePackage("Planned"); err != nil || planned.Description != "Not released yet" {
+		t.Errorf("package definition was not imported: %+v, %v", planned, err)
+	}
+	sourceReleases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range sourceReleases {
+		imported, err := target.releaseDB.GetReleaseMetadata(source.SoftwareName, source.Version)
+		if err != nil {
+			t.Errorf("release %s %s was not imported: %v", source.SoftwareName, source.Version, err)
+			continue
+		}
+		got, _ := json.Marshal(imported) // Compares timestamps without their monotonic clock reading
+		want, _ := json.Marshal(source)
+		if !bytes.Equal(got, want) {
+			t.Errorf("imported metadata = %s, want %s", got, want)
+		}
+		want, err = os.ReadFile(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = os.ReadFile(target.releaseDB.GetReleaseFilePath(target.cfg.RepositoryPath, imported))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("release file of %s %s = %q, %v; want %q", source.SoftwareName, source.Version, got, err, want)
+		}
+		if source.HasSBOM {
+			if _, err := os.Stat(target.releaseDB.GetReleaseSBOMFilePath(target.cfg.RepositoryPath, imported)); err != nil {
+				t.Errorf("SBOM of %s %s was not imported: %v", source.SoftwareName, source.Version, err)
+			}
+		}
+	}
+}
+
+func TestImportRefusesNonEmptyStorage(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	archivePath := exportTestServer(t, s)
+
+	target := newTestStorage(t)
+	if err := target.userDB.CreateUser(&User{Username: "existing", Roles: []string{"user"}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportRepository(target.cfg, target.userDB, target.releaseDB, target.packageDB, archivePath, false); !errors.Is(err, ErrStorageNotEmpty) {
+		t.Fatalf("import into non-empty storage = %v, want ErrStorageNotEmpty", err)
+	}
+	if _, err := target.userDB.GetUserByUsername("existing"); err != nil {
+		t.Errorf("refused import changed the storage: %v", err)
+	}
+
+	if _, err := ImportRepository(target.cfg, target.userDB, target.releaseDB, target.packageDB, archivePath, true); err != nil {
+		t.Fatalf("forced import failed: %v", err)
+	}
+	if _, err := target.userDB.GetUserByUsername("existing"); err == nil {
+		t.Error("forced import kept the existing user")
+	}
+	if _, err := target.releaseDB.GetReleaseMetadata("MyApp", "1.0.0"); err != nil {
+		t.Errorf("forced import did not restore the release: %v", err)
+	}
+}
+
+// rewriteArchive copies an archive, passing the content of each release file through change.
+func rewriteArchive(t *testing.T, archivePath string, change func(content []byte) []byte) string {
+	t.Helper()
+	source, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	gzipReader, err := gzip.NewReader(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rewritten bytes.Buffer
+	gzipWriter := gzip.NewWriter(&rewritten)
+	tarWriter := tar.NewWriter(gzipWriter)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(header.Name, exportTGZSuffix) {
+			content = change(content)
+			header.Size = int64(len(content))
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write(content)
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	path := filepath.Join(t.TempDir(), "rewritten.tar.gz")
+	if err := os.WriteFile(path, rewritten.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRejectsTamperedReleaseFile(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	tampered := rewriteArchive(t, exportTestServer(t, s), func(content []byte) []byte {
+		return append(content, "tampered"...)
+	})
+
+	target := newTestStorage(t)
+	if _, err := ImportRepository(target.cfg, target.userDB, target.releaseDB, target.packageDB, tampered, false); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("import of a tampered archive = %v, want ErrInvalidArchive", err)
+	}
+	if users, _ := target.userDB.ListUsers(); len(users) != 0 {
+		t.Errorf("rejected import created %d users", len(users))
+	}
+	if releases, _ := target.releaseDB.ListAllReleasesMetadata(); len(releases) != 0 {
+		t.Errorf("rejected import created %d releases", len(releases))
+	}
+}
@@ -0,0 +1,98 @@
+// internal/api/fetch.go - Remote release file retrieval.
+//
+// This file downloads release files referenced by the file_url upload field,
+// restricting schemes, following a bounded number of redirects and enforcing
+// the upload size limit.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ErrInvalidFileURL is returned when file_url is malformed or uses a scheme other than http/https.
+var ErrInvalidFileURL = errors.New("invalid file_url")
+
+// UpstreamStatusError reports a non-2xx response from the server hosting a file_url.
+type UpstreamStatusError struct {
+	StatusCode int
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream returned status %d", e.StatusCode)
+}
+
+// NewFileURLClient creates the HTTP client used to fetch file_url uploads.
+func NewFileURLClient(cfg *Config) *http.Client {
+	maxRedirects := cfg.FileURLMaxRedirects
+	return &http.Client{
+		Timeout: time.Duration(cfg.FileURLTimeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if err := validateFileURLScheme(req.URL); err != nil {
+				return err // Never follow a redirect to a local scheme
+			}
+			return nil
+		},
+	}
+}
+
+// fetchReleaseFile downloads fileURL into dir and returns the local file path.
+// At most maxBytes are accepted; larger payloads fail with an *http.MaxBytesError.
+func fetchReleaseFile(client *http.Client, fileURL string, dir string, maxBytes int64) (string, error) {
+	parsedURL, err := url.Parse(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFileURL, err)
+	}
+	if err := validateFileURLScheme(parsedURL); err != nil {
+		return "", err
+	}
+
+	resp, err := client.Get(parsedURL.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch file_url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > maxBytes {
+		return "", &http.MaxBytesError{Limit: maxBytes}
+	}
+
+	fileName := path.Base(parsedURL.Path)
+	if fileName == "." || fileName == "/" {
+		fileName = "release-file" // URL has no usable file name
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	filePath := filepath.Join(dir, fileName)
+
+	limited := &io.LimitedReader{R: resp.Body, N: maxBytes + 1} // One extra byte detects oversized bodies
+	if err := writeToFile(limited, filePath); err != nil {
+		return "", err
+	}
+	if limited.N == 0 {
+		return "", &http.MaxBytesError{Limit: maxBytes}
+	}
+	return filePath, nil
+}
+
+// validateFileURLScheme only allows remote http(s) URLs, rejecting file:// and other local schemes.
+func validateFileURLScheme(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: only http and https URLs are allowed", ErrInvalidFileURL)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// internal/api/fetch_test.go - Tests of remote release file retrieval.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uploadFromURL posts a release whose file the server fetches from fileURL.
+func (s *testServer) uploadFromURL(token string, version string, fileURL string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("software_name", "MyApp")
+	form.WriteField("version", version)
+	form.WriteField("file_url", fileURL)
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/releases", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.do(withToken(req, token))
+}
+
+// archivedContent returns the size recorded in the tar header of a stored release and its content.
+func archivedContent(t *testing.T, path string) (int64, []byte) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(tarReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header.Size, content
+}
+
+func TestUploadFromFileURL(t *testing.T) {
+	payload := bytes.Repeat([]byte("remote release "), 10000)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/myapp.bin":
+			w.Write(payload)
+		case strings.HasPrefix(r.URL.Path, "/redirect/"): // /redirect/N redirects N times before serving the payload
+			hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+			target := "/myapp.bin"
+			if hops > 1 {
+				target = fmt.Sprintf("/redirect/%d", hops-1)
+			}
+			http.Redirect(w, r, target, http.StatusFound)
+		case r.URL.Path == "/to-file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	s := newTestServer(t, func(cfg *Config) { cfg.FileURLMaxRedirects = 3 })
+	token := s.token("admin", testAdminPassword)
+
+	tests := []struct {
+		name       string
+		fileURL    string
+		wantStatus int
+		wantError  string // Part of the error message
+	}{
+		{"payload", upstream.URL + "/myapp.bin", http.StatusCreated, ""},
+		{"redirects within the limit", upstream.URL + "/redirect/3", http.StatusCreated, ""},
+		{"too many redirects", upstream.URL + "/redirect/4", http.StatusBadGateway, ""},
+		{"upstream error", upstream.URL + "/missing.bin", http.StatusBadGateway, "status 404"},
+		{"file scheme", "file:///etc/passwd", http.StatusBadRequest, "only http and https"},
+		{"ftp scheme", "ftp://example.com/myapp.bin", http.StatusBadRequest, "only http and https"},
+		{"redirect to a file URL", upstream.URL + "/to-file", http.StatusBadRequest, "only http and https"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := fmt.Sprintf("1.%d.0", i)
+			resp := s.uploadFromURL(token, version, tt.fileURL)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("upload = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantError) {
+				t.Errorf("error %s does not mention %q", resp.Body, tt.wantError)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", version)
+			if tt.wantStatus != http.StatusCreated {
+				if err == nil {
+					t.Error("failed fetch stored a release")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != release.FileSize {
+				t.Errorf("stored file size = %d, want the recorded %d", info.Size(), release.FileSize)
+			}
+			size, content := archivedContent(t, path)
+			if size != int64(len(payload)) || !bytes.Equal(content, payload) {
+				t.Errorf("archived file of %d bytes differs from the %d byte payload", size, len(payload))
+			}
+		})
+	}
+}
+
+func TestUploadFromFileURLSizeLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 4096)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		}
+		w.Write(payload)
+		w.(http.Flusher).Flush() // Sent without a length when chunked
+	}))
+	defer upstream.Close()
+
+	s := newTestServer(t, func(cfg *Config) { cfg.MaxUploadBytes = 1024 })
+	token := s.token("admin", testAdminPassword)
+	for _, query := range []string{"", "?chunked=1"} {
+		if resp := s.uploadFromURL(token, "1.0.0", upstream.URL+"/myapp.bin"+query); resp.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("fetching an oversized file%s = %d, want 413: %s", query, resp.Code, resp.Body)
+		}
+	}
+	if files := listFiles(t, s.cfg.TempPath); len(files) != 0 {
+		t.Errorf("rejected fetches left temporary files %v", files)
+	}
+}
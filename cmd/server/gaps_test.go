@@ -0,0 +1,69 @@
+// api/gaps_test.go - Tests of the version gap report.
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestVersionGaps(t *testing.T) {
+	patchGap := func(line, from, to string) VersionGap {
+		return VersionGap{Line: line, Kind: "patch", MissingFrom: from, MissingTo: to}
+	}
+	minorGap := func(line, from, to string) VersionGap {
+		return VersionGap{Line: line, Kind: "minor", MissingFrom: from, MissingTo: to}
+	}
+	tests := []struct {
+		name     string
+		versions []string
+		want     []VersionGap
+	}{
+		{"contiguous", []string{"1.0.0", "1.0.1", "1.0.2", "1.1.0", "1.1.1", "2.0.0"}, []VersionGap{}},
+		{"single release", []string{"1.4.2"}, []VersionGap{}},
+		{"skipped patch", []string{"1.0.0", "1.0.2"}, []VersionGap{patchGap("1.0", "1.0.1", "1.0.1")}},
+		{"skipped patches", []string{"1.0.4", "1.0.0"}, []VersionGap{patchGap("1.0", "1.0.1", "1.0.3")}},
+		{"skipped minors", []string{"1.0.0", "1.3.0"}, []VersionGap{minorGap("1", "1.1", "1.2")}},
+		{"skipped major is not a gap", []string{"1.0.0", "3.0.0"}, []VersionGap{}},
+		{"pre-release does not fill a gap", []string{"1.0.0", "1.0.1-rc.1", "1.0.2"}, []VersionGap{patchGap("1.0", "1.0.1", "1.0.1")}},
+		{"build metadata counts once", []string{"1.0.0", "1.0.1+build.1", "1.0.1+build.2", "1.0.2"}, []VersionGap{}},
+		{"several lines", []string{"2.0.0", "1.2.0", "1.2.3", "1.0.0", "2.0.1", "2.0.5"}, []VersionGap{
+			minorGap("1", "1.1", "1.1"),
+			patchGap("1.2", "1.2.1", "1.2.2"),
+			patchGap("2.0", "2.0.2", "2.0.4"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			token := s.token("admin", testAdminPassword)
+			for _, version := range tt.versions {
+				s.mustUpload(token, "MyApp", version, []byte("release "+version))
+			}
+			resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/myapp/gaps", nil)))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			var report VersionGapsReport
+			decodeResponse(t, resp, &report)
+			if report.SoftwareName != "MyApp" {
+				t.Errorf("software name = %q, want MyApp", report.SoftwareName)
+			}
+			if !reflect.DeepEqual(report.Gaps, tt.want) {
+				t.Errorf("gaps = %+v, want %+v", report.Gaps, tt.want)
+			}
+		})
+	}
+
+	t.Run("access", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		s.createUser("alice", "Alice-Pass-123", "publisher")
+		s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release"))
+		if resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/NoSuchApp/gaps", nil))); resp.Code != http.StatusNotFound {
+			t.Errorf("gaps of a missing package = %d, want %d", resp.Code, http.StatusNotFound)
+		}
+		if resp := s.do(withBasicAuth(s.request(http.MethodGet, "/api/v1/admin/packages/MyApp/gaps", nil), "alice", "Alice-Pass-123")); resp.Code != http.StatusForbidden {
+			t.Errorf("gaps as a publisher = %d, want %d", resp.Code, http.StatusForbidden)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// api/health_test.go - Tests of the liveness and readiness probes.
+package main
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestHealthProbes(t *testing.T) {
+	s := newTestServer(t, nil)
+	probe := func(path string) (int, map[string]string) {
+		resp := s.do(s.request(http.MethodGet, path, nil)) // Probes need no credentials
+		var body map[string]string
+		decodeResponse(t, resp, &body)
+		return resp.Code, body
+	}
+
+	tests := []struct {
+		name       string
+		setup      func()
+		wantLive   int
+		wantReady  int
+		wantStatus string
+	}{
+		{"starting", func() {}, http.StatusOK, http.StatusServiceUnavailable, "starting"},
+		{"ready", s.readiness.MarkReady, http.StatusOK, http.StatusOK, "ready"},
+		{"shutting down", s.shutdownState.Begin, http.StatusOK, http.StatusServiceUnavailable, "shutting down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			if code, _ := probe("/healthz"); code != tt.wantLive {
+				t.Errorf("/healthz = %d, want %d", code, tt.wantLive)
+			}
+			code, body := probe("/readyz")
+			if code != tt.wantReady || body["status"] != tt.wantStatus {
+				t.Errorf("/readyz = %d %v, want %d with status %q", code, body, tt.wantReady, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDeepHealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		deep          bool
+		canary        string // Version of MyApp configured as canary, newest release if empty
+		uploads       []string
+		removeFile    string // Version whose release file is deleted
+		wantReady     int
+		wantStatusMsg string
+	}{
+		{"canary present", true, "1.0.0", []string{"1.0.0", "1.1.0"}, "1.1.0", http.StatusOK, "ready"},
+		{"canary file missing", true, "1.0.0", []string{"1.0.0", "1.1.0"}, "1.0.0", http.StatusServiceUnavailable, "not ready"},
+		{"canary release missing", true, "9.9.9", []string{"1.0.0"}, "", http.StatusServiceUnavailable, "not ready"},
+		{"newest release present", true, "", []string{"1.0.0", "1.1.0"}, "1.0.0", http.StatusOK, "ready"},
+		{"newest release file missing", true, "", []string{"1.0.0", "1.1.0"}, "1.1.0", http.StatusServiceUnavailable, "not ready"},
+		{"empty repository", true, "", nil, "", http.StatusOK, "ready"},
+		{"deep check disabled", false, "", []string{"1.0.0"}, "1.0.0", http.StatusOK, "ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) {
+				cfg.DeepHealthCheck = tt.deep
+				if tt.canary != "" {
+					cfg.HealthCanarySoftware, cfg.HealthCanaryVersion = "MyApp", tt.canary
+				}
+			})
+			token := s.token("admin", testAdminPassword)
+			for _, version := range tt.uploads {
+				s.mustUpload(token, "MyApp", version, []byte("release "+version))
+			}
+			if tt.removeFile != "" {
+				release, err := s.releaseService.GetRelease("MyApp", tt.removeFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Remove(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.readiness.MarkReady()
+
+			resp := s.do(s.request(http.MethodGet, "/readyz", nil))
+			var body map[string]string
+			decodeResponse(t, resp, &body)
+			if resp.Code != tt.wantReady || body["status"] != tt.wantStatusMsg {
+				t.Errorf("/readyz = %d %v, want %d with status %q", resp.Code, body, tt.wantReady, tt.wantStatusMsg)
+			}
+			if (tt.wantReady != http.StatusOK) != (body["error"] != "") {
+				t.Errorf("error = %q, want one only when not ready", body["error"])
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// api/helpers_test.go - Test fixtures.
+//
+// This file builds a complete server on temporary storage, wired like main, and provides
+// helpers to create users, obtain API tokens and upload releases through the HTTP API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testAdminPassword is the password of the administrator every test server starts with.
+const testAdminPassword = "Admin-Pass-123"
+
+// testServer is a server on temporary storage with direct access to its services.
+type testServer struct {
+	t              *testing.T
+	cfg            *Config
+	userDB         UserDatabase
+	releaseDB      ReleaseDatabase
+	packageDB      SoftwarePackageDatabase
+	tokenDB        TokenDatabase
+	releaseService *ReleaseService
+	userService    *UserService
+	authService    *AuthService
+	readiness      *ReadinessState
+	shutdownState  *ShutdownState
+	handler        http.Handler
+}
+
+// newTestServer starts a server on the JSON backend in a temporary directory. configure, if set,
+// adjusts the default configuration before the databases are opened.
+func newTestServer(t *testing.T, configure func(cfg *Config)) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.DataPath = filepath.Join(dir, "data")
+	cfg.RepositoryPath = filepath.Join(dir, "repository")
+	cfg.TempPath = filepath.Join(dir, "tmp")
+	cfg.AuditLogPath = ""
+	cfg.MinFreeDiskBytes = 0        // The test machine's free space must not decide the outcome
+	cfg.BcryptCost = bcrypt.MinCost // Keeps logins fast
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("invalid test configuration: %v", err)
+	}
+	if err := prepareStorageDirectories(cfg); err != nil {
+		t.Fatalf("failed to prepare storage directories: %v", err)
+	}
+
+	logger := discardLogger()
+	SetPasswordHashCost(cfg.BcryptCost)
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		t.Fatalf("failed to open databases: %v", err)
+	}
+	t.Cleanup(closeDatabases)
+	tokenDB, err := NewJSONTokenDatabase(filepath.Join(cfg.DataPath, "tokens.json"))
+	if err != nil {
+		t.Fatalf("failed to open token database: %v", err)
+	}
+
+	releaseService := NewReleaseService(cfg, releaseDB, packageDB, logger)
+	t.Cleanup(releaseService.FlushDownloadCounts) // Background writes must finish before the directory is removed
+	userService := NewUserService(userDB, tokenDB, logger)
+	authenticator, err := NewAuthenticator(cfg, userService, logger)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	authService := NewAuthService(cfg, userService, authenticator, tokenDB, logger)
+	auditLog, err := OpenAuditLog(cfg.AuditLogPath, logger) // Disabled unless configure sets a path
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+
+	router := mux.NewRouter()
+	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	notice := NewServerNotice("")
+	readiness := NewReadinessState()
+	shutdownState := NewShutdownState()
+	SetupHealthRoutes(router, cfg, releaseService, readiness, shutdownState, logger)
+	SetupPublicRoutes(apiRouter, cfg, releaseService, userService, notice, logger)
+	SetupAdminRoutes(apiRouter, cfg, releaseService, userService, authService, NewIdempotencyCache(time.Minute), auditLog, notice, logger)
+	SetupUserRoutes(apiRouter, userService, authService, logger)
+	SetupTokenRoutes(apiRouter, cfg, releaseService, authService, shutdownState, logger)
+	router.Use(MaxBodyBytesMiddleware(cfg.MaxJSONBodyBytes))
+	router.Use(notice.Middleware)
+	router.Use(DeprecationMiddleware(cfg.DeprecatedRoutes, logger))
+	router.Use(PathParamsMiddleware)
+
+	s := &testServer{
+		t:              t,
+		cfg:            cfg,
+		userDB:         userDB,
+		releaseDB:      releaseDB,
+		packageDB:      packageDB,
+		tokenDB:        tokenDB,
+		releaseService: releaseService,
+		userService:    userService,
+		authService:    authService,
+		readiness:      readiness,
+		shutdownState:  shutdownState,
+		handler:        router,
+	}
+	s.createUser("admin", testAdminPassword, "administrator")
+	return s
+}
+
+// createUser creates an enabled user that does not have to change its password.
+func (s *testServer) createUser(username string, password string, roles ...string) {
+	s.t.Helper()
+	if err := s.userService.CreateUser(&User{Username: username, Roles: roles, Enabled: true}, password); err != nil {
+		s.t.Fatalf("failed to create user %s: %v", username, err)
+	}
+}
+
+// do serves a request and returns the recorded response.
+func (s *testServer) do(req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// request builds a request with an optional JSON body.
+func (s *testServer) request(method string, path string, body any) *http.Request {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// asAdmin authenticates a request with the administrator's Basic Auth credentials.
+func asAdmin(req *http.Request) *http.Request {
+	return withBasicAuth(req, "admin", testAdminPassword)
+}
+
+// withBasicAuth authenticates a request with a user's Basic Auth credentials.
+func withBasicAuth(req *http.Request, username string, password string) *http.Request {
+	req.SetBasicAuth(username, password)
+	return req
+}
+
+// withToken authenticates a request with an API token.
+func withToken(req *http.Request, token string) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// token creates an API token for the user and returns its secret.
+func (s *testServer) token(username string, password string) string {
+	s.t.Helper()
+	req := s.request(http.MethodPost, "/api/v1/auth/token", nil)
+	req.SetBasicAuth(username, password)
+	resp := s.do(req)
+	if resp.Code != http.StatusCreated {
+		s.t.Fatalf("creating a token for %s: status %d: %s", username, resp.Code, resp.Body)
+	}
+	var created CreateAPITokenResponse
+	decodeResponse(s.t, resp, &created)
+	return created.APIKey
+}
+
+// upload posts a release through the upload endpoint. fields are sent as additional form fields.
+func (s *testServer) upload(token string, softwareName string, version string, content []byte, fields map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("software_name", softwareName)
+	form.WriteField("version", version)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile("file", "release.bin")
+	if err != nil {
+		s.t.Fatalf("failed to build upload form: %v", err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/releases", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.do(withToken(req, token))
+}
+
+// mustUpload uploads a release and fails the test unless it was created.
+func (s *testServer) mustUpload(token string, softwareName string, version string, content []byte) {
+	s.t.Helper()
+	if resp := s.upload(token, softwareName, version, content, nil); resp.Code != http.StatusCreated {
+		s.t.Fatalf("uploading %s %s: status %d: %s", softwareName, version, resp.Code, resp.Body)
+	}
+}
+
+// discardLogger returns a logger that drops all records.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// decodeResponse decodes a JSON response body into v.
+func decodeResponse(t *testing.T, resp *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(resp.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", resp.Body, err)
+	}
+}
@@ -0,0 +1,139 @@
+// internal/middleware/idempotency.go - Idempotency-Key support for mutating requests.
+//
+// This file caches the response of the first POST request carrying a given
+// Idempotency-Key and replays it for retries within a configurable window.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client-chosen idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentResponse is a recorded response, or a placeholder while the first request is in flight.
+type idempotentResponse struct {
+	requestHash [32]byte
+	inFlight    bool
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// IdempotencyCache stores recorded responses keyed by user, method, path and idempotency key.
+type IdempotencyCache struct {
+	window    time.Duration
+	responses map[string]*idempotentResponse
+	mu        sync.Mutex
+}
+
+// NewIdempotencyCache creates a new IdempotencyCache replaying responses for the given window.
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:    window,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// Middleware replays the recorded response for POST requests that repeat an Idempotency-Key.
+// Reusing a key with a different request body is rejected with 422, and a retry that arrives
+// while the first request is still running gets 409. Server errors are not recorded so they can be retried.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost || c.window <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		username, _ := GetUsernameFromContext(r.Context())
+		cacheKey := username + " " + r.Method + " " + r.URL.Path + " " + key
+		requestHash := sha256.Sum256(body)
+
+		now := time.Now()
+		c.mu.Lock()
+		c.purgeExpiredLocked(now)
+		if recorded, ok := c.responses[cacheKey]; ok {
+			c.mu.Unlock()
+			switch {
+			case recorded.requestHash != requestHash:
+				respondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+			case recorded.inFlight:
+				respondError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				for name, values := range recorded.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(recorded.status)
+				w.Write(recorded.body)
+			}
+			return
+		}
+		c.responses[cacheKey] = &idempotentResponse{requestHash: requestHash, inFlight: true, expiresAt: now.Add(c.window)}
+		c.mu.Unlock()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if recorder.status >= http.StatusInternalServerError {
+			delete(c.responses, cacheKey) // Let the client retry server errors
+			return
+		}
+		c.responses[cacheKey] = &idempotentResponse{
+			requestHash: requestHash,
+			status:      recorder.status,
+			header:      w.Header().Clone(),
+			body:        recorder.body.Bytes(),
+			expiresAt:   time.Now().Add(c.window),
+		}
+	})
+}
+
+// purgeExpiredLocked drops recorded responses whose window has passed, including placeholders
+// left behind by a request that never completed. Callers hold c.mu.
+func (c *IdempotencyCache) purgeExpiredLocked(now time.Time) {
+	for key, recorded := range c.responses {
+		if now.After(recorded.expiresAt) {
+			delete(c.responses, key)
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// WriteHeader records the status code before passing it on.
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body bytes before passing them on.
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(p)
+	return rr.ResponseWriter.Write(p)
+}
@@ -0,0 +1,144 @@
+// internal/middleware/idempotency_test.go - Tests of Idempotency-Key support for mutating requests.
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotentAdminCreates(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("other-admin", "Other-Admin-123", "administrator")
+	send := func(path string, body any, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := asAdmin(s.request(http.MethodPost, path, body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		return s.do(req)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body any
+	}{
+		{"create user", "/api/v1/admin/users", CreateUserRequest{Username: "alice", Password: "Alice-Pass-123", Roles: []string{"user"}}},
+		{"create package", "/api/v1/admin/packages", CreateSoftwareRequest{Name: "MyApp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := send(tt.path, tt.body, "key-"+tt.name)
+			retry := send(tt.path, tt.body, "key-"+tt.name)
+			if first.Code != http.StatusCreated || retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+				t.Errorf("retry = %d %s, want the original %d %s", retry.Code, retry.Body, first.Code, first.Body)
+			}
+			if retry.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+				t.Error("only the retry should be marked as replayed")
+			}
+			if resp := send(tt.path, tt.body, ""); resp.Code != http.StatusBadRequest {
+				t.Errorf("repeating the create without a key = %d, want 400 since it already exists", resp.Code)
+			}
+			if resp := send(tt.path, map[string]string{"name": "Other", "username": "other"}, "key-"+tt.name); resp.Code != http.StatusUnprocessableEntity {
+				t.Errorf("reusing the key with another body = %d, want 422", resp.Code)
+			}
+		})
+	}
+
+	users, err := s.userService.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Errorf("%d users exist, want admin, other-admin and a single alice", len(users))
+	}
+	if packages := s.listPackages(); len(packages) != 1 {
+		t.Errorf("packages = %v, want a single MyApp", packages)
+	}
+
+	req := withBasicAuth(s.request(http.MethodPost, "/api/v1/admin/users", tests[0].body), "other-admin", "Other-Admin-123")
+	req.Header.Set(IdempotencyKeyHeader, "key-create user")
+	if resp := s.do(req); resp.Code != http.StatusBadRequest {
+		t.Errorf("another administrator's request with the same key = %d, want it to run and fail with 400", resp.Code)
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusCreated
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte("done"))
+	}
+	send := func(mw func(http.Handler) http.Handler, method string, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/packages", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		mw(http.HandlerFunc(handler)).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	tests := []struct {
+		name      string
+		window    time.Duration
+		method    string
+		status    int
+		wait      time.Duration // Between the two requests
+		wantCalls int32
+	}{
+		{"replayed", time.Minute, http.MethodPost, http.StatusCreated, 0, 1},
+		{"client error replayed", time.Minute, http.MethodPost, http.StatusBadRequest, 0, 1},
+		{"server error retried", time.Minute, http.MethodPost, http.StatusInternalServerError, 0, 2},
+		{"window passed", time.Millisecond, http.MethodPost, http.StatusCreated, 10 * time.Millisecond, 2},
+		{"disabled", 0, http.MethodPost, http.StatusCreated, 0, 2},
+		{"not a POST", time.Minute, http.MethodPut, http.StatusCreated, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			status = tt.status
+			cache := NewIdempotencyCache(tt.window)
+			first := send(cache.Middleware, tt.method, "key")
+			time.Sleep(tt.wait)
+			retry := send(cache.Middleware, tt.method, "key")
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", got, tt.wantCalls)
+			}
+			if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+				t.Errorf("retry = %d %q, want %d %q", retry.Code, retry.Body, first.Code, first.Body)
+			}
+		})
+	}
+
+	t.Run("in flight", func(t *testing.T) {
+		cache := NewIdempotencyCache(time.Minute)
+		started, release := make(chan struct{}), make(chan struct{})
+		slow := cache.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/packages", strings.NewReader("{}"))
+			req.Header.Set(IdempotencyKeyHeader, "key")
+			slow.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+		<-started
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/packages", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		recorder := httptest.NewRecorder()
+		slow.ServeHTTP(recorder, req)
+		close(release)
+		<-done
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("retry while the first request runs = %d, want 409", recorder.Code)
+		}
+	})
+}
@@ -0,0 +1,371 @@
+// api/listing_test.go - Tests of the release listing of a software package.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listReleaseVersions lists the releases of a software package with the given query and returns
+// the response and the listed versions in order.
+func (s *testServer) listReleaseVersions(softwareName string, query string) (int, []string) {
+	s.t.Helper()
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages/"+softwareName+"/releases"+query, nil))
+	if resp.Code != http.StatusOK {
+		return resp.Code, nil
+	}
+	var page PaginatedResponse[*ReleaseMetadata]
+	decodeResponse(s.t, resp, &page)
+	versions := make([]string, len(page.Items))
+	for i, release := range page.Items {
+		versions[i] = release.Version
+	}
+	return resp.Code, versions
+}
+
+func TestListReleasesFilter(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, release := range []struct{ version, releaseDate string }{
+		{"1.0.0", "2024-01-01T00:00:00Z"},
+		{"1.1.0", "2024-03-01T00:00:00Z"},
+		{"1.2.0", "2024-06-01T00:00:00Z"},
+		{"1.3.0", ""},
+		{"2.0.0", "2024-09-01T00:00:00Z"},
+	} {
+		fields := map[string]string{"release_date": release.releaseDate}
+		if resp := s.upload(token, "MyApp", release.version, []byte("release "+release.version), fields); resp.Code != http.StatusCreated {
+			t.Fatalf("uploading %s = %d: %s", release.version, resp.Code, resp.Body)
+		}
+	}
+	if _, err := s.releaseService.SetReleaseState("MyApp", "1.2.0", "yanked"); err != nil {
+		t.Fatal(err)
+	}
+	unavailable, err := s.releaseDB.GetReleaseMetadata("MyApp", "2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unavailable.ReleaseState = "unavailable"
+	if err := s.releaseDB.UpdateReleaseMetadata(unavailable); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantVersions []string
+	}{
+		{"no filter", "", http.StatusOK, []string{"1.0.0", "1.1.0", "1.3.0", "2.0.0"}},
+		{"available", "&state=available", http.StatusOK, []string{"1.0.0", "1.1.0", "1.3.0"}},
+		{"yanked", "&state=yanked", http.StatusOK, []string{"1.2.0"}},
+		{"unavailable", "&state=unavailable", http.StatusOK, []string{"2.0.0"}},
+		{"since", "&since=2024-03-01T00:00:00Z", http.StatusOK, []string{"1.1.0", "2.0.0"}},
+		{"until", "&until=2024-03-01T00:00:00Z", http.StatusOK, []string{"1.0.0", "1.1.0"}},
+		{"since and until", "&since=2024-02-01T00:00:00Z&until=2024-10-01T00:00:00Z", http.StatusOK, []string{"1.1.0", "2.0.0"}},
+		{"time zone offset", "&until=2024-03-01T01:00:00%2B02:00", http.StatusOK, []string{"1.0.0"}},
+		{"available since", "&state=available&since=2024-02-01T00:00:00Z", http.StatusOK, []string{"1.1.0"}},
+		{"yanked in window", "&state=yanked&since=2024-05-01T00:00:00Z&until=2024-07-01T00:00:00Z", http.StatusOK, []string{"1.2.0"}},
+		{"nothing in window", "&since=2025-01-01T00:00:00Z", http.StatusOK, []string{}},
+		{"invalid since", "&since=yesterday", http.StatusBadRequest, nil},
+		{"date without time", "&until=2024-03-01", http.StatusBadRequest, nil},
+		{"unknown state", "&state=deleted", http.StatusBadRequest, nil},
+		{"until before since", "&since=2024-06-01T00:00:00Z&until=2024-01-01T00:00:00Z", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, versions := s.listReleaseVersions("MyApp", "?order=asc"+tt.query)
+			if code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && !slices.Equal(versions, tt.wantVersions) {
+				t.Errorf("versions = %v, want %v", versions, tt.wantVersions)
+			}
+		})
+	}
+
+	filter := ReleaseFilter{Since: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := s.releaseService.ListReleasesForSoftware("MyApp", "", "", "", filter, false, false); !errors.Is(err, ErrInvalidReleaseFilter) {
+		t.Errorf("listing with an empty date window = %v, want ErrInvalidReleaseFilter", err)
+	}
+}
+
+func TestEmptyListingsAreArrays(t *testing.T) {
+	s := newTestServer(t, nil)
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "Empty"}))); resp.Code != http.StatusCreated {
+		t.Fatalf("creating a package = %d: %s", resp.Code, resp.Body)
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"package without releases", s.request(http.MethodGet, "/api/v1/packages/Empty/releases", nil)},
+		{"package without releases, admin view", asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/Empty/releases", nil))},
+		{"filtered out releases", s.request(http.MethodGet, "/api/v1/packages/Empty/releases?state=yanked", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(tt.req)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			var page struct {
+				Items json.RawMessage `json:"items"`
+			}
+			decodeResponse(t, resp, &page)
+			if string(page.Items) != "[]" {
+				t.Errorf("items = %s, want []", page.Items)
+			}
+		})
+	}
+
+	t.Run("users", func(t *testing.T) {
+		userDB, err := NewJSONUserDatabase(filepath.Join(t.TempDir(), "users.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer userDB.Close()
+		recorder := httptest.NewRecorder()
+		handleListUsers(NewUserService(userDB, s.tokenDB, discardLogger()), discardLogger()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/users", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+		}
+		if got := string(bytes.TrimSpace(recorder.Body.Bytes())); got != "[]" {
+			t.Errorf("body = %s, want []", got)
+		}
+	})
+	t.Run("services", func(t *testing.T) {
+		packages, err := s.releaseService.ListSoftwarePackages(false)
+		if err != nil || packages == nil {
+			t.Errorf("ListSoftwarePackages = %v, %v; want an empty slice", packages, err)
+		}
+		releases, err := s.releaseService.ListReleasesForSoftware("Empty", "", "", "", ReleaseFilter{}, false, false)
+		if err != nil || releases == nil {
+			t.Errorf("ListReleasesForSoftware = %v, %v; want an empty slice", releases, err)
+		}
+	})
+}
+
+func TestListReleasesSortValidation(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantError  string // Part of the error message
+	}{
+		{"version ascending", "?sort=version&order=asc", http.StatusOK, ""},
+		{"date descending", "?sort=date&order=desc", http.StatusOK, ""},
+		{"defaults", "", http.StatusOK, ""},
+		{"unknown sort field", "?sort=name", http.StatusBadRequest, "[version date]"},
+		{"sort field in other casing", "?sort=Version", http.StatusBadRequest, "[version date]"},
+		{"unknown order", "?order=descending", http.StatusBadRequest, "[asc desc]"},
+		{"unknown order with a valid field", "?sort=date&order=up", http.StatusBadRequest, "[asc desc]"},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/api/v1/packages/MyApp/releases", "/api/v1/releases"} {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				resp := s.do(s.request(http.MethodGet, path+tt.query, nil))
+				if resp.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+				}
+				if tt.wantError != "" && !strings.Contains(resp.Body.String(), tt.wantError) {
+					t.Errorf("error %s does not list the valid values %s", resp.Body, tt.wantError)
+				}
+			})
+		}
+	}
+	if _, err := s.releaseService.ListReleasesForSoftware("MyApp", "name", "", "", ReleaseFilter{}, false, false); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("listing sorted by an unknown field = %v, want ErrInvalidSort", err)
+	}
+}
+
+// permutations returns every ordering of items.
+func permutations[T any](items []T) [][]T {
+	if len(items) <= 1 {
+		return [][]T{slices.Clone(items)}
+	}
+	var result [][]T
+	for i := range items {
+		rest := slices.Concat(items[:i], items[i+1:])
+		for _, tail := range permutations(rest) {
+			result = append(result, append([]T{items[i]}, tail...))
+		}
+	}
+	return result
+}
+
+func TestReleaseOrderIsDeterministic(t *testing.T) {
+	uploaded := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	releases := []*ReleaseMetadata{ // Equal versions and dates, some also uploaded at the same time
+		{Version: "1.0.0+b", ReleaseDate: released, ReleaseTimestamp: uploaded},
+		{Version: "1.0.0", ReleaseDate: released, ReleaseTimestamp: uploaded.Add(time.Minute)},
+		{Version: "1.0.0+a", ReleaseTimestamp: uploaded.Add(time.Minute)},
+		{Version: "2.0.0", ReleaseTimestamp: uploaded.Add(2 * time.Minute)},
+	}
+
+	tests := []struct {
+		sortField         string
+		wantAscending     []string
+		defaultDescending bool // Whether an empty order sorts descending
+	}{
+		{"version", []string{"1.0.0+b", "1.0.0", "1.0.0+a", "2.0.0"}, false},
+		{"date", []string{"1.0.0+a", "2.0.0", "1.0.0+b", "1.0.0"}, false},
+		{"", []string{"1.0.0+b", "1.0.0", "1.0.0+a", "2.0.0"}, true},
+	}
+	for _, tt := range tests {
+		t.Run("sort="+tt.sortField, func(t *testing.T) {
+			wantDescending := slices.Clone(tt.wantAscending)
+			slices.Reverse(wantDescending)
+			wantDefault := tt.wantAscending
+			if tt.defaultDescending {
+				wantDefault = wantDescending
+			}
+			for _, order := range []struct {
+				name string
+				want []string
+			}{{"asc", tt.wantAscending}, {"desc", wantDescending}, {"", wantDefault}} {
+				for _, input := range permutations(releases) {
+					sort.Slice(input, func(i, j int) bool {
+						return releaseLess(input[i], input[j], tt.sortField, order.name)
+					})
+					got := make([]string, len(input))
+					for i, release := range input {
+						got[i] = release.Version
+					}
+					if !slices.Equal(got, order.want) {
+						t.Fatalf("order %q sorts to %v, want %v", order.name, got, order.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListingPagination(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) { cfg.MaxPageLimit = 3 })
+	token := s.token("admin", testAdminPassword)
+	for _, name := range []string{"Gamma", "Alpha", "Delta", "Beta"} {
+		s.mustUpload(token, name, "1.0.0", []byte("release of "+name))
+	}
+	for _, version := range []string{"1.2.0", "1.0.0", "1.4.0", "1.1.0", "1.3.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+
+	endpoints := []struct {
+		path string
+		key  string   // Field identifying an item
+		all  []string // Every item in listing order
+	}{
+		{"/api/v1/packages", "name", []string{"Alpha", "Beta", "Delta", "Gamma", "MyApp"}},
+		{"/api/v1/packages/MyApp/releases", "version", []string{"1.4.0", "1.3.0", "1.2.0", "1.1.0", "1.0.0"}},
+		{"/api/v1/packages/MyApp/releases?sort=version&order=asc", "version", []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"}},
+	}
+	withQuery := func(path string, query string) string {
+		if strings.Contains(path, "?") {
+			return path + "&" + query
+		}
+		return path + "?" + query
+	}
+	tests := []struct {
+		name       string
+		query      string
+		wantStart  int // Index of the first item in the full listing
+		wantCount  int
+		wantLimit  int
+		wantOffset int
+	}{
+		{"default limit capped", "", 0, 3, 3, 0},
+		{"first page", "limit=2", 0, 2, 2, 0},
+		{"second page", "limit=2&offset=2", 2, 2, 2, 2},
+		{"partial last page", "limit=2&offset=4", 4, 1, 2, 4},
+		{"last item", "limit=1&offset=4", 4, 1, 1, 4},
+		{"offset at total", "limit=2&offset=5", 5, 0, 2, 5},
+		{"offset past total", "offset=100", 5, 0, 3, 100},
+		{"limit above maximum", "limit=10&offset=1", 1, 3, 3, 1},
+	}
+	for _, endpoint := range endpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.path+" "+tt.name, func(t *testing.T) {
+				resp := s.do(s.request(http.MethodGet, withQuery(endpoint.path, tt.query), nil))
+				if resp.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+				}
+				var page PaginatedResponse[map[string]any]
+				decodeResponse(t, resp, &page)
+				if page.Items == nil {
+					t.Fatalf("items = null in %s, want an array", resp.Body)
+				}
+				got := make([]string, len(page.Items))
+				for i, item := range page.Items {
+					got[i], _ = item[endpoint.key].(string)
+				}
+				if want := endpoint.all[tt.wantStart : tt.wantStart+tt.wantCount]; !slices.Equal(got, want) {
+					t.Errorf("items = %v, want %v", got, want)
+				}
+				if page.Total != len(endpoint.all) || page.Limit != tt.wantLimit || page.Offset != tt.wantOffset {
+					t.Errorf("total, limit, offset = %d, %d, %d; want %d, %d, %d", page.Total, page.Limit, page.Offset, len(endpoint.all), tt.wantLimit, tt.wantOffset)
+				}
+			})
+		}
+		for _, query := range []string{"limit=0", "limit=-1", "limit=two", "offset=-1", "offset=one", "limit=1.5"} {
+			t.Run(endpoint.path+" invalid "+query, func(t *testing.T) {
+				if resp := s.do(s.request(http.MethodGet, withQuery(endpoint.path, query), nil)); resp.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want %d: %s", resp.Code, http.StatusBadRequest, resp.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestListReleasesVersionPrefix(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.20.5", "1.2.0", "12.0.0", "1.2.10", "1.20.0", "1.2.3-rc.1", "1.3.0", "1.2.1", "2.1.2"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+
+	tests := []struct {
+		prefix     string
+		wantStatus int
+		want       []string
+	}{
+		{"1.2", http.StatusOK, []string{"1.2.0", "1.2.1", "1.2.3-rc.1", "1.2.10"}},
+		{"1.20", http.StatusOK, []string{"1.20.0", "1.20.5"}},
+		{"1", http.StatusOK, []string{"1.2.0", "1.2.1", "1.2.3-rc.1", "1.2.10", "1.3.0", "1.20.0", "1.20.5"}},
+		{"12", http.StatusOK, []string{"12.0.0"}},
+		{"1.2.1", http.StatusOK, []string{"1.2.1"}},
+		{"1.2.3", http.StatusOK, []string{"1.2.3-rc.1"}},
+		{"1.4", http.StatusOK, []string{}},
+		{"1.x", http.StatusBadRequest, nil},
+		{"v1.2", http.StatusBadRequest, nil},
+		{"1.", http.StatusBadRequest, nil},
+		{"-1", http.StatusBadRequest, nil},
+		{"1.2.3.4.5.6.7", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			status, got := s.listReleaseVersions("MyApp", "?sort=version&order=asc&version_prefix="+tt.prefix)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && !slices.Equal(got, tt.want) {
+				t.Errorf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// internal/security/lockout.go - Failed-login tracking and account lockout.
+//
+// This file tracks failed Basic Auth attempts per username and locks accounts
+// temporarily once a configurable number of consecutive failures is reached.
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginAttempts holds the failed-login state of a single username.
+type loginAttempts struct {
+	failedCount  int
+	lastFailedAt time.Time
+	lockedUntil  time.Time
+}
+
+// LoginAttemptTracker records failed logins in memory and decides when an account is locked.
+type LoginAttemptTracker struct {
+	maxFailures     int           // Consecutive failures before locking, 0 disables lockout
+	lockoutDuration time.Duration // How long an account stays locked
+	attempts        map[string]*loginAttempts
+	mu              sync.Mutex
+}
+
+// NewLoginAttemptTracker creates a new LoginAttemptTracker instance.
+func NewLoginAttemptTracker(maxFailures int, lockoutDuration time.Duration) *LoginAttemptTracker {
+	return &LoginAttemptTracker{
+		maxFailures:     maxFailures,
+		lockoutDuration: lockoutDuration,
+		attempts:        make(map[string]*loginAttempts),
+	}
+}
+
+// IsLocked reports whether the username is currently locked out.
+func (t *LoginAttemptTracker) IsLocked(username string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[username]
+	return ok && now.Before(a.lockedUntil)
+}
+
+// RecordFailure counts a failed login and locks the account once the threshold is reached.
+func (t *LoginAttemptTracker) RecordFailure(username string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[username]
+	if !ok {
+		a = &loginAttempts{}
+		t.attempts[username] = a
+	}
+	a.failedCount++
+	a.lastFailedAt = now
+	if t.maxFailures > 0 && a.failedCount >= t.maxFailures {
+		a.lockedUntil = now.Add(t.lockoutDuration)
+	}
+}
+
+// Cleanup drops the state of usernames that are not locked and whose last failure is older than the
+// lockout duration, so attempts against unknown or forgotten usernames don't accumulate.
+func (t *LoginAttemptTracker) Cleanup(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := now.Add(-t.lockoutDuration)
+	for username, a := range t.attempts {
+		if !now.Before(a.lockedUntil) && a.lastFailedAt.Before(cutoff) {
+			delete(t.attempts, username)
+		}
+	}
+}
+
+// StartCleanup periodically drops expired failed-login state so memory stays bounded.
+func (t *LoginAttemptTracker) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			t.Cleanup(now)
+		}
+	}()
+}
+
+// RecordSuccess clears the failed-login state after a successful login.
+func (t *LoginAttemptTracker) RecordSuccess(username string) {
+	t.Reset(username)
+}
+
+// Reset clears the failed-login counters and any lock for the username.
+func (t *LoginAttemptTracker) Reset(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, username)
+}
+
+// Status returns the failed-login state of the username.
+func (t *LoginAttemptTracker) Status(username string, now time.Time) UserSecurityStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := UserSecurityStatus{Username: username}
+	a, ok := t.attempts[username]
+	if !ok {
+		return status
+	}
+	status.FailedAttempts = a.failedCount
+	status.LastFailedAt = timePtr(a.lastFailedAt)
+	if now.Before(a.lockedUntil) {
+		status.Locked = true
+		status.LockedUntil = timePtr(a.lockedUntil)
+	}
+	return status
+}
+
+// timePtr returns a pointer to t, or nil for the zero time.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,121 @@
+// internal/security/lockout_test.go - Tests of failed-login tracking and account lockout.
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptTracker(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const lockout = 15 * time.Minute
+
+	tests := []struct {
+		name        string
+		maxFailures int
+		failures    int
+		success     bool // A successful login after the failures
+		checkAfter  time.Duration
+		wantLocked  bool
+		wantFailed  int
+	}{
+		{"below threshold", 3, 2, false, 0, false, 2},
+		{"at threshold", 3, 3, false, 0, true, 3},
+		{"above threshold", 3, 5, false, 0, true, 5},
+		{"lock still active", 3, 3, false, lockout - time.Second, true, 3},
+		{"lock expired", 3, 3, false, lockout, false, 3},
+		{"success clears failures", 3, 2, true, 0, false, 0},
+		{"lockout disabled", 0, 10, false, 0, false, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewLoginAttemptTracker(tt.maxFailures, lockout)
+			for range tt.failures {
+				tracker.RecordFailure("alice", start)
+			}
+			if tt.success {
+				tracker.RecordSuccess("alice")
+			}
+			now := start.Add(tt.checkAfter)
+			if got := tracker.IsLocked("alice", now); got != tt.wantLocked {
+				t.Errorf("IsLocked = %v, want %v", got, tt.wantLocked)
+			}
+			status := tracker.Status("alice", now)
+			if status.Locked != tt.wantLocked || status.FailedAttempts != tt.wantFailed {
+				t.Errorf("Status = locked %v with %d failures, want locked %v with %d failures", status.Locked, status.FailedAttempts, tt.wantLocked, tt.wantFailed)
+			}
+			if status.Locked && (status.LockedUntil == nil || !status.LockedUntil.Equal(start.Add(lockout))) {
+				t.Errorf("LockedUntil = %v, want %v", status.LockedUntil, start.Add(lockout))
+			}
+			if tracker.IsLocked("bob", now) {
+				t.Error("failures of alice locked bob")
+			}
+		})
+	}
+}
+
+func TestLoginAttemptTrackerCleanup(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const lockout = 15 * time.Minute
+
+	tests := []struct {
+		name         string
+		failures     int
+		cleanupAfter time.Duration
+		wantKept     bool
+	}{
+		{"recent failure", 1, lockout / 2, true},
+		{"old failure", 1, lockout + time.Second, false},
+		{"locked", 3, lockout / 2, true},
+		{"lock expired", 3, lockout + time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewLoginAttemptTracker(3, lockout)
+			for range tt.failures {
+				tracker.RecordFailure("alice", start)
+			}
+			tracker.Cleanup(start.Add(tt.cleanupAfter))
+			if kept := tracker.Status("alice", start).FailedAttempts > 0; kept != tt.wantKept {
+				t.Errorf("state kept after cleanup = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.MaxFailedLogins = 3
+		cfg.LockoutMinutes = 15
+	})
+	const password = "Alice-Pass-123"
+	s.createUser("alice", password, "user")
+	login := func(password string) int {
+		req := s.request(http.MethodGet, "/api/v1/auth/tokens", nil)
+		req.SetBasicAuth("alice", password)
+		return s.do(req).Code
+	}
+
+	for range 3 {
+		if code := login("wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("login with wrong password = %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+	if code := login(password); code != http.StatusUnauthorized {
+		t.Errorf("login of locked account with correct password = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	var status UserSecurityStatus
+	decodeResponse(t, s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users/alice/security", nil))), &status)
+	if !status.Locked || status.FailedAttempts != 3 {
+		t.Errorf("security status = %+v, want locked with 3 failures", status)
+	}
+
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/users/alice/security", nil))); resp.Code != http.StatusNoContent {
+		t.Fatalf("resetting security status = %d: %s", resp.Code, resp.Body)
+	}
+	if code := login(password); code != http.StatusOK {
+		t.Errorf("login after reset = %d, want %d", code, http.StatusOK)
+	}
+}
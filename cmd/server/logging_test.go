@@ -0,0 +1,87 @@
+// internal/logging/logging_test.go - Tests of the structured log output.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestLog sets up logging into path with the given format, logs two records and closes the log file.
+func writeTestLog(t *testing.T, format string, path string) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.LogFilePath = path
+	cfg.LogFormat = format
+	logger, logFile, err := SetupLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Release uploaded", "software_name", "MyApp", "version", "1.0.0")
+	logger.Error("Failed to store release", "software_name", "MyApp", "version", "1.1.0", "error", "disk full")
+	if err := logFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONLogLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "repo-man.log")
+	writeTestLog(t, LogFormatJSON, path)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line %q is not a JSON object: %v", scanner.Text(), err)
+		}
+		for _, key := range []string{"time", "level", "msg"} {
+			if _, ok := record[key]; !ok {
+				t.Errorf("log line %q has no %q key", scanner.Text(), key)
+			}
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("log has %d lines, want the startup line and 2 more", len(records))
+	}
+	want := []map[string]any{
+		{"level": "INFO", "msg": "Logger initialized", "format": LogFormatJSON},
+		{"level": "INFO", "msg": "Release uploaded", "software_name": "MyApp", "version": "1.0.0"},
+		{"level": "ERROR", "msg": "Failed to store release", "software_name": "MyApp", "version": "1.1.0", "error": "disk full"},
+	}
+	for i, fields := range want {
+		for key, value := range fields {
+			if records[i][key] != value {
+				t.Errorf("line %d %s = %v, want %v", i+1, key, records[i][key], value)
+			}
+		}
+	}
+}
+
+func TestTextLogLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "repo-man.log")
+	writeTestLog(t, LogFormatText, path)
+	writeTestLog(t, LogFormatText, path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("log has %d lines, want 3 appended by each start: %s", len(lines), content)
+	}
+	for _, part := range []string{"level=INFO", `msg="Release uploaded"`, "software_name=MyApp", "version=1.0.0"} {
+		if !strings.Contains(lines[1], part) {
+			t.Errorf("log line %q does not contain %s", lines[1], part)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// internal/logging/logrotate.go - Size-based log file rotation.
+//
+// This file implements an append-only log file writer that rolls the file over to
+// numbered backups once it reaches a maximum size and prunes the oldest backups.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser appending to a log file that is rotated by size.
+// The current file is path; backups are path.1 (newest) to path.N (oldest).
+// It is safe for concurrent use.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64 // Rotation threshold, 0 disables rotation
+	maxBackups int   // Number of backups kept, 0 keeps none
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// OpenRotatingFile opens path for appending, rotating it once it would grow beyond maxBytes.
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Write appends p to the log file, rotating first if p would push it past the size limit.
+// A single write is never split across files.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close flushes the current log file to disk and closes it.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Sync()
+	if closeErr := rf.file.Close(); err == nil {
+		err = closeErr
+	}
+	rf.file = nil
+	return err
+}
+
+// open opens the current log file for appending. Callers hold rf.mu or have exclusive access.
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate closes the current file, shifts the backups up by one, dropping the oldest,
+// and starts a new file. Callers hold rf.mu.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file for rotation: %w", err)
+	}
+	rf.file = nil
+
+	if rf.maxBackups <= 0 {
+		os.Remove(rf.path)
+	} else {
+		os.Remove(rf.backupPath(rf.maxBackups))
+		for i := rf.maxBackups - 1; i >= 1; i-- {
+			os.Rename(rf.backupPath(i), rf.backupPath(i+1)) // Missing backups are skipped
+		}
+		if err := os.Rename(rf.path, rf.backupPath(1)); err != nil {
+			rf.open() // Keep logging to the current file rather than losing output
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	return rf.open()
+}
+
+// backupPath returns the path of the n-th backup, 1 being the newest.
+func (rf *RotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", rf.path, n)
+}
@@ -0,0 +1,124 @@
+// internal/logging/logrotate_test.go - Tests of size-based log file rotation.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logLine returns a log line of 60 bytes.
+func logLine(n int) string {
+	return fmt.Sprintf("line %02d %s\n", n, strings.Repeat("x", 51))
+}
+
+// readLogFiles returns the content of path and its first backups, "" for missing files.
+func readLogFiles(path string, backups int) []string {
+	contents := make([]string, backups+1)
+	for i := range contents {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		content, _ := os.ReadFile(name)
+		contents[i] = string(content)
+	}
+	return contents
+}
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBytes   int64
+		maxBackups int
+		writes     int
+		want       []string // Current file, then backups .1, .2 and .3
+	}{
+		{"below the limit", 200, 2, 3, []string{logLine(1) + logLine(2) + logLine(3), "", "", ""}},
+		{"past the limit", 100, 2, 2, []string{logLine(2), logLine(1), "", ""}},
+		{"oldest backup pruned", 100, 2, 4, []string{logLine(4), logLine(3), logLine(2), ""}},
+		{"no backups kept", 100, 0, 3, []string{logLine(3), "", "", ""}},
+		{"rotation disabled", 0, 2, 3, []string{logLine(1) + logLine(2) + logLine(3), "", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "repo-man.log")
+			rf, err := OpenRotatingFile(path, tt.maxBytes, tt.maxBackups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= tt.writes; i++ {
+				if _, err := rf.Write([]byte(logLine(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := rf.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got := readLogFiles(path, 3)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("file %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileCountsExistingContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo-man.log")
+	if err := os.WriteFile(path, []byte(logLine(1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := OpenRotatingFile(path, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf.Write([]byte(logLine(2)))
+	rf.Close()
+	if got := readLogFiles(path, 1); got[0] != logLine(2) || got[1] != logLine(1) {
+		t.Errorf("files = %q, want the line written before opening rotated out", got)
+	}
+	if _, err := rf.Write([]byte(logLine(3))); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("write after Close = %v, want os.ErrClosed", err)
+	}
+}
+
+func TestRotatingFileConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo-man.log")
+	const writers, backups = 20, 100
+	rf, err := OpenRotatingFile(path, 300, backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rf.Write([]byte(logLine(i)))
+		}()
+	}
+	wg.Wait()
+	rf.Close()
+
+	lines := 0
+	for _, content := range readLogFiles(path, backups) {
+		for _, line := range strings.SplitAfter(content, "\n") {
+			if line == "" {
+				continue
+			}
+			if len(line) != len(logLine(0)) {
+				t.Errorf("log line %q was split or interleaved", line)
+			}
+			lines++
+		}
+	}
+	if lines != writers {
+		t.Errorf("log files hold %d lines, want %d", lines, writers)
+	}
+}
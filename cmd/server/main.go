@@ -6,7 +6,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,71 +19,103 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// exitCodeForcedShutdown is the exit status when requests were still in flight at the shutdown deadline.
+// Startup and other fatal errors exit with 1.
+const exitCodeForcedShutdown = 2
+
 func main() {
 	// Load environment variables from .env file if it exists
 	godotenv.Load()
 
+	if len(os.Args) > 1 { // Maintenance subcommand, e.g. useradd; the server is not started
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	exitCode := 0
+	defer func() { // Registered first so it runs after every other deferred close
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	cfg, err := LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	logger, logFile, err := SetupLogger(cfg.LogFilePath)
+	logger, logFile, err := SetupLogger(cfg)
 	if err != nil {
 		log.Fatalf("Failed to setup logger: %v", err)
 	}
-	defer logFile.Close() // Close log file on exit
+	defer logFile.Close()   // Flush and close the log file on graceful shutdown
+	slog.SetDefault(logger) // Route the standard log package, e.g. from libraries, to the same log
 
-	logger.Printf("Starting Release Repository Manager version %s", ServerVersion)
-	logger.Printf("Configuration loaded from: %s", cfg.ConfigFileUsed)
+	auditLog, err := OpenAuditLog(cfg.AuditLogPath, logger)
+	if err != nil {
+		fatal(logger, "Failed to open audit log", err)
+	}
+	defer auditLog.Close()
 
-	userDB, err := NewJSONUserDatabase(cfg.DataPath + "/users.json")
+	logger.Info("Starting Release Repository Manager", "version", ServerVersion)
+	logger.Info("Configuration loaded", "path", cfg.ConfigFileUsed)
+
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
 	if err != nil {
-		logger.Fatalf("Failed to initialize user database: %v", err)
+		fatal(logger, "Failed to initialize databases", err)
 	}
-	defer userDB.Close()
+	defer closeDatabases()
+	logger.Info("Storage backend selected", "backend", cfg.StorageBackend)
 
-	releaseDB, err := NewJSONReleaseDatabase(cfg.DataPath + "/releases.json")
+	tokenDB, err := NewJSONTokenDatabase(cfg.DataPath + "/tokens.json")
 	if err != nil {
-		logger.Fatalf("Failed to initialize release database: %v", err)
+		fatal(logger, "Failed to initialize token database", err)
 	}
-	defer releaseDB.Close()
+	defer tokenDB.Close()
 
-	releaseService := NewReleaseService(cfg, releaseDB, logger)
-	userService := NewUserService(userDB, logger)
-	authService := NewAuthService(userService, logger)
+	SetPasswordHashCost(cfg.BcryptCost)
+	SetPasswordPolicy(PasswordPolicy{MinLength: cfg.PasswordMinLength, RequiredClasses: cfg.PasswordRequiredClasses})
 
-	// Initialize Admin User if not exists
-	if _, err := userService.GetUserByUsername("admin"); err != nil {
-		defaultAdmin := &User{
-			Username:     "admin",
-			PasswordHash: HashPassword("admin"), // Default password as specified
-			Roles:        []string{"administrator"},
-			Enabled:      true,
-		}
-		if err := userService.CreateUser(defaultAdmin); err != nil {
-			logger.Fatalf("Failed to create default admin user: %v", err)
-		}
-		logger.Println("Default administrator user 'admin' created.")
+	releaseService := NewReleaseService(cfg, releaseDB, packageDB, logger)
+	userService := NewUserService(userDB, tokenDB, logger)
+	authenticator, err := NewAuthenticator(cfg, userService, logger)
+	if err != nil {
+		fatal(logger, "Failed to initialize authenticator", err)
 	}
+	authService := NewAuthService(cfg, userService, authenticator, tokenDB, logger)
 
-	// Perform database reconciliation at startup
-	if err := releaseService.ReconcileReleases(); err != nil {
-		logger.Fatalf("Release database reconciliation failed: %v", err)
-		os.Exit(1) // Exit with error as per REQ-302
+	if err := ensureDefaultAdmin(userService, logger); err != nil {
+		fatal(logger, "Failed to create default admin user", err)
 	}
-	logger.Println("Release database reconciliation completed successfully.")
+
+	StartTempDirSweeper(cfg, logger) // Clean up uploads abandoned by a previous crash
+	authService.loginAttempts.StartCleanup(time.Minute)
+	tokenCleanupCtx, stopTokenCleanup := context.WithCancel(context.Background())
+	tokenCleanupDone := authService.StartTokenCleanup(tokenCleanupCtx, time.Duration(cfg.TokenCleanupIntervalMinutes)*time.Minute)
+
+	shutdownState := NewShutdownState()
+	inFlight := NewInFlightRequests()
+	readiness := NewReadinessState()
+	idempotencyCache := NewIdempotencyCache(time.Duration(cfg.IdempotencyWindowMinutes) * time.Minute)
+	serverNotice := NewServerNotice(cfg.ServerNotice)
 
 	router := mux.NewRouter()
 	apiRouter := router.PathPrefix("/api/v1").Subrouter() // Versioned API
 
-	SetupPublicRoutes(apiRouter, releaseService, userService, logger)
-	SetupAdminRoutes(apiRouter, releaseService, userService, authService, logger)
+	SetupHealthRoutes(router, cfg, releaseService, readiness, shutdownState, logger)
+	SetupPublicRoutes(apiRouter, cfg, releaseService, userService, serverNotice, logger)
+	SetupAdminRoutes(apiRouter, cfg, releaseService, userService, authService, idempotencyCache, auditLog, serverNotice, logger)
 	SetupUserRoutes(apiRouter, userService, authService, logger)
-	SetupTokenRoutes(apiRouter, releaseService, authService, logger)
-
-	// Add middleware for logging, rate limiting, CORS, and JSON validation can be added here.
-	// Example: router.Use(middleware.RequestLogger(logger))
+	SetupTokenRoutes(apiRouter, cfg, releaseService, authService, shutdownState, logger)
+	CheckOpenAPIRoutes(router, logger) // Warn when openapi.json has drifted from the registered routes
+
+	// Add middleware for CORS and JSON validation can be added here.
+	router.Use(inFlight.Middleware) // Outermost, so the count covers the whole handler chain
+	router.Use(RequestLoggerMiddleware(logger))
+	router.Use(MaxBodyBytesMiddleware(cfg.MaxJSONBodyBytes))
+	router.Use(serverNotice.Middleware)
+	router.Use(DeprecationMiddleware(cfg.DeprecatedRoutes, logger))
+	router.Use(RateLimitMiddleware(newRateLimiter(cfg.AuthRateLimitRPS, cfg.AuthRateLimitBurst), newRateLimiter(cfg.ReadRateLimitRPS, cfg.ReadRateLimitBurst)))
+	router.Use(PathParamsMiddleware) // Reject traversal attempts in software names and versions before any file system access
 
 	server := &http.Server{
 		Addr:         cfg.APIServerAddress,
@@ -92,23 +126,129 @@ func main() {
 	}
 
 	go func() {
-		logger.Printf("Starting API server at %s", cfg.APIServerAddress)
+		logger.Info("Starting API server", "address", cfg.APIServerAddress)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Server failed to start: %v", err)
+			fatal(logger, "Server failed to start", err)
 		}
 	}()
 
+	// Perform database reconciliation at startup; /readyz reports 503 until it has completed
+	reconcileResult, err := releaseService.ReconcileReleases()
+	if err != nil {
+		fatal(logger, "Release database reconciliation failed", err) // Exit with error as per REQ-302
+	}
+	for _, note := range reconcileResult.Notes {
+		logger.Info("Reconciliation", "software_name", note.SoftwareName, "version", note.Version, "message", note.Message)
+	}
+	logger.Info("Release database reconciliation completed", "checked", reconcileResult.Checked, "newly_available", reconcileResult.NewlyAvailable,
+		"newly_unavailable", reconcileResult.NewlyUnavailable, "size_updated", reconcileResult.SizeUpdated, "corrupt", reconcileResult.Corrupt, "errored", reconcileResult.Errored)
+	readiness.MarkReady()
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	logger.Println("Shutting down server...")
+	shutdownState.Begin() // Reject new uploads while in-flight requests drain
+	logger.Info("Shutting down server")
+
+	exitCode = shutdownServer(server, inFlight, time.Duration(cfg.ShutdownDelay)*time.Second, logger)
+	releaseService.FlushDownloadCounts() // Persist the counts of downloads that finished while draining
+	stopTokenCleanup()
+	<-tokenCleanupDone // A purge in progress finishes before the token database is closed
+	if exitCode == 0 {
+		logger.Info("Server shutdown completed")
+	} else {
+		logger.Warn("Server shutdown forced")
+	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownDelay)*time.Second)
+// shutdownServer stops the server, waiting up to delay for in-flight requests to finish. It returns 0, or
+// exitCodeForcedShutdown when requests were still running at the deadline and their connections were closed.
+func shutdownServer(server *http.Server, inFlight *InFlightRequests, delay time.Duration, logger *slog.Logger) int {
+	ctx, cancel := context.WithTimeout(context.Background(), delay)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server shutdown failed: %v", err)
+		logger.Error("Shutdown deadline reached, closing remaining connections", "in_flight_requests", inFlight.Count(),
+			"shutdown_delay_seconds", delay.Seconds(), "error", err)
+		server.Close() // Abort the requests that did not finish in time
+		return exitCodeForcedShutdown
+	}
+	return 0
+}
+
+// newRateLimiter creates a per-IP limiter with idle cleanup, or nil when the rate is 0 (disabled).
+func newRateLimiter(rps float64, burst int) RateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	limiter := NewIPRateLimiter(rps, burst)
+	limiter.StartCleanup(time.Minute, 10*time.Minute)
+	return limiter
+}
+
+// fatal logs an error and exits. Deferred functions do not run.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// ensureDefaultAdmin creates the "admin" user when it does not exist yet. Its password is generated
+// and logged once, and must be changed on first login; there is no well-known default password.
+func ensureDefaultAdmin(userService *UserService, logger *slog.Logger) error {
+	if _, err := userService.GetUserByUsername("admin"); err == nil {
+		return nil
+	}
+	adminPassword, err := GenerateRandomPassword()
+	if err != nil {
+		return err
+	}
+	defaultAdmin := &User{
+		Username:           "admin",
+		Roles:              []string{"administrator"},
+		Enabled:            true,
+		MustChangePassword: true, // The generated password ends up in the log
+	}
+	if err := userService.CreateUser(defaultAdmin, adminPassword); err != nil {
+		return err
+	}
+	logger.Warn("Default administrator user created with a generated password that must be changed on first login", "username", "admin", "password", adminPassword)
+	return nil
+}
+
+// openDatabases opens the user, release and software package databases of the configured storage backend.
+// The returned function closes them.
+func openDatabases(cfg *Config) (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase, func(), error) {
+	softwareIDsFilepath := cfg.DataPath + "/software_ids.json"
+	if cfg.StorageBackend == StorageBackendSQLite {
+		db, err := OpenSQLiteDatabase(cfg.DataPath + "/repo-man.db")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		releaseDB, err := NewSQLiteReleaseDatabase(db, softwareIDsFilepath)
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, nil, fmt.Errorf("release database: %w", err)
+		}
+		return NewSQLiteUserDatabase(db), releaseDB, NewSQLiteSoftwarePackageDatabase(db), func() { db.Close() }, nil
+	}
+
+	userDB, err := NewJSONUserDatabase(cfg.DataPath + "/users.json")
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("user database: %w", err)
+	}
+	releaseDB, err := NewJSONReleaseDatabase(cfg.DataPath+"/releases.json", softwareIDsFilepath)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("release database: %w", err)
+	}
+	packageDB, err := NewJSONSoftwarePackageDatabase(cfg.DataPath + "/packages.json")
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("software package database: %w", err)
+	}
+	closeDatabases := func() {
+		userDB.Close()
+		releaseDB.Close()
+		packageDB.Close()
 	}
-	logger.Println("Server shutdown completed.")
+	return userDB, releaseDB, packageDB, closeDatabases, nil
 }
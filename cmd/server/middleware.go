@@ -0,0 +1,230 @@
+// internal/middleware/middleware.go - HTTP middleware shared across routes.
+//
+// This file implements cross-cutting HTTP middleware such as request logging
+// and rejecting requests once graceful shutdown has begun.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadinessState tracks whether startup has completed and the server may receive traffic.
+type ReadinessState struct {
+	ready atomic.Bool
+}
+
+// NewReadinessState creates a new ReadinessState instance, initially not ready.
+func NewReadinessState() *ReadinessState {
+	return &ReadinessState{}
+}
+
+// MarkReady marks startup as completed.
+func (s *ReadinessState) MarkReady() {
+	s.ready.Store(true)
+}
+
+// IsReady reports whether startup has completed.
+func (s *ReadinessState) IsReady() bool {
+	return s.ready.Load()
+}
+
+// InFlightRequests counts the requests currently being handled, for the shutdown report.
+type InFlightRequests struct {
+	count atomic.Int64
+}
+
+// NewInFlightRequests creates a new InFlightRequests counter.
+func NewInFlightRequests() *InFlightRequests {
+	return &InFlightRequests{}
+}
+
+// Middleware counts a request as in flight until its handler returns.
+func (c *InFlightRequests) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.count.Add(1)
+		defer c.count.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of requests currently in flight.
+func (c *InFlightRequests) Count() int64 {
+	return c.count.Load()
+}
+
+// ShutdownState tracks whether the server has begun graceful shutdown.
+type ShutdownState struct {
+	shuttingDown atomic.Bool
+}
+
+// NewShutdownState creates a new ShutdownState instance.
+func NewShutdownState() *ShutdownState {
+	return &ShutdownState{}
+}
+
+// Begin marks the server as shutting down.
+func (s *ShutdownState) Begin() {
+	s.shuttingDown.Store(true)
+}
+
+// IsShuttingDown reports whether graceful shutdown has begun.
+func (s *ShutdownState) IsShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// MaxBodyBytesMiddleware caps request bodies at limit bytes, so decodeJSONBody answers oversized
+// bodies with 413. Multipart uploads are left alone; the upload handler applies its own, larger limit.
+func MaxBodyBytesMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PathParamsMiddleware rejects requests whose software_name or version route variable could escape the
+// repository directory, with 400 before any handler turns them into file paths.
+func PathParamsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if softwareName, ok := vars["software_name"]; ok {
+			if err := ValidateSoftwareName(softwareName); err != nil {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if version, ok := vars["version"]; ok {
+			if err := validatePathSegment(version); err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("%v: %v", ErrInvalidVersion, err))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectDuringShutdownMiddleware rejects new requests with 503 once shutdown has begun.
+// Requests that were already in flight when shutdown started are allowed to finish.
+func (s *ShutdownState) RejectDuringShutdownMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.IsShuttingDown() {
+			respondError(w, http.StatusServiceUnavailable, "Server is shutting down, request not accepted")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServerNoticeHeader is the response header carrying the current message of the day.
+const ServerNoticeHeader = "X-Server-Notice"
+
+// ServerNotice holds the operator-set message of the day broadcast to clients.
+type ServerNotice struct {
+	message string
+	mu      sync.RWMutex
+}
+
+// NewServerNotice creates a new ServerNotice with an initial message, empty for none.
+func NewServerNotice(message string) *ServerNotice {
+	return &ServerNotice{message: message}
+}
+
+// Get returns the current notice, empty when none is set.
+func (n *ServerNotice) Get() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.message
+}
+
+// Set replaces the current notice; an empty message clears it.
+func (n *ServerNotice) Set(message string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.message = message
+}
+
+// Middleware adds the X-Server-Notice header to every response while a notice is set.
+func (n *ServerNotice) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if message := n.Get(); message != "" {
+			w.Header().Set(ServerNoticeHeader, message)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLogEntry collects per-request details filled in by inner handlers, such as the
+// authenticated user, which the outer logging middleware cannot see through the context.
+type requestLogEntry struct {
+	username string
+}
+
+// ContextKeyRequestLog is the key for the request's log entry in context.
+var ContextKeyRequestLog contextKey = "request_log"
+
+// setRequestLogUsername records the authenticated username for the request log line, if any.
+func setRequestLogUsername(ctx context.Context, username string) {
+	if entry, ok := ctx.Value(ContextKeyRequestLog).(*requestLogEntry); ok {
+		entry.username = username
+	}
+}
+
+// statusRecorder wraps a ResponseWriter to capture the status code and bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader captures the status code before passing it on.
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written, defaulting the status to 200 like net/http does.
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(p)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// RequestLoggerMiddleware logs the method, path, status, byte count, latency and
+// authenticated user (or "-") of every request.
+func RequestLoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &requestLogEntry{username: "-"}
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), ContextKeyRequestLog, entry)))
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK // Handler wrote nothing
+			}
+			logger.Info("request", "method", r.Method, "path", r.URL.Path, "status", recorder.status, "bytes", recorder.bytes, "duration_ms", time.Since(start).Milliseconds(), "user", entry.username)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// internal/middleware/middleware_test.go - Tests of the request logging middleware.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	var logged bytes.Buffer
+	handler := RequestLoggerMiddleware(slog.New(slog.NewJSONHandler(&logged, nil)))(s.handler)
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		wantUser   string
+	}{
+		{"anonymous", s.request(http.MethodGet, "/api/v1/packages/MyApp/releases?limit=1", nil), http.StatusOK, "-"},
+		{"not found", s.request(http.MethodGet, "/api/v1/packages/NoSuchApp/releases", nil), http.StatusNotFound, "-"},
+		{"basic auth", asAdmin(s.request(http.MethodPost, "/api/v1/auth/token", nil)), http.StatusCreated, "admin"},
+		{"api token", withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token), http.StatusOK, "admin"},
+		{"rejected credentials", withBasicAuth(s.request(http.MethodPost, "/api/v1/auth/token", nil), "admin", "wrong"), http.StatusUnauthorized, "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, tt.req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			var record struct {
+				Msg        string `json:"msg"`
+				Method     string `json:"method"`
+				Path       string `json:"path"`
+				Status     int    `json:"status"`
+				Bytes      int    `json:"bytes"`
+				DurationMS *int64 `json:"duration_ms"`
+				User       string `json:"user"`
+			}
+			if err := json.Unmarshal(logged.Bytes(), &record); err != nil {
+				t.Fatalf("failed to decode log line %q: %v", logged.String(), err)
+			}
+			if record.Msg != "request" || record.Method != tt.req.Method || record.Path != tt.req.URL.Path {
+				t.Errorf("log line describes %s %s (%q), want %s %s", record.Method, record.Path, record.Msg, tt.req.Method, tt.req.URL.Path)
+			}
+			if record.Status != tt.wantStatus {
+				t.Errorf("logged status = %d, want %d", record.Status, tt.wantStatus)
+			}
+			if record.Bytes != recorder.Body.Len() {
+				t.Errorf("logged bytes = %d, want the %d bytes sent", record.Bytes, recorder.Body.Len())
+			}
+			if record.DurationMS == nil || *record.DurationMS < 0 {
+				t.Errorf("logged duration = %v, want a non-negative duration", record.DurationMS)
+			}
+			if record.User != tt.wantUser {
+				t.Errorf("logged user = %q, want %q", record.User, tt.wantUser)
+			}
+		})
+	}
+
+	t.Run("empty response", func(t *testing.T) {
+		logged.Reset()
+		silent := RequestLoggerMiddleware(slog.New(slog.NewJSONHandler(&logged, nil)))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		silent.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/nothing", nil))
+		var record struct {
+			Status int `json:"status"`
+			Bytes  int `json:"bytes"`
+		}
+		if err := json.Unmarshal(logged.Bytes(), &record); err != nil || record.Status != http.StatusOK || record.Bytes != 0 {
+			t.Errorf("log line %q, want status 200 and no bytes for a handler writing nothing", logged.String())
+		}
+	})
+}
@@ -4,7 +4,10 @@
 // including models for software packages, releases, users, and API request/response formats.
 package main
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SoftwarePackage represents a software package definition.
 type SoftwarePackage struct {
@@ -12,6 +15,12 @@ type SoftwarePackage struct {
 	Description string `json:"description"` // Description of the software
 	Category    string `json:"category"`    // Category of software (e.g., "Library", "Application")
 	Enabled     bool   `json:"enabled"`     // Is the software package enabled for releases/access
+	Featured    bool   `json:"featured"`    // Highlighted in the featured package listing
+	Owner       string `json:"owner"`       // Username allowed to publish releases, empty if unowned
+
+	StorageQuotaBytes int64 `json:"storage_quota_bytes,omitempty"` // Overrides the configured package storage quota, 0 keeps it
+
+	Tags map[string]string `json:"tags,omitempty"` // Dist-tags naming release channels, tag -> version (e.g. "beta" -> "2.0.0-rc.1")
 }
 
 // SoftwarePackageInfo is a simplified info for listing software packages.
@@ -19,27 +28,170 @@ type SoftwarePackageInfo struct {
 	Name              string    `json:"name"`
 	LatestVersion     string    `json:"version"`
 	LatestReleaseDate time.Time `json:"release_date"`
+	Description       string    `json:"description"`
+	Category          string    `json:"category"`
+	Enabled           bool      `json:"enabled"`
+	Featured          bool      `json:"featured"`
+	Owner             string    `json:"owner"`
+}
+
+// PackageSearchResult groups the releases of one package that matched a search query.
+type PackageSearchResult struct {
+	SoftwareName string             `json:"software_name"`
+	Category     string             `json:"category"`
+	Releases     []*ReleaseMetadata `json:"releases"`
+}
+
+// VersionGap is a run of skipped version numbers inside a release line.
+type VersionGap struct {
+	Line        string `json:"line"`         // "X.Y" for patch gaps, "X" for minor gaps
+	Kind        string `json:"kind"`         // "patch" or "minor"
+	MissingFrom string `json:"missing_from"` // First skipped version
+	MissingTo   string `json:"missing_to"`   // Last skipped version, equal to MissingFrom for a single gap
+}
+
+// VersionGapsReport lists the version gaps found for a software package.
+type VersionGapsReport struct {
+	SoftwareName string       `json:"software_name"`
+	Gaps         []VersionGap `json:"gaps"`
+}
+
+// ReleaseVersionInfo is a release's metadata together with where it stands among the package's releases.
+type ReleaseVersionInfo struct {
+	*ReleaseMetadata
+	IsLatest           bool   `json:"is_latest"`            // Whether this release is the package's latest release
+	NewerVersionsCount int    `json:"newer_versions_count"` // Number of releases with a higher version
+	LatestVersion      string `json:"latest_version"`       // Version of the package's latest release
+}
+
+// ReconcileResult summarizes the metadata changes made by one reconciliation run.
+type ReconcileResult struct {
+	Checked          int             `json:"checked"`           // Releases whose file was checked
+	NewlyAvailable   int             `json:"newly_available"`   // Releases whose file reappeared or whose corrupt file matches its checksum again
+	NewlyUnavailable int             `json:"newly_unavailable"` // Releases whose file went missing
+	SizeUpdated      int             `json:"size_updated"`      // Releases whose recorded file size was corrected
+	Corrupt          int             `json:"corrupt"`           // Releases whose checksum does not match, including ones already marked corrupt
+	Errored          int             `json:"errored"`           // Releases whose file could not be checked, left unchanged
+	Notes            []ReconcileNote `json:"notes"`             // One entry per changed or errored release
+}
+
+// ReconcileNote describes what reconciliation changed or failed to check for a single release.
+type ReconcileNote struct {
+	SoftwareName string `json:"software_name"`
+	Version      string `json:"version"`
+	Message      string `json:"message"`
+}
+
+// ReleaseFilter restricts a release listing; zero fields do not filter.
+// Releases without a release date are left out as soon as Since or Until is set.
+type ReleaseFilter struct {
+	State string    // Only releases in this state
+	Since time.Time // Only releases with a release date at or after this time
+	Until time.Time // Only releases with a release date at or before this time
+}
+
+// PaginatedResponse is the envelope returned by paginated listing endpoints.
+type PaginatedResponse[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"` // Number of items across all pages
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
 
 // ReleaseMetadata holds metadata about a specific software release.
 type ReleaseMetadata struct {
-	ID               string    `json:"id"`                // Unique ID for the release (e.g., UUID)
-	SoftwareName     string    `json:"software_name"`     // Name of the software package
-	Version          string    `json:"version"`           // Release version (X.Y.Z)
-	ReleaseTimestamp time.Time `json:"release_timestamp"` // Timestamp of when the release was created/uploaded
-	FileSize         int64     `json:"file_size"`         // Size of the release TGZ file in bytes
-	ReleaseState     string    `json:"release_state"`     // State of the release ("available", "unavailable", etc.)
-	Changelog        string    `json:"changelog"`         // Release changelog/notes
-	ReleaseDate      time.Time `json:"release_date"`      // Release date provided by user
+	ID                string            `json:"id"`                           // Unique ID for the release (e.g., UUID)
+	SoftwareName      string            `json:"software_name"`                // Name of the software package
+	Version           string            `json:"version"`                      // Release version (X.Y.Z)
+	ReleaseTimestamp  time.Time         `json:"release_timestamp"`            // Timestamp of when the release was created/uploaded
+	FileSize          int64             `json:"file_size"`                    // Size of the release TGZ file in bytes
+	Checksum          string            `json:"checksum"`                     // Hex-encoded primary digest of the release TGZ file, used for integrity checks
+	ChecksumAlgorithm string            `json:"checksum_algorithm,omitempty"` // Algorithm of Checksum, SHA-256 when empty
+	Digests           map[string]string `json:"digests,omitempty"`            // Hex-encoded digests of the release TGZ file keyed by algorithm
+	ReleaseState      string            `json:"release_state"`                // State of the release ("available", "unavailable", "corrupt", "yanked")
+	DownloadCount     int64             `json:"download_count"`               // Number of times the full release file was served
+	UploadedBy        string            `json:"uploaded_by,omitempty"`        // User whose API key uploaded the release
+	Changelog         string            `json:"changelog"`                    // Release changelog/notes
+	ChangelogEntries  []ChangelogEntry  `json:"changelog_entries,omitempty"`  // Optional structured changelog
+	ReleaseDate       time.Time         `json:"release_date"`                 // Release date provided by user
+	HasSBOM           bool              `json:"has_sbom"`                     // Whether an SBOM document is attached to the release
+	SBOMFormat        string            `json:"sbom_format,omitempty"`        // Format of the attached SBOM ("cyclonedx" or "spdx")
+	HasSignature      bool              `json:"has_signature"`                // Whether a verified minisign signature of the uploaded file is attached
+	SBOMSize          int64             `json:"sbom_size,omitempty"`          // Size of the attached SBOM in bytes
+	SignatureSize     int64             `json:"signature_size,omitempty"`     // Size of the attached signature in bytes
+}
+
+// StoredSize returns the number of bytes the release takes up in the repository: its file and the
+// attached SBOM and signature.
+func (m *ReleaseMetadata) StoredSize() int64 {
+	return m.FileSize + m.SBOMSize + m.SignatureSize
+}
+
+// PrimaryChecksumAlgorithm returns the algorithm of the release's Checksum.
+func (m *ReleaseMetadata) PrimaryChecksumAlgorithm() string {
+	if m.ChecksumAlgorithm == "" {
+		return defaultChecksumAlgorithm // Recorded before the algorithm was configurable
+	}
+	return m.ChecksumAlgorithm
+}
+
+// ETag returns the quoted entity tag of the release file, its SHA-256 digest when one is recorded
+// and otherwise its primary checksum. It is empty when the release has no checksum.
+func (m *ReleaseMetadata) ETag() string {
+	digest := m.Digests[ChecksumSHA256]
+	if digest == "" {
+		digest = m.Checksum
+	}
+	if digest == "" {
+		return ""
+	}
+	return `"` + digest + `"`
+}
+
+// DownloadFileName returns the file name a release is offered under for download, software_version.tgz.
+// It is derived from the metadata rather than the storage file name, so it keeps the name's casing.
+func (m *ReleaseMetadata) DownloadFileName() string {
+	return m.SoftwareName + "_" + m.Version + ".tgz"
+}
+
+// CatalogEntry identifies a single release in an exported package catalog.
+type CatalogEntry struct {
+	SoftwareName      string `json:"software_name"`
+	Version           string `json:"version"`
+	Checksum          string `json:"checksum"`
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"` // Algorithm of Checksum, SHA-256 when empty
+}
+
+// PrimaryChecksumAlgorithm returns the algorithm of the entry's Checksum.
+func (e CatalogEntry) PrimaryChecksumAlgorithm() string {
+	if e.ChecksumAlgorithm == "" {
+		return defaultChecksumAlgorithm // Exported before catalogs named the algorithm
+	}
+	return e.ChecksumAlgorithm
 }
 
 // --- Request and Response structs for API endpoints ---
 
 // CreateUserRequest is the request body for creating a new user.
 type CreateUserRequest struct {
-	Username string   `json:"username"`
-	Password string   `json:"password"`
-	Roles    []string `json:"role"` // e.g., ["user", "administrator"]
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	Roles       []string `json:"roles"`          // e.g., ["user", "publisher", "administrator"]
+	LegacyRoles []string `json:"role,omitempty"` // Former name of roles, used when roles is absent
+}
+
+// BulkCreateUserResult is the outcome of one entry of a bulk user import.
+type BulkCreateUserResult struct {
+	Username string `json:"username"`
+	Created  bool   `json:"created"`
+	Error    string `json:"error,omitempty"` // Why the user was not created
+}
+
+// BulkCreateUsersResponse reports the outcome of a bulk user import, one result per request entry in request order.
+type BulkCreateUsersResponse struct {
+	Created int                    `json:"created"`
+	Failed  int                    `json:"failed"`
+	Results []BulkCreateUserResult `json:"results"`
 }
 
 // UpdateUserRequest is the request body for updating a user (e.g., password change).
@@ -52,11 +204,48 @@ type EnableDisableRequest struct {
 	Enabled bool `json:"enabled"`
 }
 
+// UpdateReleaseStateRequest is the request body for changing a release's state.
+type UpdateReleaseStateRequest struct {
+	State string `json:"state"` // "yanked", or "available" to undo a yank
+}
+
 // CreateSoftwareRequest is the request body for creating a new software package.
 type CreateSoftwareRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Category    string `json:"category"`
+	Owner       string `json:"owner"` // Optional owning user
+}
+
+// TransferSoftwareRequest is the request body for transferring a software package to a new owner.
+type TransferSoftwareRequest struct {
+	NewOwner string `json:"new_owner"`
+}
+
+// FeaturedRequest is the request body for flagging a software package as featured.
+type FeaturedRequest struct {
+	Featured bool `json:"featured"`
+}
+
+// StorageQuotaRequest is the request body for setting the storage quota of a software package.
+type StorageQuotaRequest struct {
+	StorageQuotaBytes int64 `json:"storage_quota_bytes"` // 0 falls back to the configured package quota
+}
+
+// ReleaseTagRequest is the request body for pointing a dist-tag at a release.
+type ReleaseTagRequest struct {
+	Version string `json:"version"`
+}
+
+// ReleaseTagsResponse lists the dist-tags of a software package.
+type ReleaseTagsResponse struct {
+	SoftwareName string            `json:"software_name"`
+	Tags         map[string]string `json:"tags"` // Tag -> version
+}
+
+// ServerNoticeRequest is the request body for setting the message of the day.
+type ServerNoticeRequest struct {
+	Message string `json:"message"`
 }
 
 // UpdateSoftwareRequest is the request body for updating a software package's details.
@@ -65,11 +254,77 @@ type UpdateSoftwareRequest struct {
 	Category    string `json:"category"`
 }
 
-// UploadReleaseRequest is the request body for uploading a new software release.
+// UploadReleaseRequest holds the metadata fields of a multipart release upload.
 type UploadReleaseRequest struct {
-	SoftwareName string    `json:"software_name"`
-	Version      string    `json:"version"`
-	ReleaseDate  time.Time `json:"release_date"`
-	Changelog    string    `json:"changelog"`
-	FileUrl      string    `json:"file_url"` // URL to download the release file from (or file upload in future)
+	SoftwareName     string           `json:"software_name"`
+	Version          string           `json:"version"`
+	ReleaseDate      time.Time        `json:"release_date"`
+	Changelog        string           `json:"changelog"`
+	ChangelogEntries []ChangelogEntry `json:"changelog_entries"`   // Structured changelog, sent as a JSON array
+	FileUrl          string           `json:"file_url"`            // URL to download the release file from
+	SBOM             json.RawMessage  `json:"sbom,omitempty"`      // Optional CycloneDX or SPDX JSON document
+	Signature        string           `json:"signature,omitempty"` // Optional minisign signature of the uploaded file
+	Overwrite        bool             `json:"overwrite"`           // Replace an existing release of the same version
+}
+
+// CreateAPITokenRequest is the optional request body for generating an API token.
+type CreateAPITokenRequest struct {
+	Name        string `json:"name"`        // Identifies the token among the user's tokens
+	Description string `json:"description"` // Free-text note about the token's use
+}
+
+// APITokenInfo describes an API token without revealing its secret.
+type APITokenInfo struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"` // First characters of the secret, enough to recognize the key
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"` // Accurate to about a minute, absent if never used
+	Revoked     bool       `json:"revoked"`
+	Stale       bool       `json:"stale"` // Unused for longer than token_stale_after_days
+}
+
+// CreateAPITokenResponse is the response body for a newly generated API token.
+type CreateAPITokenResponse struct {
+	APIKey    string     `json:"api_key"`
+	TokenID   string     `json:"token_id"`
+	Name      string     `json:"name,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// UserSecurityStatus reports a user's failed-login counters and lock status.
+type UserSecurityStatus struct {
+	Username       string     `json:"username"`
+	FailedAttempts int        `json:"failed_attempts"`
+	Locked         bool       `json:"locked"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	LastFailedAt   *time.Time `json:"last_failed_at,omitempty"`
+}
+
+// UserAccessReport aggregates everything that determines what a user is allowed to do.
+type UserAccessReport struct {
+	Username        string              `json:"username"`
+	Enabled         bool                `json:"enabled"`          // Disabled users cannot authenticate
+	Roles           []string            `json:"roles"`            // Roles stored in the user record
+	IsAdministrator bool                `json:"is_administrator"` // Whether the roles grant access to the admin API
+	OwnedPackages   []string            `json:"owned_packages"`   // Packages only this user may publish releases to
+	ActiveTokens    []APITokenInfo      `json:"active_tokens"`    // API tokens that are neither revoked nor expired
+	Security        *UserSecurityStatus `json:"security"`         // Failed-login counters and lock status
+}
+
+// CatalogDiffResponse reports the releases that differ between this instance and another instance's catalog.
+type CatalogDiffResponse struct {
+	OnlyLocal  []CatalogEntry `json:"only_local"`  // Releases present here but not in the other catalog
+	OnlyRemote []CatalogEntry `json:"only_remote"` // Releases present in the other catalog but not here
+	// Releases present in both catalogs whose checksums were computed with different algorithms
+	NotComparable []CatalogComparison `json:"not_comparable"`
+}
+
+// CatalogComparison pairs the entries of a release found in both catalogs.
+type CatalogComparison struct {
+	Local  CatalogEntry `json:"local"`
+	Remote CatalogEntry `json:"remote"`
 }
@@ -0,0 +1,103 @@
+// internal/openapi/openapi.go - Embedded OpenAPI document.
+//
+// This file embeds the OpenAPI 3 description of the API, serves it at /openapi.json,
+// and checks at startup that it documents exactly the routes registered on the router.
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// handleGetOpenAPI serves the embedded OpenAPI document.
+func handleGetOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(openAPIDocument)
+	}
+}
+
+// openAPIOperations returns the "METHOD /path" operations described by an OpenAPI document.
+func openAPIOperations(document []byte) (map[string]bool, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(document, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	operations := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "head", "post", "put", "patch", "delete", "options":
+				operations[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	return operations, nil
+}
+
+// routerOperations returns the "METHOD /path" operations registered on a router.
+// Routes without a method matcher, such as subrouter prefixes, are skipped.
+func routerOperations(router *mux.Router) (map[string]bool, error) {
+	operations := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			operations[method+" "+path] = true
+		}
+		return nil
+	})
+	return operations, err
+}
+
+// CheckOpenAPIRoutes logs a warning for every route missing from the embedded OpenAPI document
+// and every documented operation that no route serves.
+func CheckOpenAPIRoutes(router *mux.Router, logger *slog.Logger) {
+	documented, err := openAPIOperations(openAPIDocument)
+	if err != nil {
+		logger.Warn("Could not check OpenAPI document against routes", "error", err)
+		return
+	}
+	registered, err := routerOperations(router)
+	if err != nil {
+		logger.Warn("Could not check OpenAPI document against routes", "error", err)
+		return
+	}
+	for _, operation := range sortedOperationDifference(registered, documented) {
+		logger.Warn("Route is not described in the OpenAPI document", "operation", operation)
+	}
+	for _, operation := range sortedOperationDifference(documented, registered) {
+		logger.Warn("OpenAPI document describes a route that is not registered", "operation", operation)
+	}
+}
+
+// sortedOperationDifference returns the operations in a that are not in b, sorted.
+func sortedOperationDifference(a map[string]bool, b map[string]bool) []string {
+	var difference []string
+	for operation := range a {
+		if !b[operation] {
+			difference = append(difference, operation)
+		}
+	}
+	sort.Strings(difference)
+	return difference
+}
@@ -0,0 +1,63 @@
+// internal/openapi/openapi_test.go - Tests of the embedded OpenAPI document.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServeOpenAPIDocument(t *testing.T) {
+	s := newTestServer(t, nil)
+	resp := s.do(s.request(http.MethodGet, "/openapi.json", nil))
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/openapi.json = %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	var document struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string]json.RawMessage `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &document); err != nil {
+		t.Fatalf("served document is not JSON: %v", err)
+	}
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want an OpenAPI 3 document", document.OpenAPI)
+	}
+	for _, path := range []string{"/api/v1/status", "/api/v1/releases", "/api/v1/releases/{software_name}/{version}", "/api/v1/admin/users"} {
+		if _, ok := document.Paths[path]; !ok {
+			t.Errorf("document does not describe %s", path)
+		}
+	}
+	for _, scheme := range []string{"basicAuth", "apiKey"} {
+		if _, ok := document.Components.SecuritySchemes[scheme]; !ok {
+			t.Errorf("document does not define the %s security scheme", scheme)
+		}
+	}
+}
+
+func TestOpenAPIDocumentMatchesRoutes(t *testing.T) {
+	s := newTestServer(t, nil)
+	documented, err := openAPIOperations(openAPIDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered, err := routerOperations(s.handler.(*mux.Router))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := sortedOperationDifference(registered, documented); len(missing) != 0 {
+		t.Errorf("routes missing from the OpenAPI document: %v", missing)
+	}
+	if stale := sortedOperationDifference(documented, registered); len(stale) != 0 {
+		t.Errorf("documented operations without a route: %v", stale)
+	}
+	if !registered["GET /openapi.json"] {
+		t.Error("the document's own route is not registered")
+	}
+}
@@ -0,0 +1,152 @@
+// internal/repository/package.go - Software package persistence.
+//
+// This file defines the SoftwarePackageDatabase interface and its JSON file-based
+// implementation for storing package-level details that are not derived from releases.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SoftwarePackageDatabase interface defines operations for software package definitions.
+type SoftwarePackageDatabase interface {
+	GetSoftwarePackage(name string) (*SoftwarePackage, error)
+	ListSoftwarePackages() ([]*SoftwarePackage, error)
+	CreateSoftwarePackage(software *SoftwarePackage) error
+	UpdateSoftwarePackage(software *SoftwarePackage) error
+	DeleteSoftwarePackage(name string) error
+	Close() error
+}
+
+// JSONSoftwarePackageDatabase is a JSON file-based implementation of SoftwarePackageDatabase.
+type JSONSoftwarePackageDatabase struct {
+	filepath string
+	packages map[string]*SoftwarePackage // softwareKey -> package
+	mu       sync.RWMutex                // Mutex for read/write operations
+}
+
+// NewJSONSoftwarePackageDatabase creates a new JSONSoftwarePackageDatabase instance.
+func NewJSONSoftwarePackageDatabase(filepath string) (*JSONSoftwarePackageDatabase, error) {
+	db := &JSONSoftwarePackageDatabase{
+		filepath: filepath,
+		packages: make(map[string]*SoftwarePackage),
+	}
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
+	if err := db.loadSoftwarePackages(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// GetSoftwarePackage retrieves a software package definition by name, regardless of casing.
+func (db *JSONSoftwarePackageDatabase) GetSoftwarePackage(name string) (*SoftwarePackage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	software, ok := db.packages[softwareKey(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, name)
+	}
+	return software, nil
+}
+
+// ListSoftwarePackages retrieves all software package definitions.
+func (db *JSONSoftwarePackageDatabase) ListSoftwarePackages() ([]*SoftwarePackage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	packageList := make([]*SoftwarePackage, 0, len(db.packages))
+	for _, software := range db.packages {
+		packageList = append(packageList, software)
+	}
+	return packageList, nil
+}
+
+// CreateSoftwarePackage creates a new software package definition. Names are unique regardless of casing.
+func (db *JSONSoftwarePackageDatabase) CreateSoftwarePackage(software *SoftwarePackage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	key := softwareKey(software.Name)
+	if existing, exists := db.packages[key]; exists {
+		return fmt.Errorf("software package already exists: %s", existing.Name)
+	}
+	db.packages[key] = software
+	return db.saveSoftwarePackages()
+}
+
+// UpdateSoftwarePackage overwrites an existing software package definition, keeping its stored name.
+func (db *JSONSoftwarePackageDatabase) UpdateSoftwarePackage(software *SoftwarePackage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	key := softwareKey(software.Name)
+	existing, exists := db.packages[key]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, software.Name)
+	}
+	updated := *software
+	updated.Name = existing.Name
+	db.packages[key] = &updated
+	return db.saveSoftwarePackages()
+}
+
+// DeleteSoftwarePackage deletes a software package definition.
+func (db *JSONSoftwarePackageDatabase) DeleteSoftwarePackage(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	key := softwareKey(name)
+	if _, exists := db.packages[key]; !exists {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, name)
+	}
+	delete(db.packages, key)
+	return db.saveSoftwarePackages()
+}
+
+// Close closes the database connection (no action needed for JSON file).
+func (db *JSONSoftwarePackageDatabase) Close() error {
+	return nil // No resources to close for JSON file DB
+}
+
+// loadSoftwarePackages loads software package definitions from the JSON file.
+func (db *JSONSoftwarePackageDatabase) loadSoftwarePackages() error {
+	if _, err := os.Stat(db.filepath); os.IsNotExist(err) {
+		return nil // File doesn't exist, assume empty DB
+	}
+
+	file, err := os.Open(db.filepath)
+	if err != nil {
+		return fmt.Errorf("failed to open software package database file: %w", err)
+	}
+	defer file.Close()
+
+	var packages []*SoftwarePackage // Slice to hold packages from JSON
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&packages); err != nil {
+		return fmt.Errorf("failed to decode software package database: %w", err)
+	}
+
+	db.packages = make(map[string]*SoftwarePackage) // Initialize map
+	for _, software := range packages {
+		key := softwareKey(software.Name)
+		if existing, ok := db.packages[key]; ok { // Written before names were matched regardless of casing
+			return fmt.Errorf("software packages %s and %s only differ in the casing of the name, remove one of them", existing.Name, software.Name)
+		}
+		db.packages[key] = software
+	}
+	return nil
+}
+
+// saveSoftwarePackages saves software package definitions to the JSON file. Callers must hold db.mu for writing.
+func (db *JSONSoftwarePackageDatabase) saveSoftwarePackages() error {
+	packagesSlice := make([]*SoftwarePackage, 0, len(db.packages))
+	for _, software := range db.packages {
+		packagesSlice = append(packagesSlice, software)
+	}
+
+	if err := writeJSONFileAtomic(db.filepath, packagesSlice); err != nil {
+		return fmt.Errorf("failed to write software package database file: %w", err)
+	}
+	return nil
+}
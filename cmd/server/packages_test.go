@@ -0,0 +1,618 @@
+// api/packages_test.go - Tests of the software package listings.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listPackages fetches the public package listing, keyed by package name.
+func (s *testServer) listPackages() map[string]*SoftwarePackageInfo {
+	s.t.Helper()
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages", nil))
+	if resp.Code != http.StatusOK {
+		s.t.Fatalf("listing packages = %d: %s", resp.Code, resp.Body)
+	}
+	var page PaginatedResponse[*SoftwarePackageInfo]
+	decodeResponse(s.t, resp, &page)
+	byName := make(map[string]*SoftwarePackageInfo, len(page.Items))
+	for _, pkgInfo := range page.Items {
+		byName[pkgInfo.Name] = pkgInfo
+	}
+	return byName
+}
+
+func TestListSoftwarePackagesSkipsWithdrawnReleases(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for i, version := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		releaseDate := time.Date(2024, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		if resp := s.upload(token, "MyApp", version, []byte("release "+version), map[string]string{"release_date": releaseDate}); resp.Code != http.StatusCreated {
+			t.Fatalf("uploading MyApp %s = %d: %s", version, resp.Code, resp.Body)
+		}
+	}
+	s.mustUpload(token, "Withdrawn", "1.0.0", []byte("withdrawn release"))
+	for _, release := range []struct{ name, version string }{{"MyApp", "2.0.0"}, {"Withdrawn", "1.0.0"}} {
+		yank := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/"+release.name+"/releases/"+release.version+"/state", UpdateReleaseStateRequest{State: "yanked"}))
+		if resp := s.do(yank); resp.Code != http.StatusOK {
+			t.Fatalf("yanking %s %s = %d: %s", release.name, release.version, resp.Code, resp.Body)
+		}
+	}
+	unavailable, err := s.releaseService.GetRelease("MyApp", "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, unavailable)); err != nil {
+		t.Fatal(err)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil))); resp.Code != http.StatusOK {
+		t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+	}
+
+	packages := s.listPackages()
+	if got := packages["MyApp"]; got == nil || got.LatestVersion != "1.0.0" {
+		t.Errorf("MyApp is listed as %+v, want latest version 1.0.0", got)
+	}
+	if got, want := packages["MyApp"], time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); got != nil && !got.LatestReleaseDate.Equal(want) {
+		t.Errorf("MyApp is listed with release date %v, want %v", got.LatestReleaseDate, want)
+	}
+	if got := packages["Withdrawn"]; got == nil || got.LatestVersion != "" {
+		t.Errorf("Withdrawn is listed as %+v, want it listed without a latest version", got)
+	}
+}
+
+func TestSoftwareNamesIgnoreCase(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "myapp", "1.1.0", []byte("release 1.1.0"))
+	s.mustUpload(token, " MYAPP ", "1.2.0", []byte("release 1.2.0"))
+
+	packages := s.listPackages()
+	if len(packages) != 1 || packages["MyApp"] == nil || packages["MyApp"].LatestVersion != "1.2.0" {
+		t.Fatalf("packages = %v, want only MyApp at 1.2.0", packages)
+	}
+	for _, name := range []string{"MyApp", "myapp", "MYAPP", "mYaPp"} {
+		t.Run(name, func(t *testing.T) {
+			if _, versions := s.listReleaseVersions(name, "?order=asc"); !slices.Equal(versions, []string{"1.0.0", "1.1.0", "1.2.0"}) {
+				t.Errorf("releases of %s = %v, want all three", name, versions)
+			}
+			release, err := s.releaseService.GetRelease(name, "1.0.0")
+			if err != nil || release.SoftwareName != "MyApp" {
+				t.Errorf("GetRelease(%s) = %+v, %v; want the release of MyApp", name, release, err)
+			}
+			download := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/"+name+"/1.1.0", nil), token))
+			if download.Code != http.StatusOK {
+				t.Errorf("download of %s 1.1.0 = %d: %s", name, download.Code, download.Body)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(s.cfg.RepositoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("repository holds %d software directories, want 1", len(entries))
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "MYAPP"}))); resp.Code != http.StatusBadRequest {
+		t.Errorf("creating MYAPP next to MyApp = %d, want 400", resp.Code)
+	}
+}
+
+func TestEnableDisableSoftwarePackage(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantEnabled bool
+	}{
+		{"disable", false, false},
+		{"enable", true, true},
+		{"enable again", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/status", EnableDisableRequest{Enabled: tt.enabled}))
+			if resp := s.do(req); resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			software, err := s.packageDB.GetSoftwarePackage("MyApp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if software.Enabled != tt.wantEnabled {
+				t.Errorf("stored Enabled = %v, want %v", software.Enabled, tt.wantEnabled)
+			}
+			if listed := s.listPackages()["MyApp"] != nil; listed != tt.wantEnabled {
+				t.Errorf("MyApp listed publicly = %v, want %v", listed, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestLookupInOtherCasing(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "MyApp", "1.1.0", []byte("release 1.1.0"))
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "NewTool"}))); resp.Code != http.StatusCreated {
+		t.Fatalf("creating NewTool = %d: %s", resp.Code, resp.Body)
+	}
+
+	tests := []struct {
+		name     string
+		req      func(softwareName string) *http.Request
+		wantName string // Stored name the response must report, empty for downloads
+	}{
+		{"release listing", func(n string) *http.Request {
+			return s.request(http.MethodGet, "/api/v1/packages/"+n+"/releases", nil)
+		}, "MyApp"},
+		{"admin release listing", func(n string) *http.Request {
+			return asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/"+n+"/releases", nil))
+		}, "MyApp"},
+		{"latest release", func(n string) *http.Request {
+			return s.request(http.MethodGet, "/api/v1/packages/"+n+"/latest", nil)
+		}, "MyApp"},
+		{"release metadata", func(n string) *http.Request {
+			return s.request(http.MethodGet, "/api/v1/packages/"+n+"/releases/1.0.0", nil)
+		}, "MyApp"},
+		{"tags", func(n string) *http.Request {
+			return s.request(http.MethodGet, "/api/v1/packages/"+n+"/tags", nil)
+		}, "MyApp"},
+		{"download", func(n string) *http.Request {
+			return withToken(s.request(http.MethodGet, "/api/v1/releases/"+n+"/1.0.0", nil), token)
+		}, ""},
+		{"latest download", func(n string) *http.Request {
+			return withToken(s.request(http.MethodGet, "/api/v1/packages/"+n+"/latest/download", nil), token)
+		}, ""},
+	}
+	for _, tt := range tests {
+		for _, casing := range []string{"MyApp", "myapp", "MYAPP"} {
+			t.Run(tt.name+" "+casing, func(t *testing.T) {
+				resp := s.do(tt.req(casing))
+				if resp.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+				}
+				if tt.wantName != "" && !strings.Contains(resp.Body.String(), `"`+tt.wantName+`"`) {
+					t.Errorf("response %s does not name %s", resp.Body, tt.wantName)
+				}
+			})
+		}
+	}
+
+	if _, versions := s.listReleaseVersions("newtool", ""); versions == nil {
+		t.Error("a package without releases was not found in other casing")
+	}
+	if code, _ := s.listReleaseVersions("myap", ""); code != http.StatusNotFound {
+		t.Errorf("listing a missing package = %d, want 404", code)
+	}
+}
+
+// listFeatured fetches the featured package listing and returns the package names in order.
+func (s *testServer) listFeatured() []string {
+	s.t.Helper()
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages/featured", nil))
+	if resp.Code != http.StatusOK {
+		s.t.Fatalf("listing featured packages = %d: %s", resp.Code, resp.Body)
+	}
+	var packages []*SoftwarePackageInfo
+	decodeResponse(s.t, resp, &packages)
+	names := make([]string, len(packages))
+	for i, pkgInfo := range packages {
+		if !pkgInfo.Featured {
+			s.t.Errorf("%s is listed as featured without the flag", pkgInfo.Name)
+		}
+		names[i] = pkgInfo.Name
+	}
+	return names
+}
+
+func TestFeaturedPackages(t *testing.T) {
+	for _, tt := range []struct {
+		order string
+		want  []string
+	}{
+		{FeaturedOrderName, []string{"Alpha", "Delta", "Gamma"}},
+		{FeaturedOrderLatestRelease, []string{"Gamma", "Alpha", "Delta"}}, // Delta has no release yet
+	} {
+		t.Run(tt.order, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) { cfg.FeaturedPackagesOrder = tt.order })
+			token := s.token("admin", testAdminPassword)
+			for i, name := range []string{"Alpha", "Beta", "Gamma"} {
+				releaseDate := time.Date(2024, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+				if resp := s.upload(token, name, "1.0.0", []byte("release of "+name), map[string]string{"release_date": releaseDate}); resp.Code != http.StatusCreated {
+					t.Fatalf("uploading %s = %d: %s", name, resp.Code, resp.Body)
+				}
+			}
+			if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "Delta"}))); resp.Code != http.StatusCreated {
+				t.Fatalf("creating Delta = %d: %s", resp.Code, resp.Body)
+			}
+			if got := s.listFeatured(); len(got) != 0 {
+				t.Fatalf("featured packages = %v, want none before any is flagged", got)
+			}
+
+			setFeatured := func(name string, featured bool) int {
+				return s.do(asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/"+name+"/featured", FeaturedRequest{Featured: featured}))).Code
+			}
+			for _, name := range []string{"gamma", "Alpha", "Delta"} {
+				if status := setFeatured(name, true); status != http.StatusOK {
+					t.Fatalf("featuring %s = %d", name, status)
+				}
+			}
+			if got := s.listFeatured(); !slices.Equal(got, tt.want) {
+				t.Errorf("featured packages = %v, want %v", got, tt.want)
+			}
+			if listed := s.listPackages(); listed["Gamma"] == nil || !listed["Gamma"].Featured || listed["Beta"] == nil || listed["Beta"].Featured {
+				t.Errorf("package listing flags Gamma as %+v and Beta as %+v, want only Gamma featured", listed["Gamma"], listed["Beta"])
+			}
+
+			if status := setFeatured("Alpha", false); status != http.StatusOK {
+				t.Fatalf("unfeaturing Alpha = %d", status)
+			}
+			if err := s.releaseService.EnableDisableSoftwarePackage("Delta", false); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.listFeatured(); !slices.Equal(got, []string{"Gamma"}) {
+				t.Errorf("featured packages = %v, want only Gamma once Alpha is unflagged and Delta disabled", got)
+			}
+		})
+	}
+
+	t.Run("access and missing package", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		s.createUser("alice", "Alice-Pass-123", "publisher")
+		s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release"))
+		req := withBasicAuth(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/featured", FeaturedRequest{Featured: true}), "alice", "Alice-Pass-123")
+		if resp := s.do(req); resp.Code != http.StatusForbidden {
+			t.Errorf("featuring as a publisher = %d, want %d", resp.Code, http.StatusForbidden)
+		}
+		if resp := s.do(asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/NoSuchApp/featured", FeaturedRequest{Featured: true}))); resp.Code != http.StatusNotFound {
+			t.Errorf("featuring a missing package = %d, want %d", resp.Code, http.StatusNotFound)
+		}
+		if got := s.listFeatured(); len(got) != 0 {
+			t.Errorf("featured packages = %v, want none", got)
+		}
+	})
+}
+
+func TestSoftwarePackageLifecycle(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	stored := func(name string) *SoftwarePackage { // Read back from packages.json, as a restarted server would
+		t.Helper()
+		packageDB, err := NewJSONSoftwarePackageDatabase(filepath.Join(s.cfg.DataPath, "packages.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		software, err := packageDB.GetSoftwarePackage(name)
+		if err != nil {
+			return nil
+		}
+		return software
+	}
+
+	create := asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "MyApp", Description: "An app", Category: "tools"}))
+	if resp := s.do(create); resp.Code != http.StatusCreated {
+		t.Fatalf("creating MyApp = %d: %s", resp.Code, resp.Body)
+	}
+	if got := stored("MyApp"); got == nil || got.Description != "An app" || got.Category != "tools" || !got.Enabled {
+		t.Errorf("stored MyApp = %+v, want the description, category and enabled", got)
+	}
+	if got := s.listPackages()["MyApp"]; got == nil || got.LatestVersion != "" || got.Description != "An app" {
+		t.Errorf("MyApp is listed as %+v, want its details without a latest version", got)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "MyApp"}))); resp.Code != http.StatusBadRequest {
+		t.Errorf("creating MyApp again = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+
+	update := asAdmin(s.request(http.MethodPut, "/api/v1/admin/packages/MyApp", UpdateSoftwareRequest{Description: "A better app", Category: "utilities"}))
+	if resp := s.do(update); resp.Code != http.StatusOK {
+		t.Fatalf("updating MyApp = %d: %s", resp.Code, resp.Body)
+	}
+	if got := stored("MyApp"); got == nil || got.Description != "A better app" || got.Category != "utilities" {
+		t.Errorf("stored MyApp = %+v, want the updated details", got)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodPut, "/api/v1/admin/packages/NoSuchApp", UpdateSoftwareRequest{Description: "x"}))); resp.Code != http.StatusBadRequest {
+		t.Errorf("updating a missing package = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+
+	// Deleting the package deletes its releases with it, and leaves other packages alone.
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+	s.mustUpload(token, "Other", "1.0.0", []byte("other release"))
+	if got := s.listPackages()["MyApp"]; got == nil || got.LatestVersion != "1.1.0" || got.Category != "utilities" {
+		t.Errorf("MyApp is listed as %+v, want the stored details with latest version 1.1.0", got)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/packages/myapp", nil))); resp.Code != http.StatusNoContent {
+		t.Fatalf("deleting MyApp = %d: %s", resp.Code, resp.Body)
+	}
+	if got := stored("MyApp"); got != nil {
+		t.Errorf("MyApp is still stored as %+v", got)
+	}
+	if releases, err := s.releaseDB.ListReleasesMetadataForSoftware("MyApp"); err == nil && len(releases) > 0 {
+		t.Errorf("releases of MyApp = %v, want them deleted", releaseNames(releases))
+	}
+	all, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := releaseNames(all); !slices.Equal(got, []string{"Other 1.0.0"}) {
+		t.Errorf("remaining releases = %v, want only Other 1.0.0", got)
+	}
+	for _, file := range listFiles(t, s.cfg.RepositoryPath) {
+		if strings.Contains(strings.ToLower(file), "myapp") {
+			t.Errorf("file %s of MyApp is left behind", file)
+		}
+	}
+	if packages := s.listPackages(); packages["MyApp"] != nil || packages["Other"] == nil {
+		t.Errorf("packages = %v, want only Other", packages)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/packages/MyApp", nil))); resp.Code != http.StatusBadRequest {
+		t.Errorf("deleting MyApp again = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDeleteSoftwarePackageRemovesFiles(t *testing.T) {
+	setup := func(t *testing.T) (*testServer, map[string]string) {
+		s := newTestServer(t, nil)
+		token := s.token("admin", testAdminPassword)
+		filePaths := map[string]string{}
+		for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+			if resp := s.upload(token, "MyApp", version, []byte("release "+version), map[string]string{"sbom": testCycloneDXSBOM}); resp.Code != http.StatusCreated {
+				t.Fatalf("uploading %s = %d: %s", version, resp.Code, resp.Body)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filePaths[version] = s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+		}
+		return s, filePaths
+	}
+
+	t.Run("all files removed", func(t *testing.T) {
+		s, filePaths := setup(t)
+		softwareDir := filepath.Dir(filePaths["1.0.0"])
+		if files := listFiles(t, softwareDir); len(files) != 6 {
+			t.Fatalf("software directory holds %v, want a release file and an SBOM per release", files)
+		}
+		if err := s.releaseService.DeleteSoftwarePackage("MyApp"); err != nil {
+			t.Fatalf("DeleteSoftwarePackage failed: %v", err)
+		}
+		if _, err := os.Stat(softwareDir); !os.IsNotExist(err) {
+			t.Errorf("software directory %s is left behind: %v", softwareDir, err)
+		}
+		if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 0 {
+			t.Errorf("repository holds %v, want it empty", files)
+		}
+	})
+
+	t.Run("undeletable file", func(t *testing.T) {
+		s, filePaths := setup(t)
+		stuck := filePaths["1.1.0"] // A directory with content in place of the file cannot be removed
+		if err := os.Remove(stuck); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(stuck, "content"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		err := s.releaseService.DeleteSoftwarePackage("MyApp")
+		if err == nil || !strings.Contains(err.Error(), "MyApp 1.1.0") || strings.Contains(err.Error(), "1.0.0") || strings.Contains(err.Error(), "1.2.0") {
+			t.Fatalf("DeleteSoftwarePackage = %v, want an error naming only 1.1.0", err)
+		}
+		for version, filePath := range filePaths {
+			_, statErr := os.Stat(filePath)
+			_, getErr := s.releaseDB.GetReleaseMetadata("MyApp", version)
+			if wantKept := version == "1.1.0"; (statErr == nil) != wantKept || (getErr == nil) != wantKept {
+				t.Errorf("%s: file kept = %v, metadata kept = %v; want both %v", version, statErr == nil, getErr == nil, wantKept)
+			}
+		}
+		if _, err := os.Stat(filepath.Dir(stuck)); err != nil {
+			t.Errorf("software directory holding the undeletable file is gone: %v", err)
+		}
+		if got := s.listPackages()["MyApp"]; got == nil || got.LatestVersion != "1.1.0" {
+			t.Errorf("MyApp is listed as %+v, want it kept with the remaining release", got)
+		}
+
+		// Once the file can be removed, deleting again finishes the job.
+		if err := os.RemoveAll(stuck); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.releaseService.DeleteSoftwarePackage("MyApp"); err != nil {
+			t.Fatalf("deleting again failed: %v", err)
+		}
+		if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 0 {
+			t.Errorf("repository holds %v, want it empty", files)
+		}
+	})
+}
+
+func TestTransferSoftwarePackage(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	s.createUser("bob", "Bob-Pass-123", "publisher")
+	tokens := map[string]string{"alice": s.token("alice", "Alice-Pass-123"), "bob": s.token("bob", "Bob-Pass-123")}
+	create := asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "MyApp", Owner: "alice"}))
+	if resp := s.do(create); resp.Code != http.StatusCreated {
+		t.Fatalf("creating MyApp = %d: %s", resp.Code, resp.Body)
+	}
+	transfer := func(name string, newOwner string) int {
+		return s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages/"+name+"/transfer", TransferSoftwareRequest{NewOwner: newOwner}))).Code
+	}
+	ownedPackages := func(username string) []string {
+		resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users/"+username+"/access", nil)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("access report of %s = %d: %s", username, resp.Code, resp.Body)
+		}
+		var report UserAccessReport
+		decodeResponse(t, resp, &report)
+		return report.OwnedPackages
+	}
+
+	// The steps run in order; each checks who may publish afterwards.
+	steps := []struct {
+		name       string
+		transferTo string
+		wantStatus int
+		wantOwner  string
+	}{
+		{"created", "", 0, "alice"},
+		{"to bob", "bob", http.StatusOK, "bob"},
+		{"to a missing user", "mallory", http.StatusBadRequest, "bob"},
+		{"back to alice", "alice", http.StatusOK, "alice"},
+	}
+	for i, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.transferTo != "" {
+				if status := transfer("myapp", step.transferTo); status != step.wantStatus {
+					t.Fatalf("transfer = %d, want %d", status, step.wantStatus)
+				}
+			}
+			if got := s.listPackages()["MyApp"]; got == nil || got.Owner != step.wantOwner {
+				t.Errorf("MyApp is listed as %+v, want owner %s", got, step.wantOwner)
+			}
+			for username, token := range tokens {
+				wantStatus := http.StatusForbidden
+				wantOwned := []string{}
+				if username == step.wantOwner {
+					wantStatus, wantOwned = http.StatusCreated, []string{"MyApp"}
+				}
+				version := fmt.Sprintf("1.%d.0-%s", i, username)
+				if resp := s.upload(token, "MyApp", version, []byte("release "+version), nil); resp.Code != wantStatus {
+					t.Errorf("upload by %s = %d, want %d: %s", username, resp.Code, wantStatus, resp.Body)
+				}
+				if got := ownedPackages(username); !slices.Equal(got, wantOwned) {
+					t.Errorf("packages owned by %s = %v, want %v", username, got, wantOwned)
+				}
+			}
+		})
+	}
+
+	t.Run("missing package", func(t *testing.T) {
+		if status := transfer("NoSuchApp", "bob"); status != http.StatusNotFound {
+			t.Errorf("transfer = %d, want %d", status, http.StatusNotFound)
+		}
+	})
+	t.Run("publisher", func(t *testing.T) {
+		req := withBasicAuth(s.request(http.MethodPost, "/api/v1/admin/packages/MyApp/transfer", TransferSoftwareRequest{NewOwner: "alice"}), "alice", "Alice-Pass-123")
+		if resp := s.do(req); resp.Code != http.StatusForbidden {
+			t.Errorf("transfer by the owner = %d, want %d", resp.Code, http.StatusForbidden)
+		}
+	})
+	t.Run("audit log", func(t *testing.T) {
+		var logged bytes.Buffer
+		releaseService := NewReleaseService(s.cfg, s.releaseDB, s.packageDB, slog.New(slog.NewJSONHandler(&logged, nil)))
+		if err := releaseService.TransferSoftwarePackage("myapp", "bob", "admin"); err != nil {
+			t.Fatal(err)
+		}
+		var record map[string]any
+		if err := json.Unmarshal(logged.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode the audit record %q: %v", logged.String(), err)
+		}
+		want := map[string]any{"actor": "admin", "software_name": "MyApp", "previous_owner": "alice", "new_owner": "bob"}
+		for key, value := range want {
+			if record[key] != value {
+				t.Errorf("audit record %s = %v, want %v", key, record[key], value)
+			}
+		}
+	})
+}
+
+func TestDisabledPackageIsHidden(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+	s.mustUpload(token, "Other", "1.0.0", []byte("other release"))
+	setEnabled := func(enabled bool) {
+		t.Helper()
+		if resp := s.do(asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/status", EnableDisableRequest{Enabled: enabled}))); resp.Code != http.StatusOK {
+			t.Fatalf("setting enabled to %v = %d: %s", enabled, resp.Code, resp.Body)
+		}
+	}
+	adminPackages := func(query string) map[string]*SoftwarePackageInfo {
+		t.Helper()
+		resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages"+query, nil)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("admin package listing = %d: %s", resp.Code, resp.Body)
+		}
+		var page PaginatedResponse[*SoftwarePackageInfo]
+		decodeResponse(t, resp, &page)
+		byName := map[string]*SoftwarePackageInfo{}
+		for _, pkgInfo := range page.Items {
+			byName[pkgInfo.Name] = pkgInfo
+		}
+		return byName
+	}
+	publicPaths := []string{
+		"/api/v1/packages/MyApp/releases",
+		"/api/v1/packages/MyApp/releases?include_disabled=true", // Only honored on the admin side
+		"/api/v1/packages/MyApp/latest",
+		"/api/v1/packages/MyApp/releases/1.0.0",
+		"/api/v1/packages/MyApp/1.0.0/changelog",
+		"/api/v1/releases/MyApp/1.0.0",
+		"/api/v1/packages/MyApp/latest/download",
+	}
+	setEnabled(false)
+
+	for _, path := range publicPaths {
+		t.Run("public "+path, func(t *testing.T) {
+			if resp := s.do(withToken(s.request(http.MethodGet, path, nil), token)); resp.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d: %s", resp.Code, http.StatusNotFound, resp.Body)
+			}
+		})
+	}
+	t.Run("public listing", func(t *testing.T) {
+		if packages := s.listPackages(); packages["MyApp"] != nil || packages["Other"] == nil {
+			t.Errorf("packages = %v, want only Other", packages)
+		}
+		if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/Other/1.0.0", nil), token)); resp.Code != http.StatusOK {
+			t.Errorf("download of Other = %d, want it unaffected", resp.Code)
+		}
+	})
+	t.Run("admin", func(t *testing.T) {
+		if got := adminPackages("")["MyApp"]; got != nil {
+			t.Errorf("admin listing shows %+v without include_disabled", got)
+		}
+		if got := adminPackages("?include_disabled=true")["MyApp"]; got == nil || got.Enabled || got.LatestVersion != "1.1.0" {
+			t.Errorf("admin listing shows MyApp as %+v, want it disabled at 1.1.0", got)
+		}
+		resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/MyApp/releases?include_disabled=true&order=asc", nil)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("admin release listing = %d: %s", resp.Code, resp.Body)
+		}
+		var page PaginatedResponse[*ReleaseMetadata]
+		decodeResponse(t, resp, &page)
+		if got := releaseNames(page.Items); !slices.Equal(got, []string{"MyApp 1.0.0", "MyApp 1.1.0"}) {
+			t.Errorf("admin release listing = %v, want both releases", got)
+		}
+		if resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/packages/MyApp/releases", nil))); resp.Code != http.StatusNotFound {
+			t.Errorf("admin release listing without include_disabled = %d, want %d", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	setEnabled(true)
+	for _, path := range publicPaths {
+		t.Run("enabled again "+path, func(t *testing.T) {
+			if resp := s.do(withToken(s.request(http.MethodGet, path, nil), token)); resp.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d: %s", resp.Code, http.StatusOK, resp.Body)
+			}
+		})
+	}
+}
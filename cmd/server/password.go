@@ -0,0 +1,113 @@
+// internal/security/password.go - Password strength policy.
+//
+// This file implements the configurable policy that new passwords must satisfy
+// and the generator used for the bootstrap administrator's password.
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Character classes a password policy can require.
+const (
+	PasswordClassLower  = "lower"
+	PasswordClassUpper  = "upper"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+)
+
+// passwordClasses lists the supported character classes in the order they are reported.
+var passwordClasses = []string{PasswordClassLower, PasswordClassUpper, PasswordClassDigit, PasswordClassSymbol}
+
+// ErrWeakPassword is returned when a new password does not satisfy the password policy.
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordPolicy describes the requirements new passwords must meet.
+type PasswordPolicy struct {
+	MinLength       int      // Minimum number of characters
+	RequiredClasses []string // Character classes that must each appear at least once
+}
+
+// passwordPolicy is the policy enforced by ValidatePassword.
+var passwordPolicy = PasswordPolicy{MinLength: defaultPasswordMinLen, RequiredClasses: defaultPasswordClasses}
+
+// SetPasswordPolicy sets the policy enforced by ValidatePassword.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// ValidatePassword checks a new password against the configured password policy.
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < passwordPolicy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters long", ErrWeakPassword, passwordPolicy.MinLength)
+	}
+	var missing []string
+	for _, class := range passwordPolicy.RequiredClasses {
+		if !strings.ContainsFunc(password, passwordClassMatcher(class)) {
+			missing = append(missing, class)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing required character classes %v (required: %v)", ErrWeakPassword, missing, passwordPolicy.RequiredClasses)
+	}
+	return nil
+}
+
+// passwordClassMatcher returns the rune predicate for a character class.
+func passwordClassMatcher(class string) func(rune) bool {
+	switch class {
+	case PasswordClassLower:
+		return unicode.IsLower
+	case PasswordClassUpper:
+		return unicode.IsUpper
+	case PasswordClassDigit:
+		return unicode.IsDigit
+	default: // PasswordClassSymbol
+		return func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) }
+	}
+}
+
+// validatePasswordPolicy checks the password policy settings of the configuration.
+func validatePasswordPolicy(minLength int, classes []string) error {
+	if minLength < 1 {
+		return fmt.Errorf("password minimum length must be at least 1")
+	}
+	for i, class := range classes {
+		if !slices.Contains(passwordClasses, class) {
+			return fmt.Errorf("unsupported password character class %q, expected one of %v", class, passwordClasses)
+		}
+		if slices.Contains(classes[:i], class) {
+			return fmt.Errorf("password character class %q is listed twice", class)
+		}
+	}
+	return nil
+}
+
+// generatedPasswordAlphabet holds characters from every class, so generated passwords can satisfy any policy.
+const generatedPasswordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!#%+-?@_"
+
+// GenerateRandomPassword returns a random password that satisfies the password policy.
+func GenerateRandomPassword() (string, error) {
+	length := max(passwordPolicy.MinLength, 20)
+	alphabetSize := big.NewInt(int64(len(generatedPasswordAlphabet)))
+	for {
+		password := make([]byte, length)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			password[i] = generatedPasswordAlphabet[n.Int64()]
+		}
+		if ValidatePassword(string(password)) == nil {
+			return string(password), nil
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// internal/security/password_test.go - Tests of the password policy and the bootstrap administrator.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidatePassword(t *testing.T) {
+	defaultPolicy := PasswordPolicy{MinLength: defaultPasswordMinLen, RequiredClasses: defaultPasswordClasses}
+	t.Cleanup(func() { SetPasswordPolicy(defaultPolicy) })
+	symbolPolicy := PasswordPolicy{MinLength: 8, RequiredClasses: []string{PasswordClassSymbol}}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		wantErr  bool
+	}{
+		{"compliant", defaultPolicy, "Compliant-Pass-1", false},
+		{"too short", defaultPolicy, "Short-1a", true},
+		{"exactly the minimum length", defaultPolicy, "Abcdefghij12", false},
+		{"missing upper case", defaultPolicy, "lowercase-pass-1", true},
+		{"missing lower case", defaultPolicy, "UPPERCASE-PASS-1", true},
+		{"missing digit", defaultPolicy, "No-Digits-Password", true},
+		{"length counts characters, not bytes", defaultPolicy, "Äöü1Äöü1Äöü", true},
+		{"symbol required and present", symbolPolicy, "pass word", false},
+		{"symbol required and missing", symbolPolicy, "password", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPasswordPolicy(tt.policy)
+			err := ValidatePassword(tt.password)
+			if tt.wantErr && !errors.Is(err, ErrWeakPassword) {
+				t.Errorf("ValidatePassword(%q) = %v, want ErrWeakPassword", tt.password, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ValidatePassword(%q) = %v, want nil", tt.password, err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyEnforced(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123")
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{"create user with a short password", asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", CreateUserRequest{Username: "bob", Password: "Bob-1", Roles: []string{"user"}})), http.StatusBadRequest},
+		{"create user without a digit", asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", CreateUserRequest{Username: "bob", Password: "Bob-Password-Long", Roles: []string{"user"}})), http.StatusBadRequest},
+		{"create user with a compliant password", asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", CreateUserRequest{Username: "bob", Password: "Bob-Pass-123", Roles: []string{"user"}})), http.StatusCreated},
+		{"admin sets a short password", asAdmin(s.request(http.MethodPut, "/api/v1/admin/users/alice", UpdateUserRequest{Password: "short"})), http.StatusBadRequest},
+		{"user changes to a short password", withBasicAuth(s.request(http.MethodPut, "/api/v1/auth/password", UpdateUserRequest{Password: "short"}), "alice", "Alice-Pass-123"), http.StatusBadRequest},
+		{"user changes to a compliant password", withBasicAuth(s.request(http.MethodPut, "/api/v1/auth/password", UpdateUserRequest{Password: "Alice-Pass-456"}), "alice", "Alice-Pass-123"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := s.do(tt.req); resp.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+}
+
+func TestEnsureDefaultAdmin(t *testing.T) {
+	SetPasswordHashCost(bcrypt.MinCost)
+	userDB, err := NewJSONUserDatabase(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := NewUserService(userDB, nil, discardLogger())
+	var logged bytes.Buffer
+	if err := ensureDefaultAdmin(userService, slog.New(slog.NewJSONHandler(&logged, nil))); err != nil {
+		t.Fatal(err)
+	}
+	var record struct {
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(logged.Bytes(), &record); err != nil || record.Password == "" {
+		t.Fatalf("the generated password was not logged: %q", logged.String())
+	}
+
+	admin, err := userService.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.MustChangePassword {
+		t.Error("the bootstrap administrator does not have to change the generated password")
+	}
+	if !CompareHashAndPassword(admin.PasswordHash, record.Password) {
+		t.Error("the logged password does not match the stored hash")
+	}
+	if err := ValidatePassword(record.Password); err != nil {
+		t.Errorf("generated password does not satisfy the policy: %v", err)
+	}
+	if other, err := GenerateRandomPassword(); err != nil || other == record.Password {
+		t.Errorf("GenerateRandomPassword = %q, %v; want a different password each time", other, err)
+	}
+
+	// A restart keeps the existing administrator and its password.
+	logged.Reset()
+	if err := ensureDefaultAdmin(userService, slog.New(slog.NewJSONHandler(&logged, nil))); err != nil {
+		t.Fatal(err)
+	}
+	if logged.Len() != 0 {
+		t.Errorf("restart logged %q, want nothing", logged.String())
+	}
+	if again, _ := userService.GetUserByUsername("admin"); again.PasswordHash != admin.PasswordHash {
+		t.Error("restart replaced the administrator's password")
+	}
+}
@@ -0,0 +1,151 @@
+// internal/service/quota_test.go - Tests of package and repository storage quotas.
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// quotaTestContent is the release file every quota test uploads. It is random so that it does not
+// compress, keeping the stored archive at least as large as the uploaded file.
+var quotaTestContent = func() []byte {
+	content := make([]byte, 4096)
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range content {
+		content[i] = byte(random.UintN(256))
+	}
+	return content
+}()
+
+// storedReleaseSize returns the size one upload of quotaTestContent takes up in the repository.
+func storedReleaseSize(t *testing.T) int64 {
+	t.Helper()
+	s := newTestServer(t, nil)
+	s.mustUpload(s.token("admin", testAdminPassword), "Probe", "1.0.0", quotaTestContent)
+	release, err := s.releaseService.GetRelease("Probe", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release.StoredSize()
+}
+
+func TestStorageQuotas(t *testing.T) {
+	size := storedReleaseSize(t)
+	sbom := `{"bomFormat":"CycloneDX","specVersion":"1.5","components":[` + strings.Repeat(`{"type":"library","name":"padding"},`, 40) + `{"type":"library","name":"last"}]}`
+
+	// quotaStep is either an upload or, if setQuota is set, a change of the package's own quota.
+	type quotaStep struct {
+		softwareName string
+		version      string
+		fields       map[string]string
+		setQuota     int64
+		wantStatus   int
+	}
+	tests := []struct {
+		name         string
+		repository   int64 // Repository quota, 0 for none
+		packageQuota int64 // Configured package quota, 0 for none
+		steps        []quotaStep
+	}{
+		{"package quota", 0, size*2 + size/2, []quotaStep{
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", version: "1.1.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", version: "1.2.0", wantStatus: http.StatusInsufficientStorage},
+			{softwareName: "B", version: "1.0.0", wantStatus: http.StatusCreated}, // Other packages have their own quota
+		}},
+		{"repository quota", size*2 + size/2, 0, []quotaStep{
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "B", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", version: "1.1.0", wantStatus: http.StatusInsufficientStorage},
+			{softwareName: "C", version: "1.0.0", wantStatus: http.StatusInsufficientStorage},
+		}},
+		{"package override", 0, size * 10, []quotaStep{
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", setQuota: size + size/2, wantStatus: http.StatusOK},
+			{softwareName: "A", version: "1.1.0", wantStatus: http.StatusInsufficientStorage},
+			{softwareName: "B", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "B", version: "1.1.0", wantStatus: http.StatusCreated},
+		}},
+		{"sbom counts towards quota", 0, size + int64(len(sbom))/2, []quotaStep{
+			{softwareName: "A", version: "1.0.0", fields: map[string]string{"sbom": sbom}, wantStatus: http.StatusInsufficientStorage},
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+		}},
+		{"replaced release is not counted twice", 0, size + size/2, []quotaStep{
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", version: "1.0.0", fields: map[string]string{"overwrite": "true"}, wantStatus: http.StatusCreated},
+		}},
+		{"negative package quota", 0, 0, []quotaStep{
+			{softwareName: "A", version: "1.0.0", wantStatus: http.StatusCreated},
+			{softwareName: "A", setQuota: -1, wantStatus: http.StatusBadRequest},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) {
+				cfg.StorageQuotaBytes = tt.repository
+				cfg.PackageStorageQuotaBytes = tt.packageQuota
+			})
+			token := s.token("admin", testAdminPassword)
+			for i, step := range tt.steps {
+				if step.setQuota != 0 {
+					req := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/"+step.softwareName+"/quota", StorageQuotaRequest{StorageQuotaBytes: step.setQuota}))
+					if resp := s.do(req); resp.Code != step.wantStatus {
+						t.Fatalf("step %d: setting quota of %s to %d = %d, want %d: %s", i, step.softwareName, step.setQuota, resp.Code, step.wantStatus, resp.Body)
+					}
+					continue
+				}
+				if resp := s.upload(token, step.softwareName, step.version, quotaTestContent, step.fields); resp.Code != step.wantStatus {
+					t.Fatalf("step %d: upload of %s %s = %d, want %d: %s", i, step.softwareName, step.version, resp.Code, step.wantStatus, resp.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageQuotaConcurrentUploads(t *testing.T) {
+	size := storedReleaseSize(t)
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.PackageStorageQuotaBytes = size*4 + size/2
+	})
+	token := s.token("admin", testAdminPassword)
+
+	versions := []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3", "1.0.4", "1.0.5", "1.0.6", "1.0.7"}
+	statuses := make([]int, len(versions))
+	var wg sync.WaitGroup
+	for i, version := range versions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = s.upload(token, "A", version, quotaTestContent, nil).Code
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for i, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusInsufficientStorage:
+		default:
+			t.Errorf("upload of %s = %d, want %d or %d", versions[i], status, http.StatusCreated, http.StatusInsufficientStorage)
+		}
+	}
+	if created != 4 {
+		t.Errorf("%d concurrent uploads were accepted, want the 4 that fit the quota", created)
+	}
+	releases, err := s.releaseDB.ListReleasesMetadataForSoftware("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usage int64
+	for _, release := range releases {
+		usage += release.StoredSize()
+	}
+	if usage > s.cfg.PackageStorageQuotaBytes {
+		t.Errorf("package uses %d bytes, over its quota of %d", usage, s.cfg.PackageStorageQuotaBytes)
+	}
+}
@@ -0,0 +1,133 @@
+// internal/middleware/ratelimit.go - Per-client request rate limiting.
+//
+// This file implements a token-bucket rate limiter keyed by client IP and the
+// middleware that rejects requests over the limit with 429 Too Many Requests.
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter decides whether a request from the given client key may proceed.
+// When it may not, the returned duration tells the client how long to wait.
+type RateLimiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// clientBucket is the token bucket of a single client.
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// IPRateLimiter is an in-memory RateLimiter with one token bucket per client key.
+type IPRateLimiter struct {
+	rps     rate.Limit
+	burst   int
+	now     func() time.Time // Clock, replaceable for deterministic use
+	buckets map[string]*clientBucket
+	mu      sync.Mutex
+}
+
+// NewIPRateLimiter creates a new IPRateLimiter allowing rps requests per second with the given burst.
+func NewIPRateLimiter(rps float64, burst int) *IPRateLimiter {
+	return &IPRateLimiter{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*clientBucket),
+	}
+}
+
+// Allow takes a token from the client's bucket if one is available.
+func (l *IPRateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &clientBucket{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+
+	reservation := bucket.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now) // Rejected requests don't consume tokens
+		return false, delay
+	}
+	return true, 0
+}
+
+// Cleanup drops the buckets of clients idle for longer than maxIdle.
+func (l *IPRateLimiter) Cleanup(maxIdle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := l.now().Add(-maxIdle)
+	for key, bucket := range l.buckets {
+		if bucket.lastSeen.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// StartCleanup periodically drops idle client buckets so memory stays bounded.
+func (l *IPRateLimiter) StartCleanup(interval time.Duration, maxIdle time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			l.Cleanup(maxIdle)
+		}
+	}()
+}
+
+// RateLimitMiddleware applies authLimiter to credential-checking endpoints (/auth and /admin)
+// and readLimiter to the remaining API endpoints. A nil limiter disables that group.
+// Health probes outside the API are never limited.
+func RateLimitMiddleware(authLimiter RateLimiter, readLimiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter := readLimiter
+			if isAuthEndpoint(r.URL.Path) {
+				limiter = authLimiter
+			}
+			if limiter == nil || !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowed, retryAfter := limiter.Allow(clientIP(r)); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				respondError(w, http.StatusTooManyRequests, "Rate limit exceeded, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAuthEndpoint reports whether a path belongs to an endpoint group that checks Basic Auth credentials.
+func isAuthEndpoint(path string) bool {
+	return strings.HasPrefix(path, "/api/v1/auth/") || strings.HasPrefix(path, "/api/v1/admin/")
+}
+
+// clientIP returns the IP of the direct peer, without trusting forwarding headers.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,155 @@
+// internal/middleware/ratelimit_test.go - Tests of per-client request rate limiting.
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fixedLimiter allows the first limit requests of each key and rejects the rest.
+type fixedLimiter struct {
+	limit      int
+	retryAfter time.Duration
+	seen       map[string]int
+}
+
+func newFixedLimiter(limit int) *fixedLimiter {
+	return &fixedLimiter{limit: limit, retryAfter: 1500 * time.Millisecond, seen: make(map[string]int)}
+}
+
+func (l *fixedLimiter) Allow(key string) (bool, time.Duration) {
+	l.seen[key]++
+	if l.seen[key] > l.limit {
+		return false, l.retryAfter
+	}
+	return true, 0
+}
+
+// newTestRateLimiter creates an IPRateLimiter whose clock only moves when the test advances it.
+func newTestRateLimiter(rps float64, burst int) (*IPRateLimiter, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewIPRateLimiter(rps, burst)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	limiter, advance := newTestRateLimiter(1, 3)
+	for i := 1; i <= 3; i++ {
+		if allowed, _ := limiter.Allow("192.0.2.1"); !allowed {
+			t.Fatalf("request %d within the burst was rejected", i)
+		}
+	}
+	allowed, retryAfter := limiter.Allow("192.0.2.1")
+	if allowed || retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("request 4 = %v, retry after %v; want it rejected with a wait of at most 1s", allowed, retryAfter)
+	}
+	if allowed, _ := limiter.Allow("192.0.2.2"); !allowed {
+		t.Error("another client was limited by the first client's requests")
+	}
+
+	advance(time.Second) // Refills one token; the rejected request did not consume one
+	if allowed, _ := limiter.Allow("192.0.2.1"); !allowed {
+		t.Error("request after the refill was rejected")
+	}
+	if allowed, _ := limiter.Allow("192.0.2.1"); allowed {
+		t.Error("second request after a single refill was allowed")
+	}
+
+	advance(time.Minute)
+	limiter.Allow("192.0.2.2")
+	limiter.Cleanup(30 * time.Second)
+	if _, ok := limiter.buckets["192.0.2.1"]; ok || len(limiter.buckets) != 1 {
+		t.Errorf("buckets after cleanup = %v, want only the recently seen client", limiter.buckets)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		authLimited bool // Whether the auth limiter rather than the read limiter applies
+		unlimited   bool
+	}{
+		{"token endpoint", "/api/v1/auth/token", true, false},
+		{"admin endpoint", "/api/v1/admin/users", true, false},
+		{"read endpoint", "/api/v1/packages", false, false},
+		{"download endpoint", "/api/v1/releases/MyApp/1.0.0", false, false},
+		{"health probe", "/health/ready", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authLimiter, readLimiter := newFixedLimiter(3), newFixedLimiter(5)
+			handler := RateLimitMiddleware(authLimiter, readLimiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			limit := readLimiter.limit
+			if tt.authLimited {
+				limit = authLimiter.limit
+			}
+			for i := 1; i <= limit+1; i++ {
+				req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				req.RemoteAddr = "192.0.2.1:4711"
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+
+				wantStatus := http.StatusOK
+				if i > limit && !tt.unlimited {
+					wantStatus = http.StatusTooManyRequests
+				}
+				if recorder.Code != wantStatus {
+					t.Fatalf("request %d = %d, want %d", i, recorder.Code, wantStatus)
+				}
+				if wantStatus == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") != "2" {
+					t.Errorf("Retry-After = %q, want 2 for a wait of 1.5s", recorder.Header().Get("Retry-After"))
+				}
+			}
+			if tt.unlimited && len(authLimiter.seen)+len(readLimiter.seen) != 0 {
+				t.Error("an unlimited path was counted by a limiter")
+			}
+			if !tt.unlimited && authLimiter.seen["192.0.2.1"] > 0 != tt.authLimited {
+				t.Errorf("auth limiter saw %d requests, read limiter %d; want the other limiter", authLimiter.seen["192.0.2.1"], readLimiter.seen["192.0.2.1"])
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		handler := RateLimitMiddleware(nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		for i := 0; i < 100; i++ {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/auth/token", nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("request %d without limiters = %d, want 200", i+1, recorder.Code)
+			}
+		}
+	})
+}
+
+func TestRateLimitedServer(t *testing.T) {
+	s := newTestServer(t, nil)
+	limiter, advance := newTestRateLimiter(0.5, 3)
+	handler := RateLimitMiddleware(limiter, limiter)(s.handler)
+	request := func() *httptest.ResponseRecorder {
+		req := withBasicAuth(s.request(http.MethodPost, "/api/v1/auth/token", nil), "admin", "wrong-password")
+		req.RemoteAddr = "192.0.2.1:4711"
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i := 1; i <= 3; i++ {
+		if resp := request(); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("login attempt %d = %d, want 401", i, resp.Code)
+		}
+	}
+	resp := request()
+	if resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") != "2" {
+		t.Errorf("login attempt 4 = %d with Retry-After %q, want 429 and 2", resp.Code, resp.Header().Get("Retry-After"))
+	}
+	advance(2 * time.Second)
+	if resp := request(); resp.Code != http.StatusUnauthorized {
+		t.Errorf("login attempt after waiting = %d, want 401", resp.Code)
+	}
+}
@@ -0,0 +1,237 @@
+// internal/service/reconcile_test.go - Tests of release reconciliation.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestReconcileReleases(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	s.mustUpload(token, "Other", "1.0.0", []byte("untouched release"))
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(content []byte) func(t *testing.T) {
+		return func(t *testing.T) {
+			if err := os.WriteFile(filePath, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The steps run in order, each changing the release file before reconciling.
+	tests := []struct {
+		name      string
+		change    func(t *testing.T)
+		want      ReconcileResult // Notes are only counted
+		wantNotes int
+		wantState string
+	}{
+		{"unchanged", nil, ReconcileResult{Checked: 2}, 0, "available"},
+		{"file removed", func(t *testing.T) {
+			if err := os.Remove(filePath); err != nil {
+				t.Fatal(err)
+			}
+		}, ReconcileResult{Checked: 2, NewlyUnavailable: 1}, 1, "unavailable"},
+		{"file restored", writeFile(original), ReconcileResult{Checked: 2, NewlyAvailable: 1}, 1, "available"},
+		{"file corrupted", writeFile(bytes.Repeat([]byte{0}, len(original))), ReconcileResult{Checked: 2, Corrupt: 1}, 1, "corrupt"},
+		{"still corrupt", nil, ReconcileResult{Checked: 2, Corrupt: 1}, 0, "corrupt"},
+		{"corrupt file restored", writeFile(original), ReconcileResult{Checked: 2, NewlyAvailable: 1}, 1, "available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				tt.change(t)
+			}
+			resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil)))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+			}
+			var got ReconcileResult
+			decodeResponse(t, resp, &got)
+			if len(got.Notes) != tt.wantNotes {
+				t.Errorf("notes = %+v, want %d", got.Notes, tt.wantNotes)
+			}
+			got.Notes = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.ReleaseState != tt.wantState {
+				t.Errorf("state = %q, want %q", release.ReleaseState, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestReconcileReleasesInVariousStates(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	filePaths := map[string]string{}
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+		release, err := s.releaseService.GetRelease("MyApp", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filePaths[version] = s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+	}
+	yank := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/releases/1.3.0/state", UpdateReleaseStateRequest{State: "yanked"}))
+	if resp := s.do(yank); resp.Code != http.StatusOK {
+		t.Fatalf("yanking 1.3.0 = %d: %s", resp.Code, resp.Body)
+	}
+	// 1.0.0 stays intact.
+	if err := os.Remove(filePaths["1.1.0"]); err != nil { // Missing
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePaths["1.2.0"], []byte("replaced with other and longer content"), 0644); err != nil { // Corrupt and resized
+		t.Fatal(err)
+	}
+	if err := os.Remove(filePaths["1.3.0"]); err != nil { // Missing, but yanked
+		t.Fatal(err)
+	}
+	if err := os.Remove(filePaths["1.4.0"]); err != nil { // Cannot be read
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filePaths["1.4.0"], 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+	}
+	var got ReconcileResult
+	decodeResponse(t, resp, &got)
+	notes := map[string]int{}
+	for _, note := range got.Notes {
+		notes[note.Version]++
+	}
+	if want := map[string]int{"1.1.0": 1, "1.2.0": 2, "1.3.0": 1, "1.4.0": 1}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("notes per version = %v, want %v: %+v", notes, want, got.Notes)
+	}
+	got.Notes = nil
+	if want := (ReconcileResult{Checked: 5, NewlyUnavailable: 1, SizeUpdated: 1, Corrupt: 1, Errored: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("result = %+v, want %+v", got, want)
+	}
+
+	wantStates := map[string]string{
+		"1.0.0": "available",
+		"1.1.0": "unavailable",
+		"1.2.0": "corrupt",
+		"1.3.0": "yanked",
+		"1.4.0": "available", // Left unchanged, since its file could not be checked
+	}
+	for version, wantState := range wantStates {
+		release, err := s.releaseService.GetRelease("MyApp", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if release.ReleaseState != wantState {
+			t.Errorf("state of %s = %q, want %q", version, release.ReleaseState, wantState)
+		}
+	}
+}
+
+// reconcileSnapshot is the outcome of a reconciliation: its result, with notes in a stable order,
+// and the state of every release afterwards.
+type reconcileSnapshot struct {
+	result ReconcileResult
+	states map[string]string
+}
+
+func TestConcurrentReconcileMatchesSerial(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for i := range 48 {
+		name, version := fmt.Sprintf("App%d", i%4), fmt.Sprintf("1.%d.0", i)
+		s.mustUpload(token, name, version, []byte("release "+name+" "+version))
+		release, err := s.releaseService.GetRelease(name, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filePath := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+		switch i % 5 { // 0 stays intact
+		case 1:
+			err = os.Remove(filePath) // Missing
+		case 2:
+			err = os.WriteFile(filePath, bytes.Repeat([]byte{0}, int(release.FileSize)), 0644) // Corrupt
+		case 3:
+			err = os.WriteFile(filePath, []byte("other and longer content"), 0644) // Corrupt and resized
+		case 4:
+			if err = os.Remove(filePath); err == nil {
+				err = os.Mkdir(filePath, 0755) // Cannot be read
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := make([]ReleaseMetadata, len(stored))
+	for i, metadata := range stored {
+		original[i] = *metadata
+	}
+
+	// Each run starts from the original metadata, so all of them should find the same changes.
+	reconcile := func(concurrency int) reconcileSnapshot {
+		t.Helper()
+		for i := range original {
+			metadata := original[i]
+			if err := s.releaseDB.UpdateReleaseMetadata(&metadata); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result, err := s.releaseDB.ReconcileReleases(s.cfg.RepositoryPath, concurrency)
+		if err != nil {
+			t.Fatalf("reconciling on %d workers failed: %v", concurrency, err)
+		}
+		slices.SortFunc(result.Notes, func(a, b ReconcileNote) int {
+			return cmp.Or(cmp.Compare(a.SoftwareName, b.SoftwareName), cmp.Compare(a.Version, b.Version), cmp.Compare(a.Message, b.Message))
+		})
+		snapshot := reconcileSnapshot{result: *result, states: map[string]string{}}
+		releases, err := s.releaseDB.ListAllReleasesMetadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, release := range releases {
+			snapshot.states[release.SoftwareName+" "+release.Version] = release.ReleaseState
+		}
+		return snapshot
+	}
+
+	serial := reconcile(1)
+	counts := serial.result
+	counts.Notes = nil
+	if want := (ReconcileResult{Checked: 48, NewlyUnavailable: 10, SizeUpdated: 9, Corrupt: 19, Errored: 9}); !reflect.DeepEqual(counts, want) {
+		t.Fatalf("serial result = %+v, want %+v", counts, want)
+	}
+	for _, concurrency := range []int{2, 4, 16, 100} {
+		t.Run(fmt.Sprintf("%d workers", concurrency), func(t *testing.T) {
+			if got := reconcile(concurrency); !reflect.DeepEqual(got, serial) {
+				t.Errorf("result = %+v\nwant the serial %+v", got, serial)
+			}
+		})
+	}
+}
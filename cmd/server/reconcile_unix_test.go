@@ -0,0 +1,86 @@
+// internal/service/reconcile_unix_test.go - Tests of the reconciliation worker bound on Unix-like systems.
+//
+// Release files are replaced by named pipes, so each worker stays inside its checksum until the
+// test writes to the pipe, and the workers busy at any moment can be counted.
+
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReconcileConcurrencyIsBounded(t *testing.T) {
+	const releases, concurrency = 12, 3
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	var pipes []string
+	for i := range releases {
+		version := fmt.Sprintf("1.%d.0", i)
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+		release, err := s.releaseService.GetRelease("MyApp", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filePath := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+		if err := os.Remove(filePath); err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Mkfifo(filePath, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pipes = append(pipes, filePath)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.releaseDB.ReconcileReleases(s.cfg.RepositoryPath, concurrency)
+		done <- err
+	}()
+
+	// Opening a pipe for writing without blocking only succeeds once a worker reads it. The held
+	// writers keep those workers busy, so no worker may pick up another release meanwhile.
+	busy := map[string]*os.File{}
+	pending := map[string]bool{}
+	for _, pipe := range pipes {
+		pending[pipe] = true
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for len(pending) > 0 {
+		for pipe := range pending {
+			if busy[pipe] != nil {
+				continue
+			}
+			writer, err := os.OpenFile(pipe, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+			if errors.Is(err, syscall.ENXIO) {
+				continue // Not being read yet
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			busy[pipe] = writer
+		}
+		if len(busy) > concurrency {
+			t.Fatalf("%d releases are checked at once, want at most %d", len(busy), concurrency)
+		}
+		if len(busy) == min(concurrency, len(pending)) {
+			for pipe, writer := range busy { // Closing the writer ends the worker's read
+				writer.Close()
+				delete(busy, pipe)
+				delete(pending, pipe)
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d releases are checked at once after 10s, want %d", len(busy), min(concurrency, len(pending)))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+}
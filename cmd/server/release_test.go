@@ -0,0 +1,270 @@
+// api/release_test.go - Tests of the public release metadata endpoints.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetReleaseVersionInfo(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+
+	tests := []struct {
+		name        string
+		version     string
+		wantLatest  bool
+		wantNewer   int
+		wantVersion string
+	}{
+		{"latest release", "2.0.0", true, 0, "2.0.0"},
+		{"older release", "1.1.0", false, 1, "2.0.0"},
+		{"oldest release", "1.0.0", false, 2, "2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/"+tt.version, nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("getting %s = %d: %s", tt.version, resp.Code, resp.Body)
+			}
+			var info ReleaseVersionInfo
+			decodeResponse(t, resp, &info)
+			if info.ReleaseMetadata == nil || info.Version != tt.version {
+				t.Fatalf("response describes %+v, want release %s", info.ReleaseMetadata, tt.version)
+			}
+			if info.IsLatest != tt.wantLatest {
+				t.Errorf("is_latest = %v, want %v", info.IsLatest, tt.wantLatest)
+			}
+			if info.NewerVersionsCount != tt.wantNewer {
+				t.Errorf("newer_versions_count = %d, want %d", info.NewerVersionsCount, tt.wantNewer)
+			}
+			if info.LatestVersion != tt.wantVersion {
+				t.Errorf("latest_version = %q, want %q", info.LatestVersion, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestGetReleaseVersionInfoWithoutLatest(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	yank := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/packages/MyApp/releases/1.0.0/state", UpdateReleaseStateRequest{State: "yanked"}))
+	if resp := s.do(yank); resp.Code != http.StatusOK {
+		t.Fatalf("yanking 1.0.0 = %d: %s", resp.Code, resp.Body)
+	}
+
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/1.0.0", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("getting the yanked release = %d: %s", resp.Code, resp.Body)
+	}
+	var info ReleaseVersionInfo
+	decodeResponse(t, resp, &info)
+	if info.IsLatest || info.LatestVersion != "" {
+		t.Errorf("is_latest = %v, latest_version = %q; want false and empty when no release is available", info.IsLatest, info.LatestVersion)
+	}
+}
+
+// failingReleaseDB is a release database whose reads of a package's releases fail.
+type failingReleaseDB struct {
+	ReleaseDatabase
+	err error
+}
+
+func (db *failingReleaseDB) GetReleaseMetadata(softwareName string, version string) (*ReleaseMetadata, error) {
+	return nil, db.err
+}
+
+func (db *failingReleaseDB) ListReleasesMetadataForSoftware(softwareName string) ([]*ReleaseMetadata, error) {
+	return nil, db.err
+}
+
+func TestReleaseEndpointsErrorStatus(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+
+	endpoints := []struct {
+		name string
+		path string // Format string taking the package name
+	}{
+		{"releases", "/api/v1/packages/%s/releases"},
+		{"latest", "/api/v1/packages/%s/latest"},
+		{"tags", "/api/v1/packages/%s/tags"},
+		{"release", "/api/v1/packages/%s/releases/1.0.0"},
+	}
+	for _, endpoint := range endpoints {
+		t.Run("missing package "+endpoint.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, fmt.Sprintf(endpoint.path, "NoSuchApp"), nil))
+			if resp.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d: %s", resp.Code, http.StatusNotFound, resp.Body)
+			}
+		})
+	}
+	t.Run("missing latest download", func(t *testing.T) {
+		resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/packages/NoSuchApp/latest/download", nil), token))
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d: %s", resp.Code, http.StatusNotFound, resp.Body)
+		}
+	})
+
+	// A database failure is a server error, and its details stay in the log.
+	failure := errors.New("disk failure")
+	releaseService := NewReleaseService(s.cfg, &failingReleaseDB{ReleaseDatabase: s.releaseDB, err: failure}, s.packageDB, discardLogger())
+	router := mux.NewRouter()
+	SetupPublicRoutes(router.PathPrefix("/api/v1").Subrouter(), s.cfg, releaseService, s.userService, NewServerNotice(""), discardLogger())
+	for _, endpoint := range endpoints {
+		t.Run("database failure "+endpoint.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, s.request(http.MethodGet, fmt.Sprintf(endpoint.path, "MyApp"), nil))
+			if recorder.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusInternalServerError, recorder.Body)
+			}
+			if strings.Contains(recorder.Body.String(), failure.Error()) {
+				t.Errorf("response %q exposes the database error", recorder.Body)
+			}
+		})
+	}
+}
+
+func TestLatestStrategies(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    PackageSettings
+		wantVersion string
+	}{
+		{"default", PackageSettings{}, "2.0.0"},
+		{"highest version", PackageSettings{LatestStrategy: LatestStrategyHighestVersion}, "2.0.0"},
+		{"most recent", PackageSettings{LatestStrategy: LatestStrategyMostRecent}, "1.5.0"},
+		{"pinned", PackageSettings{LatestStrategy: LatestStrategyPinned, PinnedVersion: "1.0.0"}, "1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) {
+				cfg.PackageSettings = map[string]PackageSettings{"MyApp": tt.settings}
+			})
+			token := s.token("admin", testAdminPassword)
+			for _, version := range []string{"1.0.0", "2.0.0", "1.5.0"} { // 1.5.0 is uploaded last
+				s.mustUpload(token, "MyApp", version, []byte("release "+version))
+			}
+
+			resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/latest", nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("getting latest = %d: %s", resp.Code, resp.Body)
+			}
+			var latest ReleaseVersionInfo
+			decodeResponse(t, resp, &latest)
+			if latest.ReleaseMetadata == nil || latest.Version != tt.wantVersion {
+				t.Errorf("latest release is %+v, want %s", latest.ReleaseMetadata, tt.wantVersion)
+			}
+			if got := s.listPackages()["MyApp"]; got == nil || got.LatestVersion != tt.wantVersion {
+				t.Errorf("MyApp is listed as %+v, want latest version %s", got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+// releaseNames returns "name version" for each release in order.
+func releaseNames(releases []*ReleaseMetadata) []string {
+	names := make([]string, len(releases))
+	for i, release := range releases {
+		names[i] = release.SoftwareName + " " + release.Version
+	}
+	return names
+}
+
+func TestListAllReleases(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, release := range []struct{ name, version string }{
+		{"Zed", "1.0.0"}, {"Alpha", "2.0.0"}, {"Mid", "0.1.0"}, {"Alpha", "1.10.0"}, {"Hidden", "1.0.0"}, {"Alpha", "1.2.0"},
+	} {
+		s.mustUpload(token, release.name, release.version, []byte(release.name+" "+release.version))
+	}
+	if err := s.releaseService.EnableDisableSoftwarePackage("Hidden", false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantItems []string
+		wantTotal int
+	}{
+		{"first page", "?limit=2", []string{"Alpha 1.2.0", "Alpha 1.10.0"}, 5},
+		{"page across packages", "?limit=2&offset=2", []string{"Alpha 2.0.0", "Mid 0.1.0"}, 5},
+		{"last page", "?limit=2&offset=4", []string{"Zed 1.0.0"}, 5},
+		{"past the end", "?limit=2&offset=6", []string{}, 5},
+		{"descending versions", "?order=desc", []string{"Alpha 2.0.0", "Alpha 1.10.0", "Alpha 1.2.0", "Mid 0.1.0", "Zed 1.0.0"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, "/api/v1/releases"+tt.query, nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			var page PaginatedResponse[*ReleaseMetadata]
+			decodeResponse(t, resp, &page)
+			if got := releaseNames(page.Items); !slices.Equal(got, tt.wantItems) {
+				t.Errorf("releases = %v, want %v", got, tt.wantItems)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", page.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestGetReleaseMetadata(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	resp := s.upload(s.token("alice", "Alice-Pass-123"), "MyApp", "1.0.0", []byte("release content"), map[string]string{"changelog": "First release"})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("upload = %d: %s", resp.Code, resp.Body)
+	}
+	stored, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		version    string
+		wantStatus int
+	}{
+		{"existing version", "1.0.0", http.StatusOK},
+		{"missing version", "1.0.1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/"+tt.version, nil))
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(resp.Body.String(), tt.version) {
+					t.Errorf("error %s does not name the version", resp.Body)
+				}
+				return
+			}
+			var info ReleaseVersionInfo
+			decodeResponse(t, resp, &info)
+			if !sameMetadata(info.ReleaseMetadata, stored) {
+				t.Errorf("metadata = %+v, want the stored %+v", info.ReleaseMetadata, stored)
+			}
+			if info.Checksum == "" || info.FileSize == 0 || info.ReleaseState != "available" || info.Changelog != "First release" || info.UploadedBy != "alice" {
+				t.Errorf("metadata = %+v, want checksum, size, state, changelog and uploader", info.ReleaseMetadata)
+			}
+		})
+	}
+}
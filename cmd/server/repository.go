@@ -6,78 +6,146 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/google/uuid"
 )
 
+// ErrNotFound is wrapped by the errors of lookups whose record does not exist, so callers can tell
+// a missing package or release apart from a failing database.
+var ErrNotFound = errors.New("not found")
+
+// ErrSoftwareNotFound is returned when a software package does not exist or is hidden because it is disabled.
+var ErrSoftwareNotFound = fmt.Errorf("software package %w", ErrNotFound)
+
+// ErrReleaseExists is returned when creating a release whose software name and version are already taken.
+var ErrReleaseExists = errors.New("release version already exists")
+
+// ErrSoftwareNameConflict is returned when creating a release whose software name differs only in casing
+// from the name the package is stored under.
+var ErrSoftwareNameConflict = errors.New("software name already in use with different casing")
+
+// softwareKey returns the key software packages are stored and looked up by. The stored records keep
+// the name as it was first given, for display.
+func softwareKey(softwareName string) string {
+	return strings.ToLower(softwareName)
+}
+
 // ReleaseDatabase interface defines operations for release metadata management.
 type ReleaseDatabase interface {
 	GetReleaseMetadata(softwareName string, version string) (*ReleaseMetadata, error)
+	GetReleaseByID(id string) (*ReleaseMetadata, error)
 	ListReleasesMetadataForSoftware(softwareName string) ([]*ReleaseMetadata, error)
 	ListAllReleasesMetadata() ([]*ReleaseMetadata, error)
 	CreateReleaseMetadata(metadata *ReleaseMetadata) error
 	UpdateReleaseMetadata(metadata *ReleaseMetadata) error // For status updates, etc.
 	DeleteReleaseMetadata(softwareName string, version string) error
-	ReconcileReleases(repoPath string) error
-	StoreReleaseFile(repoPath string, tgzFilePath string, metadata *ReleaseMetadata) (string, error)
-	GetReleaseTGZReader(repoPath string, metadata *ReleaseMetadata) (io.ReadCloser, error)
+	AddDownloadCounts(counts map[ReleaseRef]int64) error // Releases deleted in the meantime are skipped
+	ReconcileReleases(repoPath string, concurrency int) (*ReconcileResult, error)
+	StoreReleaseFile(repoPath string, tgz io.Reader, metadata *ReleaseMetadata, checksumAlgorithms []string) (*StoredReleaseFile, error)
+	GetReleaseTGZReader(repoPath string, metadata *ReleaseMetadata) (io.ReadSeekCloser, error) // Seekable so downloads can serve byte ranges
 	GetReleaseFilePath(repoPath string, metadata *ReleaseMetadata) string
+	StoreReleaseSBOM(repoPath string, metadata *ReleaseMetadata, sbom []byte) error
+	GetReleaseSBOMFilePath(repoPath string, metadata *ReleaseMetadata) string
+	StoreReleaseSignature(repoPath string, metadata *ReleaseMetadata, signature []byte) error
+	GetReleaseSignatureFilePath(repoPath string, metadata *ReleaseMetadata) string
+	DeleteReleaseFile(repoPath string, metadata *ReleaseMetadata) error
+	RemoveSoftwareDir(repoPath string, softwareName string) error
 	Close() error
 }
 
+// releaseFileStore implements the file operations of ReleaseDatabase on the repository
+// directory. It is shared by the metadata storage backends.
+type releaseFileStore struct {
+	softwareIDs *SoftwareIDRegistry // IDs used in directory and file names
+}
+
 // JSONReleaseDatabase is a JSON file-based implementation of ReleaseDatabase.
 type JSONReleaseDatabase struct {
+	releaseFileStore
 	filepath string
-	releases map[string]map[string]*ReleaseMetadata // softwareName -> version -> metadata
+	releases map[string]map[string]*ReleaseMetadata // softwareKey -> version -> metadata
 	mu       sync.RWMutex                           // Mutex for read/write operations
-	config   *Config
 }
 
-// NewJSONReleaseDatabase creates a new JSONReleaseDatabase instance.
-func NewJSONReleaseDatabase(filepath string) (*JSONReleaseDatabase, error) {
+// NewJSONReleaseDatabase creates a new JSONReleaseDatabase instance, with software IDs
+// persisted in softwareIDsFilepath.
+func NewJSONReleaseDatabase(filepath string, softwareIDsFilepath string) (*JSONReleaseDatabase, error) {
 	db := &JSONReleaseDatabase{
 		filepath: filepath,
 		releases: make(map[string]map[string]*ReleaseMetadata),
 	}
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
 	if err := db.loadReleasesMetadata(); err != nil {
 		return nil, err
 	}
+	existingNames := make([]string, 0, len(db.releases))
+	for softwareName := range db.releases {
+		existingNames = append(existingNames, softwareName)
+	}
+	softwareIDs, err := NewSoftwareIDRegistry(softwareIDsFilepath, existingNames)
+	if err != nil {
+		return nil, err
+	}
+	db.softwareIDs = softwareIDs
 	return db, nil
 }
 
 // GetReleaseFilePath returns the file path for a release based on the repository path and release metadata.
-func (db *JSONReleaseDatabase) GetReleaseFilePath(repoPath string, metadata *ReleaseMetadata) string {
-	return db.getReleaseFilePath(repoPath, metadata)
+func (fs *releaseFileStore) GetReleaseFilePath(repoPath string, metadata *ReleaseMetadata) string {
+	return fs.getReleaseFilePath(repoPath, metadata)
 }
 
 // GetReleaseMetadata retrieves release metadata for a specific software and version.
 func (db *JSONReleaseDatabase) GetReleaseMetadata(softwareName string, version string) (*ReleaseMetadata, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	softwareReleases, ok := db.releases[softwareName]
+	softwareReleases, ok := db.releases[softwareKey(softwareName)]
 	if !ok {
-		return nil, fmt.Errorf("software package not found: %s", softwareName)
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
 	}
 	metadata, ok := softwareReleases[version]
 	if !ok {
-		return nil, fmt.Errorf("release version not found for software %s: %s", softwareName, version)
+		return nil, fmt.Errorf("release version %w for software %s: %s", ErrNotFound, softwareName, version)
 	}
 	return metadata, nil
 }
 
+// GetReleaseByID retrieves release metadata by its unique release ID.
+func (db *JSONReleaseDatabase) GetReleaseByID(id string) (*ReleaseMetadata, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if id != "" {
+		for _, softwareReleases := range db.releases {
+			for _, metadata := range softwareReleases {
+				if metadata.ID == id {
+					return metadata, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("release %w: %s", ErrNotFound, id)
+}
+
 // ListReleasesMetadataForSoftware retrieves all release metadata for a software package.
 func (db *JSONReleaseDatabase) ListReleasesMetadataForSoftware(softwareName string) ([]*ReleaseMetadata, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	softwareReleases, ok := db.releases[softwareName]
+	softwareReleases, ok := db.releases[softwareKey(softwareName)]
 	if !ok {
-		return nil, fmt.Errorf("software package not found: %s", softwareName)
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
 	}
-	var releasesMetadata []*ReleaseMetadata
+	releasesMetadata := make([]*ReleaseMetadata, 0, len(softwareReleases))
 	for _, metadata := range softwareReleases {
 		releasesMetadata = append(releasesMetadata, metadata)
 	}
@@ -88,7 +156,7 @@ func (db *JSONReleaseDatabase) ListReleasesMetadataForSoftware(softwareName stri
 func (db *JSONReleaseDatabase) ListAllReleasesMetadata() ([]*ReleaseMetadata, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	var allReleasesMetadata []*ReleaseMetadata
+	allReleasesMetadata := make([]*ReleaseMetadata, 0)
 	for _, softwareReleases := range db.releases {
 		for _, metadata := range softwareReleases {
 			allReleasesMetadata = append(allReleasesMetadata, metadata)
@@ -97,17 +165,27 @@ func (db *JSONReleaseDatabase) ListAllReleasesMetadata() ([]*ReleaseMetadata, er
 	return allReleasesMetadata, nil
 }
 
-// CreateReleaseMetadata creates new release metadata.
+// CreateReleaseMetadata creates new release metadata. The software name must be spelled like the
+// releases already stored for the package, as their files are named after it.
 func (db *JSONReleaseDatabase) CreateReleaseMetadata(metadata *ReleaseMetadata) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	if _, softwareExists := db.releases[metadata.SoftwareName]; !softwareExists {
-		db.releases[metadata.SoftwareName] = make(map[string]*ReleaseMetadata)
+	key := softwareKey(metadata.SoftwareName)
+	softwareReleases, softwareExists := db.releases[key]
+	if !softwareExists {
+		softwareReleases = make(map[string]*ReleaseMetadata)
+		db.releases[key] = softwareReleases
+	}
+	for _, existing := range softwareReleases {
+		if existing.SoftwareName != metadata.SoftwareName {
+			return fmt.Errorf("%w: %s is stored as %s", ErrSoftwareNameConflict, metadata.SoftwareName, existing.SoftwareName)
+		}
+		break // All releases of a package share its name
 	}
-	if _, versionExists := db.releases[metadata.SoftwareName][metadata.Version]; versionExists {
-		return fmt.Errorf("release version already exists for software %s: %s", metadata.SoftwareName, metadata.Version)
+	if _, versionExists := softwareReleases[metadata.Version]; versionExists {
+		return fmt.Errorf("%w for software %s: %s", ErrReleaseExists, metadata.SoftwareName, metadata.Version)
 	}
-	db.releases[metadata.SoftwareName][metadata.Version] = metadata
+	softwareReleases[metadata.Version] = metadata
 	return db.saveReleasesMetadata()
 }
 
@@ -115,67 +193,227 @@ func (db *JSONReleaseDatabase) CreateReleaseMetadata(metadata *ReleaseMetadata)
 func (db *JSONReleaseDatabase) UpdateReleaseMetadata(metadata *ReleaseMetadata) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	if _, softwareExists := db.releases[metadata.SoftwareName]; !softwareExists {
-		return fmt.Errorf("software package not found: %s", metadata.SoftwareName)
+	if err := db.replaceReleaseMetadata(metadata); err != nil {
+		return err
 	}
-	if _, versionExists := db.releases[metadata.SoftwareName][metadata.Version]; !versionExists {
-		return fmt.Errorf("release version not found for software %s: %s", metadata.SoftwareName, metadata.Version)
-	}
-	db.releases[metadata.SoftwareName][metadata.Version] = metadata // Overwrite with new metadata
 	return db.saveReleasesMetadata()
 }
 
+// replaceReleaseMetadata stores metadata in place of the existing record of the release without saving.
+// Callers must hold db.mu for writing.
+func (db *JSONReleaseDatabase) replaceReleaseMetadata(metadata *ReleaseMetadata) error {
+	softwareReleases, softwareExists := db.releases[softwareKey(metadata.SoftwareName)]
+	if !softwareExists {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, metadata.SoftwareName)
+	}
+	if _, versionExists := softwareReleases[metadata.Version]; !versionExists {
+		return fmt.Errorf("release version %w for software %s: %s", ErrNotFound, metadata.SoftwareName, metadata.Version)
+	}
+	softwareReleases[metadata.Version] = metadata // Overwrite with new metadata
+	return nil
+}
+
 // DeleteReleaseMetadata deletes release metadata.
 func (db *JSONReleaseDatabase) DeleteReleaseMetadata(softwareName string, version string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	if _, softwareReleases := db.releases[softwareName]; !softwareReleases {
-		return fmt.Errorf("software package not found: %s", softwareName)
+	key := softwareKey(softwareName)
+	if _, softwareReleases := db.releases[key]; !softwareReleases {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	if _, versionExists := db.releases[key][version]; !versionExists {
+		return fmt.Errorf("release version %w for software %s: %s", ErrNotFound, softwareName, version)
+	}
+	delete(db.releases[key], version)
+	if len(db.releases[key]) == 0 { // Clean up software entry if no releases left
+		delete(db.releases, key)
 	}
-	if _, versionExists := db.releases[softwareName][version]; !versionExists {
-		return fmt.Errorf("release version not found for software %s: %s", softwareName, version)
+	return db.saveReleasesMetadata()
+}
+
+// AddDownloadCounts adds to the download counts of several releases and saves them at once.
+func (db *JSONReleaseDatabase) AddDownloadCounts(counts map[ReleaseRef]int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	changed := false
+	for ref, n := range counts {
+		metadata, ok := db.releases[softwareKey(ref.SoftwareName)][ref.Version]
+		if !ok {
+			continue
+		}
+		updated := *metadata // Replaced rather than changed in place, readers may hold the old pointer
+		updated.DownloadCount += n
+		db.releases[softwareKey(ref.SoftwareName)][ref.Version] = &updated
+		changed = true
 	}
-	delete(db.releases[softwareName], version)
-	if len(db.releases[softwareName]) == 0 { // Clean up software entry if no releases left
-		delete(db.releases, softwareName)
+	if !changed {
+		return nil
 	}
 	return db.saveReleasesMetadata()
 }
 
+// reconcileOutcome holds the on-disk findings for a single release, computed without touching shared metadata.
+type reconcileOutcome struct {
+	metadata *ReleaseMetadata
+	state    string
+	fileSize int64
+	checksum string
+	err      error
+}
+
 // ReconcileReleases reconciles the metadata database with the actual files in the repository.
-func (db *JSONReleaseDatabase) ReconcileReleases(repoPath string) error {
+// File checks run on up to concurrency workers; state changes are applied and saved once at the end.
+// A release whose file cannot be checked is counted as errored and left unchanged.
+// Findings are applied to copies of the records stored at that point, so requests served meanwhile
+// never see a record change under them. Releases deleted or re-uploaded during the checks are skipped.
+func (db *JSONReleaseDatabase) ReconcileReleases(repoPath string, concurrency int) (*ReconcileResult, error) {
 	allReleasesMetadata, err := db.ListAllReleasesMetadata()
 	if err != nil {
-		return fmt.Errorf("failed to list all release metadata for reconciliation: %w", err)
+		return nil, fmt.Errorf("failed to list all release metadata for reconciliation: %w", err)
+	}
+	outcomes, err := db.checkReleaseFiles(repoPath, allReleasesMetadata, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	current := outcomes[:0]
+	for _, outcome := range outcomes {
+		stored, ok := db.releases[softwareKey(outcome.metadata.SoftwareName)][outcome.metadata.Version]
+		if !ok || stored.Checksum != outcome.metadata.Checksum {
+			continue // Deleted or replaced since its file was checked
+		}
+		outcome.metadata = stored // Download counts may have moved on
+		current = append(current, outcome)
+	}
+	result, reconciled := applyReconcileOutcomes(current)
+	for _, metadata := range reconciled {
+		if err := db.replaceReleaseMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.saveReleasesMetadata(); err != nil { // Save any state changes after reconciliation
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkReleaseFiles checks the files of the given releases on up to concurrency workers.
+func (fs *releaseFileStore) checkReleaseFiles(repoPath string, allReleasesMetadata []*ReleaseMetadata, concurrency int) ([]reconcileOutcome, error) {
+	if err := os.MkdirAll(repoPath, 0755); err != nil { // Fresh deployment: create the repository root
+		return nil, fmt.Errorf("failed to create repository directory: %w", err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	outcomes := make([]reconcileOutcome, len(allReleasesMetadata))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(allReleasesMetadata); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcomes[i] = fs.checkReleaseFile(repoPath, allReleasesMetadata[i])
+			}
+		}()
+	}
+	for i := range allReleasesMetadata {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
+	return outcomes, nil
+}
 
-	for _, metadata := range allReleasesMetadata {
-		releaseFilePath := db.getReleaseFilePath(repoPath, metadata)
-		_, err := os.Stat(releaseFilePath)
-		if os.IsNotExist(err) {
-			metadata.ReleaseState = "unavailable" // Mark as unavailable if file is missing
-			if err := db.UpdateReleaseMetadata(metadata); err != nil {
-				return fmt.Errorf("failed to update metadata during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
+// applyReconcileOutcomes applies the findings of a reconciliation to copies of the checked release
+// metadata and summarizes the changes; the records themselves are never modified, as readers may hold them.
+// It returns the reconciled copies, to be stored by the caller. Releases whose file could not be checked
+// are left unchanged apart from a missing ID.
+func applyReconcileOutcomes(outcomes []reconcileOutcome) (*ReconcileResult, []*ReleaseMetadata) {
+	result := &ReconcileResult{Checked: len(outcomes), Notes: []ReconcileNote{}}
+	reconciled := make([]*ReleaseMetadata, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		metadata := new(ReleaseMetadata)
+		*metadata = *outcome.metadata // Only scalar fields change, so a shallow copy suffices
+		reconciled = append(reconciled, metadata)
+		note := func(format string, args ...interface{}) {
+			result.Notes = append(result.Notes, ReconcileNote{SoftwareName: metadata.SoftwareName, Version: metadata.Version, Message: fmt.Sprintf(format, args...)})
+		}
+		if metadata.ID == "" {
+			metadata.ID = uuid.New().String() // Releases stored before IDs were assigned; kept from now on
+		}
+		if outcome.err != nil {
+			result.Errored++
+			note("%v", outcome.err) // Metadata is left as-is when the file cannot be checked
+			continue
+		}
+		previousState := metadata.ReleaseState
+		if previousState == "yanked" {
+			// Yanking is an administrator's decision that reconciliation never reverts; findings are only noted.
+			if outcome.state == "unavailable" {
+				note("release file missing from yanked release")
+			} else if metadata.Checksum != "" && metadata.Checksum != outcome.checksum {
+				note("checksum mismatch on yanked release")
 			}
-		} else if err == nil {
-			metadata.ReleaseState = "available" // Ensure state is "available" if file exists
-			fileInfo, err := os.Stat(releaseFilePath)
-			if err != nil {
-				return fmt.Errorf("failed to stat file during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
+			continue
+		}
+		metadata.ReleaseState = outcome.state
+		if outcome.state == "unavailable" {
+			if previousState != "unavailable" {
+				result.NewlyUnavailable++
+				note("release file missing, marked unavailable")
 			}
-			if metadata.FileSize != fileInfo.Size() {
-				metadata.FileSize = fileInfo.Size() // Update file size if it has changed
-				if err := db.UpdateReleaseMetadata(metadata); err != nil {
-					return fmt.Errorf("failed to update file size during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
-				}
+			continue
+		}
+		if metadata.FileSize != outcome.fileSize {
+			note("file size changed from %d to %d bytes", metadata.FileSize, outcome.fileSize)
+			metadata.FileSize = outcome.fileSize // Update file size if it has changed
+			result.SizeUpdated++
+		}
+		if metadata.Checksum == "" {
+			metadata.Checksum = outcome.checksum // Backfill releases stored before checksums were recorded
+		} else if metadata.Checksum != outcome.checksum {
+			metadata.ReleaseState = "corrupt" // Content changed since upload
+			if previousState != "corrupt" {
+				note("checksum mismatch, marked corrupt")
 			}
-
-			// Optionally verify timestamp as well if needed, but file size is more robust.
-		} else if err != nil {
-			return fmt.Errorf("error checking release file during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
+			result.Corrupt++
+		}
+		if previousState == "unavailable" && metadata.ReleaseState == "available" {
+			result.NewlyAvailable++
+			note("release file found, marked available")
 		}
+		if previousState == "corrupt" && metadata.ReleaseState == "available" {
+			result.NewlyAvailable++
+			note("checksum matches again, marked available")
+		}
+	}
+	return result, reconciled
+}
+
+// checkReleaseFile stats and checksums the file of a single release for reconciliation.
+func (fs *releaseFileStore) checkReleaseFile(repoPath string, metadata *ReleaseMetadata) reconcileOutcome {
+	outcome := reconcileOutcome{metadata: metadata}
+	releaseFilePath := fs.getReleaseFilePath(repoPath, metadata)
+	fileInfo, err := os.Stat(releaseFilePath)
+	if os.IsNotExist(err) {
+		outcome.state = "unavailable" // Mark as unavailable if file is missing
+		return outcome
+	} else if err != nil {
+		outcome.err = fmt.Errorf("error checking release file during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
+		return outcome
+	}
+
+	outcome.state = "available" // Ensure state is "available" if file exists
+	outcome.fileSize = fileInfo.Size()
+	outcome.checksum, err = computeFileChecksum(releaseFilePath, metadata.PrimaryChecksumAlgorithm())
+	if err != nil {
+		outcome.err = fmt.Errorf("failed to checksum file during reconciliation for %s %s: %w", metadata.SoftwareName, metadata.Version, err)
 	}
-	return db.saveReleasesMetadata() // Save any state changes after reconciliation
+	return outcome
 }
 
 // Close closes the database connection (no action needed for JSON file).
@@ -203,56 +441,97 @@ func (db *JSONReleaseDatabase) loadReleasesMetadata() error {
 
 	db.releases = make(map[string]map[string]*ReleaseMetadata) // Initialize map
 	for _, metadata := range releasesMetadata {
-		if _, ok := db.releases[metadata.SoftwareName]; !ok {
-			db.releases[metadata.SoftwareName] = make(map[string]*ReleaseMetadata)
+		key := softwareKey(metadata.SoftwareName)
+		if _, ok := db.releases[key]; !ok {
+			db.releases[key] = make(map[string]*ReleaseMetadata)
+		}
+		if existing, ok := db.releases[key][metadata.Version]; ok { // Written before names were matched regardless of casing
+			return fmt.Errorf("release %s of software %s and %s only differ in the casing of the name, remove one of them", metadata.Version, existing.SoftwareName, metadata.SoftwareName)
 		}
-		db.releases[metadata.SoftwareName][metadata.Version] = metadata // Populate nested map
+		db.releases[key][metadata.Version] = metadata // Populate nested map
 	}
 	return nil
 }
 
-// saveReleasesMetadata saves release metadata to the JSON file.
+// saveReleasesMetadata saves release metadata to the JSON file. Callers must hold db.mu for writing.
 func (db *JSONReleaseDatabase) saveReleasesMetadata() error {
-	db.mu.RLock() // Read lock to prevent data race during encoding
 	releasesSlice := make([]*ReleaseMetadata, 0)
 	for _, softwareReleases := range db.releases {
 		for _, metadata := range softwareReleases {
 			releasesSlice = append(releasesSlice, metadata)
 		}
 	}
-	db.mu.RUnlock()
-
-	file, err := os.Create(db.filepath)
-	if err != nil {
-		return fmt.Errorf("failed to open release metadata database file for writing: %w", err)
-	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ") // Pretty print JSON
-	if err := encoder.Encode(releasesSlice); err != nil {
-		return fmt.Errorf("failed to encode release metadata database to JSON: %w", err)
+	if err := writeJSONFileAtomic(db.filepath, releasesSlice); err != nil {
+		return fmt.Errorf("failed to write release metadata database file: %w", err)
 	}
 	return nil
 }
 
 // getSoftwareDirPath constructs the directory path for a software package.
-func (db *JSONReleaseDatabase) getSoftwareDirPath(repoPath string, softwareName string) string {
-	softwareID := generateSoftwareIDFromName(softwareName)                        // Implement ID generation logic
-	dirName := fmt.Sprintf("%06d_%s", softwareID, sanitizeFilename(softwareName)) // REQ-301: Directory naming
+func (fs *releaseFileStore) getSoftwareDirPath(repoPath string, softwareName string) string {
+	softwareID := fs.softwareIDs.Lookup(softwareName) // Assigned by EnsureReleaseDirExists before any file is written
+	sanitize := fs.filenameSanitizer(repoPath, softwareName)
+	dirName := fmt.Sprintf("%06d_%s", softwareID, sanitize(softwareName)) // REQ-301: Directory naming
 	return filepath.Join(repoPath, dirName)
 }
 
+// filenameSanitizer returns the function naming the directory and files of a software package.
+// A package whose directory was created before sanitizeFilename replaced all unsafe characters
+// keeps the earlier naming, so its existing directory and release files still resolve.
+func (fs *releaseFileStore) filenameSanitizer(repoPath string, softwareName string) func(string) string {
+	legacyName := legacySanitizeFilename(softwareName)
+	if legacyName == sanitizeFilename(softwareName) {
+		return sanitizeFilename
+	}
+	legacyDirPath := filepath.Join(repoPath, fmt.Sprintf("%06d_%s", fs.softwareIDs.Lookup(softwareName), legacyName))
+	if info, err := os.Stat(legacyDirPath); err == nil && info.IsDir() {
+		return legacySanitizeFilename
+	}
+	return sanitizeFilename
+}
+
 // getReleaseFilePath constructs the full file path for a release TGZ file.
-func (db *JSONReleaseDatabase) getReleaseFilePath(repoPath string, metadata *ReleaseMetadata) string {
-	softwareDirPath := db.getSoftwareDirPath(repoPath, metadata.SoftwareName)
-	fileName := fmt.Sprintf("%06d_%s_%02s.%02s.%02s.tgz", generateSoftwareIDFromName(metadata.SoftwareName), sanitizeFilename(metadata.SoftwareName), strings.Split(metadata.Version, ".")[0], strings.Split(metadata.Version, ".")[1], strings.Split(metadata.Version, ".")[2]) // REQ-301: File naming
+func (fs *releaseFileStore) getReleaseFilePath(repoPath string, metadata *ReleaseMetadata) string {
+	softwareDirPath := fs.getSoftwareDirPath(repoPath, metadata.SoftwareName)
+	softwareID := fs.softwareIDs.Lookup(metadata.SoftwareName)
+	sanitize := fs.filenameSanitizer(repoPath, metadata.SoftwareName)
+	core, suffix := metadata.Version, ""
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core, suffix = core[:i], core[i:] // Keep SemVer pre-release/build suffixes so they don't collide
+	}
+	coreParts := strings.Split(core, ".")
+	if !isNumericVersionCore(coreParts) { // Named mutable versions such as "nightly" have no numeric core
+		fileName := fmt.Sprintf("%06d_%s_%s.tgz", softwareID, sanitize(metadata.SoftwareName), sanitize(metadata.Version))
+		return filepath.Join(softwareDirPath, fileName)
+	}
+	for i, part := range coreParts {
+		number, _ := strconv.Atoi(part)            // Numeric, checked above
+		coreParts[i] = fmt.Sprintf("%02d", number) // Two digits per component, X.Y.Z gives 01.02.03 and X.Y.Z.BUILD gives 01.02.03.04
+	}
+	fileName := fmt.Sprintf("%06d_%s_%s%s.tgz", softwareID, sanitize(metadata.SoftwareName), strings.Join(coreParts, "."), suffix) // REQ-301: File naming
 	return filepath.Join(softwareDirPath, fileName)
 }
 
+// isNumericVersionCore reports whether the dot-separated core of a version consists of numeric components only.
+func isNumericVersionCore(coreParts []string) bool {
+	if len(coreParts) > maxVersionComponents {
+		return false
+	}
+	for _, part := range coreParts {
+		if !isNumericIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
+
 // EnsureReleaseDirExists creates the software-specific directory if it doesn't exist.
-func (db *JSONReleaseDatabase) EnsureReleaseDirExists(repoPath string, softwareName string) error {
-	dirPath := db.getSoftwareDirPath(repoPath, softwareName)
+func (fs *releaseFileStore) EnsureReleaseDirExists(repoPath string, softwareName string) error {
+	if _, err := fs.softwareIDs.Assign(softwareName); err != nil {
+		return fmt.Errorf("failed to assign software ID: %w", err)
+	}
+	dirPath := fs.getSoftwareDirPath(repoPath, softwareName)
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 		if err := os.MkdirAll(dirPath, 0755); err != nil {
 			return fmt.Errorf("failed to create software release directory: %w", err)
@@ -261,21 +540,42 @@ func (db *JSONReleaseDatabase) EnsureReleaseDirExists(repoPath string, softwareN
 	return nil
 }
 
-// StoreReleaseFile stores the uploaded release TGZ file in the repository.
-func (db *JSONReleaseDatabase) StoreReleaseFile(repoPath string, tgzFilePath string, metadata *ReleaseMetadata) (string, error) {
-	if err := db.EnsureReleaseDirExists(repoPath, metadata.SoftwareName); err != nil {
-		return "", err
+// StoredReleaseFile describes a release TGZ file written to the repository.
+type StoredReleaseFile struct {
+	Path    string
+	Size    int64
+	Digests map[string]string // Hex-encoded digests keyed by algorithm
+}
+
+// StoreReleaseFile streams a release TGZ file into the repository, computing its size and
+// digests while it is written. A partially written file is removed on failure.
+func (fs *releaseFileStore) StoreReleaseFile(repoPath string, tgz io.Reader, metadata *ReleaseMetadata, checksumAlgorithms []string) (*StoredReleaseFile, error) {
+	digests, err := newDigestWriter(checksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.EnsureReleaseDirExists(repoPath, metadata.SoftwareName); err != nil {
+		return nil, err
+	}
+	destFilePath := fs.getReleaseFilePath(repoPath, metadata)
+	destFile, err := os.Create(destFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release file: %w", err)
 	}
-	destFilePath := db.getReleaseFilePath(repoPath, metadata)
-	if err := copyFile(tgzFilePath, destFilePath); err != nil {
-		return "", fmt.Errorf("failed to store release file: %w", err)
+	size, err := io.Copy(io.MultiWriter(destFile, digests), tgz)
+	if closeErr := destFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destFilePath)
+		return nil, fmt.Errorf("failed to store release file: %w", err)
 	}
-	return destFilePath, nil
+	return &StoredReleaseFile{Path: destFilePath, Size: size, Digests: digests.Digests()}, nil
 }
 
-// GetReleaseTGZReader returns an io.Reader for the release TGZ file.
-func (db *JSONReleaseDatabase) GetReleaseTGZReader(repoPath string, metadata *ReleaseMetadata) (io.ReadCloser, error) {
-	releaseFilePath := db.getReleaseFilePath(repoPath, metadata)
+// GetReleaseTGZReader opens the release TGZ file for reading and seeking.
+func (fs *releaseFileStore) GetReleaseTGZReader(repoPath string, metadata *ReleaseMetadata) (io.ReadSeekCloser, error) {
+	releaseFilePath := fs.getReleaseFilePath(repoPath, metadata)
 	file, err := os.Open(releaseFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open release file for reading: %w", err)
@@ -283,42 +583,159 @@ func (db *JSONReleaseDatabase) GetReleaseTGZReader(repoPath string, metadata *Re
 	return file, nil
 }
 
-// --- Helper functions ---
+// StoreReleaseSBOM stores the SBOM document of a release next to its TGZ file.
+func (fs *releaseFileStore) StoreReleaseSBOM(repoPath string, metadata *ReleaseMetadata, sbom []byte) error {
+	if err := fs.EnsureReleaseDirExists(repoPath, metadata.SoftwareName); err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.GetReleaseSBOMFilePath(repoPath, metadata), sbom, 0644); err != nil {
+		return fmt.Errorf("failed to store release sbom: %w", err)
+	}
+	return nil
+}
 
-// generateSoftwareIDFromName generates a unique ID (placeholder - implement actual logic).
-func generateSoftwareIDFromName(softwareName string) int {
-	// TODO: Implement a proper ID generation strategy (e.g., using UUIDs, or a counter).
-	// For now, using a simple hash or fixed number for demonstration.
-	hash := 0
-	for _, char := range softwareName {
-		hash = hash*31 + int(char)
+// GetReleaseSBOMFilePath returns the file path of a release's SBOM document.
+func (fs *releaseFileStore) GetReleaseSBOMFilePath(repoPath string, metadata *ReleaseMetadata) string {
+	return strings.TrimSuffix(fs.getReleaseFilePath(repoPath, metadata), ".tgz") + ".sbom.json"
+}
+
+// StoreReleaseSignature stores the minisign signature of a release next to its TGZ file.
+func (fs *releaseFileStore) StoreReleaseSignature(repoPath string, metadata *ReleaseMetadata, signature []byte) error {
+	if err := fs.EnsureReleaseDirExists(repoPath, metadata.SoftwareName); err != nil {
+		return err
 	}
-	return hash & 0xFFFFF // Keep it within 6 digits range for example
+	if err := os.WriteFile(fs.GetReleaseSignatureFilePath(repoPath, metadata), signature, 0644); err != nil {
+		return fmt.Errorf("failed to store release signature: %w", err)
+	}
+	return nil
 }
 
-// sanitizeFilename sanitizes a filename to be filesystem-safe (replace invalid chars).
+// GetReleaseSignatureFilePath returns the file path of a release's minisign signature.
+func (fs *releaseFileStore) GetReleaseSignatureFilePath(repoPath string, metadata *ReleaseMetadata) string {
+	return strings.TrimSuffix(fs.getReleaseFilePath(repoPath, metadata), ".tgz") + ".minisig"
+}
+
+// DeleteReleaseFile removes the release TGZ file and its SBOM document and signature, if any, from the repository.
+func (fs *releaseFileStore) DeleteReleaseFile(repoPath string, metadata *ReleaseMetadata) error {
+	if err := os.Remove(fs.getReleaseFilePath(repoPath, metadata)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove release file: %w", err)
+	}
+	if err := os.Remove(fs.GetReleaseSBOMFilePath(repoPath, metadata)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove release sbom: %w", err)
+	}
+	if err := os.Remove(fs.GetReleaseSignatureFilePath(repoPath, metadata)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove release signature: %w", err)
+	}
+	return nil
+}
+
+// RemoveSoftwareDir removes the (empty) directory of a software package from the repository.
+// A directory that still holds files is left in place and reported as an error.
+func (fs *releaseFileStore) RemoveSoftwareDir(repoPath string, softwareName string) error {
+	if err := os.Remove(fs.getSoftwareDirPath(repoPath, softwareName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove software directory: %w", err)
+	}
+	return nil
+}
+
+// --- Helper functions ---
+
+// maxSanitizedFilenameLength caps the length of a sanitized name, leaving room for the ID prefix and version in file names.
+const maxSanitizedFilenameLength = 64
+
+// windowsReservedNames lists the device names Windows refuses as file names, with or without an extension.
+var windowsReservedNames = []string{
+	"con", "prn", "aux", "nul",
+	"com0", "com1", "com2", "com3", "com4", "com5", "com6", "com7", "com8", "com9",
+	"lpt0", "lpt1", "lpt2", "lpt3", "lpt4", "lpt5", "lpt6", "lpt7", "lpt8", "lpt9",
+}
+
+// sanitizeFilename makes a name safe to use in file names on any platform. The name is lowercased and
+// every run of characters other than ASCII letters, digits, '-' and '.' is replaced with a single '_'.
+// Dots are kept as versions use them, but repeated, leading and trailing dots are dropped. The result is capped at maxSanitizedFilenameLength and never empty
+// or a Windows device name. The mapping is deterministic but not reversible.
 func sanitizeFilename(filename string) string {
-	// Replace spaces and other unsafe characters with underscores.
+	var b strings.Builder
+	for _, r := range strings.ToLower(filename) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+		case r == '.':
+			if !strings.HasSuffix(b.String(), ".") { // ".." never survives
+				b.WriteByte('.')
+			}
+		case !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+		if b.Len() >= maxSanitizedFilenameLength {
+			break
+		}
+	}
+	sanitized := strings.Trim(b.String(), ".") // Windows drops trailing dots; a leading dot would hide the file
+	if sanitized == "" {
+		return "_"
+	}
+	base, extension, _ := strings.Cut(sanitized, ".")
+	if slices.Contains(windowsReservedNames, base) {
+		sanitized = base + "_"
+		if extension != "" {
+			sanitized += "." + extension
+		}
+	}
+	return sanitized
+}
+
+// legacySanitizeFilename is the naming used before sanitizeFilename replaced all unsafe characters.
+// It only lowercases and replaces spaces, and is kept so existing package directories still resolve.
+func legacySanitizeFilename(filename string) string {
 	return strings.ReplaceAll(strings.ToLower(filename), " ", "_")
 }
 
-// copyFile copies a file from source to destination path.
-func copyFile(sourcePath string, destPath string) error {
-	sourceFile, err := os.Open(sourcePath)
+// writeJSONFileAtomic writes v as indented JSON to a temporary file in the directory of path, syncs it
+// and renames it over path, so a crash or failed write leaves the previous content intact.
+func writeJSONFileAtomic(path string, v any) error {
+	return writeJSONFileAtomicMode(path, v, 0644)
+}
+
+// writeJSONFileAtomicMode is writeJSONFileAtomic for a file created with the given permissions.
+func writeJSONFileAtomicMode(path string, v any, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return fmt.Errorf("failed to create temporary file: %w", err)
 	}
-	defer sourceFile.Close()
+	defer os.Remove(tmpFile.Name()) // Fails harmlessly once the file has been renamed
 
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+	encoder := json.NewEncoder(tmpFile)
+	encoder.SetIndent("", "  ") // Pretty print JSON
+	if err := encoder.Encode(v); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	if err := tmpFile.Chmod(perm); err != nil { // CreateTemp creates owner-only files
+		tmpFile.Close()
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
 	}
-	defer destFile.Close()
+	if dir, err := os.Open(filepath.Dir(path)); err == nil { // Persist the rename itself; best effort
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
 
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+// ensureDatabaseDirExists creates the parent directory of a database file if it doesn't exist.
+func ensureDatabaseDirExists(dbFilePath string) error {
+	if err := os.MkdirAll(filepath.Dir(dbFilePath), 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
 	}
 	return nil
 }
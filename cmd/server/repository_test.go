@@ -0,0 +1,144 @@
+// internal/repository/repository_test.go - Tests of streaming release files into the repository.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// countingReader counts the bytes read through it and records the largest single read.
+type countingReader struct {
+	r       io.Reader
+	total   int64
+	maxRead int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.total += int64(n)
+	cr.maxRead = max(cr.maxRead, n)
+	return n, err
+}
+
+// maxStreamingRead bounds a single read of a streamed release, far below the sizes of the payloads.
+const maxStreamingRead = 256 * 1024
+
+// largePayload returns size bytes of incompressible data.
+func largePayload(size int) []byte {
+	payload := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(payload)
+	return payload
+}
+
+func TestStoreReleaseFileStreams(t *testing.T) {
+	storage := newTestStorage(t)
+	payload := largePayload(8 << 20)
+	source := &countingReader{r: bytes.NewReader(payload)}
+	metadata := &ReleaseMetadata{SoftwareName: "MyApp", Version: "1.0.0"}
+
+	stored, err := storage.releaseDB.StoreReleaseFile(storage.cfg.RepositoryPath, source, metadata, []string{ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.total != int64(len(payload)) || stored.Size != int64(len(payload)) {
+		t.Errorf("read %d bytes and stored %d, want %d", source.total, stored.Size, len(payload))
+	}
+	if source.maxRead > maxStreamingRead {
+		t.Errorf("largest read = %d bytes, want at most %d when streaming", source.maxRead, maxStreamingRead)
+	}
+	sum := sha256.Sum256(payload)
+	if got, want := stored.Digests[ChecksumSHA256], hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("digest = %s, want %s", got, want)
+	}
+	if content, err := os.ReadFile(stored.Path); err != nil || !bytes.Equal(content, payload) {
+		t.Errorf("stored file differs from the payload: %v", err)
+	}
+}
+
+func TestTGZArchiveReaderStreams(t *testing.T) {
+	payload := largePayload(8 << 20)
+	sourceFile := filepath.Join(t.TempDir(), "release.bin")
+	if err := os.WriteFile(sourceFile, payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	archive := newTGZArchiveReader(sourceFile)
+	defer archive.Close()
+	compressed := &countingReader{r: archive}
+	gzipReader, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.New()
+	extracted := &countingReader{r: tarReader}
+	if _, err := io.Copy(hash, extracted); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if header.Name != "release.bin" || header.Size != int64(len(payload)) {
+		t.Errorf("archive entry = %s of %d bytes, want release.bin of %d", header.Name, header.Size, len(payload))
+	}
+	sum := sha256.Sum256(payload)
+	if extracted.total != int64(len(payload)) || !bytes.Equal(hash.Sum(nil), sum[:]) {
+		t.Errorf("extracted %d bytes that differ from the file of %d bytes", extracted.total, len(payload))
+	}
+	if compressed.total < int64(len(payload)) {
+		t.Errorf("archive of incompressible data has %d bytes, want at least %d", compressed.total, len(payload))
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(payload))/2 {
+		t.Errorf("archiving %d bytes allocated %d bytes, want it streamed without holding the file", len(payload), allocated)
+	}
+}
+
+func TestUploadLargeRelease(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	payload := largePayload(4 << 20)
+	s.mustUpload(token, "MyApp", "1.0.0", payload)
+
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != release.FileSize {
+		t.Errorf("stored file size = %d, want the recorded %d", info.Size(), release.FileSize)
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	if _, err := tarReader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := io.ReadAll(tarReader); err != nil || !bytes.Equal(content, payload) {
+		t.Errorf("archived upload differs from the payload: %v", err)
+	}
+}
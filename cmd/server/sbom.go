@@ -0,0 +1,39 @@
+// internal/service/sbom.go - SBOM document validation.
+//
+// This file validates Software Bill of Materials documents attached to releases
+// and detects whether they are CycloneDX or SPDX JSON.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Supported SBOM formats.
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// DetectSBOMFormat checks that data is a well-formed CycloneDX or SPDX JSON document and returns its format.
+func DetectSBOMFormat(data []byte) (string, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return "", fmt.Errorf("sbom is not a valid JSON object: %w", err)
+	}
+
+	if bomFormat, _ := document["bomFormat"].(string); bomFormat == "CycloneDX" {
+		if _, ok := document["specVersion"].(string); !ok {
+			return "", fmt.Errorf("cyclonedx sbom is missing specVersion")
+		}
+		return SBOMFormatCycloneDX, nil
+	}
+	if spdxVersion, _ := document["spdxVersion"].(string); strings.HasPrefix(spdxVersion, "SPDX-") {
+		if _, ok := document["SPDXID"].(string); !ok {
+			return "", fmt.Errorf("spdx sbom is missing SPDXID")
+		}
+		return SBOMFormatSPDX, nil
+	}
+	return "", fmt.Errorf("sbom is neither a CycloneDX nor an SPDX JSON document")
+}
@@ -0,0 +1,102 @@
+// internal/service/sbom_test.go - Tests of SBOM document validation and attachment.
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	testCycloneDXSBOM = `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": []}`
+	testSPDXSBOM      = `{"spdxVersion": "SPDX-2.3", "SPDXID": "SPDXRef-DOCUMENT", "name": "myapp"}`
+)
+
+func TestDetectSBOMFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		sbom       string
+		wantFormat string // Empty if the document must be rejected
+	}{
+		{"cyclonedx", testCycloneDXSBOM, SBOMFormatCycloneDX},
+		{"spdx", testSPDXSBOM, SBOMFormatSPDX},
+		{"truncated JSON", `{"bomFormat": "CycloneDX"`, ""},
+		{"not JSON", "<bom/>", ""},
+		{"JSON array", `[{"bomFormat": "CycloneDX", "specVersion": "1.5"}]`, ""},
+		{"cyclonedx without specVersion", `{"bomFormat": "CycloneDX"}`, ""},
+		{"spdx without SPDXID", `{"spdxVersion": "SPDX-2.3"}`, ""},
+		{"unknown document", `{"name": "myapp"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectSBOMFormat([]byte(tt.sbom))
+			if tt.wantFormat == "" {
+				if err == nil {
+					t.Errorf("DetectSBOMFormat = %q, want an error", format)
+				}
+				return
+			}
+			if err != nil || format != tt.wantFormat {
+				t.Errorf("DetectSBOMFormat = %q, %v; want %q", format, err, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestUploadReleaseSBOM(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+
+	tests := []struct {
+		name       string
+		version    string
+		sbom       string
+		wantStatus int
+		wantFormat string
+	}{
+		{"cyclonedx", "1.0.0", testCycloneDXSBOM, http.StatusCreated, SBOMFormatCycloneDX},
+		{"spdx", "1.1.0", testSPDXSBOM, http.StatusCreated, SBOMFormatSPDX},
+		{"malformed", "1.2.0", `{"bomFormat": `, http.StatusBadRequest, ""},
+		{"unknown type", "1.3.0", `{"name": "myapp"}`, http.StatusBadRequest, ""},
+		{"without sbom", "1.4.0", "", http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{}
+			if tt.sbom != "" {
+				fields["sbom"] = tt.sbom
+			}
+			resp := s.upload(token, "MyApp", tt.version, []byte("release "+tt.version), fields)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("upload = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", tt.version)
+			if tt.wantStatus != http.StatusCreated {
+				if err == nil {
+					t.Errorf("release with a rejected SBOM was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.HasSBOM != (tt.sbom != "") || release.SBOMFormat != tt.wantFormat || release.SBOMSize != int64(len(tt.sbom)) {
+				t.Errorf("metadata has SBOM %v in format %q of %d bytes, want %v, %q and %d",
+					release.HasSBOM, release.SBOMFormat, release.SBOMSize, tt.sbom != "", tt.wantFormat, len(tt.sbom))
+			}
+
+			sbom := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/"+tt.version+"/sbom", nil))
+			if tt.sbom == "" {
+				if sbom.Code != http.StatusNotFound {
+					t.Errorf("SBOM of a release without one = %d, want 404", sbom.Code)
+				}
+				return
+			}
+			if sbom.Code != http.StatusOK || sbom.Body.String() != tt.sbom {
+				t.Errorf("SBOM = %d %q, want %q", sbom.Code, sbom.Body, tt.sbom)
+			}
+			if got := sbom.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// internal/service/search_test.go - Tests of the release search.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// searchMatches returns "name version" for each release of the search results, in result order.
+func searchMatches(results []*PackageSearchResult) []string {
+	matches := []string{}
+	for _, result := range results {
+		for _, release := range result.Releases {
+			matches = append(matches, result.SoftwareName+" "+release.Version)
+		}
+	}
+	return matches
+}
+
+func TestSearchReleases(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, release := range []struct{ name, version, changelog string }{
+		{"MyApp", "1.0.0", "Initial release"},
+		{"MyApp", "1.1.0", "Fixed a crash in the Parser"},
+		{"Server", "1.0.0", "First version"},
+		{"HiddenTool", "1.0.0", "Fixed a crash"},
+	} {
+		if resp := s.upload(token, release.name, release.version, []byte(release.name+" "+release.version), map[string]string{"changelog": release.changelog}); resp.Code != http.StatusCreated {
+			t.Fatalf("uploading %s %s = %d: %s", release.name, release.version, resp.Code, resp.Body)
+		}
+	}
+	for _, details := range []struct{ name, description, category string }{
+		{"MyApp", "Desktop client", "tools"},
+		{"Server", "Backend of the desktop client", "services"},
+	} {
+		if err := s.releaseService.UpdateSoftwarePackageDetails(details.name, details.description, details.category); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.releaseService.EnableDisableSoftwarePackage("HiddenTool", false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+		want  []string
+	}{
+		{"changelog", "crash", "", []string{"MyApp 1.1.0"}}, // HiddenTool is disabled
+		{"changelog in other casing", "PARSER", "", []string{"MyApp 1.1.0"}},
+		{"name matches every release", "myapp", "", []string{"MyApp 1.1.0", "MyApp 1.0.0"}},
+		{"description", "desktop", "", []string{"MyApp 1.1.0", "MyApp 1.0.0", "Server 1.0.0"}},
+		{"category", "tool", "", []string{"MyApp 1.1.0", "MyApp 1.0.0"}}, // Not HiddenTool by name
+		{"restricted to changelog", "desktop", SearchFieldChangelog, []string{}},
+		{"restricted to name", "crash", SearchFieldName, []string{}},
+		{"restricted to category", "services", SearchFieldCategory, []string{"Server 1.0.0"}},
+		{"restricted to description", "backend", SearchFieldDescription, []string{"Server 1.0.0"}},
+		{"no match", "nothing", "", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.releaseService.SearchReleases(tt.query, tt.field)
+			if err != nil {
+				t.Fatalf("SearchReleases failed: %v", err)
+			}
+			if got := searchMatches(results); !slices.Equal(got, tt.want) {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := s.releaseService.SearchReleases("crash", "version"); !errors.Is(err, ErrInvalidSearchField) {
+		t.Errorf("searching an unknown field = %v, want ErrInvalidSearchField", err)
+	}
+
+	t.Run("endpoint", func(t *testing.T) {
+		resp := s.do(s.request(http.MethodGet, "/api/v1/search?q=Desktop&field=description", nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+		}
+		var results []*PackageSearchResult
+		decodeResponse(t, resp, &results)
+		if got := searchMatches(results); !slices.Equal(got, []string{"MyApp 1.1.0", "MyApp 1.0.0", "Server 1.0.0"}) {
+			t.Errorf("matches = %v, want both packages", got)
+		}
+		if len(results) != 2 || results[0].Category != "tools" {
+			t.Errorf("results = %+v, want one group per package with its category", results)
+		}
+		for _, query := range []string{"", "?q=", "?q=%20", "?q=crash&field=version"} {
+			if resp := s.do(s.request(http.MethodGet, "/api/v1/search"+query, nil)); resp.Code != http.StatusBadRequest {
+				t.Errorf("searching %q = %d, want %d", query, resp.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
@@ -7,41 +7,103 @@ package main
 import (
 	"context"
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthService struct for authentication and authorization services.
 type AuthService struct {
-	userService *UserService // Dependency on UserService
-	logger      *log.Logger
-	apiKeys     map[string]string // In-memory API key storage (for simplicity, consider persistence)
+	userService   *UserService  // Dependency on UserService
+	authenticator Authenticator // Verifies Basic Auth credentials (local or external)
+	tokenDB       TokenDatabase // Persistent API token storage
+	tokenTTL      time.Duration // Lifetime of newly generated API tokens, 0 for no expiry
+	tokenStale    time.Duration // Unused time after which listings flag a token as stale, 0 to never flag
+	loginAttempts *LoginAttemptTracker
+	logger        *slog.Logger
 }
 
+var (
+	// ErrTokenNotFound is returned when an API token does not exist (or is not owned by the caller).
+	ErrTokenNotFound = errors.New("api token not found")
+	// ErrTokenExpired is returned when an API token is past its expiry time.
+	ErrTokenExpired = errors.New("api token expired")
+	// ErrTokenRevoked is returned when an API token has been revoked.
+	ErrTokenRevoked = errors.New("api token revoked")
+	// ErrTokenNameTaken is returned when a user already has an active API token with the requested name.
+	ErrTokenNameTaken = errors.New("api token name already in use")
+	// ErrTokenOwnerDisabled is returned when an API token belongs to a disabled user.
+	ErrTokenOwnerDisabled = errors.New("api token owner disabled")
+)
+
 // NewAuthService creates a new AuthService instance.
-func NewAuthService(userService *UserService, logger *log.Logger) *AuthService {
+func NewAuthService(cfg *Config, userService *UserService, authenticator Authenticator, tokenDB TokenDatabase, logger *slog.Logger) *AuthService {
 	return &AuthService{
-		userService: userService,
-		logger:      logger,
-		apiKeys:     make(map[string]string), // Initialize API key map
+		userService:   userService,
+		authenticator: authenticator,
+		tokenDB:       tokenDB,
+		tokenTTL:      time.Duration(cfg.TokenTTLHours) * time.Hour,
+		tokenStale:    time.Duration(cfg.TokenStaleDays) * 24 * time.Hour,
+		loginAttempts: NewLoginAttemptTracker(cfg.MaxFailedLogins, time.Duration(cfg.LockoutMinutes)*time.Minute),
+		logger:        logger,
 	}
 }
 
-// HashPassword hashes a password using MD5 (for simplicity as per spec, consider bcrypt in real-world).
-func HashPassword(password string) string {
-	hasher := md5.New()
-	hasher.Write([]byte(password))
-	return hex.EncodeToString(hasher.Sum(nil))
+// passwordHashCost is the bcrypt cost factor used when hashing new passwords.
+var passwordHashCost = bcrypt.DefaultCost
+
+// SetPasswordHashCost sets the bcrypt cost factor used by HashPassword.
+func SetPasswordHashCost(cost int) {
+	passwordHashCost = cost
+}
+
+// HashPassword hashes a password using bcrypt with the configured cost factor.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
 }
 
 // CompareHashAndPassword compares a password with its hash.
+// Legacy unsalted MD5 hashes are still accepted so existing users can log in and be upgraded.
 func CompareHashAndPassword(hashedPassword, password string) bool {
-	return hashedPassword == HashPassword(password)
+	if isLegacyPasswordHash(hashedPassword) {
+		sum := md5.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hashedPassword), []byte(hex.EncodeToString(sum[:]))) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+}
+
+// PasswordNeedsRehash reports whether a stored hash is a legacy MD5 hash or uses a different bcrypt cost.
+func PasswordNeedsRehash(hashedPassword string) bool {
+	if isLegacyPasswordHash(hashedPassword) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != passwordHashCost
+}
+
+// isLegacyPasswordHash detects hashes produced by the former MD5 scheme (32 hex characters).
+func isLegacyPasswordHash(hashedPassword string) bool {
+	if len(hashedPassword) != md5.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hashedPassword)
+	return err == nil
 }
 
 // BasicAuthMiddleware is middleware for HTTP Basic Authentication.
@@ -53,33 +115,52 @@ func (as *AuthService) BasicAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		usr, err := as.userService.GetUserByUsername(username)
-		if err != nil {
-			respondUnauthorized(w, "Invalid username or password")
+		if as.loginAttempts.IsLocked(username, time.Now()) {
+			respondUnauthorized(w, "Account temporarily locked due to too many failed login attempts")
 			return
 		}
 
-		if !usr.Enabled {
+		identity, err := as.authenticator.Authenticate(username, password)
+		if errors.Is(err, ErrAccountDisabled) {
 			respondUnauthorized(w, "Account disabled")
 			return
 		}
-
-		if !CompareHashAndPassword(usr.PasswordHash, password) {
+		if err != nil {
+			if errors.Is(err, ErrInvalidCredentials) {
+				as.loginAttempts.RecordFailure(username, time.Now())
+			}
 			respondUnauthorized(w, "Invalid username or password")
 			return
 		}
+		as.loginAttempts.RecordSuccess(username)
+
+		if identity.MustChangePassword && !isPasswordChangeRoute(r) {
+			respondForbidden(w, "Password change required: set a new password with PUT /api/v1/auth/password before using the API")
+			return
+		}
 
 		// Authentication successful, proceed
-		ctx := context.WithValue(r.Context(), ContextKeyUsername, username)
+		setRequestLogUsername(r.Context(), identity.Username)
+		ctx := context.WithValue(r.Context(), ContextKeyUsername, identity.Username)
+		ctx = context.WithValue(ctx, ContextKeyRoles, identity.Roles)
+		ctx = context.WithValue(ctx, ContextKeyAuthSource, identity.Source)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// passwordChangeRouteName names the route that stays usable while a user must change their password.
+const passwordChangeRouteName = "change-password"
+
+// isPasswordChangeRoute reports whether the request was routed to the password change endpoint.
+func isPasswordChangeRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && route.GetName() == passwordChangeRouteName
+}
+
 // AdminRoleMiddleware is middleware to check if the user has the "administrator" role.
-func AdminRoleMiddleware(next http.Handler) http.Handler {
+func (as *AuthService) AdminRoleMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//username := r.Context().Value(ContextKeyUsername).(string) // Get username from context
-		userRoles := getUserRolesFromContext(r.Context())
+		userRoles := as.getUserRolesFromContext(r.Context())
 
 		isAdmin := false
 		for _, role := range userRoles {
@@ -99,6 +180,19 @@ func AdminRoleMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// PublisherRoleMiddleware is middleware to check if the user has the "publisher" or "administrator" role.
+// Behind APIKeyAuthMiddleware the roles are those of the user the API token belongs to.
+func (as *AuthService) PublisherRoleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userRoles := as.getUserRolesFromContext(r.Context())
+		if !slices.Contains(userRoles, "publisher") && !slices.Contains(userRoles, "administrator") {
+			respondForbidden(w, "Publisher role required to upload releases")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // APIKeyAuthMiddleware is middleware for API Key authentication via header.
 func (as *AuthService) APIKeyAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -108,29 +202,254 @@ func (as *AuthService) APIKeyAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		username, ok := as.validateAPIKey(apiKey)
-		if !ok {
+		record, roles, err := as.validateAPIKey(apiKey)
+		switch {
+		case errors.Is(err, ErrTokenExpired):
+			respondUnauthorized(w, "API Key has expired")
+			return
+		case errors.Is(err, ErrTokenRevoked):
+			respondUnauthorized(w, "API Key has been revoked")
+			return
+		case errors.Is(err, ErrTokenOwnerDisabled):
+			respondUnauthorized(w, "Account disabled")
+			return
+		case err != nil:
 			respondUnauthorized(w, "Invalid API Key")
 			return
 		}
 
+		as.recordAPITokenUse(record, time.Now())
+
 		// Authentication successful, proceed
-		ctx := context.WithValue(r.Context(), ContextKeyUsername, username)
+		setRequestLogUsername(r.Context(), record.Username)
+		ctx := context.WithValue(r.Context(), ContextKeyUsername, record.Username)
+		ctx = context.WithValue(ctx, ContextKeyRoles, roles)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// GenerateAPIToken generates a new API token for a user.
-func (as *AuthService) GenerateAPIToken(username string) (string, error) {
-	token := uuid.New().String()
-	as.apiKeys[token] = username // Store token -> username (consider persistent storage)
+// GenerateAPIToken generates a new API token for an authenticated identity and persists it.
+// The token expires after the configured TTL; a TTL of 0 means it never expires.
+// A non-empty name must not be used by another of the user's active tokens.
+// Tokens of externally authenticated identities store the roles the provider granted, which are used
+// even if a local user of the same name exists; tokens of local users grant the user's current roles.
+func (as *AuthService) GenerateAPIToken(username string, roles []string, source string, name string, description string) (*APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		tokens, err := as.ListAPITokens(username)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, existing := range tokens {
+			if existing.Name == name && !existing.Revoked && !existing.IsExpired(now) {
+				return nil, fmt.Errorf("%w: %s", ErrTokenNameTaken, name)
+			}
+		}
+	}
+
+	now := time.Now()
+	token := &APIToken{
+		ID:          uuid.New().String(),
+		Token:       uuid.New().String(),
+		Username:    username,
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		CreatedAt:   now,
+	}
+	if source == AuthProviderExternal {
+		token.Source = AuthProviderExternal
+		token.Roles = slices.Clone(roles)
+	}
+	if as.tokenTTL > 0 {
+		expiresAt := now.Add(as.tokenTTL)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := as.tokenDB.CreateAPIToken(token); err != nil {
+		return nil, fmt.Errorf("failed to store api token: %w", err)
+	}
 	return token, nil
 }
 
-// validateAPIKey validates an API key and returns the associated username if valid.
-func (as *AuthService) validateAPIKey(apiKey string) (string, bool) {
-	username, ok := as.apiKeys[apiKey]
-	return username, ok
+// ListAPITokens lists token records, restricted to one user unless username is empty.
+func (as *AuthService) ListAPITokens(username string) ([]*APIToken, error) {
+	tokens, err := as.tokenDB.ListAPITokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api tokens: %w", err)
+	}
+	filtered := make([]*APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		if username == "" || token.Username == username {
+			filtered = append(filtered, token)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { // Oldest first
+		return filtered[i].CreatedAt.Before(filtered[j].CreatedAt)
+	})
+	return filtered, nil
+}
+
+// RevokeAPIToken revokes a token by ID. A non-empty owner restricts revocation to that user's tokens.
+func (as *AuthService) RevokeAPIToken(tokenID string, owner string) error {
+	token, err := as.tokenDB.GetAPITokenByID(tokenID)
+	if err != nil || (owner != "" && token.Username != owner) {
+		return ErrTokenNotFound
+	}
+	revoked := *token // The stored record may be read by concurrent requests
+	revoked.Revoked = true
+	if err := as.tokenDB.UpdateAPIToken(&revoked); err != nil {
+		return fmt.Errorf("failed to revoke api token: %w", err)
+	}
+	return nil
+}
+
+// PurgeAPITokens deletes the records of tokens that are revoked or expired at the given time
+// and returns how many were deleted.
+func (as *AuthService) PurgeAPITokens(now time.Time) (int, error) {
+	tokens, err := as.tokenDB.ListAPITokens()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list api tokens: %w", err)
+	}
+	purged := 0
+	for _, token := range tokens {
+		if !token.Revoked && !token.IsExpired(now) {
+			continue
+		}
+		if err := as.tokenDB.DeleteAPIToken(token.Token); err != nil {
+			return purged, fmt.Errorf("failed to delete api token %s: %w", token.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartTokenCleanup purges revoked and expired API tokens right away and then on every interval until
+// ctx is cancelled. The returned channel is closed once no purge is running any more, so the token
+// database can be closed safely. An interval of 0 disables the cleanup.
+func (as *AuthService) StartTokenCleanup(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	if interval <= 0 {
+		close(done)
+		return done
+	}
+	purge := func() {
+		purged, err := as.PurgeAPITokens(time.Now())
+		if err != nil {
+			as.logger.Error("API token cleanup failed", "purged", purged, "error", err)
+			return
+		}
+		if purged > 0 {
+			as.logger.Info("Purged revoked and expired API tokens", "count", purged)
+		}
+	}
+
+	go func() {
+		defer close(done)
+		purge()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purge()
+			}
+		}
+	}()
+	return done
+}
+
+// RevokeAPITokenByName revokes the owner's active API token with the given name.
+func (as *AuthService) RevokeAPITokenByName(name string, owner string) error {
+	tokens, err := as.ListAPITokens(owner)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	now := time.Now()
+	for _, token := range tokens {
+		if name != "" && token.Name == name && !token.Revoked && !token.IsExpired(now) {
+			return as.RevokeAPIToken(token.ID, owner)
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// GetUserSecurityStatus returns the failed-login counters and lock status of an existing user.
+func (as *AuthService) GetUserSecurityStatus(username string) (*UserSecurityStatus, error) {
+	if _, err := as.userService.GetUserByUsername(username); err != nil {
+		return nil, err
+	}
+	status := as.loginAttempts.Status(username, time.Now())
+	return &status, nil
+}
+
+// ListActiveAPITokens lists a user's tokens that are neither revoked nor expired.
+func (as *AuthService) ListActiveAPITokens(username string) ([]*APIToken, error) {
+	tokens, err := as.ListAPITokens(username)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	active := make([]*APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		if !token.Revoked && !token.IsExpired(now) {
+			active = append(active, token)
+		}
+	}
+	return active, nil
+}
+
+// ResetUserSecurityStatus clears the failed-login counters and any lock of an existing user.
+func (as *AuthService) ResetUserSecurityStatus(username string, actor string) error {
+	if _, err := as.userService.GetUserByUsername(username); err != nil {
+		return err
+	}
+	as.loginAttempts.Reset(username)
+	as.logger.Info("AUDIT: failed-login counters reset", "actor", actor, "username", username)
+	return nil
+}
+
+// validateAPIKey validates an API key and returns the associated token record and the roles it grants.
+// Tokens of local users grant the user's current roles, tokens of external identities the roles stored on them.
+func (as *AuthService) validateAPIKey(apiKey string) (*APIToken, []string, error) {
+	record, err := as.tokenDB.GetAPIToken(apiKey)
+	if err != nil {
+		return nil, nil, ErrTokenNotFound
+	}
+	if record.Revoked {
+		return nil, nil, ErrTokenRevoked
+	}
+	if record.IsExpired(time.Now()) {
+		return nil, nil, ErrTokenExpired
+	}
+	if record.Source == AuthProviderExternal {
+		return record, record.Roles, nil // A local user of the same name must not lend it their roles
+	}
+	owner, err := as.userService.GetUserByUsername(record.Username)
+	if err != nil {
+		return nil, nil, ErrTokenNotFound // The owner was deleted
+	}
+	if !owner.Enabled {
+		return nil, nil, ErrTokenOwnerDisabled
+	}
+	return record, owner.Roles, nil
+}
+
+// recordAPITokenUse updates a token's LastUsedAt unless it was already recorded within apiTokenUseResolution.
+func (as *AuthService) recordAPITokenUse(record *APIToken, now time.Time) {
+	if record.LastUsedAt != nil && now.Sub(*record.LastUsedAt) < apiTokenUseResolution {
+		return
+	}
+	if err := as.tokenDB.RecordAPITokenUse(record.Token, now); err != nil {
+		as.logger.Warn("Failed to record API token use", "token_id", record.ID, "error", err)
+	}
+}
+
+// TokenStaleAfter returns how long a token may go unused before listings flag it as stale, 0 if never.
+func (as *AuthService) TokenStaleAfter() time.Duration {
+	return as.tokenStale
 }
 
 // extractAPIKeyFromHeader extracts the API key from the Authorization header (Bearer token).
@@ -154,25 +473,35 @@ type contextKey string
 // ContextKeyUsername is the key for username in context.
 var ContextKeyUsername contextKey = "username"
 
+// ContextKeyRoles is the key for the authenticated identity's roles in context.
+var ContextKeyRoles contextKey = "roles"
+
+// ContextKeyAuthSource is the key for the provider that authenticated the identity in context.
+var ContextKeyAuthSource contextKey = "auth_source"
+
 // GetUsernameFromContext retrieves the username from the request context.
 func GetUsernameFromContext(ctx context.Context) (string, bool) {
 	username, ok := ctx.Value(ContextKeyUsername).(string)
 	return username, ok
 }
 
-// getUserRolesFromContext retrieves user roles - placeholder, needs to fetch from DB based on username in context.
-func getUserRolesFromContext(ctx context.Context) []string {
+// getUserRolesFromContext retrieves user roles resolved by the authenticator, falling back to
+// the roles stored in the user's record.
+func (as *AuthService) getUserRolesFromContext(ctx context.Context) []string {
+	if roles, ok := ctx.Value(ContextKeyRoles).([]string); ok {
+		return roles // Roles resolved by the authenticator
+	}
+
 	username, ok := GetUsernameFromContext(ctx)
 	if !ok {
 		return []string{} // No username, no roles
 	}
 
-	// Placeholder: Fetch user roles from database based on username (using AuthService's userService)
-	// In real implementation, fetch from database using username.
-	if username == "admin" { // Example: hardcoded admin role for "admin" user
-		return []string{"administrator", "user"}
+	usr, err := as.userService.GetUserByUsername(username)
+	if err != nil {
+		return []string{} // Unknown user, no roles
 	}
-	return []string{"user"} // Default user role
+	return usr.Roles
 }
 
 // --- Response helper functions ---
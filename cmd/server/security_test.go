@@ -0,0 +1,161 @@
+// internal/security/security_test.go - Tests of password hashing, the legacy MD5 upgrade and role checks.
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// legacyMD5Hash returns a password hash in the format stored before bcrypt was introduced.
+func legacyMD5Hash(password string) string {
+	sum := md5.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCompareHashAndPassword(t *testing.T) {
+	SetPasswordHashCost(bcrypt.MinCost)
+	bcryptHash, err := HashPassword("Correct-Horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"bcrypt match", bcryptHash, "Correct-Horse-1", true},
+		{"bcrypt mismatch", bcryptHash, "Wrong-Horse-1", false},
+		{"legacy md5 match", legacyMD5Hash("Correct-Horse-1"), "Correct-Horse-1", true},
+		{"legacy md5 mismatch", legacyMD5Hash("Correct-Horse-1"), "Wrong-Horse-1", false},
+		{"md5 hex of the password is not accepted as bcrypt", bcryptHash, legacyMD5Hash("Correct-Horse-1"), false},
+		{"empty hash", "", "", false},
+		{"malformed hash", "not-a-hash", "not-a-hash", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareHashAndPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("CompareHashAndPassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	SetPasswordHashCost(bcrypt.MinCost)
+	current, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCost, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-1"), bcrypt.MinCost+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"legacy md5", legacyMD5Hash("Correct-Horse-1"), true},
+		{"bcrypt with the configured cost", string(current), false},
+		{"bcrypt with another cost", string(otherCost), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PasswordNeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("PasswordNeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyMD5PasswordUpgradedOnLogin(t *testing.T) {
+	s := newTestServer(t, nil)
+	const password = "Legacy-Pass-123"
+	if err := s.userDB.CreateUser(&User{Username: "legacy", PasswordHash: legacyMD5Hash(password), Roles: []string{"user"}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	login := func(password string) int {
+		req := s.request(http.MethodGet, "/api/v1/auth/tokens", nil)
+		req.SetBasicAuth("legacy", password)
+		return s.do(req).Code
+	}
+
+	if code := login("Wrong-Pass-123"); code != http.StatusUnauthorized {
+		t.Fatalf("login with a wrong password = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if user, _ := s.userDB.GetUserByUsername("legacy"); user.PasswordHash != legacyMD5Hash(password) {
+		t.Fatal("a failed login changed the stored hash")
+	}
+	if code := login(password); code != http.StatusOK {
+		t.Fatalf("login with the legacy password = %d, want %d", code, http.StatusOK)
+	}
+
+	user, err := s.userDB.GetUserByUsername("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+		t.Fatalf("stored hash %q is not a bcrypt hash after login: %v", user.PasswordHash, err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		t.Error("upgraded hash does not match the password")
+	}
+	if code := login(password); code != http.StatusOK {
+		t.Errorf("login after the upgrade = %d, want %d", code, http.StatusOK)
+	}
+
+	reopened, err := NewJSONUserDatabase(filepath.Join(s.cfg.DataPath, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if persisted, err := reopened.GetUserByUsername("legacy"); err != nil || isLegacyPasswordHash(persisted.PasswordHash) {
+		t.Errorf("users.json still holds the legacy hash (%v)", err)
+	}
+}
+
+func TestAdminRoleComesFromUserRecord(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("operator", "Operator-Pass-1", "administrator")
+	s.createUser("reader", "Reader-Pass-123", "user")
+	if err := s.userService.DeleteUser("admin"); err != nil {
+		t.Fatal(err)
+	}
+	s.createUser("admin", "Demoted-Pass-123", "user", "publisher") // Named admin, but not an administrator
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		wantStatus int
+	}{
+		{"administrator role under another name", "operator", "Operator-Pass-1", http.StatusOK},
+		{"user named admin without the role", "admin", "Demoted-Pass-123", http.StatusForbidden},
+		{"plain user", "reader", "Reader-Pass-123", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(withBasicAuth(s.request(http.MethodGet, "/api/v1/admin/users", nil), tt.username, tt.password))
+			if resp.Code != tt.wantStatus {
+				t.Errorf("listing users as %s = %d, want %d: %s", tt.username, resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+
+	// Without roles from an authenticator in the context, the roles are looked up by username.
+	ctx := context.WithValue(context.Background(), ContextKeyUsername, "operator")
+	if got := s.authService.getUserRolesFromContext(ctx); !slices.Equal(got, []string{"administrator"}) {
+		t.Errorf("roles of operator = %v, want [administrator]", got)
+	}
+	ctx = context.WithValue(context.Background(), ContextKeyUsername, "nobody")
+	if got := s.authService.getUserRolesFromContext(ctx); len(got) != 0 {
+		t.Errorf("roles of an unknown user = %v, want none", got)
+	}
+}
@@ -5,75 +5,257 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
-	"log"
+	"html"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
+	"path"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Search fields accepted by SearchReleases to restrict the search scope.
+const (
+	SearchFieldName        = "name"
+	SearchFieldCategory    = "category"
+	SearchFieldDescription = "description"
+	SearchFieldChangelog   = "changelog"
+)
+
+// ErrInvalidVersionPrefix is returned when a version prefix filter is not of the form X, X.Y, X.Y.Z and so on.
+var ErrInvalidVersionPrefix = errors.New("invalid version prefix")
+
+// ErrInvalidSoftwareName is returned when a software name is empty or could not be used safely in repository paths.
+var ErrInvalidSoftwareName = errors.New("invalid software name")
+
+// ErrInvalidVersion is returned when an uploaded release version is neither a SemVer version with the configured
+// number of numeric components nor a configured mutable version.
+var ErrInvalidVersion = errors.New("invalid version")
+
+// ErrInvalidReleaseState is returned when a release state change is not one an administrator may make.
+var ErrInvalidReleaseState = errors.New("invalid release state")
+
+// ErrInvalidReleaseFilter is returned when a release listing filter names an unknown state or an empty date window.
+var ErrInvalidReleaseFilter = errors.New("invalid release filter")
+
+// ErrInvalidSort is returned when a release listing is sorted by an unknown field or in an unknown order.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// releaseSortFields and releaseSortOrders list the accepted sort and order values of release listings.
+var (
+	releaseSortFields = []string{"version", "date"}
+	releaseSortOrders = []string{"asc", "desc"}
 )
 
+// releaseStates lists the states a release can be in.
+var releaseStates = []string{"available", "unavailable", "corrupt", "yanked"}
+
+// ErrInvalidTag is returned when a dist-tag name is malformed or reserved, or the tag would point at no release.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// ErrTagNotFound is returned when a software package has no dist-tag of the given name.
+var ErrTagNotFound = errors.New("tag not found")
+
+// releaseTagPattern restricts dist-tag names; the leading letter keeps them apart from X.Y.Z versions.
+var releaseTagPattern = regexp.MustCompile(`^[a-z][a-z0-9._-]{0,63}$`)
+
+// reservedTagLatest is resolved through the package's latest strategy and cannot be set as a dist-tag.
+const reservedTagLatest = "latest"
+
+// ErrReconcileInProgress is returned when a reconciliation is requested while another one is running.
+var ErrReconcileInProgress = errors.New("reconciliation already in progress")
+
+// ErrInsufficientStorage is returned when the repository filesystem lacks room for an upload.
+var ErrInsufficientStorage = errors.New("insufficient storage")
+
+// ErrStorageQuotaExceeded is returned when an upload would take a package or the repository past its storage quota.
+var ErrStorageQuotaExceeded = fmt.Errorf("%w: storage quota exceeded", ErrInsufficientStorage)
+
+// ErrInvalidStorageQuota is returned when a package storage quota is negative.
+var ErrInvalidStorageQuota = errors.New("invalid storage quota")
+
+// ErrDiskSpaceUnsupported is returned by freeDiskSpace on platforms where it cannot be determined.
+var ErrDiskSpaceUnsupported = errors.New("free disk space lookup not supported on this platform")
+
+// ErrInvalidSearchField is returned when SearchReleases is given an unknown field filter.
+var ErrInvalidSearchField = errors.New("invalid search field")
+
 // ReleaseService struct holds dependencies for release management operations.
 type ReleaseService struct {
 	config    *Config
 	releaseDB ReleaseDatabase
-	logger    *log.Logger
+	packageDB SoftwarePackageDatabase
+	logger    *slog.Logger
+	freeSpace func(path string) (uint64, error) // Free disk space lookup, replaceable for testing
+	webhooks  *WebhookNotifier                  // Notified of published releases
+	reconcile sync.Mutex                        // Held while a reconciliation runs
+	quotas    sync.Mutex                        // Held from the final storage quota check of an upload until its metadata is stored
+	downloads *DownloadCounter                  // Batches download count updates
 }
 
 // NewReleaseService creates a new ReleaseService instance.
-func NewReleaseService(cfg *Config, db ReleaseDatabase, logger *log.Logger) *ReleaseService {
+func NewReleaseService(cfg *Config, db ReleaseDatabase, packageDB SoftwarePackageDatabase, logger *slog.Logger) *ReleaseService {
 	return &ReleaseService{
 		config:    cfg,
 		releaseDB: db,
+		packageDB: packageDB,
 		logger:    logger,
+		freeSpace: freeDiskSpace,
+		webhooks:  NewWebhookNotifier(cfg, logger),
+		downloads: NewDownloadCounter(db, downloadCountFlushInterval, logger),
+	}
+}
+
+// EnsureStorageAvailable checks that the repository filesystem keeps at least the configured
+// minimum of free space after storing incoming bytes. The check is skipped when the minimum is 0
+// or free space cannot be determined on this platform.
+func (s *ReleaseService) EnsureStorageAvailable(incoming int64) error {
+	if s.config.MinFreeDiskBytes <= 0 {
+		return nil
+	}
+	available, err := s.freeSpace(s.config.RepositoryPath)
+	if errors.Is(err, ErrDiskSpaceUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to determine free disk space: %w", err)
+	}
+	required := uint64(s.config.MinFreeDiskBytes) + uint64(max(incoming, 0))
+	if available < required {
+		return fmt.Errorf("%w: %d bytes free, %d bytes required", ErrInsufficientStorage, available, required)
+	}
+	return nil
+}
+
+// checkStorageQuotas checks that storing incoming more bytes for a package keeps the package and the
+// repository within their storage quotas, counting the release files, SBOMs and signatures already stored.
+// The release being replaced, if any, no longer counts as its files are removed once the upload commits.
+func (s *ReleaseService) checkStorageQuotas(softwareName string, incoming int64, replaced *ReleaseMetadata) error {
+	packageQuota := s.config.PackageStorageQuotaBytes
+	if software, err := s.packageDB.GetSoftwarePackage(softwareName); err == nil && software.StorageQuotaBytes > 0 {
+		packageQuota = software.StorageQuotaBytes
+	}
+	if packageQuota <= 0 && s.config.StorageQuotaBytes <= 0 {
+		return nil
+	}
+	releases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to determine storage usage: %w", err)
+	}
+	var packageUsage, totalUsage int64
+	for _, release := range releases {
+		if replaced != nil && release.ID == replaced.ID {
+			continue
+		}
+		totalUsage += release.StoredSize()
+		if softwareKey(release.SoftwareName) == softwareKey(softwareName) {
+			packageUsage += release.StoredSize()
+		}
 	}
+	incoming = max(incoming, 0)
+	if packageQuota > 0 && packageUsage+incoming > packageQuota {
+		return fmt.Errorf("%w: software package %s would use %d of its %d bytes", ErrStorageQuotaExceeded, softwareName, packageUsage+incoming, packageQuota)
+	}
+	if s.config.StorageQuotaBytes > 0 && totalUsage+incoming > s.config.StorageQuotaBytes {
+		return fmt.Errorf("%w: the repository would use %d of its %d bytes", ErrStorageQuotaExceeded, totalUsage+incoming, s.config.StorageQuotaBytes)
+	}
+	return nil
 }
 
-// GetTotalSoftwarePackages returns the total number of software packages (placeholder).
-func (s *ReleaseService) GetTotalSoftwarePackages() int {
-	releases, _ := s.releaseDB.ListAllReleasesMetadata() // Ignoring error for simplicity in this example
+// GetTotalSoftwarePackages returns the number of distinct software packages, counting both
+// package definitions and packages that only exist through their releases.
+func (s *ReleaseService) GetTotalSoftwarePackages() (int, error) {
+	releases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list releases: %w", err)
+	}
+	packages, err := s.packageDB.ListSoftwarePackages()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list software package definitions: %w", err)
+	}
 	softwarePackages := make(map[string]bool)
-	for _, r := range releases {
-		softwarePackages[r.SoftwareName] = true
+	for _, release := range releases {
+		softwarePackages[softwareKey(release.SoftwareName)] = true
+	}
+	for _, software := range packages {
+		softwarePackages[softwareKey(software.Name)] = true
 	}
-	return len(softwarePackages)
+	return len(softwarePackages), nil
 }
 
-// GetTotalReleases returns the total number of releases (placeholder).
-func (s *ReleaseService) GetTotalReleases() int {
-	releases, _ := s.releaseDB.ListAllReleasesMetadata() // Ignoring error for simplicity in this example
-	return len(releases)
+// GetTotalReleases returns the total number of releases across all software packages.
+func (s *ReleaseService) GetTotalReleases() (int, error) {
+	releases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list releases: %w", err)
+	}
+	return len(releases), nil
 }
 
-// ListSoftwarePackages retrieves a list of all software packages (names and latest versions).
-func (s *ReleaseService) ListSoftwarePackages() ([]*SoftwarePackageInfo, error) {
+// ListSoftwarePackages retrieves a list of all software packages (names and latest versions),
+// merging persisted package definitions with the latest-version info derived from releases.
+// Disabled packages are left out unless includeDisabled is set.
+func (s *ReleaseService) ListSoftwarePackages(includeDisabled bool) ([]*SoftwarePackageInfo, error) {
 	allReleases, err := s.releaseDB.ListAllReleasesMetadata()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list all releases for software packages overview: %w", err)
 	}
+	packages, err := s.packageDB.ListSoftwarePackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list software package definitions: %w", err)
+	}
 
-	packageMap := make(map[string]*SoftwarePackageInfo) // softwareName -> PackageInfo
+	releasesByKey := make(map[string][]*ReleaseMetadata) // softwareKey -> releases
 	for _, release := range allReleases {
-		if pkgInfo, ok := packageMap[release.SoftwareName]; ok {
-			currentVersion, _ := parseVersion(pkgInfo.LatestVersion)
-			newVersion, _ := parseVersion(release.Version)
-			if newVersion.GreaterThan(currentVersion) {
-				pkgInfo.LatestVersion = release.Version // Update to latest version
-				pkgInfo.LatestReleaseDate = release.ReleaseDate
-			}
-		} else {
-			packageMap[release.SoftwareName] = &SoftwarePackageInfo{
-				Name:              release.SoftwareName,
-				LatestVersion:     release.Version,
-				LatestReleaseDate: release.ReleaseDate,
-			}
+		key := softwareKey(release.SoftwareName)
+		releasesByKey[key] = append(releasesByKey[key], release)
+	}
+	packageMap := make(map[string]*SoftwarePackageInfo) // softwareKey -> PackageInfo
+	for key, releases := range releasesByKey {
+		pkgInfo := &SoftwarePackageInfo{
+			Name:    releases[0].SoftwareName,
+			Enabled: true, // Packages without a definition are enabled
+		}
+		latest, err := s.selectLatestRelease(pkgInfo.Name, releases)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
 		}
+		if latest != nil { // Packages whose releases are all withdrawn are listed without a latest version
+			pkgInfo.LatestVersion = latest.Version
+			pkgInfo.LatestReleaseDate = latest.ReleaseDate
+		}
+		packageMap[key] = pkgInfo
+	}
+	for _, software := range packages {
+		pkgInfo, ok := packageMap[softwareKey(software.Name)]
+		if !ok {
+			pkgInfo = &SoftwarePackageInfo{Name: software.Name} // Defined before its first release
+			packageMap[softwareKey(software.Name)] = pkgInfo
+		}
+		pkgInfo.Description = software.Description
+		pkgInfo.Category = software.Category
+		pkgInfo.Enabled = software.Enabled
+		pkgInfo.Featured = software.Featured
+		pkgInfo.Owner = software.Owner
 	}
 
 	packageList := make([]*SoftwarePackageInfo, 0, len(packageMap))
 	for _, pkgInfo := range packageMap {
-		packageList = append(packageList, pkgInfo)
+		if pkgInfo.Enabled || includeDisabled {
+			packageList = append(packageList, pkgInfo)
+		}
 	}
 	sort.Slice(packageList, func(i, j int) bool { // Sort by software name
 		return packageList[i].Name < packageList[j].Name
@@ -81,193 +263,1388 @@ func (s *ReleaseService) ListSoftwarePackages() ([]*SoftwarePackageInfo, error)
 	return packageList, nil
 }
 
+// ListFeaturedSoftwarePackages lists the packages flagged as featured, in the configured featured order.
+func (s *ReleaseService) ListFeaturedSoftwarePackages() ([]*SoftwarePackageInfo, error) {
+	packageInfos, err := s.ListSoftwarePackages(false)
+	if err != nil {
+		return nil, err
+	}
+	featured := make([]*SoftwarePackageInfo, 0)
+	for _, pkgInfo := range packageInfos {
+		if pkgInfo.Featured {
+			featured = append(featured, pkgInfo)
+		}
+	}
+
+	sort.SliceStable(featured, func(i, j int) bool {
+		if s.config.FeaturedPackagesOrder == FeaturedOrderLatestRelease && !featured[i].LatestReleaseDate.Equal(featured[j].LatestReleaseDate) {
+			return featured[i].LatestReleaseDate.After(featured[j].LatestReleaseDate) // Most recently released first
+		}
+		return featured[i].Name < featured[j].Name
+	})
+	return featured, nil
+}
+
+// SearchReleases finds releases whose package name, category, description or changelog contains
+// the query (case-insensitive), grouped by package. Package-level matches include all releases of
+// the package. A non-empty field restricts the search to that field. Disabled packages are skipped.
+func (s *ReleaseService) SearchReleases(query string, field string) ([]*PackageSearchResult, error) {
+	switch field {
+	case "", SearchFieldName, SearchFieldCategory, SearchFieldDescription, SearchFieldChangelog:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSearchField, field)
+	}
+	query = strings.ToLower(query)
+	matches := func(searchField string, value string) bool {
+		return (field == "" || field == searchField) && strings.Contains(strings.ToLower(value), query)
+	}
+
+	packages, err := s.ListSoftwarePackages(false)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*PackageSearchResult, 0)
+	for _, pkgInfo := range packages { // Already sorted by name, disabled packages left out
+		releases, err := s.releaseDB.ListReleasesMetadataForSoftware(pkgInfo.Name)
+		if err != nil {
+			continue // Defined package without releases
+		}
+		packageMatch := matches(SearchFieldName, pkgInfo.Name) || matches(SearchFieldCategory, pkgInfo.Category) || matches(SearchFieldDescription, pkgInfo.Description)
+
+		matched := make([]*ReleaseMetadata, 0)
+		for _, release := range releases {
+			if packageMatch || matches(SearchFieldChangelog, release.Changelog) {
+				matched = append(matched, release)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		sort.Slice(matched, func(i, j int) bool { // Newest version first
+			version1, _ := parseVersion(matched[i].Version)
+			version2, _ := parseVersion(matched[j].Version)
+			return version1.Compare(version2) > 0
+		})
+		results = append(results, &PackageSearchResult{SoftwareName: pkgInfo.Name, Category: pkgInfo.Category, Releases: matched})
+	}
+	return results, nil
+}
+
+// SetSoftwarePackageFeatured flags or unflags a software package as featured.
+func (s *ReleaseService) SetSoftwarePackageFeatured(softwareName string, featured bool) error {
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Featured = featured
+	})
+}
+
+// SetSoftwarePackageStorageQuota sets the storage quota of a software package in bytes.
+// A quota of 0 removes the override, so the configured package quota applies again.
+func (s *ReleaseService) SetSoftwarePackageStorageQuota(softwareName string, quotaBytes int64) error {
+	if quotaBytes < 0 {
+		return fmt.Errorf("%w: %d must be non-negative", ErrInvalidStorageQuota, quotaBytes)
+	}
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.StorageQuotaBytes = quotaBytes
+	})
+}
+
+// updateSoftwarePackage applies a change to a package definition and persists it.
+// Packages that only exist through their releases get a definition created on first use.
+func (s *ReleaseService) updateSoftwarePackage(softwareName string, apply func(software *SoftwarePackage)) error {
+	softwareName = s.resolveSoftwareName(softwareName)
+	software, err := s.packageDB.GetSoftwarePackage(softwareName)
+	if err != nil {
+		if _, listErr := s.releaseDB.ListReleasesMetadataForSoftware(softwareName); listErr != nil {
+			return fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+		}
+		software = &SoftwarePackage{Name: softwareName, Enabled: true}
+		apply(software)
+		return s.packageDB.CreateSoftwarePackage(software)
+	}
+	updated := *software // Copy so readers never see a half-applied change
+	apply(&updated)
+	return s.packageDB.UpdateSoftwarePackage(&updated)
+}
+
+// ValidateTagName checks that a dist-tag name is lowercase letters, digits, '.', '_' or '-', starts with a letter
+// and is not reserved.
+func ValidateTagName(tag string) error {
+	if !releaseTagPattern.MatchString(tag) {
+		return fmt.Errorf("%w: %q must be 1-64 lowercase letters, digits, '.', '_' or '-' and start with a letter", ErrInvalidTag, tag)
+	}
+	if tag == reservedTagLatest {
+		return fmt.Errorf("%w: %q is reserved for the latest release", ErrInvalidTag, tag)
+	}
+	return nil
+}
+
+// ListReleaseTags returns the dist-tags of a software package.
+func (s *ReleaseService) ListReleaseTags(softwareName string) (*ReleaseTagsResponse, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	response := &ReleaseTagsResponse{SoftwareName: softwareName, Tags: map[string]string{}}
+	software, err := s.packageDB.GetSoftwarePackage(softwareName)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if _, listErr := s.releaseDB.ListReleasesMetadataForSoftware(softwareName); listErr != nil {
+			return nil, listErr // Wraps ErrSoftwareNotFound when the package has no releases either
+		}
+		return response, nil // Only exists through its releases, so it has no tags yet
+	}
+	maps.Copy(response.Tags, software.Tags)
+	return response, nil
+}
+
+// SetReleaseTag points a dist-tag of a software package at one of its releases, replacing any previous target.
+// A tag cannot share its name with a version of the package, since either could be meant in a download URL.
+func (s *ReleaseService) SetReleaseTag(softwareName string, tag string, version string) error {
+	if err := ValidateTagName(tag); err != nil {
+		return err
+	}
+	softwareName = s.resolveSoftwareName(softwareName)
+	if _, err := s.releaseDB.GetReleaseMetadata(softwareName, tag); err == nil {
+		return fmt.Errorf("%w: %q is a version of %s", ErrInvalidTag, tag, softwareName)
+	}
+	if _, err := s.releaseDB.GetReleaseMetadata(softwareName, version); err != nil {
+		return fmt.Errorf("%w: %s has no release %q", ErrInvalidTag, softwareName, version)
+	}
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Tags = maps.Clone(software.Tags) // The stored map may be shared with concurrent readers
+		if software.Tags == nil {
+			software.Tags = make(map[string]string)
+		}
+		software.Tags[tag] = version
+	})
+}
+
+// DeleteReleaseTag removes a dist-tag from a software package.
+func (s *ReleaseService) DeleteReleaseTag(softwareName string, tag string) error {
+	softwareName = s.resolveSoftwareName(softwareName)
+	software, err := s.packageDB.GetSoftwarePackage(softwareName)
+	if err != nil || software.Tags[tag] == "" {
+		return fmt.Errorf("%w: %s has no tag %q", ErrTagNotFound, softwareName, tag)
+	}
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Tags = maps.Clone(software.Tags)
+		delete(software.Tags, tag)
+	})
+}
+
+// removeTagsOfVersion removes the dist-tags pointing at a deleted release.
+func (s *ReleaseService) removeTagsOfVersion(softwareName string, version string) error {
+	software, err := s.packageDB.GetSoftwarePackage(softwareName)
+	if err != nil || !slices.Contains(slices.Collect(maps.Values(software.Tags)), version) {
+		return nil
+	}
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Tags = maps.Clone(software.Tags)
+		maps.DeleteFunc(software.Tags, func(_ string, target string) bool { return target == version })
+	})
+}
+
+// getReleaseByVersionOrTag retrieves a release by version, falling back to the dist-tag of that name.
+// Versions take precedence, so a tag never shadows a release.
+func (s *ReleaseService) getReleaseByVersionOrTag(softwareName string, versionOrTag string) (*ReleaseMetadata, error) {
+	metadata, err := s.releaseDB.GetReleaseMetadata(softwareName, versionOrTag)
+	if err == nil || !releaseTagPattern.MatchString(versionOrTag) {
+		return metadata, err
+	}
+	software, packageErr := s.packageDB.GetSoftwarePackage(softwareName)
+	if packageErr != nil && !errors.Is(packageErr, ErrNotFound) {
+		return nil, packageErr
+	}
+	if packageErr != nil || software.Tags[versionOrTag] == "" {
+		return nil, err // Report the version lookup failure
+	}
+	return s.releaseDB.GetReleaseMetadata(softwareName, software.Tags[versionOrTag])
+}
+
 // ListReleasesForSoftware retrieves releases for a specific software, with sorting options.
-func (s *ReleaseService) ListReleasesForSoftware(softwareName string, sortField string, sortOrder string) ([]*ReleaseMetadata, error) {
+// A non-empty versionPrefix (X or X.Y or X.Y.Z, and so on) keeps only releases whose leading
+// version components equal the prefix, so "1.2" matches 1.2.x but not 1.20.x.
+// The filter further restricts the releases by state and release date.
+// Yanked releases are left out unless includeYanked is set or the filter asks for yanked releases.
+// A disabled package is reported as not found unless includeDisabled is set. A package that is
+// defined but has no releases yet lists no releases rather than being reported as not found.
+func (s *ReleaseService) ListReleasesForSoftware(softwareName string, sortField string, sortOrder string, versionPrefix string, filter ReleaseFilter, includeYanked bool, includeDisabled bool) ([]*ReleaseMetadata, error) {
+	if err := validateReleaseSort(sortField, sortOrder); err != nil {
+		return nil, err
+	}
+	if err := validateReleaseFilter(filter); err != nil {
+		return nil, err
+	}
+	softwareName = s.resolveSoftwareName(softwareName)
+	if !includeDisabled && s.isSoftwarePackageDisabled(softwareName) {
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
 	releases, err := s.releaseDB.ListReleasesMetadataForSoftware(softwareName)
+	if errors.Is(err, ErrNotFound) {
+		if _, getErr := s.packageDB.GetSoftwarePackage(softwareName); getErr == nil {
+			releases, err = make([]*ReleaseMetadata, 0), nil // Defined before its first release
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list releases for software %s: %w", softwareName, err)
 	}
+	if !includeYanked && filter.State != "yanked" {
+		releases = withoutYankedReleases(releases)
+	}
+	if versionPrefix != "" {
+		if releases, err = filterReleasesByVersionPrefix(releases, versionPrefix); err != nil {
+			return nil, err
+		}
+	}
+	releases = filterReleases(releases, filter)
 
-	// Sorting logic
 	sort.Slice(releases, func(i, j int) bool {
-		switch sortField {
-		case "version":
-			version1, _ := parseVersion(releases[i].Version)
-			version2, _ := parseVersion(releases[j].Version)
-			if sortOrder == "desc" {
-				return version1.GreaterThan(version2)
-			}
-			return version2.GreaterThan(version1) // Default "asc"
-		case "date":
-			if sortOrder == "desc" {
-				return releases[i].ReleaseDate.After(releases[j].ReleaseDate)
-			}
-			return releases[j].ReleaseDate.After(releases[i].ReleaseDate) // Default "asc"
-		default: // Default sort by version descending
-			version1, _ := parseVersion(releases[i].Version)
-			version2, _ := parseVersion(releases[j].Version)
-			return version1.GreaterThan(version2)
+		return releaseLess(releases[i], releases[j], sortField, sortOrder)
+	})
+	return releases, nil
+}
+
+// ListAllReleases retrieves the releases of all enabled packages, ordered by software name and then
+// by the same sort field and order as ListReleasesForSoftware, with versions ascending by default.
+// Yanked releases are left out unless includeYanked is set.
+func (s *ReleaseService) ListAllReleases(sortField string, sortOrder string, includeYanked bool) ([]*ReleaseMetadata, error) {
+	if err := validateReleaseSort(sortField, sortOrder); err != nil {
+		return nil, err
+	}
+	allReleases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all releases: %w", err)
+	}
+	disabled := make(map[string]bool)
+	packages, err := s.packageDB.ListSoftwarePackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list software packages: %w", err)
+	}
+	for _, software := range packages {
+		disabled[softwareKey(software.Name)] = !software.Enabled
+	}
+
+	releases := make([]*ReleaseMetadata, 0, len(allReleases))
+	for _, release := range allReleases {
+		if release.ReleaseState == "yanked" && !includeYanked {
+			continue
+		}
+		if !disabled[softwareKey(release.SoftwareName)] { // Disabled packages are hidden from public access
+			releases = append(releases, release)
+		}
+	}
+	if sortField == "" {
+		sortField = "version"
+	}
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].SoftwareName != releases[j].SoftwareName {
+			return releases[i].SoftwareName < releases[j].SoftwareName
 		}
+		return releaseLess(releases[i], releases[j], sortField, sortOrder)
 	})
 	return releases, nil
 }
 
-// GetLatestReleaseForSoftware retrieves the latest release for a specific software.
+// FindVersionGaps reports skipped patch numbers within each X.Y line and skipped minor numbers
+// within each X line, between the lowest and highest released numbers. Pre-releases are ignored.
+func (s *ReleaseService) FindVersionGaps(softwareName string) (*VersionGapsReport, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	releases, err := s.releaseDB.ListReleasesMetadataForSoftware(softwareName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases for software %s: %w", softwareName, err)
+	}
+
+	patchesByLine := make(map[[2]int][]int) // [major, minor] -> patches
+	minorsByMajor := make(map[int][]int)
+	for _, release := range releases {
+		version, err := parseVersion(release.Version)
+		if err != nil || len(version.PreRelease) > 0 {
+			continue
+		}
+		major, minor, patch := version.component(0), version.component(1), version.component(2)
+		line := [2]int{major, minor}
+		if _, seen := patchesByLine[line]; !seen {
+			minorsByMajor[major] = append(minorsByMajor[major], minor)
+		}
+		patchesByLine[line] = append(patchesByLine[line], patch)
+	}
+
+	gaps := make([]VersionGap, 0)
+	majors := slices.Sorted(maps.Keys(minorsByMajor))
+	for _, major := range majors {
+		minors := minorsByMajor[major]
+		slices.Sort(minors)
+		for i := 1; i < len(minors); i++ {
+			if minors[i]-minors[i-1] > 1 {
+				gaps = append(gaps, VersionGap{
+					Line:        fmt.Sprintf("%d", major),
+					Kind:        "minor",
+					MissingFrom: fmt.Sprintf("%d.%d", major, minors[i-1]+1),
+					MissingTo:   fmt.Sprintf("%d.%d", major, minors[i]-1),
+				})
+			}
+		}
+		for _, minor := range minors {
+			patches := slices.Compact(slices.Sorted(slices.Values(patchesByLine[[2]int{major, minor}]))) // Drop duplicates from build metadata
+			for i := 1; i < len(patches); i++ {
+				if patches[i]-patches[i-1] > 1 {
+					gaps = append(gaps, VersionGap{
+						Line:        fmt.Sprintf("%d.%d", major, minor),
+						Kind:        "patch",
+						MissingFrom: fmt.Sprintf("%d.%d.%d", major, minor, patches[i-1]+1),
+						MissingTo:   fmt.Sprintf("%d.%d.%d", major, minor, patches[i]-1),
+					})
+				}
+			}
+		}
+	}
+	return &VersionGapsReport{SoftwareName: softwareName, Gaps: gaps}, nil
+}
+
+// GetLatestReleaseForSoftware retrieves the latest release for a specific software,
+// honoring the package's configured latest strategy (highest version by default).
+// Only available releases are considered: yanked, unavailable and corrupt ones are skipped.
 func (s *ReleaseService) GetLatestReleaseForSoftware(softwareName string) (*ReleaseMetadata, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
 	releases, err := s.releaseDB.ListReleasesMetadataForSoftware(softwareName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get releases for software %s to find latest: %w", softwareName, err)
 	}
+	return s.selectLatestRelease(softwareName, releases)
+}
+
+// selectLatestRelease picks the latest of a package's releases according to its latest strategy.
+// Only available releases are considered. The releases slice is not modified.
+func (s *ReleaseService) selectLatestRelease(softwareName string, releases []*ReleaseMetadata) (*ReleaseMetadata, error) {
+	available := make([]*ReleaseMetadata, 0, len(releases))
+	for _, release := range releases {
+		if release.ReleaseState == "available" {
+			available = append(available, release)
+		}
+	}
+	releases = available
 
 	if len(releases) == 0 {
-		return nil, fmt.Errorf("no releases found for software: %s", softwareName)
+		return nil, fmt.Errorf("%w: no available releases for software %s", ErrNotFound, softwareName)
 	}
 
-	sort.Slice(releases, func(i, j int) bool { // Sort by version descending to get latest first
-		version1, _ := parseVersion(releases[i].Version)
-		version2, _ := parseVersion(releases[j].Version)
-		return version1.GreaterThan(version2)
-	})
+	settings := s.packageSettings(softwareName)
+	switch settings.LatestStrategy {
+	case LatestStrategyPinned:
+		for _, release := range releases {
+			if release.Version == settings.PinnedVersion {
+				return release, nil
+			}
+		}
+		return nil, fmt.Errorf("pinned version %s %w for software: %s", settings.PinnedVersion, ErrNotFound, softwareName)
+	case LatestStrategyMostRecent:
+		sort.Slice(releases, func(i, j int) bool { // Sort by upload time descending to get latest first
+			return releases[i].ReleaseTimestamp.After(releases[j].ReleaseTimestamp)
+		})
+	default: // LatestStrategyHighestVersion
+		sort.Slice(releases, func(i, j int) bool { // Sort by version descending to get latest first
+			version1, _ := parseVersion(releases[i].Version)
+			version2, _ := parseVersion(releases[j].Version)
+			return version1.Compare(version2) > 0
+		})
+	}
 	return releases[0], nil // The first element after sorting is the latest
 }
 
+// withoutYankedReleases returns the releases that have not been yanked, keeping their order.
+func withoutYankedReleases(releases []*ReleaseMetadata) []*ReleaseMetadata {
+	kept := make([]*ReleaseMetadata, 0, len(releases))
+	for _, release := range releases {
+		if release.ReleaseState != "yanked" {
+			kept = append(kept, release)
+		}
+	}
+	return kept
+}
+
+// SetReleaseState yanks a release, or makes a yanked release available again.
+// Yanked releases stay downloadable by exact version but are hidden from listings and "latest".
+// Releases that are unavailable or corrupt are left to reconciliation and cannot be changed here.
+func (s *ReleaseService) SetReleaseState(softwareName string, version string, state string) (*ReleaseMetadata, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if state != "yanked" && state != "available" {
+		return nil, fmt.Errorf("%w: %q, expected \"yanked\" or \"available\"", ErrInvalidReleaseState, state)
+	}
+	metadata, err := s.releaseDB.GetReleaseMetadata(softwareName, version)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.ReleaseState == state {
+		return metadata, nil
+	}
+	if metadata.ReleaseState != "yanked" && metadata.ReleaseState != "available" {
+		return nil, fmt.Errorf("%w: release %s %s is %s", ErrInvalidReleaseState, softwareName, version, metadata.ReleaseState)
+	}
+	updated := *metadata // The stored metadata may be shared with concurrent readers
+	updated.ReleaseState = state
+	if err := s.releaseDB.UpdateReleaseMetadata(&updated); err != nil {
+		return nil, fmt.Errorf("failed to update release state: %w", err)
+	}
+	return &updated, nil
+}
+
+// DescribeReleaseVersion computes how a release relates to the rest of its package's releases:
+// whether it is the latest, and how many releases carry a higher version. When every release of the
+// package is withdrawn there is no latest release, so the release is not the latest and LatestVersion is empty.
+func (s *ReleaseService) DescribeReleaseVersion(release *ReleaseMetadata) (*ReleaseVersionInfo, error) {
+	latestVersion := ""
+	latest, err := s.GetLatestReleaseForSoftware(release.SoftwareName)
+	if err == nil {
+		latestVersion = latest.Version
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	releases, err := s.releaseDB.ListReleasesMetadataForSoftware(release.SoftwareName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases for software %s: %w", release.SoftwareName, err)
+	}
+
+	version, _ := parseVersion(release.Version) // Named versions such as "nightly" rank below numbered ones
+	newerCount := 0
+	for _, other := range releases {
+		if otherVersion, err := parseVersion(other.Version); err == nil && otherVersion.GreaterThan(version) {
+			newerCount++
+		}
+	}
+	return &ReleaseVersionInfo{
+		ReleaseMetadata:    release,
+		IsLatest:           latestVersion != "" && release.Version == latestVersion,
+		NewerVersionsCount: newerCount,
+		LatestVersion:      latestVersion,
+	}, nil
+}
+
 // CreateSoftwarePackage creates a new software package definition.
 func (s *ReleaseService) CreateSoftwarePackage(software *SoftwarePackage) error {
-	// For now, software package details are stored in memory or could be in metadata DB in future.
-	// For now, only name is really used in metadata storage structure.
-	// Consider adding a separate SoftwarePackageDatabase if more details need persistence.
-	return nil // Placeholder for now, software packages are implicitly created with releases
+	software.Name = strings.TrimSpace(software.Name)
+	if err := ValidateSoftwareName(software.Name); err != nil {
+		return err
+	}
+	if existing := s.resolveSoftwareName(software.Name); existing != software.Name {
+		return fmt.Errorf("software package already exists: %s", existing) // Names are unique regardless of casing
+	}
+	return s.packageDB.CreateSoftwarePackage(software)
 }
 
 // UpdateSoftwarePackageDetails updates details of a software package (name is key, other details can be updated).
 func (s *ReleaseService) UpdateSoftwarePackageDetails(softwareName string, description string, category string) error {
-	// Placeholder - update software package details (description, category).
-	// Needs to be implemented if SoftwarePackage struct is persisted.
-	return nil
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Description = description
+		software.Category = category
+	})
 }
 
-// DeleteSoftwarePackage deletes a software package and all associated releases.
+// DeleteSoftwarePackage deletes a software package with all its release files and metadata.
+// Deletion continues past failing files; metadata is only removed for files that were deleted,
+// and the failures are reported together so reconciliation stays consistent with the disk.
 func (s *ReleaseService) DeleteSoftwarePackage(softwareName string) error {
-	// Placeholder - delete software package and releases.
-	// Needs to be implemented if SoftwarePackage struct is persisted and releases need cascading delete.
+	softwareName = s.resolveSoftwareName(softwareName)
+	_, getErr := s.packageDB.GetSoftwarePackage(softwareName)
+	releases, listErr := s.releaseDB.ListReleasesMetadataForSoftware(softwareName)
+	if getErr != nil && listErr != nil {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+
+	var failures []error
+	for _, release := range releases { // Cascade to the package's releases
+		if err := s.releaseDB.DeleteReleaseFile(s.config.RepositoryPath, release); err != nil {
+			failures = append(failures, fmt.Errorf("%s %s: %w", release.SoftwareName, release.Version, err))
+			continue
+		}
+		if err := s.releaseDB.DeleteReleaseMetadata(release.SoftwareName, release.Version); err != nil {
+			failures = append(failures, fmt.Errorf("%s %s: failed to delete release metadata: %w", release.SoftwareName, release.Version, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete %d release(s) of software package %s: %w", len(failures), softwareName, errors.Join(failures...))
+	}
+
+	if err := s.releaseDB.RemoveSoftwareDir(s.config.RepositoryPath, softwareName); err != nil {
+		return err
+	}
+	if getErr == nil {
+		if err := s.packageDB.DeleteSoftwarePackage(softwareName); err != nil {
+			return fmt.Errorf("failed to delete software package definition: %w", err)
+		}
+	}
 	return nil
 }
 
-// EnableDisableSoftwarePackage enables or disables a software package (and potentially its releases).
-func (s *ReleaseService) EnableDisableSoftwarePackage(softwareName string, enabled bool) error {
-	// Placeholder - enable/disable software package.
-	// Needs to be implemented if SoftwarePackage struct has an enabled status and impacts release visibility.
+// TransferSoftwarePackage reassigns ownership of a software package to newOwner.
+// The caller is responsible for checking that newOwner is an existing user.
+func (s *ReleaseService) TransferSoftwarePackage(softwareName string, newOwner string, actor string) error {
+	var previousOwner string
+	if err := s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		previousOwner = software.Owner
+		software.Owner = newOwner
+	}); err != nil {
+		return err
+	}
+	s.logger.Info("AUDIT: package ownership transferred", "actor", actor, "software_name", s.resolveSoftwareName(softwareName), "previous_owner", previousOwner, "new_owner", newOwner)
 	return nil
 }
 
-// UploadRelease handles the upload of a new software release.
-func (s *ReleaseService) UploadRelease(tgzFilePath string, metadata ReleaseMetadata) error {
-	metadata.ReleaseTimestamp = time.Now() // Set upload timestamp
-	destFilePath, err := s.releaseDB.StoreReleaseFile(s.config.RepositoryPath, tgzFilePath, &metadata)
+// ListOwnedSoftwarePackages returns the names of the packages owned by a user, sorted by name.
+func (s *ReleaseService) ListOwnedSoftwarePackages(username string) ([]string, error) {
+	packages, err := s.packageDB.ListSoftwarePackages()
 	if err != nil {
-		return fmt.Errorf("failed to store release file: %w", err)
+		return nil, fmt.Errorf("failed to list software packages: %w", err)
+	}
+	owned := make([]string, 0)
+	for _, software := range packages {
+		if software.Owner == username {
+			owned = append(owned, software.Name)
+		}
+	}
+	sort.Strings(owned)
+	return owned, nil
+}
+
+// CanPublishRelease reports whether username may publish releases of a software package.
+// Unowned packages are open to every API key holder; owned packages only to their owner.
+func (s *ReleaseService) CanPublishRelease(softwareName string, username string) bool {
+	software, err := s.packageDB.GetSoftwarePackage(s.resolveSoftwareName(softwareName))
+	if err != nil || software.Owner == "" {
+		return true
+	}
+	return software.Owner == username
+}
+
+// EnableDisableSoftwarePackage enables or disables a software package.
+func (s *ReleaseService) EnableDisableSoftwarePackage(softwareName string, enabled bool) error {
+	return s.updateSoftwarePackage(softwareName, func(software *SoftwarePackage) {
+		software.Enabled = enabled
+	})
+}
+
+// UploadRelease handles the upload of a new software release with an optional SBOM document.
+// The TGZ file is streamed from tgz straight into the repository; expectedSize is the number of
+// bytes it is expected to take, used for the free-space check.
+// A signature must already have been checked with VerifyReleaseSignature, as it covers the uploaded
+// file rather than the TGZ archive built from it.
+// The file, SBOM, signature and metadata are written as one transaction: any failure removes what was stored.
+// Once committed, the configured webhooks are notified.
+// The software name is matched against existing packages regardless of casing, keeping the stored name.
+// An existing version is only replaced if it is mutable or overwrite is set; its files are removed
+// once the replacement has been stored.
+func (s *ReleaseService) UploadRelease(tgz io.Reader, expectedSize int64, metadata ReleaseMetadata, sbom []byte, signature []byte, overwrite bool) error {
+	if err := ValidateSoftwareName(metadata.SoftwareName); err != nil {
+		return err
+	}
+	metadata.SoftwareName = s.resolveSoftwareName(metadata.SoftwareName) // Releases of "MyApp" and "myapp" share one package
+	if err := s.ValidateReleaseVersion(metadata.SoftwareName, metadata.Version); err != nil {
+		return err
+	}
+	if len(sbom) > 0 {
+		format, err := DetectSBOMFormat(sbom)
+		if err != nil {
+			return fmt.Errorf("invalid sbom: %w", err)
+		}
+		metadata.HasSBOM = true
+		metadata.SBOMFormat = format
+	}
+	if len(metadata.ChangelogEntries) > 0 {
+		if err := ValidateChangelogEntries(metadata.ChangelogEntries); err != nil {
+			return fmt.Errorf("invalid changelog entries: %w", err)
+		}
+		if metadata.Changelog == "" { // Keep a text rendering so text consumers and search still see it
+			metadata.Changelog, _ = RenderChangelog(metadata.ChangelogEntries, ChangelogFormatText)
+		}
+	}
+	if err := s.EnsureStorageAvailable(expectedSize); err != nil {
+		return err
+	}
+	existing, err := s.releaseDB.GetReleaseMetadata(metadata.SoftwareName, metadata.Version)
+	replacing := err == nil
+	if replacing && !overwrite && !s.isMutableVersion(metadata.SoftwareName, metadata.Version) {
+		// Checked before touching the file system so the existing release file is never overwritten.
+		return fmt.Errorf("%w for software %s: %s", ErrReleaseExists, metadata.SoftwareName, metadata.Version)
+	}
+	var replaced *ReleaseMetadata
+	if replacing {
+		replaced = existing
+	}
+	// Rejects uploads over quota before their file is copied; checked again with the stored sizes below
+	if err := s.checkStorageQuotas(metadata.SoftwareName, expectedSize+int64(len(sbom)+len(signature)), replaced); err != nil {
+		return err
 	}
 
-	fileInfo, err := os.Stat(destFilePath)
+	tx := NewTransaction()
+	var backups []string
+	if replacing {
+		metadata.ID = existing.ID // A replaced release keeps its identity and download count
+		metadata.DownloadCount = existing.DownloadCount
+		backups, err = s.moveAsideReleaseFiles(existing, tx)
+		if err != nil {
+			return tx.Rollback(err)
+		}
+	} else {
+		metadata.ID = uuid.New().String()
+	}
+	metadata.ReleaseTimestamp = time.Now() // Set upload timestamp
+	stored, err := s.releaseDB.StoreReleaseFile(s.config.RepositoryPath, tgz, &metadata, s.config.ChecksumAlgorithms)
 	if err != nil {
-		return fmt.Errorf("failed to get file size after storing release: %w", err)
+		return tx.Rollback(fmt.Errorf("failed to store release file: %w", err))
+	}
+	tx.OnRollback(func() error { return removeIfExists(stored.Path) })
+
+	if metadata.HasSBOM {
+		sbomFilePath := s.releaseDB.GetReleaseSBOMFilePath(s.config.RepositoryPath, &metadata)
+		tx.OnRollback(func() error { return removeIfExists(sbomFilePath) }) // Registered first: a failed write may leave a partial file
+		if err := s.releaseDB.StoreReleaseSBOM(s.config.RepositoryPath, &metadata, sbom); err != nil {
+			return tx.Rollback(fmt.Errorf("failed to store release sbom: %w", err))
+		}
+		metadata.SBOMSize = int64(len(sbom))
+	}
+
+	if len(signature) > 0 {
+		signatureFilePath := s.releaseDB.GetReleaseSignatureFilePath(s.config.RepositoryPath, &metadata)
+		tx.OnRollback(func() error { return removeIfExists(signatureFilePath) })
+		if err := s.releaseDB.StoreReleaseSignature(s.config.RepositoryPath, &metadata, signature); err != nil {
+			return tx.Rollback(fmt.Errorf("failed to store release signature: %w", err))
+		}
+		metadata.HasSignature = true
+		metadata.SignatureSize = int64(len(signature))
 	}
-	metadata.FileSize = fileInfo.Size()
+
+	metadata.FileSize = stored.Size
 	metadata.ReleaseState = "available" // Mark as available after successful upload
+	metadata.ChecksumAlgorithm = s.config.ChecksumAlgorithms[0]
+	metadata.Checksum = stored.Digests[metadata.ChecksumAlgorithm]
+	metadata.Digests = stored.Digests
+
+	if err := s.storeUploadedReleaseMetadata(&metadata, replaced); err != nil {
+		return tx.Rollback(err)
+	}
+	tx.Commit()
+	for _, backup := range backups {
+		if err := removeIfExists(backup); err != nil {
+			s.logger.Warn("Failed to remove replaced release file", "software_name", metadata.SoftwareName, "version", metadata.Version, "path", backup, "error", err)
+		}
+	}
+	s.webhooks.NotifyRelease(&metadata) // Delivered in the background, the upload does not wait for it
+	return nil
+}
+
+// storeUploadedReleaseMetadata checks the storage quotas with the stored sizes of an upload and stores
+// its metadata, replacing the metadata of replaced if set. Both happen under s.quotas, so concurrent
+// uploads cannot each pass the check and together exceed a quota.
+func (s *ReleaseService) storeUploadedReleaseMetadata(metadata *ReleaseMetadata, replaced *ReleaseMetadata) error {
+	s.quotas.Lock()
+	defer s.quotas.Unlock()
+	if err := s.checkStorageQuotas(metadata.SoftwareName, metadata.StoredSize(), replaced); err != nil {
+		return err
+	}
+	if replaced != nil {
+		if err := s.releaseDB.UpdateReleaseMetadata(metadata); err != nil {
+			return fmt.Errorf("failed to update release metadata: %w", err)
+		}
+		return nil
+	}
+	if err := s.releaseDB.CreateReleaseMetadata(metadata); err != nil {
+		return fmt.Errorf("failed to create release metadata: %w", err)
+	}
+	return nil
+}
+
+// moveAsideReleaseFiles renames the files of a release that is about to be replaced so a failed
+// replacement can restore them. It returns the moved-aside paths, to be removed once the replacement commits.
+func (s *ReleaseService) moveAsideReleaseFiles(release *ReleaseMetadata, tx *Transaction) ([]string, error) {
+	var backups []string
+	for _, filePath := range []string{
+		s.releaseDB.GetReleaseFilePath(s.config.RepositoryPath, release),
+		s.releaseDB.GetReleaseSBOMFilePath(s.config.RepositoryPath, release),
+		s.releaseDB.GetReleaseSignatureFilePath(s.config.RepositoryPath, release),
+	} {
+		backup := filePath + ".replaced"
+		if err := os.Rename(filePath, backup); err != nil {
+			if os.IsNotExist(err) {
+				continue // No SBOM or signature attached, or the file is already missing
+			}
+			return backups, fmt.Errorf("failed to move aside release file: %w", err)
+		}
+		tx.OnRollback(func() error { return os.Rename(backup, filePath) })
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// VerifyReleaseSignature checks the minisign signature of an uploaded release file against the
+// configured public key. A missing signature is accepted unless signed releases are required.
+func (s *ReleaseService) VerifyReleaseSignature(file io.Reader, signature []byte) error {
+	if len(signature) == 0 {
+		if s.config.RequireSignedReleases {
+			return fmt.Errorf("%w: a signature is required", ErrInvalidSignature)
+		}
+		return nil
+	}
+	if s.config.SignaturePublicKey == "" {
+		return fmt.Errorf("%w: signature verification is not configured", ErrInvalidSignature)
+	}
+	publicKey, err := ParseMinisignPublicKey(s.config.SignaturePublicKey) // Validated at startup
+	if err != nil {
+		return err
+	}
+	return publicKey.Verify(file, signature)
+}
 
-	if err := s.releaseDB.CreateReleaseMetadata(&metadata); err != nil {
-		// Rollback: delete the file if metadata creation fails (consider more robust transaction).
-		os.Remove(destFilePath)
-		return fmt.Errorf("failed to create release metadata and rollback file storage: %w", err)
+// ValidateReleaseVersion checks that a version can be uploaded: it must parse as SemVer with the configured
+// number of numeric components (X.Y.Z by default) unless it matches one of the package's mutable version patterns.
+// Versions that are unsafe in file names are rejected either way.
+func (s *ReleaseService) ValidateReleaseVersion(softwareName string, version string) error {
+	if err := validatePathSegment(version); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidVersion, err)
+	}
+	if s.isMutableVersion(softwareName, version) {
+		return nil
+	}
+	parsed, err := parseVersion(version)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidVersion, err)
+	}
+	if want := s.config.VersionComponents; want != 0 && len(parsed.Components) != want {
+		return fmt.Errorf("%w: %s has %d numeric components, expected %d", ErrInvalidVersion, version, len(parsed.Components), want)
 	}
 	return nil
 }
 
-// GetReleaseFilePath returns the file path for a specific release.
-func (s *ReleaseService) GetReleaseFilePath(softwareName string, version string) (string, error) {
+// packageSettings returns the configured overrides for a package, matching the software name regardless of casing.
+// Settings keyed by the exact name take precedence.
+func (s *ReleaseService) packageSettings(softwareName string) PackageSettings {
+	if settings, ok := s.config.PackageSettings[softwareName]; ok {
+		return settings
+	}
+	for name, settings := range s.config.PackageSettings {
+		if strings.EqualFold(name, softwareName) {
+			return settings
+		}
+	}
+	return PackageSettings{}
+}
+
+// isMutableVersion reports whether a version matches one of the package's mutable version patterns.
+func (s *ReleaseService) isMutableVersion(softwareName string, version string) bool {
+	for _, pattern := range s.packageSettings(softwareName).MutableVersions {
+		if matched, _ := path.Match(pattern, version); matched { // Patterns are validated at startup
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteRelease deletes a single release's metadata and files as one transaction.
+// Metadata is removed first and restored if the files cannot be deleted.
+func (s *ReleaseService) DeleteRelease(softwareName string, version string) error {
+	softwareName = s.resolveSoftwareName(softwareName)
 	metadata, err := s.releaseDB.GetReleaseMetadata(softwareName, version)
+	if err != nil {
+		return err
+	}
+	snapshot := *metadata // Copy used to restore the metadata on rollback
+
+	tx := NewTransaction()
+	if err := s.releaseDB.DeleteReleaseMetadata(softwareName, version); err != nil {
+		return fmt.Errorf("failed to delete release metadata: %w", err)
+	}
+	tx.OnRollback(func() error { return s.releaseDB.CreateReleaseMetadata(&snapshot) })
+
+	if err := s.releaseDB.DeleteReleaseFile(s.config.RepositoryPath, &snapshot); err != nil {
+		return tx.Rollback(fmt.Errorf("failed to delete release file: %w", err))
+	}
+	tx.Commit()
+	if err := s.removeTagsOfVersion(softwareName, version); err != nil { // The release is gone either way
+		s.logger.Warn("Failed to remove tags of deleted release", "software_name", softwareName, "version", version, "error", err)
+	}
+	return nil
+}
+
+// GetRelease retrieves the metadata of a specific release. The version may also be a dist-tag of the package.
+func (s *ReleaseService) GetRelease(softwareName string, version string) (*ReleaseMetadata, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	return s.getReleaseByVersionOrTag(softwareName, version)
+}
+
+// GetReleaseByID retrieves a release's metadata by its unique ID.
+func (s *ReleaseService) GetReleaseByID(id string) (*ReleaseMetadata, error) {
+	release, err := s.releaseDB.GetReleaseByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.isSoftwarePackageDisabled(release.SoftwareName) { // Disabled packages are hidden from public access
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, release.SoftwareName)
+	}
+	return release, nil
+}
+
+// GetReleaseFilePath returns the metadata and the file path of a specific release.
+// The version may also be a dist-tag; the returned metadata then names the tagged version.
+// The file is not hashed here: its checksum is computed while it is uploaded, and reconciliation marks
+// releases whose file no longer matches as corrupt, which stops them being served. Only the size is
+// checked, which catches truncated files without reading them.
+func (s *ReleaseService) GetReleaseFilePath(softwareName string, version string) (*ReleaseMetadata, string, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return nil, "", fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	metadata, err := s.getReleaseByVersionOrTag(softwareName, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if metadata.ReleaseState != "available" && metadata.ReleaseState != "yanked" { // Yanked releases stay downloadable by exact version
+		return nil, "", fmt.Errorf("release is not available: %s %s", softwareName, version)
+	}
+	filePath := s.releaseDB.GetReleaseFilePath(s.config.RepositoryPath, metadata)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open release file: %w", err)
+	}
+	if info.Size() != metadata.FileSize {
+		s.logger.Error("Release file size mismatch", "software_name", softwareName, "version", version, "expected", metadata.FileSize, "actual", info.Size())
+		return nil, "", fmt.Errorf("release file size mismatch: %s %s", softwareName, version)
+	}
+	return metadata, filePath, nil
+}
+
+// RecordDownload counts a completed download of a release. Counts are written in batches in the
+// background so the download is not held up; FlushDownloadCounts writes pending counts right away.
+func (s *ReleaseService) RecordDownload(release *ReleaseMetadata) {
+	s.downloads.Record(release.SoftwareName, release.Version)
+}
+
+// FlushDownloadCounts writes all download counts recorded so far.
+func (s *ReleaseService) FlushDownloadCounts() {
+	s.downloads.Flush()
+}
+
+// OpenReleaseFile opens the TGZ file of a release for reading. The reader is seekable so downloads
+// can be served in byte ranges whatever the storage behind it.
+func (s *ReleaseService) OpenReleaseFile(release *ReleaseMetadata) (io.ReadSeekCloser, error) {
+	return s.releaseDB.GetReleaseTGZReader(s.config.RepositoryPath, release)
+}
+
+// GetReleaseSignatureFilePath returns the file path of the signature attached to a specific release.
+func (s *ReleaseService) GetReleaseSignatureFilePath(softwareName string, version string) (string, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return "", fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	metadata, err := s.getReleaseByVersionOrTag(softwareName, version)
 	if err != nil {
 		return "", err
 	}
-	if metadata.ReleaseState != "available" {
-		return "", fmt.Errorf("release is not available: %s %s", softwareName, version)
+	if !metadata.HasSignature {
+		return "", fmt.Errorf("no signature attached to release: %s %s", softwareName, version)
 	}
-	reader, err := s.releaseDB.GetReleaseTGZReader(s.config.RepositoryPath, metadata)
+	return s.releaseDB.GetReleaseSignatureFilePath(s.config.RepositoryPath, metadata), nil
+}
+
+// GetReleaseSBOMFilePath returns the file path of the SBOM attached to a specific release.
+func (s *ReleaseService) GetReleaseSBOMFilePath(softwareName string, version string) (string, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return "", fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	metadata, err := s.getReleaseByVersionOrTag(softwareName, version)
 	if err != nil {
 		return "", err
 	}
-	defer reader.Close() // Close reader after getting path (reader itself not directly used here)
+	if !metadata.HasSBOM {
+		return "", fmt.Errorf("no sbom attached to release: %s %s", softwareName, version)
+	}
+	return s.releaseDB.GetReleaseSBOMFilePath(s.config.RepositoryPath, metadata), nil
+}
 
-	return s.releaseDB.GetReleaseFilePath(s.config.RepositoryPath, metadata), nil // Return path from DB logic
+// RenderReleaseChangelog renders a release's changelog in the given format. Structured entries
+// are preferred; releases with only a free-text changelog return the text as-is (escaped for HTML).
+func (s *ReleaseService) RenderReleaseChangelog(softwareName string, version string, format string) (string, error) {
+	softwareName = s.resolveSoftwareName(softwareName)
+	if s.isSoftwarePackageDisabled(softwareName) { // Disabled packages are hidden from public access
+		return "", fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	metadata, err := s.getReleaseByVersionOrTag(softwareName, version)
+	if err != nil {
+		return "", err
+	}
+	if len(metadata.ChangelogEntries) > 0 {
+		return RenderChangelog(metadata.ChangelogEntries, format)
+	}
+	switch format {
+	case ChangelogFormatText, ChangelogFormatMarkdown:
+		return metadata.Changelog, nil
+	case ChangelogFormatHTML:
+		return "<p>" + html.EscapeString(metadata.Changelog) + "</p>\n", nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedChangelogFormat, format)
+}
+
+// CheckReleaseRetrieval verifies the download path end-to-end by reading the first bytes of a
+// canary release: the configured one, or the most recently uploaded release if none is configured.
+// An empty repository with no canary configured has nothing to verify and is considered healthy.
+func (s *ReleaseService) CheckReleaseRetrieval() error {
+	var canary *ReleaseMetadata
+	if s.config.HealthCanarySoftware != "" {
+		metadata, err := s.releaseDB.GetReleaseMetadata(s.config.HealthCanarySoftware, s.config.HealthCanaryVersion)
+		if err != nil {
+			return fmt.Errorf("canary release not found: %w", err)
+		}
+		canary = metadata
+	} else {
+		releases, err := s.releaseDB.ListAllReleasesMetadata()
+		if err != nil {
+			return fmt.Errorf("failed to list releases to pick a canary: %w", err)
+		}
+		for _, release := range releases {
+			if release.ReleaseState == "available" && (canary == nil || release.ReleaseTimestamp.After(canary.ReleaseTimestamp)) {
+				canary = release
+			}
+		}
+		if canary == nil {
+			return nil
+		}
+	}
+
+	reader, err := s.releaseDB.GetReleaseTGZReader(s.config.RepositoryPath, canary)
+	if err != nil {
+		return fmt.Errorf("failed to open canary release %s %s: %w", canary.SoftwareName, canary.Version, err)
+	}
+	defer reader.Close()
+
+	buf := make([]byte, 512)
+	if _, err := io.ReadAtLeast(reader, buf, 1); err != nil {
+		return fmt.Errorf("failed to read canary release %s %s: %w", canary.SoftwareName, canary.Version, err)
+	}
+	return nil
+}
+
+// ExportCatalog returns the catalog of all releases, sorted by software name and version.
+func (s *ReleaseService) ExportCatalog() ([]CatalogEntry, error) {
+	releases, err := s.releaseDB.ListAllReleasesMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all releases for catalog export: %w", err)
+	}
+
+	catalog := make([]CatalogEntry, 0, len(releases))
+	for _, release := range releases {
+		catalog = append(catalog, CatalogEntry{
+			SoftwareName:      release.SoftwareName,
+			Version:           release.Version,
+			Checksum:          release.Checksum,
+			ChecksumAlgorithm: release.PrimaryChecksumAlgorithm(),
+		})
+	}
+	sortCatalog(catalog)
+	return catalog, nil
+}
+
+// DiffCatalog compares the local catalog with another instance's exported catalog.
+// Releases are matched by software name, regardless of casing, and version. Their checksums are only
+// compared when both were computed with the same algorithm: a release whose checksum differs is reported
+// on both sides, one whose algorithms differ is reported as not comparable.
+func (s *ReleaseService) DiffCatalog(remote []CatalogEntry) (*CatalogDiffResponse, error) {
+	local, err := s.ExportCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	type catalogKey struct{ softwareName, version string }
+	keyOf := func(entry CatalogEntry) catalogKey { return catalogKey{softwareKey(entry.SoftwareName), entry.Version} }
+	remoteByRelease := make(map[catalogKey]CatalogEntry, len(remote))
+	for _, entry := range remote {
+		remoteByRelease[keyOf(entry)] = entry
+	}
+
+	diff := &CatalogDiffResponse{
+		OnlyLocal:     make([]CatalogEntry, 0),
+		OnlyRemote:    make([]CatalogEntry, 0),
+		NotComparable: make([]CatalogComparison, 0),
+	}
+	for _, entry := range local {
+		remoteEntry, ok := remoteByRelease[keyOf(entry)]
+		switch {
+		case !ok:
+			diff.OnlyLocal = append(diff.OnlyLocal, entry)
+		case entry.PrimaryChecksumAlgorithm() != remoteEntry.PrimaryChecksumAlgorithm():
+			diff.NotComparable = append(diff.NotComparable, CatalogComparison{Local: entry, Remote: remoteEntry})
+		case entry.Checksum != remoteEntry.Checksum:
+			diff.OnlyLocal = append(diff.OnlyLocal, entry)
+			diff.OnlyRemote = append(diff.OnlyRemote, remoteEntry)
+		}
+		delete(remoteByRelease, keyOf(entry)) // Remaining entries are only in the remote catalog
+	}
+	for _, entry := range remoteByRelease {
+		diff.OnlyRemote = append(diff.OnlyRemote, entry)
+	}
+	sortCatalog(diff.OnlyLocal)
+	sortCatalog(diff.OnlyRemote)
+	return diff, nil
 }
 
 // ReconcileReleases performs reconciliation of the release database with the file system.
-func (s *ReleaseService) ReconcileReleases() error {
-	return s.releaseDB.ReconcileReleases(s.config.RepositoryPath)
+// Only one reconciliation runs at a time; overlapping calls fail with ErrReconcileInProgress.
+func (s *ReleaseService) ReconcileReleases() (*ReconcileResult, error) {
+	if !s.reconcile.TryLock() {
+		return nil, ErrReconcileInProgress
+	}
+	defer s.reconcile.Unlock()
+	return s.releaseDB.ReconcileReleases(s.config.RepositoryPath, s.config.ReconcileConcurrency)
 }
 
 // --- Helper functions ---
 
+// resolveSoftwareName maps a requested software name to the name the package is stored under, ignoring
+// case and surrounding whitespace. The databases look names up by softwareKey, and the stored name keeps
+// the casing the package was first created with for display. If nothing matches, the trimmed name is returned.
+func (s *ReleaseService) resolveSoftwareName(softwareName string) string {
+	softwareName = strings.TrimSpace(softwareName)
+	if software, err := s.packageDB.GetSoftwarePackage(softwareName); err == nil {
+		return software.Name
+	}
+	if releases, err := s.releaseDB.ListReleasesMetadataForSoftware(softwareName); err == nil && len(releases) > 0 {
+		return releases[0].SoftwareName
+	}
+	return softwareName
+}
+
+// releaseLess orders two releases by sortField ("version" or "date") and sortOrder ("asc" or "desc").
+// Without a sort field, releases are ordered by version, descending unless sortOrder is "asc".
+// The order is total, so "asc" and "desc" are exact inverses of each other.
+func releaseLess(a *ReleaseMetadata, b *ReleaseMetadata, sortField string, sortOrder string) bool {
+	descending := sortOrder == "desc" || (sortField == "" && sortOrder == "")
+	if descending {
+		return compareReleases(a, b, sortField) > 0
+	}
+	return compareReleases(a, b, sortField) < 0
+}
+
+// compareReleases compares two releases by sortField ("date", or version otherwise) and returns -1, 0 or 1.
+// Releases that tie, e.g. versions differing only in build metadata or undated releases, are ordered
+// by upload time and then by version string, so within a package only a release compares equal to itself.
+func compareReleases(a *ReleaseMetadata, b *ReleaseMetadata, sortField string) int {
+	var result int
+	if sortField == "date" {
+		result = a.ReleaseDate.Compare(b.ReleaseDate)
+	} else {
+		version1, _ := parseVersion(a.Version) // Named versions such as "nightly" rank below numbered ones
+		version2, _ := parseVersion(b.Version)
+		result = version1.Compare(version2)
+	}
+	if result == 0 {
+		result = a.ReleaseTimestamp.Compare(b.ReleaseTimestamp)
+	}
+	if result == 0 {
+		result = strings.Compare(a.Version, b.Version)
+	}
+	return result
+}
+
+// ValidateSoftwareName checks that a software name is non-empty and safe to use in repository paths.
+func ValidateSoftwareName(softwareName string) error {
+	if strings.TrimSpace(softwareName) == "" {
+		return fmt.Errorf("%w: software name is required", ErrInvalidSoftwareName)
+	}
+	if err := validatePathSegment(softwareName); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSoftwareName, err)
+	}
+	return nil
+}
+
+// validatePathSegment rejects values that could escape their directory once used in a file name:
+// values containing a path separator, ".." or a control character.
+func validatePathSegment(value string) error {
+	switch {
+	case strings.ContainsAny(value, `/\`):
+		return fmt.Errorf("%q must not contain path separators", value)
+	case strings.Contains(value, ".."):
+		return fmt.Errorf("%q must not contain \"..\"", value)
+	case strings.ContainsFunc(value, unicode.IsControl):
+		return fmt.Errorf("%q must not contain control characters", value)
+	}
+	return nil
+}
+
+// validateReleaseSort checks that a sort field and order are empty or among the accepted values.
+func validateReleaseSort(sortField string, sortOrder string) error {
+	if sortField != "" && !slices.Contains(releaseSortFields, sortField) {
+		return fmt.Errorf("%w: unknown sort field %q, expected one of %v", ErrInvalidSort, sortField, releaseSortFields)
+	}
+	if sortOrder != "" && !slices.Contains(releaseSortOrders, sortOrder) {
+		return fmt.Errorf("%w: unknown order %q, expected one of %v", ErrInvalidSort, sortOrder, releaseSortOrders)
+	}
+	return nil
+}
+
+// validateReleaseFilter checks that a filter names a known state and a non-empty date window.
+func validateReleaseFilter(filter ReleaseFilter) error {
+	if filter.State != "" && !slices.Contains(releaseStates, filter.State) {
+		return fmt.Errorf("%w: unknown state %q, expected one of %v", ErrInvalidReleaseFilter, filter.State, releaseStates)
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return fmt.Errorf("%w: until %s is before since %s", ErrInvalidReleaseFilter, filter.Until.Format(time.RFC3339), filter.Since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// filterReleases keeps the releases matching a filter.
+func filterReleases(releases []*ReleaseMetadata, filter ReleaseFilter) []*ReleaseMetadata {
+	if filter == (ReleaseFilter{}) {
+		return releases
+	}
+	dated := !filter.Since.IsZero() || !filter.Until.IsZero()
+	kept := make([]*ReleaseMetadata, 0, len(releases))
+	for _, release := range releases {
+		switch {
+		case filter.State != "" && release.ReleaseState != filter.State:
+		case dated && release.ReleaseDate.IsZero():
+		case !filter.Since.IsZero() && release.ReleaseDate.Before(filter.Since):
+		case !filter.Until.IsZero() && release.ReleaseDate.After(filter.Until):
+		default:
+			kept = append(kept, release)
+		}
+	}
+	return kept
+}
+
+// filterReleasesByVersionPrefix keeps releases whose parsed major/minor/patch components match the prefix.
+func filterReleasesByVersionPrefix(releases []*ReleaseMetadata, versionPrefix string) ([]*ReleaseMetadata, error) {
+	parts := strings.Split(versionPrefix, ".")
+	if len(parts) > maxVersionComponents {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersionPrefix, versionPrefix)
+	}
+	prefix := make([]int, len(parts))
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVersionPrefix, versionPrefix)
+		}
+		prefix[i] = number
+	}
+
+	filtered := make([]*ReleaseMetadata, 0, len(releases))
+	for _, release := range releases {
+		version, err := parseVersion(release.Version)
+		if err != nil {
+			continue
+		}
+		if len(version.Components) >= len(prefix) && slices.Equal(version.Components[:len(prefix)], prefix) {
+			filtered = append(filtered, release)
+		}
+	}
+	return filtered, nil
+}
+
+// isSoftwarePackageDisabled reports whether a package has a definition with its enabled flag cleared.
+func (s *ReleaseService) isSoftwarePackageDisabled(softwareName string) bool {
+	software, err := s.packageDB.GetSoftwarePackage(softwareName)
+	return err == nil && !software.Enabled
+}
+
+// removeIfExists removes a file, treating an already missing file as success.
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sortCatalog sorts catalog entries by software name, then version ascending.
+func sortCatalog(catalog []CatalogEntry) {
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].SoftwareName != catalog[j].SoftwareName {
+			return catalog[i].SoftwareName < catalog[j].SoftwareName
+		}
+		version1, _ := parseVersion(catalog[i].Version)
+		version2, _ := parseVersion(catalog[j].Version)
+		return version1.Compare(version2) < 0
+	})
+}
+
+// maxVersionComponents is the largest number of numeric components a version can have.
+const maxVersionComponents = 6
+
 // version type and parsing/comparison logic (can be moved to a separate util package if needed).
+// Versions follow SemVer 2.0: MAJOR.MINOR.PATCH with optional -PRERELEASE and +BUILD suffixes.
+// Schemes with a different number of numeric components, e.g. X.Y.Z.BUILD, are parsed as well;
+// Config.VersionComponents decides which ones can be uploaded.
 type Version struct {
-	Major    int
-	Minor    int
-	Patch    int
-	Original string // Store original string for representation
+	Components []int    // Numeric components, e.g. [1 2 3] for "1.2.3" or [1 2 3 4] for "1.2.3.4"
+	PreRelease []string // Dot-separated pre-release identifiers, empty for a normal release
+	Build      string   // Build metadata, ignored for precedence
+	Original   string   // Store original string for representation
 }
 
 func parseVersion(versionStr string) (Version, error) {
-	parts := strings.SplitN(versionStr, ".", 3)
-	if len(parts) != 3 {
-		return Version{}, fmt.Errorf("invalid version format: %s, expected X.Y.Z", versionStr)
+	core, build, hasBuild := strings.Cut(versionStr, "+")
+	if hasBuild && !isValidSemVerIdentifiers(build, false) {
+		return Version{}, fmt.Errorf("invalid build metadata in version: %s", versionStr)
+	}
+	core, preRelease, hasPreRelease := strings.Cut(core, "-")
+	if hasPreRelease && !isValidSemVerIdentifiers(preRelease, true) {
+		return Version{}, fmt.Errorf("invalid pre-release in version: %s", versionStr)
 	}
 
-	major, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return Version{}, fmt.Errorf("invalid major version: %w", err)
+	parts := strings.Split(core, ".")
+	if len(parts) > maxVersionComponents {
+		return Version{}, fmt.Errorf("invalid version format: %s, expected at most %d numeric components", versionStr, maxVersionComponents)
 	}
-	minor, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return Version{}, fmt.Errorf("invalid minor version: %w", err)
+	components := make([]int, len(parts))
+	for i, part := range parts {
+		if !isNumericIdentifier(part) {
+			return Version{}, fmt.Errorf("invalid version format: %s, expected numeric components such as X.Y.Z", versionStr)
+		}
+		if len(part) > 1 && part[0] == '0' { // SemVer §2, otherwise "1.02.3" and "1.2.3" would share a file name
+			return Version{}, fmt.Errorf("invalid version format: %s, numeric components must not have leading zeros", versionStr)
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version component %s: %w", part, err)
+		}
+		components[i] = number
 	}
-	patch, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return Version{}, fmt.Errorf("invalid patch version: %w", err)
+
+	version := Version{Components: components, Build: build, Original: versionStr}
+	if hasPreRelease {
+		version.PreRelease = strings.Split(preRelease, ".")
 	}
+	return version, nil
+}
 
-	return Version{Major: major, Minor: minor, Patch: patch, Original: versionStr}, nil
+// component returns the i-th numeric component of a version, 0 when the version has fewer components.
+func (v Version) component(i int) int {
+	if i < len(v.Components) {
+		return v.Components[i]
+	}
+	return 0
 }
 
-// GreaterThan compares two versions.
-func (v Version) GreaterThan(other Version) bool {
-	if v.Major > other.Major {
-		return true
+// isValidSemVerIdentifiers checks dot-separated identifiers: non-empty, [0-9A-Za-z-] only and,
+// for pre-release identifiers, no leading zeros on numeric identifiers.
+func isValidSemVerIdentifiers(identifiers string, preRelease bool) bool {
+	for _, identifier := range strings.Split(identifiers, ".") {
+		if identifier == "" {
+			return false
+		}
+		for _, c := range identifier {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if preRelease && isNumericIdentifier(identifier) && len(identifier) > 1 && identifier[0] == '0' {
+			return false
+		}
 	}
-	if v.Major < other.Major {
-		return false
+	return true
+}
+
+// isNumericIdentifier reports whether a SemVer identifier consists only of digits.
+func isNumericIdentifier(identifier string) bool {
+	for _, c := range identifier {
+		if c < '0' || c > '9' {
+			return false
+		}
 	}
-	// Majors are equal, compare minors
-	if v.Minor > other.Minor {
-		return true
+	return identifier != ""
+}
+
+// Compare compares two versions using SemVer precedence and returns -1, 0 or 1 when v is lower than,
+// equal to or higher than other. Numeric components are compared in order, so "1.2.3.10" is higher
+// than "1.2.3.9" and "1.2.3" equals "1.2.3.0". Build metadata is ignored, so "1.0.0+a" and "1.0.0+b" compare equal.
+func (v Version) Compare(other Version) int {
+	for i := 0; i < max(len(v.Components), len(other.Components)); i++ { // Missing components count as 0
+		if result := cmp.Compare(v.component(i), other.component(i)); result != 0 {
+			return result
+		}
 	}
-	if v.Minor < other.Minor {
-		return false
+	// Cores equal, a normal release has higher precedence than any of its pre-releases
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+// Equal reports whether two versions have the same precedence.
+func (v Version) Equal(other Version) bool {
+	return v.Compare(other) == 0
+}
+
+// LessThan reports whether v has lower precedence than other.
+func (v Version) LessThan(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+// GreaterThan reports whether v has higher precedence than other.
+func (v Version) GreaterThan(other Version) bool {
+	return v.Compare(other) > 0
+}
+
+// comparePreRelease compares pre-release identifier lists per SemVer 2.0 and returns -1, 0 or 1.
+func comparePreRelease(a []string, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
 	}
-	// Majors and minors equal, compare patches
-	return v.Patch > other.Patch
+	for i := 0; i < len(a) && i < len(b); i++ {
+		aNumeric, bNumeric := isNumericIdentifier(a[i]), isNumericIdentifier(b[i])
+		switch {
+		case aNumeric && bNumeric:
+			aNum, _ := strconv.Atoi(a[i])
+			bNum, _ := strconv.Atoi(b[i])
+			if aNum != bNum {
+				if aNum > bNum {
+					return 1
+				}
+				return -1
+			}
+		case aNumeric: // Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case bNumeric:
+			return 1
+		case a[i] != b[i]:
+			if a[i] > b[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	// All shared identifiers equal, the longer list has higher precedence
+	switch {
+	case len(a) > len(b):
+		return 1
+	case len(a) < len(b):
+		return -1
+	}
+	return 0
 }
 
 // UserService struct for user related operations.
 type UserService struct {
-	userDB UserDatabase // Assuming UserDatabase is defined in repository package
-	logger *log.Logger
+	userDB  UserDatabase  // Assuming UserDatabase is defined in repository package
+	tokenDB TokenDatabase // API tokens, removed together with their owner
+	logger  *slog.Logger
 }
 
 // NewUserService creates a new UserService instance.
-func NewUserService(db UserDatabase, logger *log.Logger) *UserService {
+func NewUserService(db UserDatabase, tokenDB TokenDatabase, logger *slog.Logger) *UserService {
 	return &UserService{
-		userDB: db,
-		logger: logger,
+		userDB:  db,
+		tokenDB: tokenDB,
+		logger:  logger,
 	}
 }
 
@@ -290,27 +1667,104 @@ func (s *UserService) ListUsers() ([]*User, error) {
 }
 
 // CreateUser creates a new
-func (s *UserService) CreateUser(user *User) error {
+func (s *UserService) CreateUser(user *User, password string) error {
+	if err := validateNewUser(user, password); err != nil {
+		return err
+	}
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+	}
+	user.PasswordHash = passwordHash
 	if err := s.userDB.CreateUser(user); err != nil {
 		return fmt.Errorf("failed to create user %s: %w", user.Username, err)
 	}
 	return nil
 }
 
-// UpdateUserPassword updates a user's password.
+// CreateUsers creates several users and returns the outcome of each, nil for a created user, in the order of users.
+// All entries are validated before any user is created. Entries that fail validation, name an existing user or
+// repeat the username of an earlier entry are skipped, and the remaining users are still created.
+func (s *UserService) CreateUsers(users []*User, passwords []string) []error {
+	results := make([]error, len(users))
+	seen := make(map[string]bool, len(users))
+	for i, user := range users {
+		switch {
+		case seen[user.Username]:
+			results[i] = fmt.Errorf("username %s is listed more than once", user.Username)
+		default:
+			results[i] = validateNewUser(user, passwords[i])
+			if results[i] == nil {
+				if _, err := s.userDB.GetUserByUsername(user.Username); err == nil {
+					results[i] = fmt.Errorf("%w: %s", ErrUserExists, user.Username)
+				}
+			}
+		}
+		seen[user.Username] = true
+	}
+	for i, user := range users {
+		if results[i] == nil {
+			results[i] = s.CreateUser(user, passwords[i]) // Still fails if the user was created concurrently
+		}
+	}
+	return results
+}
+
+// validateNewUser checks the username, roles and password of a user about to be created.
+func validateNewUser(user *User, password string) error {
+	if err := ValidateUsername(user.Username); err != nil {
+		return err
+	}
+	if err := ValidateRoles(user.Roles); err != nil {
+		return err
+	}
+	return ValidatePassword(password)
+}
+
+// UpdateUserPassword updates a user's password after checking it against the password policy.
+// Setting a new password clears the user's MustChangePassword flag.
 func (s *UserService) UpdateUserPassword(username string, newPassword string) error {
-	hashedPassword := HashPassword(newPassword) // Hash the new password
-	if err := s.userDB.UpdateUserPassword(username, hashedPassword); err != nil {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	return s.storePassword(username, newPassword, false)
+}
+
+// RehashPassword stores a fresh hash of a user's current password without applying the password policy,
+// so passwords set before the policy existed can still be upgraded on login. The MustChangePassword flag is kept.
+func (s *UserService) RehashPassword(user *User, password string) error {
+	return s.storePassword(user.Username, password, user.MustChangePassword)
+}
+
+// storePassword hashes and stores a user's password.
+func (s *UserService) storePassword(username string, password string, mustChangePassword bool) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to update password for user %s: %w", username, err)
+	}
+	if err := s.userDB.UpdateUserPassword(username, hashedPassword, mustChangePassword); err != nil {
 		return fmt.Errorf("failed to update password for user %s: %w", username, err)
 	}
 	return nil
 }
 
-// DeleteUser deletes a
+// DeleteUser deletes a user and their API tokens.
 func (s *UserService) DeleteUser(username string) error {
 	if err := s.userDB.DeleteUser(username); err != nil {
 		return fmt.Errorf("failed to delete user %s: %w", username, err)
 	}
+	tokens, err := s.tokenDB.ListAPITokens()
+	if err != nil {
+		return fmt.Errorf("failed to delete api tokens of user %s: %w", username, err)
+	}
+	for _, token := range tokens {
+		if token.Username != username || token.Source == AuthProviderExternal { // External identities do not own the local account
+			continue
+		}
+		if err := s.tokenDB.DeleteAPIToken(token.Token); err != nil {
+			return fmt.Errorf("failed to delete api token %s of user %s: %w", token.ID, username, err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,61 @@
+// shutdown_test.go - Tests of the graceful shutdown.
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		handlerDelay time.Duration // How long the request in flight at shutdown still runs
+		wantExitCode int
+		wantLog      string
+	}{
+		{"request finishes in time", 50 * time.Millisecond, 0, ""},
+		{"request outlasts the deadline", 5 * time.Second, exitCodeForcedShutdown, "in_flight_requests=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inFlight := NewInFlightRequests()
+			started := make(chan struct{})
+			handler := inFlight.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.handlerDelay):
+				case <-r.Context().Done(): // Aborted by the forced close
+				}
+			}))
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := &http.Server{Handler: handler}
+			go server.Serve(listener)
+			go http.Get("http://" + listener.Addr().String() + "/slow")
+			<-started
+
+			var logs bytes.Buffer
+			start := time.Now()
+			exitCode := shutdownServer(server, inFlight, 500*time.Millisecond, slog.New(slog.NewTextHandler(&logs, nil)))
+			if exitCode != tt.wantExitCode {
+				t.Errorf("exit code = %d, want %d", exitCode, tt.wantExitCode)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("shutdown took %v, want it bounded by the delay", elapsed)
+			}
+			if tt.wantLog == "" && logs.Len() != 0 {
+				t.Errorf("clean shutdown logged %q", logs.String())
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log %q does not report %s", logs.String(), tt.wantLog)
+			}
+		})
+	}
+}
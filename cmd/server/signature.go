@@ -0,0 +1,101 @@
+// internal/release/signature.go - Release signature verification.
+//
+// This file verifies detached minisign signatures of uploaded release files against
+// the configured minisign public key. Both the legacy and the prehashed (BLAKE2b-512)
+// signature algorithms are supported, and the trusted comment is verified too.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrInvalidSignature is returned when a release signature is missing, malformed or does not verify.
+var ErrInvalidSignature = errors.New("invalid release signature")
+
+// Minisign signature algorithms: the message itself, or its BLAKE2b-512 hash, is signed.
+var (
+	minisignAlgorithmLegacy    = []byte("Ed")
+	minisignAlgorithmPrehashed = []byte("ED")
+)
+
+const minisignTrustedCommentPrefix = "trusted comment: "
+
+// MinisignPublicKey is a minisign Ed25519 public key and its key ID.
+type MinisignPublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// ParseMinisignPublicKey parses a minisign public key, either the base64 key line alone
+// or the whole minisign.pub file with its untrusted comment line.
+func ParseMinisignPublicKey(text string) (*MinisignPublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return nil, fmt.Errorf("minisign public key is not valid base64: %w", err)
+	}
+	if len(decoded) != 2+8+ed25519.PublicKeySize || !bytes.Equal(decoded[:2], minisignAlgorithmLegacy) {
+		return nil, fmt.Errorf("minisign public key is not an Ed25519 key")
+	}
+	publicKey := &MinisignPublicKey{key: ed25519.PublicKey(decoded[10:])}
+	copy(publicKey.keyID[:], decoded[2:10])
+	return publicKey, nil
+}
+
+// Verify checks a minisign signature file against the message read from r.
+func (pk *MinisignPublicKey) Verify(r io.Reader, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 {
+		return fmt.Errorf("%w: expected a minisign signature file of 4 lines", ErrInvalidSignature)
+	}
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature line", ErrInvalidSignature)
+	}
+	if !bytes.Equal(sig[2:10], pk.keyID[:]) {
+		return fmt.Errorf("%w: signed with key %X, expected %X", ErrInvalidSignature, sig[2:10], pk.keyID[:])
+	}
+	trustedComment, ok := strings.CutPrefix(lines[2], minisignTrustedCommentPrefix)
+	if !ok {
+		return fmt.Errorf("%w: missing trusted comment", ErrInvalidSignature)
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed trusted comment signature", ErrInvalidSignature)
+	}
+
+	var message []byte
+	switch {
+	case bytes.Equal(sig[:2], minisignAlgorithmPrehashed):
+		hasher, _ := blake2b.New512(nil)
+		if _, err := io.Copy(hasher, r); err != nil {
+			return fmt.Errorf("failed to read signed file: %w", err)
+		}
+		message = hasher.Sum(nil)
+	case bytes.Equal(sig[:2], minisignAlgorithmLegacy):
+		if message, err = io.ReadAll(r); err != nil {
+			return fmt.Errorf("failed to read signed file: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported signature algorithm %q", ErrInvalidSignature, sig[:2])
+	}
+	if !ed25519.Verify(pk.key, message, sig[10:]) {
+		return fmt.Errorf("%w: signature does not match the file", ErrInvalidSignature)
+	}
+	signedComment := append(append([]byte{}, sig[10:]...), trustedComment...) // The trusted comment is signed together with the signature
+	if !ed25519.Verify(pk.key, signedComment, globalSig) {
+		return fmt.Errorf("%w: trusted comment signature does not verify", ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// internal/release/signature_test.go - Tests of minisign release signature verification.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// testSigner signs files the way the minisign tool does.
+type testSigner struct {
+	keyID      [8]byte
+	privateKey ed25519.PrivateKey
+	publicKey  string // Contents of the minisign.pub file
+}
+
+// newTestSigner creates a signer with a key derived from seed and the given key ID.
+func newTestSigner(seed byte, keyID [8]byte) *testSigner {
+	privateKey := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	encoded := append(append(append([]byte{}, minisignAlgorithmLegacy...), keyID[:]...), privateKey.Public().(ed25519.PublicKey)...)
+	return &testSigner{
+		keyID:      keyID,
+		privateKey: privateKey,
+		publicKey:  "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(encoded) + "\n",
+	}
+}
+
+// sign returns a minisign signature file of content. With prehashed, the BLAKE2b-512 hash of the
+// content is signed, as minisign does by default.
+func (s *testSigner) sign(content []byte, prehashed bool, trustedComment string) string {
+	algorithm, message := minisignAlgorithmLegacy, content
+	if prehashed {
+		hash := blake2b.Sum512(content)
+		algorithm, message = minisignAlgorithmPrehashed, hash[:]
+	}
+	signature := ed25519.Sign(s.privateKey, message)
+	signatureLine := append(append(append([]byte{}, algorithm...), s.keyID[:]...), signature...)
+	globalSignature := ed25519.Sign(s.privateKey, append(append([]byte{}, signature...), trustedComment...))
+	return fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\n%s%s\n%s\n",
+		base64.StdEncoding.EncodeToString(signatureLine), minisignTrustedCommentPrefix, trustedComment,
+		base64.StdEncoding.EncodeToString(globalSignature))
+}
+
+func TestMinisignVerify(t *testing.T) {
+	signer := newTestSigner(1, [8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	publicKey, err := ParseMinisignPublicKey(signer.publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("release content")
+	valid := signer.sign(content, true, "timestamp:1700000000\tfile:release.tgz")
+	lines := strings.Split(valid, "\n")
+
+	tests := []struct {
+		name      string
+		content   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"prehashed", content, valid, false},
+		{"legacy", content, signer.sign(content, false, "timestamp:1700000000"), false},
+		{"windows line endings", content, strings.ReplaceAll(valid, "\n", "\r\n"), false},
+		{"other file", []byte("tampered content"), valid, true},
+		{"other key", content, newTestSigner(2, signer.keyID).sign(content, true, "timestamp:1700000000"), true},
+		{"other key ID", content, newTestSigner(1, [8]byte{8, 7, 6, 5, 4, 3, 2, 1}).sign(content, true, "timestamp:1700000000"), true},
+		{"altered trusted comment", content, strings.Replace(valid, "timestamp:1700000000", "timestamp:1800000000", 1), true},
+		{"missing trusted comment", content, strings.Join([]string{lines[0], lines[1], "timestamp:1700000000", lines[3]}, "\n"), true},
+		{"truncated", content, strings.Join(lines[:2], "\n"), true},
+		{"not base64", content, strings.Join([]string{lines[0], "!!!", lines[2], lines[3]}, "\n"), true},
+		{"empty", content, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := publicKey.Verify(bytes.NewReader(tt.content), []byte(tt.signature))
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Verify = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestUploadSignedRelease(t *testing.T) {
+	signer := newTestSigner(1, [8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.SignaturePublicKey = signer.publicKey
+		cfg.RequireSignedReleases = true
+	})
+	token := s.token("admin", testAdminPassword)
+	content := []byte("release content")
+	signature := signer.sign(content, true, "timestamp:1700000000")
+
+	tests := []struct {
+		name       string
+		version    string
+		signature  string
+		wantStatus int
+	}{
+		{"valid signature", "1.0.0", signature, http.StatusCreated},
+		{"signature of another file", "1.1.0", signer.sign([]byte("other content"), true, "timestamp:1700000000"), http.StatusBadRequest},
+		{"signature by another key", "1.2.0", newTestSigner(2, signer.keyID).sign(content, true, "timestamp:1700000000"), http.StatusBadRequest},
+		{"malformed signature", "1.3.0", "not a signature", http.StatusBadRequest},
+		{"missing signature", "1.4.0", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{}
+			if tt.signature != "" {
+				fields["signature"] = tt.signature
+			}
+			resp := s.upload(token, "MyApp", tt.version, content, fields)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("upload = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			release, err := s.releaseService.GetRelease("MyApp", tt.version)
+			if tt.wantStatus != http.StatusCreated {
+				if err == nil {
+					t.Errorf("rejected release %s was stored", tt.version)
+				}
+				return
+			}
+			if err != nil || !release.HasSignature {
+				t.Fatalf("stored release = %+v, %v; want it to have a signature", release, err)
+			}
+			download := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/"+tt.version+"?signature=true", nil), token))
+			if download.Code != http.StatusOK || download.Body.String() != tt.signature {
+				t.Errorf("signature download = %d %q, want %q", download.Code, download.Body, tt.signature)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// internal/repository/softwareid.go - Persistent software ID assignment.
+//
+// This file assigns each software name a stable, collision-free integer ID used in
+// repository directory and file names, persisted in a JSON mapping file.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SoftwareIDRegistry hands out monotonically increasing IDs to software names and remembers them.
+type SoftwareIDRegistry struct {
+	filepath string
+	ids      map[string]int // softwareName -> ID
+	nextID   int
+	mu       sync.Mutex
+}
+
+// softwareIDMapping is the on-disk representation of the registry.
+type softwareIDMapping struct {
+	NextID int            `json:"next_id"`
+	IDs    map[string]int `json:"ids"`
+}
+
+// NewSoftwareIDRegistry loads the ID mapping file. When the file does not exist yet, the names in
+// existingNames are seeded with the IDs of the former name hash so their files stay where they are.
+func NewSoftwareIDRegistry(filepath string, existingNames []string) (*SoftwareIDRegistry, error) {
+	registry := &SoftwareIDRegistry{
+		filepath: filepath,
+		ids:      make(map[string]int),
+		nextID:   1,
+	}
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		for _, name := range existingNames {
+			id := legacySoftwareIDFromName(name)
+			registry.ids[name] = id
+			registry.nextID = max(registry.nextID, id+1)
+		}
+		if err := registry.save(); err != nil {
+			return nil, err
+		}
+		return registry, nil
+	}
+	if err := registry.load(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Lookup returns the ID of a software name, or 0 if none has been assigned yet.
+func (reg *SoftwareIDRegistry) Lookup(softwareName string) int {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.ids[softwareName]
+}
+
+// Assign returns the ID of a software name, assigning and persisting the next free ID on first use.
+func (reg *SoftwareIDRegistry) Assign(softwareName string) (int, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if id, ok := reg.ids[softwareName]; ok {
+		return id, nil
+	}
+	id := reg.nextID
+	reg.ids[softwareName] = id
+	reg.nextID++
+	if err := reg.save(); err != nil {
+		delete(reg.ids, softwareName) // Not durable, so don't hand it out
+		reg.nextID--
+		return 0, err
+	}
+	return id, nil
+}
+
+// load reads the mapping from the JSON file.
+func (reg *SoftwareIDRegistry) load() error {
+	file, err := os.Open(reg.filepath)
+	if err != nil {
+		return fmt.Errorf("failed to open software ID mapping file: %w", err)
+	}
+	defer file.Close()
+
+	var mapping softwareIDMapping
+	if err := json.NewDecoder(file).Decode(&mapping); err != nil {
+		return fmt.Errorf("failed to decode software ID mapping: %w", err)
+	}
+	if mapping.IDs != nil {
+		reg.ids = mapping.IDs
+	}
+	reg.nextID = max(mapping.NextID, 1)
+	for _, id := range reg.ids {
+		reg.nextID = max(reg.nextID, id+1) // Never reuse an ID, even if next_id was edited by hand
+	}
+	return nil
+}
+
+// save writes the mapping to the JSON file. Callers hold reg.mu or have exclusive access.
+func (reg *SoftwareIDRegistry) save() error {
+	if err := writeJSONFileAtomic(reg.filepath, softwareIDMapping{NextID: reg.nextID, IDs: reg.ids}); err != nil {
+		return fmt.Errorf("failed to write software ID mapping file: %w", err)
+	}
+	return nil
+}
+
+// legacySoftwareIDFromName is the name hash used for IDs before they were assigned persistently.
+// It can collide and is only kept to seed the mapping of existing repositories.
+func legacySoftwareIDFromName(softwareName string) int {
+	hash := 0
+	for _, char := range softwareName {
+		hash = hash*31 + int(char)
+	}
+	return hash & 0xFFFFF
+}
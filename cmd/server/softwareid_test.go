@@ -0,0 +1,91 @@
+// internal/repository/softwareid_test.go - Tests of the persistent software ID assignment.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// "Aa" and "BB" have the same legacy name hash, so they shared a directory ID before IDs were assigned.
+const collidingNameA, collidingNameB = "Aa", "BB"
+
+func TestSoftwareIDsOfCollidingNames(t *testing.T) {
+	if legacySoftwareIDFromName(collidingNameA) != legacySoftwareIDFromName(collidingNameB) {
+		t.Fatalf("%q and %q no longer collide under the legacy hash", collidingNameA, collidingNameB)
+	}
+	path := filepath.Join(t.TempDir(), "software_ids.json")
+	registry, err := NewSoftwareIDRegistry(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idA, err := registry.Assign(collidingNameA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := registry.Assign(collidingNameB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idA == idB {
+		t.Fatalf("%q and %q were both assigned ID %d", collidingNameA, collidingNameB, idA)
+	}
+
+	reopened, err := NewSoftwareIDRegistry(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Lookup(collidingNameA); got != idA {
+		t.Errorf("reopened ID of %q = %d, want %d", collidingNameA, got, idA)
+	}
+	if got := reopened.Lookup(collidingNameB); got != idB {
+		t.Errorf("reopened ID of %q = %d, want %d", collidingNameB, got, idB)
+	}
+	if id, err := reopened.Assign("Other"); err != nil || id == idA || id == idB {
+		t.Errorf("ID of a new name after reopening = %d, %v; want an unused ID", id, err)
+	}
+}
+
+func TestSoftwareIDsSeededFromExistingNames(t *testing.T) {
+	registry, err := NewSoftwareIDRegistry(filepath.Join(t.TempDir(), "software_ids.json"), []string{"MyApp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := registry.Lookup("MyApp"), legacySoftwareIDFromName("MyApp"); got != want {
+		t.Errorf("seeded ID = %d, want the legacy ID %d", got, want)
+	}
+	if id, err := registry.Assign("Other"); err != nil || id <= legacySoftwareIDFromName("MyApp") {
+		t.Errorf("ID of a new name = %d, %v; want one above the seeded IDs", id, err)
+	}
+}
+
+func TestUploadsOfCollidingNamesKeepSeparateFiles(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, collidingNameA, "1.0.0", []byte("release of "+collidingNameA))
+	s.mustUpload(token, collidingNameB, "1.0.0", []byte("release of "+collidingNameB))
+
+	files := map[string]string{} // Path -> content
+	for _, name := range []string{collidingNameA, collidingNameB} {
+		release, err := s.releaseService.GetRelease(name, "1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("release file of %s is missing: %v", name, err)
+		}
+		files[path] = string(content)
+	}
+	if len(files) != 2 {
+		t.Fatalf("releases of %q and %q share a file: %v", collidingNameA, collidingNameB, files)
+	}
+	var contents []string
+	for _, content := range files {
+		contents = append(contents, content)
+	}
+	if contents[0] == contents[1] {
+		t.Errorf("releases of %q and %q have the same file content", collidingNameA, collidingNameB)
+	}
+}
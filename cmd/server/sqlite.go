@@ -0,0 +1,605 @@
+// internal/storage/sqlite.go - SQLite storage backend.
+//
+// This file implements UserDatabase, ReleaseDatabase and SoftwarePackageDatabase on a
+// single SQLite database file, with schema migrations applied when the database is opened.
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite" // Registers the pure-Go "sqlite" driver
+)
+
+// init makes softwareKey available to SQL as software_key(name), so migrations can fill in keys
+// exactly as the server computes them.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("software_key", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		name, _ := args[0].(string)
+		return softwareKey(name), nil
+	})
+}
+
+// sqliteMigrations are applied in order; the number of applied migrations is the schema version.
+// Applied migrations must never be edited, only new ones appended.
+var sqliteMigrations = []string{
+	// 1: users, packages and releases. Release metadata has nested fields, so the full record is
+	// stored as a JSON document next to the columns it is looked up by.
+	`CREATE TABLE users (
+		username      TEXT PRIMARY KEY,
+		password_hash TEXT NOT NULL,
+		roles         TEXT NOT NULL,
+		enabled       INTEGER NOT NULL
+	);
+	CREATE TABLE software_packages (
+		name        TEXT PRIMARY KEY,
+		description TEXT NOT NULL,
+		category    TEXT NOT NULL,
+		enabled     INTEGER NOT NULL,
+		featured    INTEGER NOT NULL,
+		owner       TEXT NOT NULL
+	);
+	CREATE TABLE releases (
+		software_name TEXT NOT NULL,
+		version       TEXT NOT NULL,
+		id            TEXT NOT NULL,
+		metadata      TEXT NOT NULL,
+		PRIMARY KEY (software_name, version)
+	);
+	CREATE INDEX releases_id ON releases (id);`,
+	// 2: who created each user.
+	`ALTER TABLE users ADD COLUMN created_by TEXT NOT NULL DEFAULT '';`,
+	// 3: users that must change their password before doing anything else.
+	`ALTER TABLE users ADD COLUMN must_change_password INTEGER NOT NULL DEFAULT 0;`,
+	// 4: dist-tags of software packages, a JSON object of tag -> version.
+	`ALTER TABLE software_packages ADD COLUMN tags TEXT NOT NULL DEFAULT '{}';`,
+	// 5: per-package storage quota override in bytes, 0 for none.
+	`ALTER TABLE software_packages ADD COLUMN storage_quota_bytes INTEGER NOT NULL DEFAULT 0;`,
+	// 6: software names are matched regardless of casing, through a normalized key next to the stored name.
+	`ALTER TABLE software_packages ADD COLUMN name_key TEXT NOT NULL DEFAULT '';
+	UPDATE software_packages SET name_key = software_key(name);
+	CREATE UNIQUE INDEX software_packages_name_key ON software_packages (name_key);
+	ALTER TABLE releases ADD COLUMN name_key TEXT NOT NULL DEFAULT '';
+	UPDATE releases SET name_key = software_key(software_name);
+	CREATE UNIQUE INDEX releases_name_key ON releases (name_key, version);`,
+}
+
+// OpenSQLiteDatabase opens (or creates) the SQLite database file and migrates it to the current schema.
+func OpenSQLiteDatabase(filepath string) (*sql.DB, error) {
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err := migrateSQLiteDatabase(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrateSQLiteDatabase applies the migrations that have not been applied yet, each in its own transaction.
+func migrateSQLiteDatabase(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > len(sqliteMigrations) {
+		return fmt.Errorf("sqlite database schema version %d is newer than this server supports (%d)", current, len(sqliteMigrations))
+	}
+	for version := current + 1; version <= len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// sqliteRowScanner is implemented by *sql.Row and *sql.Rows.
+type sqliteRowScanner interface {
+	Scan(dest ...any) error
+}
+
+// --- Users ---
+
+// SQLiteUserDatabase is a SQLite implementation of UserDatabase.
+type SQLiteUserDatabase struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserDatabase creates a SQLiteUserDatabase on an opened database.
+func NewSQLiteUserDatabase(db *sql.DB) *SQLiteUserDatabase {
+	return &SQLiteUserDatabase{db: db}
+}
+
+const sqliteUserColumns = `username, password_hash, roles, enabled, created_by, must_change_password`
+
+// scanUser reads a user row selected with sqliteUserColumns.
+func scanUser(row sqliteRowScanner) (*User, error) {
+	var user User
+	var roles string
+	if err := row.Scan(&user.Username, &user.PasswordHash, &roles, &user.Enabled, &user.CreatedBy, &user.MustChangePassword); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(roles), &user.Roles); err != nil {
+		return nil, fmt.Errorf("failed to decode roles of user %s: %w", user.Username, err)
+	}
+	return &user, nil
+}
+
+// GetUserByUsername retrieves a user by username.
+func (db *SQLiteUserDatabase) GetUserByUsername(username string) (*User, error) {
+	user, err := scanUser(db.db.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE username = ?`, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user not found: %s", username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return user, nil
+}
+
+// ListUsers retrieves all users.
+func (db *SQLiteUserDatabase) ListUsers() ([]*User, error) {
+	rows, err := db.db.Query(`SELECT ` + sqliteUserColumns + ` FROM users`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+	users := make([]*User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// CreateUser creates a new user.
+func (db *SQLiteUserDatabase) CreateUser(user *User) error {
+	roles, err := json.Marshal(user.Roles)
+	if err != nil {
+		return fmt.Errorf("failed to encode user roles: %w", err)
+	}
+	result, err := db.db.Exec(`INSERT INTO users (`+sqliteUserColumns+`) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (username) DO NOTHING`,
+		user.Username, user.PasswordHash, string(roles), user.Enabled, user.CreatedBy, user.MustChangePassword)
+	if err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrUserExists, user.Username)
+	}
+	return nil
+}
+
+// UpdateUserPassword updates a user's password and whether it must be changed on next login.
+func (db *SQLiteUserDatabase) UpdateUserPassword(username string, newPasswordHash string, mustChangePassword bool) error {
+	return db.updateUser(username, `UPDATE users SET password_hash = ?, must_change_password = ? WHERE username = ?`, newPasswordHash, mustChangePassword, username)
+}
+
+// DeleteUser deletes a user.
+func (db *SQLiteUserDatabase) DeleteUser(username string) error {
+	return db.updateUser(username, `DELETE FROM users WHERE username = ?`, username)
+}
+
+// EnableDisableUser enables or disables a user account.
+func (db *SQLiteUserDatabase) EnableDisableUser(username string, enabled bool) error {
+	return db.updateUser(username, `UPDATE users SET enabled = ? WHERE username = ?`, enabled, username)
+}
+
+// updateUser executes a statement that changes exactly one user row.
+func (db *SQLiteUserDatabase) updateUser(username string, query string, args ...any) error {
+	result, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found: %s", username)
+	}
+	return nil
+}
+
+// Close is a no-op: the shared database handle is closed by its opener.
+func (db *SQLiteUserDatabase) Close() error {
+	return nil
+}
+
+// --- Software packages ---
+
+// SQLiteSoftwarePackageDatabase is a SQLite implementation of SoftwarePackageDatabase.
+type SQLiteSoftwarePackageDatabase struct {
+	db *sql.DB
+}
+
+// NewSQLiteSoftwarePackageDatabase creates a SQLiteSoftwarePackageDatabase on an opened database.
+func NewSQLiteSoftwarePackageDatabase(db *sql.DB) *SQLiteSoftwarePackageDatabase {
+	return &SQLiteSoftwarePackageDatabase{db: db}
+}
+
+const sqlitePackageColumns = `name, description, category, enabled, featured, owner, tags, storage_quota_bytes`
+
+// scanSoftwarePackage reads a software package row selected with sqlitePackageColumns.
+func scanSoftwarePackage(row sqliteRowScanner) (*SoftwarePackage, error) {
+	var software SoftwarePackage
+	var tags string
+	if err := row.Scan(&software.Name, &software.Description, &software.Category, &software.Enabled, &software.Featured, &software.Owner, &tags, &software.StorageQuotaBytes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &software.Tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tags of software package %s: %w", software.Name, err)
+	}
+	if len(software.Tags) == 0 {
+		software.Tags = nil // Same as a JSON backend package without tags
+	}
+	return &software, nil
+}
+
+// encodePackageTags encodes the dist-tags of a software package for the tags column.
+func encodePackageTags(software *SoftwarePackage) (string, error) {
+	if len(software.Tags) == 0 {
+		return "{}", nil
+	}
+	tags, err := json.Marshal(software.Tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode software package tags: %w", err)
+	}
+	return string(tags), nil
+}
+
+// GetSoftwarePackage retrieves a software package definition by name, regardless of casing.
+func (db *SQLiteSoftwarePackageDatabase) GetSoftwarePackage(name string) (*SoftwarePackage, error) {
+	software, err := scanSoftwarePackage(db.db.QueryRow(`SELECT `+sqlitePackageColumns+` FROM software_packages WHERE name_key = ?`, softwareKey(name)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query software package: %w", err)
+	}
+	return software, nil
+}
+
+// ListSoftwarePackages retrieves all software package definitions.
+func (db *SQLiteSoftwarePackageDatabase) ListSoftwarePackages() ([]*SoftwarePackage, error) {
+	rows, err := db.db.Query(`SELECT ` + sqlitePackageColumns + ` FROM software_packages`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query software packages: %w", err)
+	}
+	defer rows.Close()
+	packages := make([]*SoftwarePackage, 0)
+	for rows.Next() {
+		software, err := scanSoftwarePackage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read software package: %w", err)
+		}
+		packages = append(packages, software)
+	}
+	return packages, rows.Err()
+}
+
+// CreateSoftwarePackage creates a new software package definition. Names are unique regardless of casing.
+func (db *SQLiteSoftwarePackageDatabase) CreateSoftwarePackage(software *SoftwarePackage) error {
+	tags, err := encodePackageTags(software)
+	if err != nil {
+		return err
+	}
+	result, err := db.db.Exec(`INSERT INTO software_packages (`+sqlitePackageColumns+`, name_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		software.Name, software.Description, software.Category, software.Enabled, software.Featured, software.Owner, tags, software.StorageQuotaBytes, softwareKey(software.Name))
+	if err != nil {
+		return fmt.Errorf("failed to insert software package: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("software package already exists: %s", software.Name)
+	}
+	return nil
+}
+
+// UpdateSoftwarePackage overwrites an existing software package definition, keeping its stored name.
+func (db *SQLiteSoftwarePackageDatabase) UpdateSoftwarePackage(software *SoftwarePackage) error {
+	tags, err := encodePackageTags(software)
+	if err != nil {
+		return err
+	}
+	result, err := db.db.Exec(`UPDATE software_packages SET description = ?, category = ?, enabled = ?, featured = ?, owner = ?, tags = ?, storage_quota_bytes = ? WHERE name_key = ?`,
+		software.Description, software.Category, software.Enabled, software.Featured, software.Owner, tags, software.StorageQuotaBytes, softwareKey(software.Name))
+	if err != nil {
+		return fmt.Errorf("failed to update software package: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, software.Name)
+	}
+	return nil
+}
+
+// DeleteSoftwarePackage deletes a software package definition.
+func (db *SQLiteSoftwarePackageDatabase) DeleteSoftwarePackage(name string) error {
+	result, err := db.db.Exec(`DELETE FROM software_packages WHERE name_key = ?`, softwareKey(name))
+	if err != nil {
+		return fmt.Errorf("failed to delete software package: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, name)
+	}
+	return nil
+}
+
+// Close is a no-op: the shared database handle is closed by its opener.
+func (db *SQLiteSoftwarePackageDatabase) Close() error {
+	return nil
+}
+
+// --- Releases ---
+
+// SQLiteReleaseDatabase is a SQLite implementation of ReleaseDatabase. Release files are
+// stored in the repository directory exactly as with the JSON backend.
+type SQLiteReleaseDatabase struct {
+	releaseFileStore
+	db *sql.DB
+}
+
+// NewSQLiteReleaseDatabase creates a SQLiteReleaseDatabase on an opened database, with software IDs
+// persisted in softwareIDsFilepath.
+func NewSQLiteReleaseDatabase(db *sql.DB, softwareIDsFilepath string) (*SQLiteReleaseDatabase, error) {
+	rows, err := db.Query(`SELECT DISTINCT software_name FROM releases`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query software names: %w", err)
+	}
+	defer rows.Close()
+	existingNames := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read software name: %w", err)
+		}
+		existingNames = append(existingNames, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query software names: %w", err)
+	}
+	softwareIDs, err := NewSoftwareIDRegistry(softwareIDsFilepath, existingNames)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteReleaseDatabase{releaseFileStore: releaseFileStore{softwareIDs: softwareIDs}, db: db}, nil
+}
+
+// scanReleaseMetadata decodes the metadata document of a release row.
+func scanReleaseMetadata(row sqliteRowScanner) (*ReleaseMetadata, error) {
+	var document string
+	if err := row.Scan(&document); err != nil {
+		return nil, err
+	}
+	var metadata ReleaseMetadata
+	if err := json.Unmarshal([]byte(document), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode release metadata: %w", err)
+	}
+	return &metadata, nil
+}
+
+// queryReleasesMetadata runs a query selecting metadata documents and decodes every row.
+func (db *SQLiteReleaseDatabase) queryReleasesMetadata(query string, args ...any) ([]*ReleaseMetadata, error) {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query releases: %w", err)
+	}
+	defer rows.Close()
+	releasesMetadata := make([]*ReleaseMetadata, 0)
+	for rows.Next() {
+		metadata, err := scanReleaseMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		releasesMetadata = append(releasesMetadata, metadata)
+	}
+	return releasesMetadata, rows.Err()
+}
+
+// softwareExists reports whether any release of a software package is stored.
+func (db *SQLiteReleaseDatabase) softwareExists(softwareName string) (bool, error) {
+	var exists bool
+	if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM releases WHERE name_key = ?)`, softwareKey(softwareName)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to query releases: %w", err)
+	}
+	return exists, nil
+}
+
+// releaseNotFound returns the same errors as the JSON backend for a missing software package or version.
+func (db *SQLiteReleaseDatabase) releaseNotFound(softwareName string, version string) error {
+	exists, err := db.softwareExists(softwareName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	return fmt.Errorf("release version %w for software %s: %s", ErrNotFound, softwareName, version)
+}
+
+// GetReleaseMetadata retrieves release metadata for a specific software and version.
+func (db *SQLiteReleaseDatabase) GetReleaseMetadata(softwareName string, version string) (*ReleaseMetadata, error) {
+	metadata, err := scanReleaseMetadata(db.db.QueryRow(`SELECT metadata FROM releases WHERE name_key = ? AND version = ?`, softwareKey(softwareName), version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, db.releaseNotFound(softwareName, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query release: %w", err)
+	}
+	return metadata, nil
+}
+
+// GetReleaseByID retrieves release metadata by its unique release ID.
+func (db *SQLiteReleaseDatabase) GetReleaseByID(id string) (*ReleaseMetadata, error) {
+	if id == "" {
+		return nil, fmt.Errorf("release %w: %s", ErrNotFound, id)
+	}
+	metadata, err := scanReleaseMetadata(db.db.QueryRow(`SELECT metadata FROM releases WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("release %w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query release: %w", err)
+	}
+	return metadata, nil
+}
+
+// ListReleasesMetadataForSoftware retrieves all release metadata for a software package.
+func (db *SQLiteReleaseDatabase) ListReleasesMetadataForSoftware(softwareName string) ([]*ReleaseMetadata, error) {
+	releasesMetadata, err := db.queryReleasesMetadata(`SELECT metadata FROM releases WHERE name_key = ?`, softwareKey(softwareName))
+	if err != nil {
+		return nil, err
+	}
+	if len(releasesMetadata) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSoftwareNotFound, softwareName)
+	}
+	return releasesMetadata, nil
+}
+
+// ListAllReleasesMetadata retrieves metadata for all releases across all software packages.
+func (db *SQLiteReleaseDatabase) ListAllReleasesMetadata() ([]*ReleaseMetadata, error) {
+	return db.queryReleasesMetadata(`SELECT metadata FROM releases`)
+}
+
+// CreateReleaseMetadata creates new release metadata. The software name must be spelled like the
+// releases already stored for the package, as their files are named after it.
+func (db *SQLiteReleaseDatabase) CreateReleaseMetadata(metadata *ReleaseMetadata) error {
+	document, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode release metadata: %w", err)
+	}
+	key := softwareKey(metadata.SoftwareName)
+	result, err := db.db.Exec(`INSERT INTO releases (software_name, version, id, metadata, name_key) SELECT ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM releases WHERE name_key = ? AND software_name != ?) ON CONFLICT DO NOTHING`,
+		metadata.SoftwareName, metadata.Version, metadata.ID, string(document), key, key, metadata.SoftwareName)
+	if err != nil {
+		return fmt.Errorf("failed to insert release: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var storedName string
+		if err := db.db.QueryRow(`SELECT software_name FROM releases WHERE name_key = ? AND software_name != ? LIMIT 1`, key, metadata.SoftwareName).Scan(&storedName); err == nil {
+			return fmt.Errorf("%w: %s is stored as %s", ErrSoftwareNameConflict, metadata.SoftwareName, storedName)
+		}
+		return fmt.Errorf("%w for software %s: %s", ErrReleaseExists, metadata.SoftwareName, metadata.Version)
+	}
+	return nil
+}
+
+// UpdateReleaseMetadata updates existing release metadata.
+func (db *SQLiteReleaseDatabase) UpdateReleaseMetadata(metadata *ReleaseMetadata) error {
+	n, err := updateReleaseMetadata(db.db, metadata)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.releaseNotFound(metadata.SoftwareName, metadata.Version)
+	}
+	return nil
+}
+
+// AddDownloadCounts adds to the download counts of several releases in one transaction.
+// The counts are updated inside the stored documents so concurrent updates are not lost.
+func (db *SQLiteReleaseDatabase) AddDownloadCounts(counts map[ReleaseRef]int64) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin download count transaction: %w", err)
+	}
+	for ref, n := range counts {
+		// A release deleted meanwhile simply matches no row
+		if _, err := tx.Exec(`UPDATE releases SET metadata = json_set(metadata, '$.download_count', COALESCE(json_extract(metadata, '$.download_count'), 0) + ?)
+			WHERE name_key = ? AND version = ?`, n, softwareKey(ref.SoftwareName), ref.Version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update release download count: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit download counts: %w", err)
+	}
+	return nil
+}
+
+// sqliteExecer is implemented by *sql.DB and *sql.Tx.
+type sqliteExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// updateReleaseMetadata overwrites the stored metadata of a release and returns the number of rows changed.
+func updateReleaseMetadata(execer sqliteExecer, metadata *ReleaseMetadata) (int64, error) {
+	document, err := json.Marshal(metadata)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode release metadata: %w", err)
+	}
+	result, err := execer.Exec(`UPDATE releases SET id = ?, metadata = ? WHERE name_key = ? AND version = ?`,
+		metadata.ID, string(document), softwareKey(metadata.SoftwareName), metadata.Version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update release: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteReleaseMetadata deletes release metadata.
+func (db *SQLiteReleaseDatabase) DeleteReleaseMetadata(softwareName string, version string) error {
+	result, err := db.db.Exec(`DELETE FROM releases WHERE name_key = ? AND version = ?`, softwareKey(softwareName), version)
+	if err != nil {
+		return fmt.Errorf("failed to delete release: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return db.releaseNotFound(softwareName, version)
+	}
+	return nil
+}
+
+// ReconcileReleases reconciles the metadata database with the actual files in the repository.
+// File checks run on up to concurrency workers; state changes are written in one transaction at the end.
+// A release whose file cannot be checked is counted as errored and left unchanged.
+func (db *SQLiteReleaseDatabase) ReconcileReleases(repoPath string, concurrency int) (*ReconcileResult, error) {
+	allReleasesMetadata, err := db.ListAllReleasesMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all release metadata for reconciliation: %w", err)
+	}
+	outcomes, err := db.checkReleaseFiles(repoPath, allReleasesMetadata, concurrency)
+	if err != nil {
+		return nil, err
+	}
+	result, reconciled := applyReconcileOutcomes(outcomes)
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin reconciliation transaction: %w", err)
+	}
+	for _, metadata := range reconciled {
+		if _, err := updateReleaseMetadata(tx, metadata); err != nil { // A release deleted meanwhile simply matches no row
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reconciliation: %w", err)
+	}
+	return result, nil
+}
+
+// Close is a no-op: the shared database handle is closed by its opener.
+func (db *SQLiteReleaseDatabase) Close() error {
+	return nil
+}
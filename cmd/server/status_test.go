@@ -0,0 +1,84 @@
+// api/status_test.go - Tests of the status endpoint and of a fresh, empty deployment.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestFreshDeployment(t *testing.T) {
+	s := newTestServer(t, nil)
+	if err := os.RemoveAll(s.cfg.RepositoryPath); err != nil { // As if the volume was mounted after startup
+		t.Fatal(err)
+	}
+
+	t.Run("status", func(t *testing.T) {
+		resp := s.do(s.request(http.MethodGet, "/api/v1/status", nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+		}
+		var status struct {
+			TotalPackages *int `json:"total_packages"`
+			TotalReleases *int `json:"total_releases"`
+		}
+		decodeResponse(t, resp, &status)
+		if status.TotalPackages == nil || *status.TotalPackages != 0 || status.TotalReleases == nil || *status.TotalReleases != 0 {
+			t.Errorf("status = %s, want zero packages and releases", resp.Body)
+		}
+	})
+	for _, path := range []string{"/api/v1/packages", "/api/v1/releases"} {
+		t.Run("listing "+path, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, path, nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			var page struct {
+				Items json.RawMessage `json:"items"`
+				Total int             `json:"total"`
+			}
+			decodeResponse(t, resp, &page)
+			if string(page.Items) != "[]" || page.Total != 0 {
+				t.Errorf("response = %s, want an empty item list and a zero total", resp.Body)
+			}
+		})
+	}
+	t.Run("reconcile", func(t *testing.T) {
+		resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/reconcile", nil)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("reconcile = %d: %s", resp.Code, resp.Body)
+		}
+		var result ReconcileResult
+		decodeResponse(t, resp, &result)
+		if result.Checked != 0 {
+			t.Errorf("reconcile checked %d releases, want 0", result.Checked)
+		}
+		if info, err := os.Stat(s.cfg.RepositoryPath); err != nil || !info.IsDir() {
+			t.Errorf("repository directory was not created: %v", err)
+		}
+	})
+}
+
+func TestStatusCountsPackages(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+	s.mustUpload(token, "MyApp", "1.1.0", []byte("release 1.1.0"))
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", CreateSoftwareRequest{Name: "Planned"}))); resp.Code != http.StatusCreated {
+		t.Fatalf("creating a package = %d: %s", resp.Code, resp.Body)
+	}
+
+	resp := s.do(s.request(http.MethodGet, "/api/v1/status", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+	}
+	var status struct {
+		TotalPackages int `json:"total_packages"`
+		TotalReleases int `json:"total_releases"`
+	}
+	decodeResponse(t, resp, &status)
+	if status.TotalPackages != 2 || status.TotalReleases != 2 {
+		t.Errorf("status = %s, want 2 packages, one of them defined without releases, and 2 releases", resp.Body)
+	}
+}
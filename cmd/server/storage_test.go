@@ -0,0 +1,257 @@
+// internal/repository/storage_test.go - Tests of the database interfaces, run against each storage backend.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var storageBackends = []string{StorageBackendJSON, StorageBackendSQLite}
+
+// openTestDatabases opens the databases of a storage backend in dir. They are closed when the test ends
+// unless the returned function closed them before.
+func openTestDatabases(t *testing.T, backend string, dir string) (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase, func()) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.StorageBackend = backend
+	cfg.DataPath = dir
+	userDB, releaseDB, packageDB, closeDatabases, err := openDatabases(cfg)
+	if err != nil {
+		t.Fatalf("failed to open %s databases: %v", backend, err)
+	}
+	closed := false
+	closeOnce := func() {
+		if !closed {
+			closed = true
+			closeDatabases()
+		}
+	}
+	t.Cleanup(closeOnce)
+	return userDB, releaseDB, packageDB, closeOnce
+}
+
+// forEachBackend runs test against the databases of each storage backend. reopen closes them and
+// opens them again on the same files.
+func forEachBackend(t *testing.T, test func(t *testing.T, userDB UserDatabase, releaseDB ReleaseDatabase, packageDB SoftwarePackageDatabase, reopen func() (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase))) {
+	for _, backend := range storageBackends {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			userDB, releaseDB, packageDB, closeDatabases := openTestDatabases(t, backend, dir)
+			reopen := func() (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase) {
+				closeDatabases()
+				var u UserDatabase
+				var r ReleaseDatabase
+				var p SoftwarePackageDatabase
+				u, r, p, closeDatabases = openTestDatabases(t, backend, dir)
+				return u, r, p
+			}
+			test(t, userDB, releaseDB, packageDB, reopen)
+		})
+	}
+}
+
+// sameMetadata compares releases by their JSON encoding, which leaves out the monotonic clock reading.
+func sameMetadata(got *ReleaseMetadata, want *ReleaseMetadata) bool {
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	return string(gotJSON) == string(wantJSON)
+}
+
+func TestUserDatabaseBackends(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, userDB UserDatabase, _ ReleaseDatabase, _ SoftwarePackageDatabase, reopen func() (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase)) {
+		alice := &User{Username: "alice", PasswordHash: "hash-1", Roles: []string{"user", "publisher"}, Enabled: true, CreatedBy: "admin"}
+		if err := userDB.CreateUser(alice); err != nil {
+			t.Fatal(err)
+		}
+		if err := userDB.CreateUser(&User{Username: "alice", PasswordHash: "other", Enabled: true}); err == nil {
+			t.Error("creating a user twice succeeded")
+		}
+		if err := userDB.CreateUser(&User{Username: "bob", PasswordHash: "hash-2", Roles: []string{"user"}, Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+		if err := userDB.UpdateUserPassword("alice", "hash-3", true); err != nil {
+			t.Fatal(err)
+		}
+		if err := userDB.EnableDisableUser("bob", false); err != nil {
+			t.Fatal(err)
+		}
+		if err := userDB.UpdateUserPassword("nobody", "hash", false); err == nil {
+			t.Error("updating the password of a missing user succeeded")
+		}
+		if err := userDB.EnableDisableUser("nobody", true); err == nil {
+			t.Error("disabling a missing user succeeded")
+		}
+
+		userDB, _, _ = reopen()
+		got, err := userDB.GetUserByUsername("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := User{Username: "alice", PasswordHash: "hash-3", Roles: []string{"user", "publisher"}, Enabled: true, CreatedBy: "admin", MustChangePassword: true}
+		if got.Username != want.Username || got.PasswordHash != want.PasswordHash || !slices.Equal(got.Roles, want.Roles) ||
+			got.Enabled != want.Enabled || got.CreatedBy != want.CreatedBy || got.MustChangePassword != want.MustChangePassword {
+			t.Errorf("reopened alice = %+v, want %+v", *got, want)
+		}
+		if bob, err := userDB.GetUserByUsername("bob"); err != nil || bob.Enabled {
+			t.Errorf("reopened bob = %+v, %v; want disabled", bob, err)
+		}
+		users, err := userDB.ListUsers()
+		if err != nil || len(users) != 2 {
+			t.Errorf("ListUsers = %d users, %v; want 2", len(users), err)
+		}
+
+		if err := userDB.DeleteUser("bob"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := userDB.GetUserByUsername("bob"); err == nil {
+			t.Error("deleted user is still found")
+		}
+		if err := userDB.DeleteUser("bob"); err == nil {
+			t.Error("deleting a missing user succeeded")
+		}
+	})
+}
+
+func TestReleaseDatabaseBackends(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ UserDatabase, releaseDB ReleaseDatabase, _ SoftwarePackageDatabase, reopen func() (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase)) {
+		timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		first := &ReleaseMetadata{
+			ID: "id-1", SoftwareName: "MyApp", Version: "1.0.0", ReleaseTimestamp: timestamp, FileSize: 42,
+			Checksum: "abc", ChecksumAlgorithm: ChecksumSHA256, Digests: map[string]string{ChecksumSHA256: "abc"},
+			ReleaseState: "available", UploadedBy: "alice", Changelog: "First release", ReleaseDate: timestamp,
+			ChangelogEntries: []ChangelogEntry{{Type: "added", Description: "Everything"}},
+		}
+		second := &ReleaseMetadata{ID: "id-2", SoftwareName: "MyApp", Version: "1.1.0", ReleaseTimestamp: timestamp, ReleaseState: "available"}
+		other := &ReleaseMetadata{ID: "id-3", SoftwareName: "Other", Version: "0.1.0", ReleaseTimestamp: timestamp, ReleaseState: "available"}
+		for _, release := range []*ReleaseMetadata{first, second, other} {
+			if err := releaseDB.CreateReleaseMetadata(release); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := releaseDB.CreateReleaseMetadata(&ReleaseMetadata{ID: "id-4", SoftwareName: "MyApp", Version: "1.0.0"}); !errors.Is(err, ErrReleaseExists) {
+			t.Errorf("creating a release twice = %v, want ErrReleaseExists", err)
+		}
+		if err := releaseDB.CreateReleaseMetadata(&ReleaseMetadata{ID: "id-5", SoftwareName: "MYAPP", Version: "2.0.0"}); !errors.Is(err, ErrSoftwareNameConflict) {
+			t.Errorf("creating a release under another casing = %v, want ErrSoftwareNameConflict", err)
+		}
+		second.ReleaseState = "yanked"
+		if err := releaseDB.UpdateReleaseMetadata(second); err != nil {
+			t.Fatal(err)
+		}
+		if err := releaseDB.AddDownloadCounts(map[ReleaseRef]int64{{SoftwareName: "MyApp", Version: "1.0.0"}: 3, {SoftwareName: "MyApp", Version: "9.9.9"}: 1}); err != nil {
+			t.Fatal(err)
+		}
+		first.DownloadCount = 3
+
+		_, releaseDB, _ = reopen()
+		for _, want := range []*ReleaseMetadata{first, second, other} {
+			got, err := releaseDB.GetReleaseMetadata(want.SoftwareName, want.Version)
+			if err != nil {
+				t.Fatalf("reopened %s %s: %v", want.SoftwareName, want.Version, err)
+			}
+			if !sameMetadata(got, want) {
+				t.Errorf("reopened %s %s = %+v, want %+v", want.SoftwareName, want.Version, got, want)
+			}
+			if byID, err := releaseDB.GetReleaseByID(want.ID); err != nil || !sameMetadata(byID, want) {
+				t.Errorf("release by ID %s = %+v, %v; want %+v", want.ID, byID, err, want)
+			}
+		}
+		if got, err := releaseDB.GetReleaseMetadata("myapp", "1.0.0"); err != nil || got.SoftwareName != "MyApp" {
+			t.Errorf("lookup in other casing = %+v, %v; want MyApp 1.0.0", got, err)
+		}
+		if _, err := releaseDB.GetReleaseMetadata("MyApp", "9.9.9"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing release = %v, want ErrNotFound", err)
+		}
+		if _, err := releaseDB.GetReleaseByID("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing release ID = %v, want ErrNotFound", err)
+		}
+		if releases, err := releaseDB.ListReleasesMetadataForSoftware("MyApp"); err != nil || len(releases) != 2 {
+			t.Errorf("releases of MyApp = %d, %v; want 2", len(releases), err)
+		}
+		if releases, err := releaseDB.ListAllReleasesMetadata(); err != nil || len(releases) != 3 {
+			t.Errorf("all releases = %d, %v; want 3", len(releases), err)
+		}
+
+		if err := releaseDB.DeleteReleaseMetadata("MyApp", "1.1.0"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := releaseDB.GetReleaseByID("id-2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleted release by ID = %v, want ErrNotFound", err)
+		}
+		if err := releaseDB.DeleteReleaseMetadata("MyApp", "1.1.0"); err == nil {
+			t.Error("deleting a missing release succeeded")
+		}
+	})
+}
+
+func TestSoftwarePackageDatabaseBackends(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ UserDatabase, _ ReleaseDatabase, packageDB SoftwarePackageDatabase, reopen func() (UserDatabase, ReleaseDatabase, SoftwarePackageDatabase)) {
+		myApp := &SoftwarePackage{Name: "MyApp", Description: "An app", Category: "Application", Enabled: true, Owner: "alice", StorageQuotaBytes: 1024}
+		if err := packageDB.CreateSoftwarePackage(myApp); err != nil {
+			t.Fatal(err)
+		}
+		if err := packageDB.CreateSoftwarePackage(&SoftwarePackage{Name: "myapp"}); err == nil {
+			t.Error("creating a package under another casing succeeded")
+		}
+		if err := packageDB.CreateSoftwarePackage(&SoftwarePackage{Name: "Other", Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+		myApp.Featured = true
+		myApp.Tags = map[string]string{"beta": "2.0.0-rc.1"}
+		if err := packageDB.UpdateSoftwarePackage(myApp); err != nil {
+			t.Fatal(err)
+		}
+		if err := packageDB.UpdateSoftwarePackage(&SoftwarePackage{Name: "Missing"}); err == nil {
+			t.Error("updating a missing package succeeded")
+		}
+
+		_, _, packageDB = reopen()
+		got, err := packageDB.GetSoftwarePackage("myapp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, myApp) {
+			t.Errorf("reopened package = %+v, want %+v", got, myApp)
+		}
+		if _, err := packageDB.GetSoftwarePackage("Missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing package = %v, want ErrNotFound", err)
+		}
+		if packages, err := packageDB.ListSoftwarePackages(); err != nil || len(packages) != 2 {
+			t.Errorf("ListSoftwarePackages = %d packages, %v; want 2", len(packages), err)
+		}
+
+		if err := packageDB.DeleteSoftwarePackage("MyApp"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := packageDB.GetSoftwarePackage("MyApp"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleted package = %v, want ErrNotFound", err)
+		}
+		if err := packageDB.DeleteSoftwarePackage("MyApp"); err == nil {
+			t.Error("deleting a missing package succeeded")
+		}
+	})
+}
+
+func TestDatabaseBackendsStoreFilesAlike(t *testing.T) {
+	paths := map[string]string{}
+	for _, backend := range storageBackends {
+		dir := t.TempDir()
+		_, releaseDB, _, _ := openTestDatabases(t, backend, filepath.Join(dir, "data"))
+		metadata := &ReleaseMetadata{SoftwareName: "MyApp", Version: "1.0.0"}
+		stored, err := releaseDB.StoreReleaseFile(filepath.Join(dir, "repository"), strings.NewReader("release"), metadata, []string{ChecksumSHA256})
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		relative, _ := filepath.Rel(dir, stored.Path)
+		paths[backend] = relative
+	}
+	if paths[StorageBackendJSON] != paths[StorageBackendSQLite] {
+		t.Errorf("release file paths = %v, want the same layout for both backends", paths)
+	}
+}
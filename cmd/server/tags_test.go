@@ -0,0 +1,118 @@
+// api/tags_test.go - Tests of the release dist-tags.
+package main
+
+import (
+	"maps"
+	"net/http"
+	"testing"
+)
+
+// releaseTags lists the dist-tags of a software package.
+func (s *testServer) releaseTags(softwareName string) map[string]string {
+	s.t.Helper()
+	resp := s.do(s.request(http.MethodGet, "/api/v1/packages/"+softwareName+"/tags", nil))
+	if resp.Code != http.StatusOK {
+		s.t.Fatalf("tags of %s = %d: %s", softwareName, resp.Code, resp.Body)
+	}
+	var tags ReleaseTagsResponse
+	decodeResponse(s.t, resp, &tags)
+	return tags.Tags
+}
+
+func TestSetReleaseTag(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0-beta.1"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+
+	tests := []struct {
+		name       string
+		tag        string
+		version    string
+		wantStatus int
+	}{
+		{"beta tag", "beta", "2.0.0-beta.1", http.StatusOK},
+		{"stable tag", "stable", "1.0.0", http.StatusOK},
+		{"missing version", "next", "3.0.0", http.StatusBadRequest},
+		{"reserved name", "latest", "1.0.0", http.StatusBadRequest},
+		{"name of a version", "1.1.0", "1.0.0", http.StatusBadRequest},
+		{"uppercase name", "Beta", "1.0.0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := s.request(http.MethodPut, "/api/v1/admin/packages/MyApp/tags/"+tt.tag, ReleaseTagRequest{Version: tt.version})
+			if resp := s.do(asAdmin(req)); resp.Code != tt.wantStatus {
+				t.Errorf("setting %s = %d, want %d: %s", tt.tag, resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+	if want := map[string]string{"beta": "2.0.0-beta.1", "stable": "1.0.0"}; !maps.Equal(s.releaseTags("MyApp"), want) {
+		t.Errorf("tags = %v, want %v", s.releaseTags("MyApp"), want)
+	}
+	req := s.request(http.MethodPut, "/api/v1/admin/packages/MyApp/tags/beta", ReleaseTagRequest{Version: "1.1.0"})
+	if resp := s.do(withBasicAuth(req, "alice", "Alice-Pass-123")); resp.Code != http.StatusForbidden {
+		t.Errorf("setting a tag as a publisher = %d, want 403", resp.Code)
+	}
+}
+
+func TestRetrieveReleaseByTag(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0-beta.1"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+	setTag := func(version string) {
+		t.Helper()
+		req := s.request(http.MethodPut, "/api/v1/admin/packages/MyApp/tags/beta", ReleaseTagRequest{Version: version})
+		if resp := s.do(asAdmin(req)); resp.Code != http.StatusOK {
+			t.Fatalf("pointing beta at %s = %d: %s", version, resp.Code, resp.Body)
+		}
+	}
+	checkBeta := func(wantVersion string) {
+		t.Helper()
+		resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/beta", nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("metadata of beta = %d: %s", resp.Code, resp.Body)
+		}
+		var info ReleaseVersionInfo
+		decodeResponse(t, resp, &info)
+		if info.ReleaseMetadata == nil || info.Version != wantVersion {
+			t.Errorf("beta describes %+v, want release %s", info.ReleaseMetadata, wantVersion)
+		}
+		download := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/beta", nil), token))
+		if download.Code != http.StatusOK {
+			t.Fatalf("download of beta = %d: %s", download.Code, download.Body)
+		}
+		if got, want := download.Header().Get("Content-Disposition"), "attachment; filename=MyApp_"+wantVersion+".tgz"; got != want {
+			t.Errorf("Content-Disposition = %q, want %q", got, want)
+		}
+	}
+
+	setTag("2.0.0-beta.1")
+	checkBeta("2.0.0-beta.1")
+	setTag("1.1.0") // Moving a tag replaces its target
+	checkBeta("1.1.0")
+
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/packages/MyApp/releases/1.1.0", nil))); resp.Code >= 300 {
+		t.Fatalf("deleting 1.1.0 = %d: %s", resp.Code, resp.Body)
+	}
+	if tags := s.releaseTags("MyApp"); len(tags) != 0 {
+		t.Errorf("tags after deleting the tagged release = %v, want none", tags)
+	}
+	if resp := s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/beta", nil)); resp.Code != http.StatusNotFound {
+		t.Errorf("metadata of a removed tag = %d, want 404", resp.Code)
+	}
+
+	setTag("1.0.0")
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/packages/MyApp/tags/beta", nil))); resp.Code != http.StatusNoContent {
+		t.Fatalf("deleting beta = %d: %s", resp.Code, resp.Body)
+	}
+	if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/beta", nil), token)); resp.Code != http.StatusNotFound {
+		t.Errorf("download of a deleted tag = %d, want 404", resp.Code)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/packages/MyApp/tags/beta", nil))); resp.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing tag = %d, want 404", resp.Code)
+	}
+}
@@ -0,0 +1,72 @@
+// internal/storage/tempdir.go - Temporary upload directory management.
+//
+// This file provides the dedicated temp root used by uploads and a sweeper that
+// removes upload directories abandoned by a crash or panic mid-request.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// uploadTempDirPrefix is the name prefix of per-upload temporary directories.
+const uploadTempDirPrefix = "release-temp-"
+
+// sweepStaleTempDirs removes upload temp directories under root that were last modified
+// before now minus maxAge. Entries not created by uploads are never touched.
+func sweepStaleTempDirs(root string, maxAge time.Duration, now time.Time) (int, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read temp directory: %w", err)
+	}
+
+	removed := 0
+	cutoff := now.Add(-maxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), uploadTempDirPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue // Vanished meanwhile, or still recent enough to belong to an in-flight upload
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove stale temp directory %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartTempDirSweeper sweeps the temp root once right away and then on every interval.
+func StartTempDirSweeper(cfg *Config, logger *slog.Logger) {
+	maxAge := time.Duration(cfg.TempDirMaxAgeMinutes) * time.Minute
+	sweep := func() {
+		removed, err := sweepStaleTempDirs(cfg.TempPath, maxAge, time.Now())
+		if err != nil {
+			logger.Error("Temp directory sweep failed", "error", err)
+		}
+		if removed > 0 {
+			logger.Info("Removed stale temp upload directories", "count", removed, "path", cfg.TempPath)
+		}
+	}
+
+	sweep()
+	if cfg.TempSweepIntervalMinutes == 0 {
+		return // Periodic sweeping disabled, startup sweep only
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.TempSweepIntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			sweep()
+		}
+	}()
+}
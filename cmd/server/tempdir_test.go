@@ -0,0 +1,92 @@
+// internal/storage/tempdir_test.go - Tests of the stale upload temp directory sweep.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSweepStaleTempDirs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now().Truncate(time.Second) // Whole seconds survive any file system's timestamp precision
+	maxAge := time.Hour
+	entries := []struct {
+		name      string
+		dir       bool
+		age       time.Duration
+		wantSwept bool
+	}{
+		{uploadTempDirPrefix + "stale", true, 2 * time.Hour, true},
+		{uploadTempDirPrefix + "just-stale", true, maxAge + time.Second, true},
+		{uploadTempDirPrefix + "at-cutoff", true, maxAge, false},
+		{uploadTempDirPrefix + "recent", true, time.Minute, false},
+		{"other-stale", true, 2 * time.Hour, false},                 // Not created by an upload
+		{uploadTempDirPrefix + "file", false, 2 * time.Hour, false}, // Uploads only create directories
+	}
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.name)
+		if entry.dir {
+			if err := os.MkdirAll(filepath.Join(path, "nested"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(path, "nested", "part.tgz"), []byte("partial upload"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(path, []byte("not a directory"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-entry.age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := sweepStaleTempDirs(root, maxAge, now)
+	if err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	for _, entry := range entries {
+		_, err := os.Stat(filepath.Join(root, entry.name))
+		if swept := os.IsNotExist(err); swept != entry.wantSwept {
+			t.Errorf("%s swept = %v, want %v", entry.name, swept, entry.wantSwept)
+		}
+	}
+
+	if removed, err := sweepStaleTempDirs(filepath.Join(root, "missing"), maxAge, now); removed != 0 || err != nil {
+		t.Errorf("sweeping a missing root = %d, %v; want 0 and no error", removed, err)
+	}
+}
+
+func TestStartTempDirSweeper(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TempPath = t.TempDir()
+	cfg.TempSweepIntervalMinutes = 0 // Startup sweep only
+	old := time.Now().Add(-time.Duration(cfg.TempDirMaxAgeMinutes+1) * time.Minute)
+	for _, name := range []string{uploadTempDirPrefix + "abandoned", uploadTempDirPrefix + "in-flight"} {
+		if err := os.Mkdir(filepath.Join(cfg.TempPath, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(cfg.TempPath, uploadTempDirPrefix+"abandoned"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	StartTempDirSweeper(cfg, discardLogger())
+	entries, err := os.ReadDir(cfg.TempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{uploadTempDirPrefix + "in-flight"}; !slices.Equal(names, want) {
+		t.Errorf("temp root holds %v after the startup sweep, want %v", names, want)
+	}
+}
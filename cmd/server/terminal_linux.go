@@ -0,0 +1,59 @@
+// internal/cli/terminal_linux.go - Password prompts on Linux terminals.
+//
+// This file switches terminal echo off while a password is typed.
+
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// getTermios reads the terminal settings of a file.
+func getTermios(file *os.File) (*syscall.Termios, error) {
+	var termios syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		return nil, errno
+	}
+	return &termios, nil
+}
+
+// setTermios applies terminal settings to a file.
+func setTermios(file *os.File, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal reports whether a file is a terminal.
+func isTerminal(file *os.File) bool {
+	_, err := getTermios(file)
+	return err == nil
+}
+
+// readPasswordNoEcho reads one line from a terminal with echo switched off.
+func readPasswordNoEcho(file *os.File) (string, error) {
+	original, err := getTermios(file)
+	if err != nil {
+		return "", err
+	}
+	silent := *original
+	silent.Lflag &^= syscall.ECHO
+	silent.Lflag |= syscall.ICANON
+	if err := setTermios(file, &silent); err != nil {
+		return "", err
+	}
+	defer setTermios(file, original)
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
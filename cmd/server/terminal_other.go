@@ -0,0 +1,22 @@
+// internal/cli/terminal_other.go - Password prompt fallback on other platforms.
+//
+// This file is used on platforms where terminal echo cannot be switched off; passwords are piped via stdin there.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal reports false, so passwords are read from stdin as a plain line.
+func isTerminal(file *os.File) bool {
+	return false
+}
+
+// readPasswordNoEcho is not supported on this platform.
+func readPasswordNoEcho(file *os.File) (string, error) {
+	return "", errors.New("reading a password without echo is not supported on this platform")
+}
@@ -0,0 +1,228 @@
+// internal/token/token.go - API token persistence.
+//
+// This file defines the APIToken struct and interfaces/implementations for storing
+// API tokens so they survive server restarts.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIToken represents an API token issued to a user.
+type APIToken struct {
+	ID          string     `json:"id"`    // Public identifier used to list and revoke the token
+	Token       string     `json:"token"` // Secret presented in the Authorization header
+	Username    string     `json:"username"`
+	Name        string     `json:"name,omitempty"`        // Human-readable name, unique among the user's active tokens
+	Description string     `json:"description,omitempty"` // Free-text note, e.g. which CI system uses the token
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`   // Nil means the token never expires
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"` // Last authentication with the token, recorded at most once per apiTokenUseResolution
+	Revoked     bool       `json:"revoked"`
+	Roles       []string   `json:"roles,omitempty"`  // Roles of an external identity, which has no user record to look them up in
+	Source      string     `json:"source,omitempty"` // AuthProviderExternal for tokens of external identities, empty for local users
+}
+
+// apiTokenPrefixLength is the number of secret characters shown in token listings.
+const apiTokenPrefixLength = 8
+
+// Prefix returns the first characters of the token secret, safe to show in listings.
+func (t *APIToken) Prefix() string {
+	if len(t.Token) <= apiTokenPrefixLength {
+		return ""
+	}
+	return t.Token[:apiTokenPrefixLength]
+}
+
+// apiTokenUseResolution bounds how often a token's LastUsedAt is written, so busy tokens do not cause a write per request.
+const apiTokenUseResolution = time.Minute
+
+// IsStale reports whether an unrevoked token has gone unused, or stayed unused since creation, for longer than staleAfter.
+// A staleAfter of 0 never flags tokens.
+func (t *APIToken) IsStale(now time.Time, staleAfter time.Duration) bool {
+	if staleAfter <= 0 || t.Revoked {
+		return false
+	}
+	lastActivity := t.CreatedAt
+	if t.LastUsedAt != nil {
+		lastActivity = *t.LastUsedAt
+	}
+	return now.Sub(lastActivity) > staleAfter
+}
+
+// IsExpired reports whether the token has expired at the given time.
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// TokenDatabase interface defines operations for API token storage.
+type TokenDatabase interface {
+	GetAPIToken(token string) (*APIToken, error)
+	GetAPITokenByID(id string) (*APIToken, error)
+	ListAPITokens() ([]*APIToken, error)
+	CreateAPIToken(token *APIToken) error
+	UpdateAPIToken(token *APIToken) error
+	RecordAPITokenUse(token string, usedAt time.Time) error
+	DeleteAPIToken(token string) error
+	Close() error
+}
+
+// JSONTokenDatabase is a JSON file-based implementation of TokenDatabase.
+type JSONTokenDatabase struct {
+	filepath string
+	tokens   map[string]*APIToken // token -> record
+	mu       sync.RWMutex         // Mutex for read/write operations
+}
+
+// NewJSONTokenDatabase creates a new JSONTokenDatabase instance.
+func NewJSONTokenDatabase(filepath string) (*JSONTokenDatabase, error) {
+	db := &JSONTokenDatabase{
+		filepath: filepath,
+		tokens:   make(map[string]*APIToken),
+	}
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
+	if err := db.loadTokens(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// GetAPIToken retrieves a token record by its token value.
+func (db *JSONTokenDatabase) GetAPIToken(token string) (*APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	record, ok := db.tokens[token]
+	if !ok {
+		return nil, fmt.Errorf("api token not found")
+	}
+	return record, nil
+}
+
+// GetAPITokenByID retrieves a token record by its public ID.
+func (db *JSONTokenDatabase) GetAPITokenByID(id string) (*APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, record := range db.tokens {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("api token not found: %s", id)
+}
+
+// ListAPITokens retrieves all token records.
+func (db *JSONTokenDatabase) ListAPITokens() ([]*APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	tokenList := make([]*APIToken, 0, len(db.tokens))
+	for _, record := range db.tokens {
+		tokenList = append(tokenList, record)
+	}
+	return tokenList, nil
+}
+
+// CreateAPIToken stores a new token record.
+func (db *JSONTokenDatabase) CreateAPIToken(token *APIToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.tokens[token.Token]; exists {
+		return fmt.Errorf("api token already exists")
+	}
+	db.tokens[token.Token] = token
+	return db.saveTokens()
+}
+
+// UpdateAPIToken updates an existing token record (e.g. to revoke it).
+func (db *JSONTokenDatabase) UpdateAPIToken(token *APIToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.tokens[token.Token]; !exists {
+		return fmt.Errorf("api token not found: %s", token.ID)
+	}
+	db.tokens[token.Token] = token
+	return db.saveTokens()
+}
+
+// RecordAPITokenUse sets a token's LastUsedAt. The stored record is replaced by an updated copy,
+// because requests authenticated with the token may be reading it.
+func (db *JSONTokenDatabase) RecordAPITokenUse(token string, usedAt time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	record, exists := db.tokens[token]
+	if !exists {
+		return fmt.Errorf("api token not found")
+	}
+	updated := *record
+	updated.LastUsedAt = &usedAt
+	db.tokens[token] = &updated
+	return db.saveTokens()
+}
+
+// DeleteAPIToken removes a token record.
+func (db *JSONTokenDatabase) DeleteAPIToken(token string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.tokens[token]; !exists {
+		return fmt.Errorf("api token not found")
+	}
+	delete(db.tokens, token)
+	return db.saveTokens()
+}
+
+// Close closes the database connection (no action needed for JSON file).
+func (db *JSONTokenDatabase) Close() error {
+	return nil // No resources to close for JSON file DB
+}
+
+// loadTokens loads tokens from the JSON file.
+func (db *JSONTokenDatabase) loadTokens() error {
+	if _, err := os.Stat(db.filepath); os.IsNotExist(err) {
+		return nil // File doesn't exist, assume empty DB
+	}
+
+	file, err := os.Open(db.filepath)
+	if err != nil {
+		return fmt.Errorf("failed to open token database file: %w", err)
+	}
+	defer file.Close()
+
+	var tokens []*APIToken // Slice to hold tokens from JSON
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&tokens); err != nil {
+		return fmt.Errorf("failed to decode token database: %w", err)
+	}
+
+	db.tokens = make(map[string]*APIToken) // Initialize map
+	for _, t := range tokens {
+		if t.ID == "" {
+			t.ID = uuid.New().String() // Tokens stored before IDs existed
+		}
+		if t.Source == "" && len(t.Roles) > 0 {
+			t.Source = AuthProviderExternal // Stored before sources existed; only external identities had roles
+		}
+		db.tokens[t.Token] = t // Populate map for efficient lookup
+	}
+	return nil
+}
+
+// saveTokens saves tokens to the JSON file. Callers must hold db.mu for writing, so saves are
+// serialized and the file always holds the latest state.
+func (db *JSONTokenDatabase) saveTokens() error {
+	tokensSlice := make([]*APIToken, 0, len(db.tokens))
+	for _, token := range db.tokens {
+		tokensSlice = append(tokensSlice, token)
+	}
+
+	if err := writeJSONFileAtomicMode(db.filepath, tokensSlice, 0600); err != nil { // Tokens are secrets
+		return fmt.Errorf("failed to write token database file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+// internal/security/token_test.go - Tests of the API token lifecycle.
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITokenLifecycle(t *testing.T) {
+	s := newTestServer(t, nil)
+	const password = "Owner-Pass-123"
+
+	tests := []struct {
+		name       string
+		prepare    func(t *testing.T, username string, created CreateAPITokenResponse)
+		wantStatus int
+	}{
+		{"active token", nil, http.StatusNotFound}, // Authenticated, the release just does not exist
+		{"revoked by ID", func(t *testing.T, username string, created CreateAPITokenResponse) {
+			req := s.request(http.MethodDelete, "/api/v1/auth/token/"+created.TokenID, nil)
+			req.SetBasicAuth(username, password)
+			if resp := s.do(req); resp.Code != http.StatusNoContent {
+				t.Fatalf("revoking token = %d: %s", resp.Code, resp.Body)
+			}
+		}, http.StatusUnauthorized},
+		{"revoked by name", func(t *testing.T, username string, created CreateAPITokenResponse) {
+			req := s.request(http.MethodDelete, "/api/v1/auth/token/by-name/"+created.Name, nil)
+			req.SetBasicAuth(username, password)
+			if resp := s.do(req); resp.Code != http.StatusNoContent {
+				t.Fatalf("revoking token by name = %d: %s", resp.Code, resp.Body)
+			}
+		}, http.StatusUnauthorized},
+		{"expired", func(t *testing.T, username string, created CreateAPITokenResponse) {
+			record, err := s.tokenDB.GetAPIToken(created.APIKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expired := *record
+			past := time.Now().Add(-time.Minute)
+			expired.ExpiresAt = &past
+			if err := s.tokenDB.UpdateAPIToken(&expired); err != nil {
+				t.Fatal(err)
+			}
+		}, http.StatusUnauthorized},
+		{"owner disabled", func(t *testing.T, username string, created CreateAPITokenResponse) {
+			req := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/users/"+username+"/status", map[string]bool{"enabled": false}))
+			if resp := s.do(req); resp.Code != http.StatusOK {
+				t.Fatalf("disabling owner = %d: %s", resp.Code, resp.Body)
+			}
+		}, http.StatusUnauthorized},
+		{"owner deleted", func(t *testing.T, username string, created CreateAPITokenResponse) {
+			if resp := s.do(asAdmin(s.request(http.MethodDelete, "/api/v1/admin/users/"+username, nil))); resp.Code != http.StatusNoContent {
+				t.Fatalf("deleting owner = %d: %s", resp.Code, resp.Body)
+			}
+			if _, err := s.tokenDB.GetAPIToken(created.APIKey); err == nil {
+				t.Error("token of the deleted user is still stored")
+			}
+		}, http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username := "owner" + string(rune('a'+i))
+			s.createUser(username, password, "user")
+			req := s.request(http.MethodPost, "/api/v1/auth/token", CreateAPITokenRequest{Name: "ci", Description: "build pipeline"})
+			req.SetBasicAuth(username, password)
+			resp := s.do(req)
+			if resp.Code != http.StatusCreated {
+				t.Fatalf("creating token = %d: %s", resp.Code, resp.Body)
+			}
+			var created CreateAPITokenResponse
+			decodeResponse(t, resp, &created)
+			if created.APIKey == "" || created.TokenID == "" || created.Name != "ci" {
+				t.Fatalf("created token = %+v, want a key, an ID and the name ci", created)
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(t, username, created)
+			}
+			download := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), created.APIKey)
+			if resp := s.do(download); resp.Code != tt.wantStatus {
+				t.Errorf("request with token = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		download := withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), "not-a-token")
+		if resp := s.do(download); resp.Code != http.StatusUnauthorized {
+			t.Errorf("request with unknown token = %d, want %d", resp.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestAPITokenNamesAreUnique(t *testing.T) {
+	s := newTestServer(t, nil)
+	create := func() int {
+		return s.do(asAdmin(s.request(http.MethodPost, "/api/v1/auth/token", CreateAPITokenRequest{Name: "deploy"}))).Code
+	}
+	if code := create(); code != http.StatusCreated {
+		t.Fatalf("first token = %d, want %d", code, http.StatusCreated)
+	}
+	if code := create(); code != http.StatusConflict {
+		t.Errorf("second token with the same name = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestAPITokensPersist(t *testing.T) {
+	s := newTestServer(t, nil)
+	apiKey := s.token("admin", testAdminPassword)
+
+	reopened, err := NewJSONTokenDatabase(filepath.Join(s.cfg.DataPath, "tokens.json"))
+	if err != nil {
+		t.Fatalf("failed to reopen token database: %v", err)
+	}
+	record, err := reopened.GetAPIToken(apiKey)
+	if err != nil {
+		t.Fatalf("token not found after reopening: %v", err)
+	}
+	if record.Username != "admin" || record.Revoked {
+		t.Errorf("reopened token = %+v, want an active token of admin", record)
+	}
+}
+
+// createNamedToken creates an API token with a name and description for a user and returns the response.
+func (s *testServer) createNamedToken(username string, password string, name string, description string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := s.request(http.MethodPost, "/api/v1/auth/token", CreateAPITokenRequest{Name: name, Description: description})
+	return s.do(withBasicAuth(req, username, password))
+}
+
+// listTokens lists a user's own API tokens.
+func (s *testServer) listTokens(username string, password string) ([]APITokenInfo, *httptest.ResponseRecorder) {
+	s.t.Helper()
+	resp := s.do(withBasicAuth(s.request(http.MethodGet, "/api/v1/auth/tokens", nil), username, password))
+	if resp.Code != http.StatusOK {
+		s.t.Fatalf("listing tokens of %s = %d: %s", username, resp.Code, resp.Body)
+	}
+	var tokens []APITokenInfo
+	decodeResponse(s.t, resp, &tokens)
+	return tokens, resp
+}
+
+func TestNamedAPITokens(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	s.createUser("bob", "Bob-Pass-12345", "user")
+
+	tests := []struct {
+		name        string
+		username    string
+		password    string
+		tokenName   string
+		description string
+		wantStatus  int
+	}{
+		{"first name", "alice", "Alice-Pass-123", "ci", "GitHub Actions", http.StatusCreated},
+		{"second name", "alice", "Alice-Pass-123", "deploy", "Release pipeline", http.StatusCreated},
+		{"name taken by the same user", "alice", "Alice-Pass-123", "ci", "", http.StatusConflict},
+		{"name taken by another user", "bob", "Bob-Pass-12345", "ci", "Laptop", http.StatusCreated},
+		{"name too long", "alice", "Alice-Pass-123", strings.Repeat("n", maxTokenNameLength+1), "", http.StatusBadRequest},
+		{"description too long", "alice", "Alice-Pass-123", "long", strings.Repeat("d", maxTokenDescriptionLength+1), http.StatusBadRequest},
+	}
+	secrets := []string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.createNamedToken(tt.username, tt.password, tt.tokenName, tt.description)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("creating token %q = %d, want %d: %s", tt.tokenName, resp.Code, tt.wantStatus, resp.Body)
+			}
+			if resp.Code == http.StatusCreated {
+				var created CreateAPITokenResponse
+				decodeResponse(t, resp, &created)
+				secrets = append(secrets, created.APIKey)
+			}
+		})
+	}
+
+	tokens, resp := s.listTokens("alice", "Alice-Pass-123")
+	got := map[string]string{}
+	for _, token := range tokens {
+		if token.Username != "alice" {
+			t.Errorf("alice's listing includes a token of %s", token.Username)
+		}
+		got[token.Name] = token.Description
+	}
+	if want := map[string]string{"ci": "GitHub Actions", "deploy": "Release pipeline"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice's tokens = %v, want %v", got, want)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(resp.Body.String(), secret) {
+			t.Errorf("token listing reveals a secret: %s", resp.Body)
+		}
+	}
+}
+
+func TestLabeledAPITokenListing(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "user")
+	before := time.Now()
+	keys := map[string]string{} // Label -> secret
+	for _, label := range []string{"laptop", "ci"} {
+		resp := s.createNamedToken("alice", "Alice-Pass-123", label, "")
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("creating %s = %d: %s", label, resp.Code, resp.Body)
+		}
+		var created CreateAPITokenResponse
+		decodeResponse(t, resp, &created)
+		keys[label] = created.APIKey
+	}
+
+	tokens, _ := s.listTokens("alice", "Alice-Pass-123")
+	if len(tokens) != 2 {
+		t.Fatalf("listing has %d tokens, want 2", len(tokens))
+	}
+	for _, token := range tokens {
+		key, ok := keys[token.Name]
+		if !ok {
+			t.Errorf("listed token %q was not created", token.Name)
+			continue
+		}
+		if token.Prefix == "" || !strings.HasPrefix(key, token.Prefix) || token.Prefix == key {
+			t.Errorf("prefix of %s = %q, want the start of its key only", token.Name, token.Prefix)
+		}
+		if token.CreatedAt.Before(before.Add(-time.Second)) || token.CreatedAt.After(time.Now()) {
+			t.Errorf("created at of %s = %v, want the creation time", token.Name, token.CreatedAt)
+		}
+		if token.LastUsedAt != nil || token.Revoked {
+			t.Errorf("new token %s = %+v, want unused and active", token.Name, token)
+		}
+	}
+
+	revoke := withBasicAuth(s.request(http.MethodDelete, "/api/v1/auth/token/by-name/laptop", nil), "alice", "Alice-Pass-123")
+	if resp := s.do(revoke); resp.Code != http.StatusNoContent {
+		t.Fatalf("revoking by label = %d: %s", resp.Code, resp.Body)
+	}
+	tokens, _ = s.listTokens("alice", "Alice-Pass-123")
+	if len(tokens) != 2 {
+		t.Fatalf("listing after revoking has %d tokens, want both", len(tokens))
+	}
+	for _, token := range tokens {
+		if token.Revoked != (token.Name == "laptop") {
+			t.Errorf("token %s revoked = %v, want only laptop revoked", token.Name, token.Revoked)
+		}
+	}
+	if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), keys["laptop"])); resp.Code != http.StatusUnauthorized {
+		t.Errorf("request with the revoked key = %d, want 401", resp.Code)
+	}
+}
+
+func TestAPITokenLastUsed(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) { cfg.TokenStaleDays = 30 })
+	apiKey := s.token("admin", testAdminPassword)
+	use := func() *APIToken {
+		t.Helper()
+		s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), apiKey))
+		record, err := s.tokenDB.GetAPIToken(apiKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+	listed := func() APITokenInfo {
+		t.Helper()
+		tokens, _ := s.listTokens("admin", testAdminPassword)
+		if len(tokens) != 1 {
+			t.Fatalf("listing has %d tokens, want 1", len(tokens))
+		}
+		return tokens[0]
+	}
+	if info := listed(); info.LastUsedAt != nil || info.Stale {
+		t.Fatalf("unused token = %+v, want no last use and not stale", info)
+	}
+
+	first := use()
+	if first.LastUsedAt == nil || time.Since(*first.LastUsedAt) > time.Minute {
+		t.Fatalf("last used at after the first use = %v, want now", first.LastUsedAt)
+	}
+	if again := use(); !again.LastUsedAt.Equal(*first.LastUsedAt) {
+		t.Errorf("last used at moved from %v to %v within a minute, want the write throttled", first.LastUsedAt, again.LastUsedAt)
+	}
+
+	longAgo := time.Now().Add(-31 * 24 * time.Hour)
+	if err := s.tokenDB.RecordAPITokenUse(apiKey, longAgo); err != nil {
+		t.Fatal(err)
+	}
+	if info := listed(); !info.Stale || !info.LastUsedAt.Equal(longAgo) {
+		t.Errorf("token last used %v = %+v, want it listed as stale", longAgo, info)
+	}
+	if later := use(); !later.LastUsedAt.After(longAgo.Add(time.Minute)) {
+		t.Errorf("last used at = %v, want it advanced past %v", later.LastUsedAt, longAgo)
+	}
+	if info := listed(); info.Stale || info.LastUsedAt == nil || time.Since(*info.LastUsedAt) > time.Minute {
+		t.Errorf("token after a new use = %+v, want it listed as recently used", info)
+	}
+}
+
+func TestAPITokenCleanup(t *testing.T) {
+	s := newTestServer(t, nil)
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	for _, token := range []*APIToken{
+		{ID: "active", Token: "active-secret", Username: "admin", CreatedAt: now},
+		{ID: "expiring", Token: "expiring-secret", Username: "admin", CreatedAt: now, ExpiresAt: &future},
+		{ID: "expired", Token: "expired-secret", Username: "admin", CreatedAt: now, ExpiresAt: &past},
+		{ID: "revoked", Token: "revoked-secret", Username: "admin", CreatedAt: now, Revoked: true},
+	} {
+		if err := s.tokenDB.CreateAPIToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remaining := func() []string {
+		t.Helper()
+		tokens, err := s.tokenDB.ListAPITokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			ids = append(ids, token.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	<-s.authService.StartTokenCleanup(context.Background(), 0) // Disabled, so nothing is purged
+	if got := remaining(); len(got) != 4 {
+		t.Fatalf("tokens after a disabled cleanup = %v, want all 4", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := s.authService.StartTokenCleanup(ctx, time.Hour) // Sweeps once right away, before watching ctx
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("token cleanup did not stop after its context was cancelled")
+	}
+	if got, want := remaining(), []string{"active", "expiring"}; !slices.Equal(got, want) {
+		t.Errorf("tokens after one sweep = %v, want %v", got, want)
+	}
+	if purged, err := s.authService.PurgeAPITokens(now); err != nil || purged != 0 {
+		t.Errorf("second sweep purged %d tokens (%v), want 0", purged, err)
+	}
+}
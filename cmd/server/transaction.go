@@ -0,0 +1,56 @@
+// internal/repository/transaction.go - Compensating transactions for multi-step mutations.
+//
+// This file implements a lightweight transaction helper that records compensating
+// actions (delete a stored file, restore metadata) while a mutation spanning the
+// metadata database and the file system progresses, and runs them on failure.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Transaction records compensating actions for the steps of a multi-step mutation.
+// On Rollback the actions run in reverse order so earlier steps are undone last.
+type Transaction struct {
+	compensations []func() error
+	finished      bool
+}
+
+// NewTransaction creates a new Transaction instance.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// OnRollback registers the action that undoes the step just performed.
+func (tx *Transaction) OnRollback(compensation func() error) {
+	tx.compensations = append(tx.compensations, compensation)
+}
+
+// Commit marks the transaction as successful; registered compensations are discarded.
+func (tx *Transaction) Commit() {
+	tx.finished = true
+	tx.compensations = nil
+}
+
+// Rollback runs the registered compensations in reverse order and returns cause,
+// joined with any compensation failures so partial rollbacks are never silent.
+func (tx *Transaction) Rollback(cause error) error {
+	if tx.finished {
+		return cause
+	}
+	tx.finished = true
+
+	var rollbackErrs []error
+	for i := len(tx.compensations) - 1; i >= 0; i-- {
+		if err := tx.compensations[i](); err != nil {
+			rollbackErrs = append(rollbackErrs, err)
+		}
+	}
+	tx.compensations = nil
+
+	if len(rollbackErrs) > 0 {
+		return fmt.Errorf("%w (rollback incomplete: %v)", cause, errors.Join(rollbackErrs...))
+	}
+	return cause
+}
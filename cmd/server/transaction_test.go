@@ -0,0 +1,212 @@
+// internal/repository/transaction_test.go - Tests of compensating transactions for multi-step mutations.
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	cause := errors.New("step failed")
+	var undone []string
+	step := func(name string, err error) func() error {
+		return func() error {
+			undone = append(undone, name)
+			return err
+		}
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		undone = nil
+		tx := NewTransaction()
+		tx.OnRollback(step("first", nil))
+		tx.OnRollback(step("second", nil))
+		if err := tx.Rollback(cause); err != cause {
+			t.Errorf("Rollback = %v, want the cause", err)
+		}
+		if !slices.Equal(undone, []string{"second", "first"}) {
+			t.Errorf("compensations ran as %v, want in reverse order", undone)
+		}
+		if tx.Rollback(cause); len(undone) != 2 {
+			t.Error("a second rollback ran the compensations again")
+		}
+	})
+	t.Run("failed compensation", func(t *testing.T) {
+		undone = nil
+		tx := NewTransaction()
+		tx.OnRollback(step("first", nil))
+		tx.OnRollback(step("second", errors.New("cannot undo")))
+		err := tx.Rollback(cause)
+		if !errors.Is(err, cause) || !strings.Contains(err.Error(), "cannot undo") {
+			t.Errorf("Rollback = %v, want the cause and the compensation failure", err)
+		}
+		if len(undone) != 2 {
+			t.Errorf("compensations ran as %v, want the rest to run after a failure", undone)
+		}
+	})
+	t.Run("commit", func(t *testing.T) {
+		undone = nil
+		tx := NewTransaction()
+		tx.OnRollback(step("first", nil))
+		tx.Commit()
+		if err := tx.Rollback(cause); err != cause || len(undone) != 0 {
+			t.Errorf("Rollback after Commit = %v and ran %v, want the cause and nothing undone", err, undone)
+		}
+	})
+}
+
+// errInjected is the failure faultyReleaseDB injects.
+var errInjected = errors.New("injected failure")
+
+// faultyReleaseDB is a release database failing at one step. Steps that write files first write
+// them, as a write failing halfway may leave a partial file behind.
+type faultyReleaseDB struct {
+	ReleaseDatabase
+	failAt string
+}
+
+func (db *faultyReleaseDB) StoreReleaseFile(repoPath string, tgz io.Reader, metadata *ReleaseMetadata, checksumAlgorithms []string) (*StoredReleaseFile, error) {
+	if db.failAt == "file" {
+		return nil, errInjected
+	}
+	return db.ReleaseDatabase.StoreReleaseFile(repoPath, tgz, metadata, checksumAlgorithms)
+}
+
+func (db *faultyReleaseDB) StoreReleaseSBOM(repoPath string, metadata *ReleaseMetadata, sbom []byte) error {
+	if err := db.ReleaseDatabase.StoreReleaseSBOM(repoPath, metadata, sbom); err != nil || db.failAt != "sbom" {
+		return err
+	}
+	return errInjected
+}
+
+func (db *faultyReleaseDB) StoreReleaseSignature(repoPath string, metadata *ReleaseMetadata, signature []byte) error {
+	if err := db.ReleaseDatabase.StoreReleaseSignature(repoPath, metadata, signature); err != nil || db.failAt != "signature" {
+		return err
+	}
+	return errInjected
+}
+
+func (db *faultyReleaseDB) CreateReleaseMetadata(metadata *ReleaseMetadata) error {
+	if db.failAt == "metadata" {
+		return errInjected
+	}
+	return db.ReleaseDatabase.CreateReleaseMetadata(metadata)
+}
+
+func (db *faultyReleaseDB) UpdateReleaseMetadata(metadata *ReleaseMetadata) error {
+	if db.failAt == "metadata" {
+		return errInjected
+	}
+	return db.ReleaseDatabase.UpdateReleaseMetadata(metadata)
+}
+
+func (db *faultyReleaseDB) DeleteReleaseMetadata(softwareName string, version string) error {
+	if db.failAt == "delete metadata" {
+		return errInjected
+	}
+	return db.ReleaseDatabase.DeleteReleaseMetadata(softwareName, version)
+}
+
+func (db *faultyReleaseDB) DeleteReleaseFile(repoPath string, metadata *ReleaseMetadata) error {
+	if db.failAt == "delete file" {
+		return errInjected
+	}
+	return db.ReleaseDatabase.DeleteReleaseFile(repoPath, metadata)
+}
+
+// repositorySnapshot returns the content of every file in the repository, keyed by path.
+func repositorySnapshot(t *testing.T, repoPath string) map[string]string {
+	t.Helper()
+	snapshot := map[string]string{}
+	for _, name := range listFiles(t, repoPath) {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot[name] = string(content)
+	}
+	return snapshot
+}
+
+// uploadThrough uploads a release with an SBOM and a signature through releaseService.
+func uploadThrough(t *testing.T, releaseService *ReleaseService, version string, content string, overwrite bool) error {
+	t.Helper()
+	sourceFile := filepath.Join(t.TempDir(), "release.bin")
+	if err := os.WriteFile(sourceFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := newTGZArchiveReader(sourceFile)
+	defer archive.Close()
+	metadata := ReleaseMetadata{SoftwareName: "MyApp", Version: version}
+	return releaseService.UploadRelease(archive, int64(len(content)), metadata, []byte(testCycloneDXSBOM), []byte("signature of "+content), overwrite)
+}
+
+func TestUploadRollsBackEveryStep(t *testing.T) {
+	for _, failAt := range []string{"file", "sbom", "signature", "metadata"} {
+		for _, overwrite := range []bool{false, true} {
+			name := "new release failing at " + failAt
+			if overwrite {
+				name = "replacement failing at " + failAt
+			}
+			t.Run(name, func(t *testing.T) {
+				s := newTestServer(t, nil)
+				if err := uploadThrough(t, s.releaseService, "1.0.0", "original", false); err != nil {
+					t.Fatal(err)
+				}
+				version := "1.1.0"
+				if overwrite {
+					version = "1.0.0"
+				}
+				filesBefore := repositorySnapshot(t, s.cfg.RepositoryPath)
+				releasesBefore, err := s.releaseDB.ListAllReleasesMetadata()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				faulty := NewReleaseService(s.cfg, &faultyReleaseDB{ReleaseDatabase: s.releaseDB, failAt: failAt}, s.packageDB, discardLogger())
+				if err := uploadThrough(t, faulty, version, "replacement", overwrite); !errors.Is(err, errInjected) {
+					t.Fatalf("upload = %v, want the injected failure", err)
+				}
+				if files := repositorySnapshot(t, s.cfg.RepositoryPath); !reflect.DeepEqual(files, filesBefore) {
+					t.Errorf("repository files = %v, want them unchanged: %v", files, filesBefore)
+				}
+				if releases, err := s.releaseDB.ListAllReleasesMetadata(); err != nil || !reflect.DeepEqual(releases, releasesBefore) {
+					t.Errorf("release metadata = %+v, %v; want it unchanged: %+v", releases, err, releasesBefore)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteRollsBackEveryStep(t *testing.T) {
+	for _, failAt := range []string{"delete metadata", "delete file"} {
+		t.Run("failing at "+failAt, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			if err := uploadThrough(t, s.releaseService, "1.0.0", "original", false); err != nil {
+				t.Fatal(err)
+			}
+			filesBefore := repositorySnapshot(t, s.cfg.RepositoryPath)
+			before, err := s.releaseDB.GetReleaseMetadata("MyApp", "1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			faulty := NewReleaseService(s.cfg, &faultyReleaseDB{ReleaseDatabase: s.releaseDB, failAt: failAt}, s.packageDB, discardLogger())
+			if err := faulty.DeleteRelease("MyApp", "1.0.0"); !errors.Is(err, errInjected) {
+				t.Fatalf("delete = %v, want the injected failure", err)
+			}
+			if files := repositorySnapshot(t, s.cfg.RepositoryPath); !reflect.DeepEqual(files, filesBefore) {
+				t.Errorf("repository files = %v, want them unchanged: %v", files, filesBefore)
+			}
+			if after, err := s.releaseDB.GetReleaseMetadata("MyApp", "1.0.0"); err != nil || !reflect.DeepEqual(after, before) {
+				t.Errorf("release metadata = %+v, %v; want it restored: %+v", after, err, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+// api/upload_test.go - Tests of release uploads.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadRelease(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("publisher", "Publisher-Pass-1", "publisher")
+	s.createUser("reader", "Reader-Pass-123", "user")
+	publisherToken := s.token("publisher", "Publisher-Pass-1")
+	readerToken := s.token("reader", "Reader-Pass-123")
+
+	// The steps run in order against the same server, so later steps see earlier uploads.
+	tests := []struct {
+		name         string
+		token        string
+		softwareName string
+		version      string
+		fields       map[string]string
+		wantStatus   int
+	}{
+		{"without publisher role", readerToken, "MyApp", "1.0.0", nil, http.StatusForbidden},
+		{"without token", "", "MyApp", "1.0.0", nil, http.StatusUnauthorized},
+		{"first release", publisherToken, "MyApp", "1.0.0", nil, http.StatusCreated},
+		{"duplicate version", publisherToken, "MyApp", "1.0.0", nil, http.StatusConflict},
+		{"duplicate version in other casing", publisherToken, "myapp", "1.0.0", nil, http.StatusConflict},
+		{"new version in other casing", publisherToken, "MYAPP", "1.1.0", nil, http.StatusCreated},
+		{"overwrite existing version", publisherToken, "MyApp", "1.0.0", map[string]string{"overwrite": "true"}, http.StatusCreated},
+		{"invalid overwrite value", publisherToken, "MyApp", "1.0.0", map[string]string{"overwrite": "maybe"}, http.StatusBadRequest},
+		{"pre-release version", publisherToken, "MyApp", "2.0.0-rc.1", nil, http.StatusCreated},
+		{"leading zero in version", publisherToken, "MyApp", "01.0.0", nil, http.StatusBadRequest},
+		{"non-numeric version", publisherToken, "MyApp", "latest", nil, http.StatusBadRequest},
+		{"missing version", publisherToken, "MyApp", "", nil, http.StatusBadRequest},
+		{"traversal in version", publisherToken, "MyApp", "../1.0.0", nil, http.StatusBadRequest},
+		{"traversal in software name", publisherToken, "../MyApp", "1.0.0", nil, http.StatusBadRequest},
+		{"missing software name", publisherToken, "", "1.0.0", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.upload(tt.token, tt.softwareName, tt.version, []byte("release content"), tt.fields)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("upload of %q %q = %d, want %d: %s", tt.softwareName, tt.version, resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+
+	release, err := s.releaseService.GetRelease("myapp", "1.1.0")
+	if err != nil {
+		t.Fatalf("release uploaded as MYAPP not found: %v", err)
+	}
+	if release.SoftwareName != "MyApp" {
+		t.Errorf("software name = %q, want the first upload's casing %q", release.SoftwareName, "MyApp")
+	}
+	if release.UploadedBy != "publisher" {
+		t.Errorf("uploaded by = %q, want %q", release.UploadedBy, "publisher")
+	}
+}
+
+func TestUploadOverwriteKeepsReleaseID(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("first"))
+	original, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := s.upload(token, "MyApp", "1.0.0", []byte("second and longer"), map[string]string{"overwrite": "true"}); resp.Code != http.StatusCreated {
+		t.Fatalf("overwrite upload = %d: %s", resp.Code, resp.Body)
+	}
+	replaced, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.ID != original.ID {
+		t.Errorf("release ID changed from %s to %s", original.ID, replaced.ID)
+	}
+	if replaced.Checksum == original.Checksum {
+		t.Errorf("checksum %s did not change after the release was replaced", replaced.Checksum)
+	}
+}
+
+func TestUploadRequiresPublisherRole(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("reader", "Reader-Pass-123", "user")
+	s.createUser("publisher", "Publisher-Pass-1", "publisher")
+	s.createUser("former", "Former-Pass-123", "publisher")
+	tokens := map[string]string{
+		"reader":    s.token("reader", "Reader-Pass-123"),
+		"publisher": s.token("publisher", "Publisher-Pass-1"),
+		"former":    s.token("former", "Former-Pass-123"),
+		"admin":     s.token("admin", testAdminPassword),
+	}
+	if err := s.userService.EnableDisableUser("former", false); err != nil { // The token stays, but its owner is looked up on every use
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		version    string
+		wantStatus int
+	}{
+		{"plain user", "reader", "1.0.0", http.StatusForbidden},
+		{"publisher", "publisher", "1.0.0", http.StatusCreated},
+		{"administrator", "admin", "1.1.0", http.StatusCreated},
+		{"disabled publisher", "former", "1.2.0", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.upload(tokens[tt.username], "MyApp", tt.version, []byte("release content"), nil)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("upload by %s = %d, want %d: %s", tt.username, resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+	if _, err := s.releaseService.GetRelease("MyApp", "1.2.0"); err == nil {
+		t.Error("the disabled publisher's upload was stored")
+	}
+}
+
+func TestUploadMutableVersions(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.PackageSettings = map[string]PackageSettings{"MyApp": {MutableVersions: []string{"nightly", "snapshot-*"}}}
+	})
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "nightly", []byte("first nightly"))
+	s.mustUpload(token, "MyApp", "snapshot-1", []byte("first snapshot"))
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("first 1.0.0"))
+
+	tests := []struct {
+		name         string
+		softwareName string
+		version      string
+		wantStatus   int
+	}{
+		{"nightly replaced", "MyApp", "nightly", http.StatusCreated},
+		{"version matching a pattern replaced", "MyApp", "snapshot-1", http.StatusCreated},
+		{"numbered release stays immutable", "MyApp", "1.0.0", http.StatusConflict},
+		{"nightly of a package without mutable versions", "Other", "nightly", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := s.releaseService.GetRelease(tt.softwareName, tt.version)
+			resp := s.upload(token, tt.softwareName, tt.version, []byte("second "+tt.version), nil)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("re-upload of %s %s = %d, want %d: %s", tt.softwareName, tt.version, resp.Code, tt.wantStatus, resp.Body)
+			}
+			if before == nil {
+				return
+			}
+			after, err := s.releaseService.GetRelease(tt.softwareName, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced := after.Checksum != before.Checksum; replaced != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("checksum changed from %s to %s, want a change only when the upload is accepted", before.Checksum, after.Checksum)
+			}
+		})
+	}
+}
+
+// listFiles returns the paths of the regular files under dir.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestUploadRejectsMalformedVersions(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+
+	for _, version := range []string{"abc", "1.2", "1.2.", "v1.2.3", "1.2.3-"} {
+		t.Run(version, func(t *testing.T) {
+			resp := s.upload(token, "MyApp", version, []byte("release content"), nil)
+			if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "version") {
+				t.Errorf("upload of version %q = %d %s, want 400 naming the version", version, resp.Code, resp.Body)
+			}
+			if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 0 {
+				t.Errorf("rejected upload left files in the repository: %v", files)
+			}
+			if files := listFiles(t, s.cfg.TempPath); len(files) != 0 {
+				t.Errorf("rejected upload left temporary files: %v", files)
+			}
+			if releases, err := s.releaseDB.ListAllReleasesMetadata(); err != nil || len(releases) != 0 {
+				t.Errorf("rejected upload stored %d releases, %v", len(releases), err)
+			}
+		})
+	}
+
+	// The service validates on its own, for callers other than the upload handler
+	err := s.releaseService.UploadRelease(strings.NewReader("content"), 7, ReleaseMetadata{SoftwareName: "MyApp", Version: "1.2"}, nil, nil, false)
+	if !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("UploadRelease of version 1.2 = %v, want ErrInvalidVersion", err)
+	}
+	if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 0 {
+		t.Errorf("rejected upload left files in the repository: %v", files)
+	}
+}
+
+func TestUploadDuplicateVersion(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("original"))
+	original, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.upload(token, "MyApp", "1.0.0", []byte("duplicate"), nil)
+	if resp.Code != http.StatusConflict || !strings.Contains(resp.Body.String(), "already exists") {
+		t.Errorf("second upload = %d %s, want 409 saying the version already exists", resp.Code, resp.Body)
+	}
+	if stored, err := s.releaseService.GetRelease("MyApp", "1.0.0"); err != nil || stored.Checksum != original.Checksum {
+		t.Errorf("stored release after the duplicate = %+v, %v; want the original", stored, err)
+	}
+
+	// Storage failures other than a duplicate are still server errors
+	if err := os.RemoveAll(s.cfg.RepositoryPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.cfg.RepositoryPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := s.upload(token, "Other", "1.0.0", []byte("release"), nil); resp.Code != http.StatusInternalServerError {
+		t.Errorf("upload into a broken repository = %d, want 500: %s", resp.Code, resp.Body)
+	}
+}
+
+func TestUploadOverwrite(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("original"))
+	original, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	download := func() string {
+		resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("download = %d: %s", resp.Code, resp.Body)
+		}
+		return resp.Body.String()
+	}
+	originalFile := download()
+
+	tests := []struct {
+		name        string
+		overwrite   string
+		wantStatus  int
+		wantChanged bool
+	}{
+		{"overwrite=false", "false", http.StatusConflict, false},
+		{"overwrite=true", "true", http.StatusCreated, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.upload(token, "MyApp", "1.0.0", []byte("replacement of "+tt.name), map[string]string{"overwrite": tt.overwrite})
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("upload = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			stored, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := stored.Checksum != original.Checksum; changed != tt.wantChanged {
+				t.Errorf("checksum changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if changed := download() != originalFile; changed != tt.wantChanged {
+				t.Errorf("downloaded file changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if advanced := stored.ReleaseTimestamp.After(original.ReleaseTimestamp); advanced != tt.wantChanged {
+				t.Errorf("upload timestamp %v advanced past %v = %v, want %v", stored.ReleaseTimestamp, original.ReleaseTimestamp, advanced, tt.wantChanged)
+			}
+			if files := listFiles(t, s.cfg.RepositoryPath); len(files) != 1 {
+				t.Errorf("repository files = %v, want only the release file", files)
+			}
+		})
+	}
+}
+
+func TestUploadRecordsUploader(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	s.mustUpload(s.token("alice", "Alice-Pass-123"), "MyApp", "1.0.0", []byte("release content"))
+
+	var listing PaginatedResponse[*ReleaseMetadata]
+	decodeResponse(t, s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases", nil)), &listing)
+	if len(listing.Items) != 1 || listing.Items[0].UploadedBy != "alice" {
+		t.Errorf("listed releases = %+v, want one uploaded by alice", listing.Items)
+	}
+	var release ReleaseMetadata
+	decodeResponse(t, s.do(s.request(http.MethodGet, "/api/v1/packages/MyApp/releases/1.0.0", nil)), &release)
+	if release.UploadedBy != "alice" {
+		t.Errorf("release uploaded by = %q, want alice", release.UploadedBy)
+	}
+
+	reopened, err := NewJSONReleaseDatabase(filepath.Join(s.cfg.DataPath, "releases.json"), filepath.Join(s.cfg.DataPath, "software_ids.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if stored, err := reopened.GetReleaseMetadata("MyApp", "1.0.0"); err != nil || stored.UploadedBy != "alice" {
+		t.Errorf("persisted release = %+v, %v; want it uploaded by alice", stored, err)
+	}
+}
+
+func TestUploadDuringShutdown(t *testing.T) {
+	t.Run("rejected once shutdown begins", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		token := s.token("admin", testAdminPassword)
+		s.mustUpload(token, "MyApp", "1.0.0", []byte("release 1.0.0"))
+		s.shutdownState.Begin()
+
+		if resp := s.upload(token, "MyApp", "1.1.0", []byte("release 1.1.0"), nil); resp.Code != http.StatusServiceUnavailable {
+			t.Errorf("upload after shutdown began = %d, want 503: %s", resp.Code, resp.Body)
+		}
+		if _, err := s.releaseService.GetRelease("MyApp", "1.1.0"); err == nil {
+			t.Error("rejected release was stored")
+		}
+		if files := listFiles(t, s.cfg.TempPath); len(files) != 0 {
+			t.Errorf("rejected upload left temporary files %v", files)
+		}
+		if resp := s.do(withToken(s.request(http.MethodGet, "/api/v1/releases/MyApp/1.0.0", nil), token)); resp.Code != http.StatusOK {
+			t.Errorf("download during shutdown = %d, want 200", resp.Code)
+		}
+	})
+
+	t.Run("in-flight upload finishes", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		token := s.token("admin", testAdminPassword)
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("software_name", "MyApp")
+		form.WriteField("version", "1.0.0")
+		part, _ := form.CreateFormFile("file", "release.bin")
+		part.Write(bytes.Repeat([]byte("x"), 64*1024))
+		form.Close()
+
+		reader, writer := io.Pipe()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/releases", reader)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- s.do(withToken(req, token)) }()
+
+		half := body.Len() / 2
+		if _, err := writer.Write(body.Bytes()[:half]); err != nil { // Returns once the handler reads the body
+			t.Fatal(err)
+		}
+		s.shutdownState.Begin()
+		writer.Write(body.Bytes()[half:])
+		writer.Close()
+		if resp := <-done; resp.Code != http.StatusCreated {
+			t.Errorf("upload in flight when shutdown began = %d, want 201: %s", resp.Code, resp.Body)
+		}
+	})
+
+	t.Run("rejection disabled", func(t *testing.T) {
+		s := newTestServer(t, func(cfg *Config) { cfg.RejectUploadsOnShutdown = false })
+		token := s.token("admin", testAdminPassword)
+		s.shutdownState.Begin()
+		if resp := s.upload(token, "MyApp", "1.0.0", []byte("release 1.0.0"), nil); resp.Code != http.StatusCreated {
+			t.Errorf("upload during shutdown with rejection disabled = %d, want 201: %s", resp.Code, resp.Body)
+		}
+	})
+}
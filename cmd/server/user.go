@@ -5,17 +5,59 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 	"sync"
 )
 
+// ErrInvalidUsername is returned when a new user's name is empty or contains disallowed characters.
+var ErrInvalidUsername = errors.New("invalid username")
+
+// ErrUserExists is returned when creating a user whose username is already taken.
+var ErrUserExists = errors.New("user already exists")
+
+// ErrInvalidRole is returned when a new user is given a role the server does not know.
+var ErrInvalidRole = errors.New("invalid role")
+
+// knownRoles lists the roles a user record may hold.
+var knownRoles = []string{"administrator", "publisher", "user"}
+
+// usernamePattern allows 1-64 letters, digits, '.', '_' and '-', starting with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
 // User represents a user in the system.
 type User struct {
 	Username     string   `json:"username"`
 	PasswordHash string   `json:"password_hash"`
 	Roles        []string `json:"roles"`
 	Enabled      bool     `json:"enabled"`
+	CreatedBy    string   `json:"created_by,omitempty"` // Administrator who created the user, empty for the default admin and users created with the useradd command
+
+	MustChangePassword bool `json:"must_change_password,omitempty"` // Only the password change endpoint is allowed until the password is updated
+}
+
+// ValidateUsername checks that a username is non-empty and uses only allowed characters.
+func ValidateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("%w: username is required", ErrInvalidUsername)
+	}
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("%w: %q must be 1-64 letters, digits, '.', '_' or '-' and start with a letter or digit", ErrInvalidUsername, username)
+	}
+	return nil
+}
+
+// ValidateRoles checks that every role is one of knownRoles.
+func ValidateRoles(roles []string) error {
+	for _, role := range roles {
+		if !slices.Contains(knownRoles, role) {
+			return fmt.Errorf("%w: %q, expected one of %v", ErrInvalidRole, role, knownRoles)
+		}
+	}
+	return nil
 }
 
 // UserDatabase interface defines operations for user management.
@@ -23,7 +65,7 @@ type UserDatabase interface {
 	GetUserByUsername(username string) (*User, error)
 	ListUsers() ([]*User, error)
 	CreateUser(user *User) error
-	UpdateUserPassword(username string, newPasswordHash string) error
+	UpdateUserPassword(username string, newPasswordHash string, mustChangePassword bool) error
 	DeleteUser(username string) error
 	EnableDisableUser(username string, enabled bool) error
 	Close() error
@@ -42,6 +84,9 @@ func NewJSONUserDatabase(filepath string) (*JSONUserDatabase, error) {
 		filepath: filepath,
 		users:    make(map[string]*User),
 	}
+	if err := ensureDatabaseDirExists(filepath); err != nil {
+		return nil, err
+	}
 	if err := db.loadUsers(); err != nil {
 		return nil, err
 	}
@@ -63,7 +108,7 @@ func (db *JSONUserDatabase) GetUserByUsername(username string) (*User, error) {
 func (db *JSONUserDatabase) ListUsers() ([]*User, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	var userList []*User
+	userList := make([]*User, 0, len(db.users))
 	for _, u := range db.users {
 		userList = append(userList, u)
 	}
@@ -73,46 +118,52 @@ func (db *JSONUserDatabase) ListUsers() ([]*User, error) {
 // CreateUser creates a new user.
 func (db *JSONUserDatabase) CreateUser(user *User) error {
 	db.mu.Lock()
+	defer db.mu.Unlock()
 	if _, exists := db.users[user.Username]; exists {
-		return fmt.Errorf("user already exists: %s", user.Username)
+		return fmt.Errorf("%w: %s", ErrUserExists, user.Username)
 	}
 	db.users[user.Username] = user
-	db.mu.Unlock()
 	return db.saveUsers()
 }
 
-// UpdateUserPassword updates a user's password.
-func (db *JSONUserDatabase) UpdateUserPassword(username string, newPasswordHash string) error {
+// UpdateUserPassword updates a user's password and whether it must be changed on next login.
+// The stored record is replaced by an updated copy because authenticating requests may be reading it.
+func (db *JSONUserDatabase) UpdateUserPassword(username string, newPasswordHash string, mustChangePassword bool) error {
 	db.mu.Lock()
+	defer db.mu.Unlock()
 	user, ok := db.users[username]
 	if !ok {
 		return fmt.Errorf("user not found: %s", username)
 	}
-	user.PasswordHash = newPasswordHash
-	db.mu.Unlock()
+	updated := *user
+	updated.PasswordHash = newPasswordHash
+	updated.MustChangePassword = mustChangePassword
+	db.users[username] = &updated
 	return db.saveUsers()
 }
 
 // DeleteUser deletes a user.
 func (db *JSONUserDatabase) DeleteUser(username string) error {
 	db.mu.Lock()
+	defer db.mu.Unlock()
 	if _, exists := db.users[username]; !exists {
 		return fmt.Errorf("user not found: %s", username)
 	}
 	delete(db.users, username)
-	db.mu.Unlock()
 	return db.saveUsers()
 }
 
 // EnableDisableUser enables or disables a user account.
 func (db *JSONUserDatabase) EnableDisableUser(username string, enabled bool) error {
 	db.mu.Lock()
+	defer db.mu.Unlock()
 	usr, ok := db.users[username]
 	if !ok {
 		return fmt.Errorf("user not found: %s", username)
 	}
-	usr.Enabled = enabled
-	db.mu.Unlock()
+	updated := *usr // Copy so authenticating requests never see a half-applied change
+	updated.Enabled = enabled
+	db.users[username] = &updated
 	return db.saveUsers()
 }
 
@@ -146,25 +197,15 @@ func (db *JSONUserDatabase) loadUsers() error {
 	return nil
 }
 
-// saveUsers saves users to the JSON file.
+// saveUsers saves users to the JSON file. Callers must hold db.mu for writing.
 func (db *JSONUserDatabase) saveUsers() error {
-	db.mu.RLock() // Read lock to prevent data race during encoding
 	usersSlice := make([]*User, 0, len(db.users))
 	for _, user := range db.users {
 		usersSlice = append(usersSlice, user)
 	}
-	db.mu.RUnlock()
-
-	file, err := os.Create(db.filepath)
-	if err != nil {
-		return fmt.Errorf("failed to open user database file for writing: %w", err)
-	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ") // Pretty print JSON
-	if err := encoder.Encode(usersSlice); err != nil {
-		return fmt.Errorf("failed to encode user database to JSON: %w", err)
+	if err := writeJSONFileAtomic(db.filepath, usersSlice); err != nil {
+		return fmt.Errorf("failed to write user database file: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,247 @@
+// api/users_test.go - Tests of the user administration endpoints.
+package main
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantError  string // Part of the error message
+		wantRoles  []string
+	}{
+		{"valid", CreateUserRequest{Username: "alice", Password: "Alice-Pass-123", Roles: []string{"publisher"}}, http.StatusCreated, "", []string{"publisher"}},
+		{"empty username", CreateUserRequest{Username: "", Password: "Valid-Pass-123", Roles: []string{"user"}}, http.StatusBadRequest, "username is required", nil},
+		{"username with a space", CreateUserRequest{Username: "bad name", Password: "Valid-Pass-123", Roles: []string{"user"}}, http.StatusBadRequest, "invalid username", nil},
+		{"username with a slash", CreateUserRequest{Username: "../admin", Password: "Valid-Pass-123", Roles: []string{"user"}}, http.StatusBadRequest, "invalid username", nil},
+		{"unknown role", CreateUserRequest{Username: "bob", Password: "Valid-Pass-123", Roles: []string{"superuser"}}, http.StatusBadRequest, "invalid role", nil},
+		{"role in other casing", CreateUserRequest{Username: "bob", Password: "Valid-Pass-123", Roles: []string{"Administrator"}}, http.StatusBadRequest, "invalid role", nil},
+		{"duplicate", CreateUserRequest{Username: "alice", Password: "Alice-Pass-123", Roles: []string{"user"}}, http.StatusBadRequest, "already exists", nil},
+		{"legacy role field", map[string]any{"username": "carol", "password": "Carol-Pass-123", "role": []string{"user"}}, http.StatusCreated, "", []string{"user"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", tt.body)))
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if tt.wantError != "" && !strings.Contains(resp.Body.String(), tt.wantError) {
+				t.Errorf("error %s does not mention %q", resp.Body, tt.wantError)
+			}
+			if tt.wantRoles == nil {
+				return
+			}
+			var username string
+			switch body := tt.body.(type) {
+			case CreateUserRequest:
+				username = body.Username
+			case map[string]any:
+				username = body["username"].(string)
+			}
+			user, err := s.userService.GetUserByUsername(username)
+			if err != nil {
+				t.Fatalf("created user is missing: %v", err)
+			}
+			if !slices.Equal(user.Roles, tt.wantRoles) || user.CreatedBy != "admin" || !user.Enabled {
+				t.Errorf("stored user = %+v, want enabled with roles %v, created by admin", user, tt.wantRoles)
+			}
+		})
+	}
+}
+
+// userAccess fetches the access report of a user.
+func (s *testServer) userAccess(username string) UserAccessReport {
+	s.t.Helper()
+	resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users/"+username+"/access", nil)))
+	if resp.Code != http.StatusOK {
+		s.t.Fatalf("access report of %s = %d: %s", username, resp.Code, resp.Body)
+	}
+	var report UserAccessReport
+	decodeResponse(s.t, resp, &report)
+	return report
+}
+
+func TestUserAccessReport(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "publisher")
+	s.createUser("bob", "Bob-Pass-12345", "user", "publisher")
+	for _, software := range []CreateSoftwareRequest{{Name: "AliceApp", Owner: "alice"}, {Name: "AliceTool", Owner: "alice"}, {Name: "Shared"}} {
+		if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/packages", software))); resp.Code != http.StatusCreated {
+			t.Fatalf("creating package %s = %d: %s", software.Name, resp.Code, resp.Body)
+		}
+	}
+	s.createNamedToken("alice", "Alice-Pass-123", "ci", "")
+
+	tests := []struct {
+		username  string
+		wantRoles []string
+		wantAdmin bool
+		wantOwned []string
+	}{
+		{"admin", []string{"administrator"}, true, []string{}},
+		{"alice", []string{"publisher"}, false, []string{"AliceApp", "AliceTool"}},
+		{"bob", []string{"user", "publisher"}, false, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			report := s.userAccess(tt.username)
+			if report.Username != tt.username || !report.Enabled {
+				t.Errorf("report is of %s, enabled %v; want enabled %s", report.Username, report.Enabled, tt.username)
+			}
+			if !slices.Equal(report.Roles, tt.wantRoles) || report.IsAdministrator != tt.wantAdmin {
+				t.Errorf("roles = %v, administrator %v; want %v, %v", report.Roles, report.IsAdministrator, tt.wantRoles, tt.wantAdmin)
+			}
+			if owned := slices.Sorted(slices.Values(report.OwnedPackages)); !slices.Equal(owned, tt.wantOwned) {
+				t.Errorf("owned packages = %v, want %v", owned, tt.wantOwned)
+			}
+			if report.Security == nil {
+				t.Error("report has no security status")
+			}
+		})
+	}
+
+	if tokens := s.userAccess("alice").ActiveTokens; len(tokens) != 1 || tokens[0].Name != "ci" {
+		t.Errorf("alice's active tokens = %+v, want the ci token", tokens)
+	}
+	transfer := s.request(http.MethodPost, "/api/v1/admin/packages/AliceTool/transfer", TransferSoftwareRequest{NewOwner: "bob"})
+	if resp := s.do(asAdmin(transfer)); resp.Code != http.StatusOK {
+		t.Fatalf("transfer = %d: %s", resp.Code, resp.Body)
+	}
+	if owned := s.userAccess("alice").OwnedPackages; !slices.Equal(owned, []string{"AliceApp"}) {
+		t.Errorf("alice's packages after the transfer = %v, want [AliceApp]", owned)
+	}
+	if owned := s.userAccess("bob").OwnedPackages; !slices.Equal(owned, []string{"AliceTool"}) {
+		t.Errorf("bob's packages after the transfer = %v, want [AliceTool]", owned)
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users/nobody/access", nil))); resp.Code != http.StatusNotFound {
+		t.Errorf("access report of a missing user = %d, want 404", resp.Code)
+	}
+}
+
+func TestListUsersShowsCreator(t *testing.T) {
+	s := newTestServer(t, nil)
+	if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/users", CreateUserRequest{Username: "alice", Password: "Alice-Pass-123", Roles: []string{"user"}}))); resp.Code != http.StatusCreated {
+		t.Fatalf("creating alice = %d: %s", resp.Code, resp.Body)
+	}
+	var users []User
+	decodeResponse(t, s.do(asAdmin(s.request(http.MethodGet, "/api/v1/admin/users", nil))), &users)
+	createdBy := map[string]string{}
+	for _, user := range users {
+		createdBy[user.Username] = user.CreatedBy
+	}
+	if want := map[string]string{"admin": "", "alice": "admin"}; !maps.Equal(createdBy, want) {
+		t.Errorf("users and their creators = %v, want %v", createdBy, want)
+	}
+}
+
+func TestBulkCreateUsers(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("existing", "Existing-Pass-1", "user")
+	body := []CreateUserRequest{
+		{Username: "alice", Password: "Alice-Pass-123", Roles: []string{"publisher"}},
+		{Username: "existing", Password: "Other-Pass-123", Roles: []string{"user"}},
+		{Username: "bob", Password: "Bob-Pass-12345", Roles: []string{"user"}},
+		{Username: "alice", Password: "Alice-Pass-456", Roles: []string{"user"}},
+		{Username: "carol", Password: "short", Roles: []string{"user"}},
+		{Username: "dave", Password: "Dave-Pass-1234", Roles: []string{"superuser"}},
+	}
+	resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/users/bulk", body)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("bulk import = %d: %s", resp.Code, resp.Body)
+	}
+	var imported BulkCreateUsersResponse
+	decodeResponse(t, resp, &imported)
+	if imported.Created != 2 || imported.Failed != 4 || len(imported.Results) != len(body) {
+		t.Fatalf("import created %d and failed %d with %d results, want 2, 4 and %d: %s", imported.Created, imported.Failed, len(imported.Results), len(body), resp.Body)
+	}
+
+	tests := []struct {
+		username    string
+		wantCreated bool
+		wantError   string // Part of the error message
+	}{
+		{"alice", true, ""},
+		{"existing", false, "already exists"},
+		{"bob", true, ""},
+		{"alice", false, "more than once"},
+		{"carol", false, "password"},
+		{"dave", false, "invalid role"},
+	}
+	for i, tt := range tests {
+		result := imported.Results[i]
+		if result.Username != tt.username || result.Created != tt.wantCreated {
+			t.Errorf("result %d = %+v, want %s created %v", i, result, tt.username, tt.wantCreated)
+		}
+		if !strings.Contains(result.Error, tt.wantError) || (tt.wantCreated && result.Error != "") {
+			t.Errorf("result %d error = %q, want it to mention %q", i, result.Error, tt.wantError)
+		}
+	}
+
+	if user, err := s.userService.GetUserByUsername("alice"); err != nil || !slices.Equal(user.Roles, []string{"publisher"}) || user.CreatedBy != "admin" {
+		t.Errorf("alice = %+v, %v; want the first entry's publisher role, created by admin", user, err)
+	}
+	if req := withBasicAuth(s.request(http.MethodPost, "/api/v1/auth/token", nil), "existing", "Existing-Pass-1"); s.do(req).Code != http.StatusCreated {
+		t.Error("the existing user's password was changed by the import")
+	}
+	for _, username := range []string{"carol", "dave"} {
+		if _, err := s.userService.GetUserByUsername(username); err == nil {
+			t.Errorf("invalid entry %s was created", username)
+		}
+	}
+	for name, invalid := range map[string]any{
+		"no entries":       []CreateUserRequest{},
+		"too many entries": make([]CreateUserRequest, maxBulkUsers+1),
+		"not an array":     CreateUserRequest{Username: "erin", Password: "Erin-Pass-1234"},
+	} {
+		if resp := s.do(asAdmin(s.request(http.MethodPost, "/api/v1/admin/users/bulk", invalid))); resp.Code != http.StatusBadRequest {
+			t.Errorf("bulk import with %s = %d, want 400", name, resp.Code)
+		}
+	}
+}
+
+func TestEnableDisableUser(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "Alice-Pass-123", "user")
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantEnabled bool
+	}{
+		{"disable", false, false},
+		{"disable again", false, false},
+		{"enable", true, true},
+		{"enable again", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := asAdmin(s.request(http.MethodPatch, "/api/v1/admin/users/alice/status", EnableDisableRequest{Enabled: tt.enabled}))
+			if resp := s.do(req); resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+			user, err := s.userService.GetUserByUsername("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Enabled != tt.wantEnabled {
+				t.Errorf("stored Enabled = %v, want %v", user.Enabled, tt.wantEnabled)
+			}
+			login := s.do(withBasicAuth(s.request(http.MethodPost, "/api/v1/auth/token", nil), "alice", "Alice-Pass-123"))
+			if (login.Code == http.StatusCreated) != tt.wantEnabled {
+				t.Errorf("login = %d, want it to succeed only while enabled", login.Code)
+			}
+		})
+	}
+	if resp := s.do(asAdmin(s.request(http.MethodPatch, "/api/v1/admin/users/nobody/status", EnableDisableRequest{Enabled: true}))); resp.Code != http.StatusBadRequest {
+		t.Errorf("enabling a missing user = %d, want 400", resp.Code)
+	}
+}
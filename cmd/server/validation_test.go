@@ -0,0 +1,97 @@
+// internal/service/validation_test.go - Tests of path traversal rejection and file name sanitizing.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateSoftwareName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"myapp", true},
+		{"My App", true},
+		{"my-app.cli", true},
+		{"v1.2", true},
+		{"", false},
+		{"   ", false},
+		{"..", false},
+		{"../etc", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"a..b", false},
+		{"a\x00b", false},
+		{"a\nb", false},
+		{"a\x7fb", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSoftwareName(tt.name)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateSoftwareName(%q) failed: %v", tt.name, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSoftwareName) {
+				t.Errorf("ValidateSoftwareName(%q) = %v, want %v", tt.name, err, ErrInvalidSoftwareName)
+			}
+		})
+	}
+}
+
+func TestPathParamsMiddlewareRejectsTraversal(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"dots in name", "/api/v1/packages/a..b/releases"},
+		{"backslash in name", "/api/v1/packages/a%5C..%5Cb/releases"},
+		{"control character in name", "/api/v1/packages/a%01b/releases"},
+		{"dots in version", "/api/v1/releases/myapp/1..2"},
+		{"backslash in version", "/api/v1/releases/myapp/%5C1.0.0"},
+		{"control character in version", "/api/v1/releases/myapp/1.0.0%0A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.do(s.request(http.MethodGet, tt.path, nil))
+			if resp.Code != http.StatusBadRequest {
+				t.Errorf("GET %s = %d, want %d: %s", tt.path, resp.Code, http.StatusBadRequest, resp.Body)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"MyApp", "myapp"},
+		{"my app", "my_app"},
+		{"my   app", "my_app"},
+		{"a/b\\c:d", "a_b_c_d"},
+		{"../../etc/passwd", "_._etc_passwd"},
+		{"..", "_"},
+		{".hidden.", "hidden"},
+		{"a...b", "a.b"},
+		{"Ünïcode", "_n_code"},
+		{"", "_"},
+		{"CON", "con_"},
+		{"nul.txt", "nul_.txt"},
+		{"console", "console"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.name); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if got := sanitizeFilename(strings.Repeat("a", 500)); len(got) > maxSanitizedFilenameLength {
+		t.Errorf("sanitizeFilename of 500 characters has length %d, want at most %d", len(got), maxSanitizedFilenameLength)
+	}
+}
@@ -0,0 +1,259 @@
+// internal/service/version_test.go - Tests of version parsing, ordering and file naming.
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		valid      bool
+		components []int
+		preRelease []string
+		build      string
+	}{
+		{version: "1.2.3", valid: true, components: []int{1, 2, 3}},
+		{version: "0.0.0", valid: true, components: []int{0, 0, 0}},
+		{version: "10.20.30.40", valid: true, components: []int{10, 20, 30, 40}},
+		{version: "1.0.0-rc.1", valid: true, components: []int{1, 0, 0}, preRelease: []string{"rc", "1"}},
+		{version: "1.0.0-0.3.7", valid: true, components: []int{1, 0, 0}, preRelease: []string{"0", "3", "7"}},
+		{version: "1.0.0-x-y-z.--", valid: true, components: []int{1, 0, 0}, preRelease: []string{"x-y-z", "--"}},
+		{version: "1.0.0+20130313144700", valid: true, components: []int{1, 0, 0}, build: "20130313144700"},
+		{version: "1.0.0-beta+exp.sha.5114f85", valid: true, components: []int{1, 0, 0}, preRelease: []string{"beta"}, build: "exp.sha.5114f85"},
+		{version: "1.0.0+001", valid: true, components: []int{1, 0, 0}, build: "001"}, // Build metadata may have leading zeros
+		{version: "01.2.3"},
+		{version: "1.02.3"},
+		{version: "1.2.03"},
+		{version: "1.0.0-01"}, // Numeric pre-release identifiers must not have leading zeros
+		{version: ""},
+		{version: "v1.2.3"},
+		{version: "1..3"},
+		{version: "1.2.3-"},
+		{version: "1.2.3+"},
+		{version: "1.2.3-rc..1"},
+		{version: "1.2.3-rc_1"},
+		{version: "1.2.3.4.5.6.7"},
+		{version: "nightly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			parsed, err := parseVersion(tt.version)
+			if !tt.valid {
+				if err == nil {
+					t.Fatalf("parseVersion(%q) = %+v, want an error", tt.version, parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVersion(%q) failed: %v", tt.version, err)
+			}
+			if !slices.Equal(parsed.Components, tt.components) {
+				t.Errorf("components = %v, want %v", parsed.Components, tt.components)
+			}
+			if !slices.Equal(parsed.PreRelease, tt.preRelease) {
+				t.Errorf("pre-release = %v, want %v", parsed.PreRelease, tt.preRelease)
+			}
+			if parsed.Build != tt.build {
+				t.Errorf("build = %q, want %q", parsed.Build, tt.build)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		lower, higher string
+	}{
+		// The precedence example of SemVer §11
+		{"1.0.0-alpha", "1.0.0-alpha.1"},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta"},
+		{"1.0.0-alpha.beta", "1.0.0-beta"},
+		{"1.0.0-beta", "1.0.0-beta.2"},
+		{"1.0.0-beta.2", "1.0.0-beta.11"},
+		{"1.0.0-beta.11", "1.0.0-rc.1"},
+		{"1.0.0-rc.1", "1.0.0"},
+		{"1.0.0", "2.0.0"},
+		{"2.0.0", "2.1.0"},
+		{"2.1.0", "2.1.1"},
+		{"1.9.0", "1.10.0"},
+		{"1.2.3.9", "1.2.3.10"},
+		{"1.2.3", "1.2.3.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lower+"<"+tt.higher, func(t *testing.T) {
+			lower, err := parseVersion(tt.lower)
+			if err != nil {
+				t.Fatal(err)
+			}
+			higher, err := parseVersion(tt.higher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lower.Compare(higher); got != -1 {
+				t.Errorf("Compare(%s, %s) = %d, want -1", tt.lower, tt.higher, got)
+			}
+			if got := higher.Compare(lower); got != 1 {
+				t.Errorf("Compare(%s, %s) = %d, want 1", tt.higher, tt.lower, got)
+			}
+			if !lower.LessThan(higher) || !higher.GreaterThan(lower) || lower.Equal(higher) {
+				t.Errorf("LessThan, GreaterThan and Equal disagree with Compare for %s and %s", tt.lower, tt.higher)
+			}
+		})
+	}
+}
+
+func TestVersionCompareComponents(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "2.2.3", -1}, // Major
+		{"2.2.3", "1.2.3", 1},
+		{"2.0.0", "1.9.9", 1},
+		{"1.2.3", "1.3.3", -1}, // Minor
+		{"1.3.3", "1.2.3", 1},
+		{"1.3.0", "1.2.9", 1},
+		{"1.2.3", "1.2.4", -1}, // Patch
+		{"1.2.4", "1.2.3", 1},
+		{"1.2.10", "1.2.9", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"?"+tt.b, func(t *testing.T) {
+			a, err := parseVersion(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parseVersion(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if a.LessThan(b) != (tt.want < 0) || a.Equal(b) != (tt.want == 0) || a.GreaterThan(b) != (tt.want > 0) {
+				t.Errorf("LessThan, Equal and GreaterThan of %s and %s disagree with %d", tt.a, tt.b, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"1.2.3", "1.2.3.0"},   // Missing components count as 0
+		{"1.0.0+a", "1.0.0+b"}, // Build metadata is ignored
+		{"1.0.0-rc.1+a", "1.0.0-rc.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"="+tt.b, func(t *testing.T) {
+			a, _ := parseVersion(tt.a)
+			b, _ := parseVersion(tt.b)
+			if got := a.Compare(b); got != 0 {
+				t.Errorf("Compare(%s, %s) = %d, want 0", tt.a, tt.b, got)
+			}
+			if !a.Equal(b) {
+				t.Errorf("Equal(%s, %s) = false", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestGetReleaseFilePathNaming(t *testing.T) {
+	repoPath := t.TempDir()
+	softwareIDs, err := NewSoftwareIDRegistry(filepath.Join(t.TempDir(), "software_ids.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &releaseFileStore{softwareIDs: softwareIDs}
+	if _, err := softwareIDs.Assign("MyApp"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"1.2.3", "000001_myapp_01.02.03.tgz"},
+		{"10.20.30", "000001_myapp_10.20.30.tgz"},
+		{"1.2.3.4", "000001_myapp_01.02.03.04.tgz"},
+		{"100.0.0", "000001_myapp_100.00.00.tgz"},
+		{"1.0.0-rc.1", "000001_myapp_01.00.00-rc.1.tgz"},
+		{"1.0.0+build.7", "000001_myapp_01.00.00+build.7.tgz"},
+		{"nightly", "000001_myapp_nightly.tgz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got := filepath.Base(fs.getReleaseFilePath(repoPath, &ReleaseMetadata{SoftwareName: "MyApp", Version: tt.version}))
+			if got != tt.want {
+				t.Errorf("file name of %s = %q, want %q", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionComponentsSetting(t *testing.T) {
+	tests := []struct {
+		name       string
+		components int
+		version    string
+		wantStatus int
+	}{
+		{"three of three", 3, "1.2.3", http.StatusCreated},
+		{"four of three", 3, "1.2.3.4", http.StatusBadRequest},
+		{"four of four", 4, "1.2.3.4", http.StatusCreated},
+		{"three of four", 4, "1.2.3", http.StatusBadRequest},
+		{"three of any", 0, "1.2.3", http.StatusCreated},
+		{"four of any", 0, "1.2.3.4", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(cfg *Config) { cfg.VersionComponents = tt.components })
+			resp := s.upload(s.token("admin", testAdminPassword), "MyApp", tt.version, []byte("release"), nil)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("uploading %s = %d, want %d: %s", tt.version, resp.Code, tt.wantStatus, resp.Body)
+			}
+		})
+	}
+}
+
+func TestThreeAndFourComponentReleases(t *testing.T) {
+	s := newTestServer(t, func(cfg *Config) { cfg.VersionComponents = 0 }) // Accept both schemes
+	token := s.token("admin", testAdminPassword)
+	for _, version := range []string{"1.2.3.10", "1.2.3", "1.10.0", "1.2.3.9", "1.2.4", "1.2.3.1"} {
+		s.mustUpload(token, "MyApp", version, []byte("release "+version))
+	}
+
+	want := []string{"1.2.3", "1.2.3.1", "1.2.3.9", "1.2.3.10", "1.2.4", "1.10.0"}
+	if _, got := s.listReleaseVersions("MyApp", "?sort=version&order=asc"); !slices.Equal(got, want) {
+		t.Errorf("ascending versions = %v, want %v", got, want)
+	}
+	latest, err := s.releaseService.GetLatestReleaseForSoftware("MyApp")
+	if err != nil || latest.Version != "1.10.0" {
+		t.Errorf("latest release = %+v, %v; want 1.10.0", latest, err)
+	}
+	for _, tt := range []struct{ version, wantFile string }{
+		{"1.2.3", "myapp_01.02.03.tgz"},
+		{"1.2.3.10", "myapp_01.02.03.10.tgz"},
+	} {
+		release, err := s.releaseService.GetRelease("MyApp", tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := s.releaseDB.GetReleaseFilePath(s.cfg.RepositoryPath, release)
+		if !strings.HasSuffix(path, tt.wantFile) {
+			t.Errorf("file of %s = %s, want a name ending in %s", tt.version, path, tt.wantFile)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("file of %s is missing: %v", tt.version, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// internal/notify/webhook.go - Release webhook notifications.
+//
+// This file posts a JSON event to the configured webhook URLs whenever a release is published,
+// signing each request with an HMAC of its body and retrying failed deliveries with backoff.
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of webhook requests.
+const (
+	WebhookEventHeader     = "X-RelMan-Event"     // Event type, e.g. "release.published"
+	WebhookDeliveryHeader  = "X-RelMan-Delivery"  // Unique per event and URL, unchanged across retries so receivers can deduplicate
+	WebhookSignatureHeader = "X-RelMan-Signature" // "sha256=" followed by the hex-encoded HMAC-SHA256 of the body
+)
+
+// WebhookEventReleasePublished is sent after a release upload has been stored.
+const WebhookEventReleasePublished = "release.published"
+
+// webhookRetryBaseDelay is the wait before the first retry; it doubles with every further attempt.
+const webhookRetryBaseDelay = time.Second
+
+// ReleaseEvent is the JSON payload of a release webhook.
+type ReleaseEvent struct {
+	Event             string    `json:"event"`
+	SoftwareName      string    `json:"software_name"`
+	Version           string    `json:"version"`
+	Checksum          string    `json:"checksum"`
+	ChecksumAlgorithm string    `json:"checksum_algorithm"`
+	UploadedBy        string    `json:"uploaded_by"`
+	Timestamp         time.Time `json:"timestamp"` // Upload time of the release
+}
+
+// WebhookNotifier delivers release events to the configured webhook URLs in the background.
+// Deliveries still pending when the server exits are dropped.
+type WebhookNotifier struct {
+	urls       []string
+	secret     []byte
+	client     *http.Client
+	maxRetries int
+	logger     *slog.Logger
+}
+
+// NewWebhookNotifier creates a notifier for the webhooks of the configuration. With no webhook URLs
+// configured, notifications are no-ops.
+func NewWebhookNotifier(cfg *Config, logger *slog.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		urls:       cfg.WebhookURLs,
+		secret:     []byte(cfg.WebhookSecret),
+		client:     &http.Client{Timeout: time.Duration(cfg.WebhookTimeout) * time.Second},
+		maxRetries: cfg.WebhookMaxRetries,
+		logger:     logger,
+	}
+}
+
+// NotifyRelease sends a release.published event for a release to every webhook without waiting for delivery.
+func (n *WebhookNotifier) NotifyRelease(release *ReleaseMetadata) {
+	if len(n.urls) == 0 {
+		return
+	}
+	payload, err := json.Marshal(ReleaseEvent{
+		Event:             WebhookEventReleasePublished,
+		SoftwareName:      release.SoftwareName,
+		Version:           release.Version,
+		Checksum:          release.Checksum,
+		ChecksumAlgorithm: release.PrimaryChecksumAlgorithm(),
+		UploadedBy:        release.UploadedBy,
+		Timestamp:         release.ReleaseTimestamp.UTC(),
+	})
+	if err != nil {
+		n.logger.Error("Failed to encode webhook payload", "software_name", release.SoftwareName, "version", release.Version, "error", err)
+		return
+	}
+	for _, webhookURL := range n.urls {
+		go n.deliver(webhookURL, WebhookEventReleasePublished, uuid.New().String(), payload)
+	}
+}
+
+// deliver posts a payload to one webhook, retrying with exponential backoff until it is accepted
+// or the retries are used up.
+func (n *WebhookNotifier) deliver(webhookURL string, event string, deliveryID string, payload []byte) {
+	delay := webhookRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := n.post(webhookURL, event, deliveryID, payload)
+		if err == nil {
+			n.logger.Info("Webhook delivered", "url", redactURL(webhookURL), "event", event, "delivery", deliveryID, "attempt", attempt+1)
+			return
+		}
+		if attempt >= n.maxRetries {
+			n.logger.Error("Webhook delivery failed", "url", redactURL(webhookURL), "event", event, "delivery", deliveryID, "attempts", attempt+1, "error", err)
+			return
+		}
+		n.logger.Warn("Webhook delivery failed, retrying", "url", redactURL(webhookURL), "event", event, "delivery", deliveryID, "attempt", attempt+1, "retry_in", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post makes a single delivery attempt. Any 2xx response counts as delivered.
+func (n *WebhookNotifier) post(webhookURL string, event string, deliveryID string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "repo-man/"+ServerVersion)
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	if len(n.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(n.secret, payload))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Drain so the connection can be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the signature header value of a payload: "sha256=" followed by the
+// hex-encoded HMAC-SHA256 of the payload keyed with the secret.
+func SignWebhookPayload(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactURL removes credentials and the query, which often carries a token, from a URL before it is logged.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	return parsed.String()
+}
+
+// validateWebhookURLs checks that every webhook URL is an absolute http or https URL.
+func validateWebhookURLs(urls []string) error {
+	for _, webhookURL := range urls {
+		parsed, err := url.Parse(webhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("webhook URL %q must be an absolute http or https URL", redactURL(webhookURL))
+		}
+	}
+	return nil
+}
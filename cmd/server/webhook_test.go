@@ -0,0 +1,153 @@
+// internal/notify/webhook_test.go - Tests of release webhook notifications.
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookDelivery is a request received by a test webhook endpoint.
+type webhookDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookReceiver starts a webhook endpoint that answers with the given statuses in turn, then with 204,
+// and passes every request it receives to the returned channel.
+func newWebhookReceiver(t *testing.T, statuses ...int) (string, <-chan webhookDelivery) {
+	t.Helper()
+	deliveries := make(chan webhookDelivery, 10)
+	responses := make(chan int, len(statuses))
+	for _, status := range statuses {
+		responses <- status
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- webhookDelivery{header: r.Header.Clone(), body: body}
+		select {
+		case status := <-responses:
+			w.WriteHeader(status)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver.URL + "/hooks/releases", deliveries
+}
+
+// nextDelivery waits for the next request to a test webhook endpoint.
+func nextDelivery(t *testing.T, deliveries <-chan webhookDelivery) webhookDelivery {
+	t.Helper()
+	select {
+	case delivery := <-deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook was delivered")
+		return webhookDelivery{}
+	}
+}
+
+func TestWebhookReleasePublished(t *testing.T) {
+	webhookURL, deliveries := newWebhookReceiver(t)
+	secret := "webhook-secret"
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.WebhookURLs = []string{webhookURL}
+		cfg.WebhookSecret = secret
+	})
+	token := s.token("admin", testAdminPassword)
+	s.mustUpload(token, "MyApp", "1.0.0", []byte("release content"))
+	release, err := s.releaseService.GetRelease("MyApp", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := nextDelivery(t, deliveries)
+	if got := delivery.header.Get(WebhookEventHeader); got != WebhookEventReleasePublished {
+		t.Errorf("%s = %q, want %q", WebhookEventHeader, got, WebhookEventReleasePublished)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(delivery.body)
+	if got, want := delivery.header.Get(WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s = %q, want %q", WebhookSignatureHeader, got, want)
+	}
+	var event ReleaseEvent
+	if err := json.Unmarshal(delivery.body, &event); err != nil {
+		t.Fatalf("payload %q is not a release event: %v", delivery.body, err)
+	}
+	want := ReleaseEvent{
+		Event:             WebhookEventReleasePublished,
+		SoftwareName:      "MyApp",
+		Version:           "1.0.0",
+		Checksum:          release.Checksum,
+		ChecksumAlgorithm: release.PrimaryChecksumAlgorithm(),
+		UploadedBy:        "admin",
+		Timestamp:         release.ReleaseTimestamp.UTC(),
+	}
+	if !event.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("payload timestamp = %v, want %v", event.Timestamp, want.Timestamp)
+	}
+	event.Timestamp = want.Timestamp
+	if event != want {
+		t.Errorf("payload = %+v, want %+v", event, want)
+	}
+}
+
+func TestWebhookUnsignedWithoutSecret(t *testing.T) {
+	webhookURL, deliveries := newWebhookReceiver(t)
+	s := newTestServer(t, func(cfg *Config) { cfg.WebhookURLs = []string{webhookURL} })
+	s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release content"))
+
+	if got := nextDelivery(t, deliveries).header.Get(WebhookSignatureHeader); got != "" {
+		t.Errorf("%s = %q, want no signature without a secret", WebhookSignatureHeader, got)
+	}
+}
+
+func TestWebhookRetriesFailedDelivery(t *testing.T) {
+	webhookURL, deliveries := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	s := newTestServer(t, func(cfg *Config) {
+		cfg.WebhookURLs = []string{webhookURL}
+		cfg.WebhookMaxRetries = 1
+	})
+	s.mustUpload(s.token("admin", testAdminPassword), "MyApp", "1.0.0", []byte("release content"))
+
+	first := nextDelivery(t, deliveries)
+	retry := nextDelivery(t, deliveries)
+	if string(retry.body) != string(first.body) {
+		t.Errorf("retry payload = %q, want %q", retry.body, first.body)
+	}
+	if id := first.header.Get(WebhookDeliveryHeader); id == "" || retry.header.Get(WebhookDeliveryHeader) != id {
+		t.Errorf("delivery IDs = %q and %q, want the same non-empty ID on a retry", id, retry.header.Get(WebhookDeliveryHeader))
+	}
+	select {
+	case <-deliveries:
+		t.Error("a delivered webhook was sent again")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestValidateWebhookURLs(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://hooks.example.com/releases", false},
+		{"http://localhost:8080/hook?token=secret", false},
+		{"ftp://hooks.example.com/releases", true},
+		{"/relative/path", true},
+		{"https://", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if err := validateWebhookURLs([]string{tt.url}); (err != nil) != tt.wantErr {
+				t.Errorf("validateWebhookURLs(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}